@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
@@ -30,11 +31,15 @@ import (
 
 	"github.com/anyproto/any-sync-filenode/account"
 	"github.com/anyproto/any-sync-filenode/config"
+	"github.com/anyproto/any-sync-filenode/configreload"
 	"github.com/anyproto/any-sync-filenode/deletelog"
 	"github.com/anyproto/any-sync-filenode/filenode"
+	"github.com/anyproto/any-sync-filenode/filenodemetric"
+	"github.com/anyproto/any-sync-filenode/health"
 	"github.com/anyproto/any-sync-filenode/index"
 	"github.com/anyproto/any-sync-filenode/redisprovider"
 	"github.com/anyproto/any-sync-filenode/stat"
+	"github.com/anyproto/any-sync-filenode/tracing"
 
 	// import this to keep govvv in go.mod on mod tidy
 	_ "github.com/ahmetb/govvv/integration-test/app-different-package/mypkg"
@@ -46,6 +51,8 @@ var (
 	flagConfigFile = flag.String("c", "etc/any-sync-filenode.yml", "path to config file")
 	flagVersion    = flag.Bool("v", false, "show version and exit")
 	flagHelp       = flag.Bool("h", false, "show help and exit")
+	flagValidate   = flag.Bool("validate", false, "validate the config file and exit")
+	flagStrict     = flag.Bool("strict-config", false, "refuse to start if the config has validation problems")
 )
 
 func main() {
@@ -72,14 +79,32 @@ func main() {
 	ctx := context.Background()
 	a := new(app.App)
 
+	if *flagValidate {
+		os.Exit(validateConfig(*flagConfigFile))
+	}
+
 	// open config file
 	conf, err := config.NewFromFile(*flagConfigFile)
 	if err != nil {
 		log.Fatal("can't open config file", zap.Error(err))
 	}
+	if err = conf.Validate(); err != nil {
+		if *flagStrict {
+			log.Fatal("config is invalid, run with -validate to see the report", zap.Error(err))
+		}
+		var vErr *config.ValidationError
+		if errors.As(err, &vErr) {
+			for _, problem := range vErr.Problems {
+				log.Warn("config problem", zap.String("problem", problem))
+			}
+		} else {
+			log.Warn("config is invalid", zap.Error(err))
+		}
+	}
 
 	// bootstrap components
-	a.Register(conf)
+	a.Register(conf).
+		Register(configreload.New(*flagConfigFile))
 	Bootstrap(a)
 
 	// start app
@@ -94,6 +119,15 @@ func main() {
 	sig := <-exit
 	log.Info("received exit signal, stop app...", zap.String("signal", fmt.Sprint(sig)))
 
+	// drain the requests before closing the components
+	drainTimeout := time.Second * time.Duration(conf.DrainTimeoutSec)
+	if drainTimeout == 0 {
+		drainTimeout = time.Second * 40
+	}
+	drainCtx, drainCancel := context.WithTimeout(ctx, drainTimeout)
+	_ = app.MustComponent[filenode.Service](a).Drain(drainCtx)
+	drainCancel()
+
 	// close app
 	ctx, cancel := context.WithTimeout(ctx, time.Minute)
 	defer cancel()
@@ -105,10 +139,36 @@ func main() {
 	time.Sleep(time.Second / 3)
 }
 
+// validateConfig prints the validation report and returns the exit code
+func validateConfig(path string) int {
+	conf, err := config.NewFromFile(path)
+	if err != nil {
+		fmt.Printf("can't load config %s: %v\n", path, err)
+		return 1
+	}
+	if err = conf.Validate(); err != nil {
+		var vErr *config.ValidationError
+		if errors.As(err, &vErr) {
+			fmt.Printf("config %s has %d problem(s):\n", path, len(vErr.Problems))
+			for _, problem := range vErr.Problems {
+				fmt.Println("  - " + problem)
+			}
+		} else {
+			fmt.Println(err)
+		}
+		return 1
+	}
+	fmt.Printf("config %s is valid\n", path)
+	return 0
+}
+
 func Bootstrap(a *app.App) {
 	a.Register(account.New()).
 		Register(stat.New()).
+		Register(health.New()).
 		Register(metric.New()).
+		Register(filenodemetric.New()).
+		Register(tracing.New()).
 		Register(nodeconfsource.New()).
 		Register(nodeconfstore.New()).
 		Register(nodeconf.New()).
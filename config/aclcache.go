@@ -0,0 +1,9 @@
+package config
+
+// AclCache configures the cache of the resolved space keys and permissions, the entries are invalidated by the acl head change
+type AclCache struct {
+	// Disabled turns the cache off, every request resolves the acl
+	Disabled bool `yaml:"disabled"`
+	// Size is the max count of the cached (identity, spaceId) pairs
+	Size uint `yaml:"size"`
+}
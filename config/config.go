@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"time"
 
 	commonaccount "github.com/anyproto/any-sync/accountservice"
 	"github.com/anyproto/any-sync/app"
@@ -15,10 +16,14 @@ import (
 
 	"github.com/anyproto/any-sync-filenode/redisprovider"
 	"github.com/anyproto/any-sync-filenode/store/s3store"
+	"github.com/anyproto/any-sync-filenode/tracing"
 )
 
 const CName = "config"
 
+// defaultSpaceDeleteRetention is used when spaceDeleteRetention is missing in the config
+const defaultSpaceDeleteRetention = time.Hour * 72
+
 func NewFromFile(path string) (c *Config, err error) {
 	c = &Config{}
 	data, err := os.ReadFile(path)
@@ -28,6 +33,9 @@ func NewFromFile(path string) (c *Config, err error) {
 	if err = yaml.Unmarshal(data, c); err != nil {
 		return nil, err
 	}
+	if err = c.ApplyEnv(); err != nil {
+		return nil, err
+	}
 	return
 }
 
@@ -45,7 +53,20 @@ type Config struct {
 	NetworkUpdateIntervalSec int                    `yaml:"networkUpdateIntervalSec"`
 	DefaultLimit             uint64                 `yaml:"defaultLimit"`
 	PersistTtl               uint                   `yaml:"persistTtl"`
-	Secure                   secureservice.Config   `yaml:"secure"`
+	// SpaceDeleteRetention is the time in seconds the deleted space is kept frozen and can be restored before the purge.
+	// The missing setting means 72 hours, 0 purges the deleted spaces on the next purge pass
+	SpaceDeleteRetention      *uint                `yaml:"spaceDeleteRetention"`
+	BlocksLockTimeoutSec      uint                 `yaml:"blocksLockTimeoutSec"`
+	BloomErrorRate            float64              `yaml:"bloomErrorRate"`
+	BloomRebuildIntervalHours uint                 `yaml:"bloomRebuildIntervalHours"`
+	MemoryPressure            MemoryPressure       `yaml:"memoryPressure"`
+	GroupPrefetch             GroupPrefetch        `yaml:"groupPrefetch"`
+	AclCache                  AclCache             `yaml:"aclCache"`
+	SelfHeal                  SelfHeal             `yaml:"selfHeal"`
+	DrainDelaySec             uint                 `yaml:"drainDelaySec"`
+	DrainTimeoutSec           uint                 `yaml:"drainTimeoutSec"`
+	Secure                    secureservice.Config `yaml:"secure"`
+	Tracing                   tracing.Config       `yaml:"tracing"`
 }
 
 func (c *Config) Init(a *app.App) (err error) {
@@ -60,6 +81,10 @@ func (c *Config) GetAccount() commonaccount.Config {
 	return c.Account
 }
 
+func (c *Config) GetTracing() tracing.Config {
+	return c.Tracing
+}
+
 func (c *Config) GetS3Store() s3store.Config {
 	return c.S3Store
 }
@@ -72,8 +97,22 @@ func (c *Config) GetDrpc() rpc.Config {
 	return c.Drpc
 }
 
+// GetMetric returns the config of the any-sync metric component without the address:
+// the listener is started by filenodemetric, so the address can be changed at runtime
 func (c *Config) GetMetric() metric.Config {
-	return c.Metric
+	return metric.Config{}
+}
+
+// GetSpaceDeleteRetention returns the retention of the deleted spaces, see SpaceDeleteRetention
+func (c *Config) GetSpaceDeleteRetention() time.Duration {
+	if c.SpaceDeleteRetention == nil {
+		return defaultSpaceDeleteRetention
+	}
+	return time.Second * time.Duration(*c.SpaceDeleteRetention)
+}
+
+func (c *Config) GetMetricAddr() string {
+	return c.Metric.Addr
 }
 
 func (c *Config) GetRedis() redisprovider.Config {
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix of the env variables that override the config values.
+// The variable name is built from the yaml path in the upper snake case, for example:
+//
+//	ANY_SYNC_FILENODE_DEFAULT_LIMIT            -> defaultLimit
+//	ANY_SYNC_FILENODE_REDIS_URL                -> redis.url
+//	ANY_SYNC_FILENODE_S3_STORE_CREDENTIALS_ACCESS_KEY -> s3Store.credentials.accessKey
+//
+// String values are taken as is, other values are parsed as yaml, so lists and sections can be set as well:
+//
+//	ANY_SYNC_FILENODE_YAMUX_LISTEN_ADDRS='["0.0.0.0:4730"]'
+const EnvPrefix = "ANY_SYNC_FILENODE"
+
+// ApplyEnv overrides the config values by the env variables
+func (c *Config) ApplyEnv() error {
+	return applyEnv(reflect.ValueOf(c).Elem(), EnvPrefix, os.LookupEnv)
+}
+
+func applyEnv(v reflect.Value, prefix string, lookup func(string) (string, bool)) error {
+	t := v.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		fv := v.Field(i)
+		envName := prefix
+		if opts != "inline" {
+			if name == "" {
+				name = field.Name
+			}
+			envName = prefix + "_" + envFieldName(name)
+			if val, ok := lookup(envName); ok {
+				if err := setEnvValue(fv, val); err != nil {
+					return fmt.Errorf("env %s: %w", envName, err)
+				}
+			}
+		}
+		// the nested variables are more specific, so they are applied after the section
+		if fv.Kind() == reflect.Struct {
+			if err := applyEnv(fv, envName, lookup); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func setEnvValue(v reflect.Value, val string) error {
+	if v.Kind() == reflect.String {
+		v.SetString(val)
+		return nil
+	}
+	return yaml.Unmarshal([]byte(val), v.Addr().Interface())
+}
+
+// envFieldName converts the camelCase yaml name to the upper snake case: s3Store -> S3_STORE, maxMemory -> MAX_MEMORY
+func envFieldName(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+			(i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestEnvFieldName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"url":          "URL",
+		"s3Store":      "S3_STORE",
+		"maxMemory":    "MAX_MEMORY",
+		"maxMsgSizeMb": "MAX_MSG_SIZE_MB",
+		"peerId":       "PEER_ID",
+		"isCluster":    "IS_CLUSTER",
+	} {
+		assert.Equal(t, expected, envFieldName(name), name)
+	}
+}
+
+func TestConfig_ApplyEnv(t *testing.T) {
+	path := filepath.Join("..", "etc", "any-sync-filenode.yml")
+	t.Run("overrides", func(t *testing.T) {
+		t.Setenv("ANY_SYNC_FILENODE_REDIS_URL", "redis://redis:6379")
+		t.Setenv("ANY_SYNC_FILENODE_S3_STORE_CREDENTIALS_ACCESS_KEY", "access")
+		t.Setenv("ANY_SYNC_FILENODE_S3_STORE_CREDENTIALS_SECRET_KEY", "secret")
+		t.Setenv("ANY_SYNC_FILENODE_DEFAULT_LIMIT", "42")
+		t.Setenv("ANY_SYNC_FILENODE_YAMUX_LISTEN_ADDRS", `["0.0.0.0:1", "0.0.0.0:2"]`)
+		t.Setenv("ANY_SYNC_FILENODE_MEMORY_PRESSURE_HIGH_WATERMARK", "0.9")
+		t.Setenv("ANY_SYNC_FILENODE_REDIS_USERNAME", "user")
+		t.Setenv("ANY_SYNC_FILENODE_REDIS_PASSWORD", "pass")
+		t.Setenv("ANY_SYNC_FILENODE_REDIS_SENTINEL_PASSWORD", "sentinelPass")
+
+		conf, err := NewFromFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "redis://redis:6379", conf.Redis.Url)
+		assert.Equal(t, "access", conf.S3Store.Credentials.AccessKey)
+		assert.Equal(t, "secret", conf.S3Store.Credentials.SecretKey)
+		assert.Equal(t, uint64(42), conf.DefaultLimit)
+		assert.Equal(t, []string{"0.0.0.0:1", "0.0.0.0:2"}, conf.Yamux.ListenAddrs)
+		assert.Equal(t, 0.9, conf.MemoryPressure.HighWatermark)
+		assert.Equal(t, "user", conf.Redis.Username)
+		assert.Equal(t, "pass", conf.Redis.Password)
+		assert.Equal(t, "sentinelPass", conf.Redis.Sentinel.Password)
+		// not overridden values are kept
+		assert.Equal(t, "anytype-test", conf.S3Store.Bucket)
+	})
+	t.Run("section and nested field", func(t *testing.T) {
+		t.Setenv("ANY_SYNC_FILENODE_METRIC", "{addr: ':1'}")
+		t.Setenv("ANY_SYNC_FILENODE_DRPC_STREAM_MAX_MSG_SIZE_MB", "8")
+		t.Setenv("ANY_SYNC_FILENODE_DRPC", "{stream: {maxMsgSizeMb: 16}}")
+
+		conf, err := NewFromFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, ":1", conf.Metric.Addr)
+		// the nested variable wins
+		assert.Equal(t, 8, conf.Drpc.Stream.MaxMsgSizeMb)
+	})
+	t.Run("invalid value", func(t *testing.T) {
+		t.Setenv("ANY_SYNC_FILENODE_DEFAULT_LIMIT", "many")
+		_, err := NewFromFile(path)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "ANY_SYNC_FILENODE_DEFAULT_LIMIT")
+	})
+}
+
+func TestConfig_Validate(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		conf, err := NewFromFile(filepath.Join("..", "etc", "any-sync-filenode.yml"))
+		require.NoError(t, err)
+		assert.NoError(t, conf.Validate())
+	})
+	t.Run("problems", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yml")
+		require.NoError(t, os.WriteFile(path, []byte(`
+s3Store:
+  bucket: Invalid_Bucket
+  credentials:
+    accessKey: access
+redis:
+  url: "://"
+bloomErrorRate: 2
+memoryPressure:
+  highWatermark: 0.5
+  lowWatermark: 0.7
+tracing:
+  exporter: jaeger
+`), 0644))
+		conf, err := NewFromFile(path)
+		require.NoError(t, err)
+
+		err = conf.Validate()
+		var vErr *ValidationError
+		require.ErrorAs(t, err, &vErr)
+		for _, section := range []string{"account:", "network:", "yamux:", "s3Store:", "redis:", "bloomErrorRate:", "memoryPressure:", "tracing:"} {
+			var found bool
+			for _, problem := range vErr.Problems {
+				if strings.HasPrefix(problem, section) {
+					found = true
+					break
+				}
+			}
+			assert.True(t, found, "no problems for %s: %v", section, vErr.Problems)
+		}
+	})
+}
+
+func TestConfig_GetSpaceDeleteRetention(t *testing.T) {
+	for data, expected := range map[string]time.Duration{
+		`{}`:                         time.Hour * 72,
+		`spaceDeleteRetention: 0`:    0,
+		`spaceDeleteRetention: 3600`: time.Hour,
+	} {
+		conf := &Config{}
+		require.NoError(t, yaml.Unmarshal([]byte(data), conf))
+		assert.Equal(t, expected, conf.GetSpaceDeleteRetention(), data)
+	}
+}
@@ -0,0 +1,11 @@
+package config
+
+// GroupPrefetch configures the background restore of the group keys when a persisted group is loaded
+type GroupPrefetch struct {
+	// Enabled restores the space keys of the group
+	Enabled bool `yaml:"enabled"`
+	// Cids additionally restores the cid entries of the spaces
+	Cids bool `yaml:"cids"`
+	// Threads limits the count of the concurrent restores on the node
+	Threads uint `yaml:"threads"`
+}
@@ -0,0 +1,13 @@
+package config
+
+// MemoryPressure configures the adaptive persistence of the index keys when the redis memory usage is high
+type MemoryPressure struct {
+	// HighWatermark is the used/max memory ratio that starts the persistence, 0 disables the mode
+	HighWatermark float64 `yaml:"highWatermark"`
+	// LowWatermark is the used/max memory ratio that stops the persistence
+	LowWatermark float64 `yaml:"lowWatermark"`
+	// MaxMemory in bytes is used when redis has no maxmemory setting
+	MaxMemory uint64 `yaml:"maxMemory"`
+	// CheckIntervalSec is the interval of the memory usage check
+	CheckIntervalSec uint `yaml:"checkIntervalSec"`
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// runtimeFields are the yaml names of the top-level settings that the components apply without a restart
+var runtimeFields = map[string]bool{
+	"defaultLimit":         true,
+	"persistTtl":           true,
+	"blocksLockTimeoutSec": true,
+}
+
+// RuntimeDiff compares the configs and returns the yaml names of the changed settings
+// split by the ones that can be applied at runtime and the ones that need a restart
+func (c *Config) RuntimeDiff(newConf *Config) (runtime, restartRequired []string) {
+	oldVal, newVal := reflect.ValueOf(c).Elem(), reflect.ValueOf(newConf).Elem()
+	typ := oldVal.Type()
+	for i := range typ.NumField() {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("yaml"), ",")
+		oldField, newField := oldVal.Field(i).Interface(), newVal.Field(i).Interface()
+		if name == "metric" {
+			// the listener is restarted by filenodemetric
+			if c.Metric.Addr != newConf.Metric.Addr {
+				runtime = append(runtime, "metric.addr")
+			}
+			continue
+		}
+		if name == "s3Store" {
+			// only the threads count can be changed at runtime
+			oldS3, newS3 := c.S3Store, newConf.S3Store
+			if oldS3.MaxThreads != newS3.MaxThreads {
+				runtime = append(runtime, "s3Store.maxThreads")
+			}
+			oldS3.MaxThreads, newS3.MaxThreads = 0, 0
+			oldField, newField = oldS3, newS3
+		}
+		if reflect.DeepEqual(oldField, newField) {
+			continue
+		}
+		if runtimeFields[name] {
+			runtime = append(runtime, name)
+		} else {
+			restartRequired = append(restartRequired, name)
+		}
+	}
+	return
+}
+
+// ApplyRuntime copies the runtime settings from the new config
+func (c *Config) ApplyRuntime(newConf *Config) {
+	c.DefaultLimit = newConf.DefaultLimit
+	c.PersistTtl = newConf.PersistTtl
+	c.BlocksLockTimeoutSec = newConf.BlocksLockTimeoutSec
+	c.S3Store.MaxThreads = newConf.S3Store.MaxThreads
+	c.Metric.Addr = newConf.Metric.Addr
+}
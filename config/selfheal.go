@@ -0,0 +1,11 @@
+package config
+
+// SelfHeal configures the background check and fix of the groups with the detected refcount anomalies
+type SelfHeal struct {
+	// Disabled stops the processing of the anomaly queue, the anomalies are still queued
+	Disabled bool `yaml:"disabled"`
+	// IntervalSec is the interval of the queue processing
+	IntervalSec uint `yaml:"intervalSec"`
+	// BatchSize limits the count of the groups checked by the instance per interval
+	BatchSize uint `yaml:"batchSize"`
+}
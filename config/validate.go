@@ -0,0 +1,201 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/anyproto/any-sync/nodeconf"
+	"github.com/redis/go-redis/v9"
+)
+
+var bucketNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+var knownNodeTypes = []nodeconf.NodeType{
+	nodeconf.NodeTypeTree,
+	nodeconf.NodeTypeConsensus,
+	nodeconf.NodeTypeFile,
+	nodeconf.NodeTypeCoordinator,
+	nodeconf.NodeTypeNamingNode,
+	nodeconf.NodeTypePaymentProcessingNode,
+}
+
+// ValidationError contains all the problems found in the config, one per line
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config:\n\t" + strings.Join(e.Problems, "\n\t")
+}
+
+type namedValue struct {
+	name, value string
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) addf(section, format string, args ...any) {
+	v.problems = append(v.problems, section+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *validator) required(section, name, value string) {
+	if value == "" {
+		v.addf(section, "%s is required", name)
+	}
+}
+
+func (v *validator) hostPort(section, name, addr string) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		v.addf(section, "%s %q is not a host:port address", name, addr)
+	}
+}
+
+// Validate checks every config section and returns *ValidationError with all the found problems
+func (c *Config) Validate() error {
+	v := &validator{}
+	c.validateAccount(v)
+	c.validateNetwork(v)
+	c.validateTransport(v)
+	c.validateS3Store(v)
+	c.validateRedis(v)
+	c.validateIndex(v)
+	c.validateTracing(v)
+	if len(v.problems) != 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+func (c *Config) validateAccount(v *validator) {
+	v.required("account", "peerId", c.Account.PeerId)
+	for _, key := range []namedValue{{"peerKey", c.Account.PeerKey}, {"signingKey", c.Account.SigningKey}} {
+		if key.value == "" {
+			v.required("account", key.name, key.value)
+		} else if _, err := base64.StdEncoding.DecodeString(key.value); err != nil {
+			v.addf("account", "%s is not valid base64: %v", key.name, err)
+		}
+	}
+}
+
+func (c *Config) validateNetwork(v *validator) {
+	v.required("network", "id", c.Network.Id)
+	v.required("network", "networkId", c.Network.NetworkId)
+	v.required("networkStorePath", "path", c.NetworkStorePath)
+	if len(c.Network.Nodes) == 0 {
+		v.addf("network", "nodes list is empty")
+		return
+	}
+	var hasCoordinator bool
+	for i, node := range c.Network.Nodes {
+		section := fmt.Sprintf("network.nodes[%d]", i)
+		v.required(section, "peerId", node.PeerId)
+		if len(node.Addresses) == 0 {
+			v.addf(section, "addresses list is empty")
+		}
+		for _, t := range node.Types {
+			if !slices.Contains(knownNodeTypes, t) {
+				v.addf(section, "unknown node type %q", t)
+			}
+		}
+		if node.HasType(nodeconf.NodeTypeCoordinator) {
+			hasCoordinator = true
+		}
+	}
+	if !hasCoordinator {
+		v.addf("network", "no node with the coordinator type")
+	}
+}
+
+func (c *Config) validateTransport(v *validator) {
+	if len(c.Yamux.ListenAddrs) == 0 && len(c.Quic.ListenAddrs) == 0 {
+		v.addf("yamux", "listenAddrs are empty for both yamux and quic")
+	}
+	for _, addr := range c.Yamux.ListenAddrs {
+		v.hostPort("yamux", "listen address", addr)
+	}
+	for _, addr := range c.Quic.ListenAddrs {
+		v.hostPort("quic", "listen address", addr)
+	}
+	if c.Metric.Addr != "" {
+		v.hostPort("metric", "addr", c.Metric.Addr)
+	}
+}
+
+func (c *Config) validateS3Store(v *validator) {
+	s3 := c.S3Store
+	for _, bucket := range []namedValue{{"bucket", s3.Bucket}, {"indexBucket", s3.IndexBucket}} {
+		if bucket.value == "" {
+			v.required("s3Store", bucket.name, bucket.value)
+		} else if !bucketNameRe.MatchString(bucket.value) || strings.Contains(bucket.value, "..") {
+			v.addf("s3Store", "%s %q is not a valid bucket name", bucket.name, bucket.value)
+		}
+	}
+	if s3.Endpoint != "" {
+		if u, err := url.Parse(s3.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			v.addf("s3Store", "endpoint %q is not a valid url", s3.Endpoint)
+		}
+	}
+	if s3.MaxThreads < 0 {
+		v.addf("s3Store", "maxThreads must not be negative")
+	}
+	if (s3.Credentials.AccessKey == "") != (s3.Credentials.SecretKey == "") {
+		v.addf("s3Store", "credentials need both accessKey and secretKey")
+	}
+}
+
+func (c *Config) validateRedis(v *validator) {
+	r := c.Redis
+	if r.Sentinel.MasterName != "" {
+		if len(r.Sentinel.Addrs) == 0 {
+			v.addf("redis", "sentinel.addrs are required for the master %q", r.Sentinel.MasterName)
+		}
+		for _, addr := range r.Sentinel.Addrs {
+			v.hostPort("redis", "sentinel address", addr)
+		}
+	}
+	if r.Url != "" {
+		var err error
+		if r.IsCluster && r.Sentinel.MasterName == "" {
+			_, err = redis.ParseClusterURL(r.Url)
+		} else {
+			_, err = redis.ParseURL(r.Url)
+		}
+		if err != nil {
+			v.addf("redis", "can't parse url: %v", err)
+		}
+	}
+	if (r.TLS.CertFile == "") != (r.TLS.KeyFile == "") {
+		v.addf("redis", "tls needs both certFile and keyFile")
+	}
+}
+
+func (c *Config) validateIndex(v *validator) {
+	if c.BloomErrorRate < 0 || c.BloomErrorRate >= 1 {
+		v.addf("bloomErrorRate", "must be in the [0, 1) range")
+	}
+	mp := c.MemoryPressure
+	if mp.HighWatermark < 0 || mp.HighWatermark > 1 || mp.LowWatermark < 0 || mp.LowWatermark > 1 {
+		v.addf("memoryPressure", "watermarks must be in the [0, 1] range")
+	} else if mp.HighWatermark > 0 && mp.LowWatermark >= mp.HighWatermark {
+		v.addf("memoryPressure", "lowWatermark must be less than highWatermark")
+	}
+}
+
+func (c *Config) validateTracing(v *validator) {
+	switch c.Tracing.Exporter {
+	case "":
+	case "otlp":
+		v.required("tracing", "endpoint", c.Tracing.Endpoint)
+	case "file":
+		v.required("tracing", "filePath", c.Tracing.FilePath)
+	default:
+		v.addf("tracing", "unexpected exporter %q", c.Tracing.Exporter)
+	}
+}
@@ -0,0 +1,153 @@
+package configreload
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/anyproto/any-sync/app"
+	"github.com/anyproto/any-sync/app/logger"
+	"go.uber.org/zap"
+
+	"github.com/anyproto/any-sync-filenode/config"
+)
+
+const CName = "filenode.configreload"
+
+var log = logger.NewNamed(CName)
+
+func New(path string) ConfigReload {
+	return &configReload{path: path}
+}
+
+// Reloadable is implemented by the components that can apply the settings at runtime
+type Reloadable interface {
+	// ReloadConfig receives the config with the new runtime values, the components take their settings the same way as in Init.
+	// After an error of another component it's called again with the current config
+	ReloadConfig(conf any) (err error)
+}
+
+type Result struct {
+	Applied         []string `json:"applied"`
+	RestartRequired []string `json:"restartRequired"`
+}
+
+// ConfigReload re-reads the config file on SIGHUP or by the POST /stat/config/reload request
+type ConfigReload interface {
+	Reload(ctx context.Context) (res Result, err error)
+	app.ComponentRunnable
+}
+
+type configReload struct {
+	path   string
+	a      *app.App
+	conf   *config.Config
+	mu     sync.Mutex
+	sigCh  chan os.Signal
+	doneCh chan struct{}
+}
+
+func (r *configReload) Init(a *app.App) (err error) {
+	r.a = a
+	r.conf = app.MustComponent[*config.Config](a)
+	return
+}
+
+func (r *configReload) Name() (name string) {
+	return CName
+}
+
+func (r *configReload) Run(ctx context.Context) (err error) {
+	r.sigCh = make(chan os.Signal, 1)
+	r.doneCh = make(chan struct{})
+	signal.Notify(r.sigCh, syscall.SIGHUP)
+	go r.signalLoop()
+
+	http.HandleFunc("/stat/config/reload", func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodPost {
+			http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		res, err := r.Reload(request.Context())
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(writer).Encode(res)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+	return
+}
+
+func (r *configReload) signalLoop() {
+	defer close(r.doneCh)
+	for range r.sigCh {
+		if _, err := r.Reload(context.Background()); err != nil {
+			log.Warn("config reload failed", zap.Error(err))
+		}
+	}
+}
+
+func (r *configReload) Reload(ctx context.Context) (res Result, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	newConf, err := config.NewFromFile(r.path)
+	if err != nil {
+		return
+	}
+	if err = newConf.Validate(); err != nil {
+		return
+	}
+	res.Applied, res.RestartRequired = r.conf.RuntimeDiff(newConf)
+	if len(res.RestartRequired) != 0 {
+		log.Warn("config changes require a restart and are not applied", zap.Strings("settings", res.RestartRequired))
+	}
+	if len(res.Applied) == 0 {
+		log.Info("config reloaded, no runtime changes")
+		return
+	}
+	// the components get the current config with the new runtime values, the shared config is changed only when all of them succeed
+	next := *r.conf
+	next.ApplyRuntime(newConf)
+	var reloaded []Reloadable
+	r.a.IterateComponents(func(c app.Component) {
+		if err != nil {
+			return
+		}
+		if rc, ok := c.(Reloadable); ok {
+			if err = rc.ReloadConfig(&next); err == nil {
+				reloaded = append(reloaded, rc)
+			}
+		}
+	})
+	if err != nil {
+		// return the applied components to the current values
+		for _, rc := range reloaded {
+			if rErr := rc.ReloadConfig(r.conf); rErr != nil {
+				log.Warn("config reload rollback failed", zap.Error(rErr))
+			}
+		}
+		return
+	}
+	r.conf.ApplyRuntime(newConf)
+	log.Info("config reloaded", zap.Strings("applied", res.Applied))
+	return
+}
+
+func (r *configReload) Close(ctx context.Context) (err error) {
+	if r.sigCh != nil {
+		signal.Stop(r.sigCh)
+		close(r.sigCh)
+		<-r.doneCh
+	}
+	return
+}
@@ -0,0 +1,187 @@
+package configreload
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anyproto/any-sync/app"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyproto/any-sync-filenode/config"
+)
+
+var ctx = context.Background()
+
+// requiredConf is the part of the config required by the validation, it's prepended to every test config
+const requiredConf = `
+account:
+  peerId: 12D3KooWQxiZ5a7vcy4DTJa8Gy1eVUmwb5ojN4SrJC9Rjxzigw6C
+  peerKey: X7YT92hRIQf42tNiIlOS01p1uJiPSqcdkE6LViS8PxnhAv/28YzTctqPjm11AC63Dq7ybmwPQiBu1pwa3AxHzQ==
+  signingKey: X7YT92hRIQf42tNiIlOS01p1uJiPSqcdkE6LViS8PxnhAv/28YzTctqPjm11AC63Dq7ybmwPQiBu1pwa3AxHzQ==
+network:
+  id: 64384a038e697b7fce2f447e
+  networkId: N4N1wDHFpFpovXBqdbq2TDXE9tXdXbtV1eTJFpKJW4YeaJqR
+  nodes:
+    - peerId: 12D3KooWKLCajM89S8unbt3tgGbRLgmiWnFZT3adn9A5pQciBSLa
+      addresses:
+        - 127.0.0.1:4830
+      types:
+        - coordinator
+networkStorePath: .
+yamux:
+  listenAddrs:
+    - 127.0.0.1:4730
+`
+
+const baseConf = `
+defaultLimit: 1024
+persistTtl: 3600
+metric:
+  addr: 127.0.0.1:8000
+s3Store:
+  bucket: blocks
+  indexBucket: index
+  maxThreads: 16
+`
+
+func TestConfigReload_Reload(t *testing.T) {
+	t.Run("runtime settings", func(t *testing.T) {
+		fx := newFixture(t, baseConf)
+		defer fx.Finish(t)
+
+		fx.writeConf(t, `
+defaultLimit: 2048
+persistTtl: 60
+metric:
+  addr: 127.0.0.1:9000
+s3Store:
+  bucket: blocks
+  indexBucket: index
+  maxThreads: 32
+`)
+		res, err := fx.Reload(ctx)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"defaultLimit", "persistTtl", "s3Store.maxThreads", "metric.addr"}, res.Applied)
+		assert.Empty(t, res.RestartRequired)
+
+		require.NotNil(t, fx.reloadable.conf)
+		assert.Equal(t, uint64(2048), fx.reloadable.conf.DefaultLimit)
+		assert.Equal(t, 32, fx.reloadable.conf.S3Store.MaxThreads)
+		assert.Equal(t, "127.0.0.1:9000", fx.conf.Metric.Addr)
+		assert.Equal(t, uint64(2048), fx.conf.DefaultLimit)
+	})
+	t.Run("restart required", func(t *testing.T) {
+		fx := newFixture(t, baseConf)
+		defer fx.Finish(t)
+
+		fx.writeConf(t, baseConf+"drainDelaySec: 10\n")
+		res, err := fx.Reload(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, res.Applied)
+		assert.Equal(t, []string{"drainDelaySec"}, res.RestartRequired)
+		// the restart-only values are kept
+		assert.Zero(t, fx.conf.DrainDelaySec)
+	})
+	t.Run("component error", func(t *testing.T) {
+		fx := newFixture(t, baseConf)
+		defer fx.Finish(t)
+		fx.a.Register(&failingReloadable{})
+
+		fx.writeConf(t, strings.Replace(baseConf, "defaultLimit: 1024", "defaultLimit: 2048", 1))
+		_, err := fx.Reload(ctx)
+		require.Error(t, err)
+		// the shared config and the applied component keep the current values
+		assert.Equal(t, uint64(1024), fx.conf.DefaultLimit)
+		require.NotNil(t, fx.reloadable.conf)
+		assert.Equal(t, uint64(1024), fx.reloadable.conf.DefaultLimit)
+	})
+	t.Run("no changes", func(t *testing.T) {
+		fx := newFixture(t, baseConf)
+		defer fx.Finish(t)
+
+		res, err := fx.Reload(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, res.Applied)
+		assert.Empty(t, res.RestartRequired)
+		assert.Nil(t, fx.reloadable.conf)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		fx := newFixture(t, baseConf)
+		defer fx.Finish(t)
+
+		fx.writeConf(t, "defaultLimit: 2048\nbloomErrorRate: 2\n")
+		_, err := fx.Reload(ctx)
+		require.Error(t, err)
+		assert.Equal(t, uint64(1024), fx.conf.DefaultLimit)
+		assert.Nil(t, fx.reloadable.conf)
+	})
+}
+
+type fixture struct {
+	*configReload
+	a          *app.App
+	path       string
+	reloadable *testReloadable
+}
+
+func newFixture(t *testing.T, data string) *fixture {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(path, []byte(requiredConf+data), 0644))
+	conf, err := config.NewFromFile(path)
+	require.NoError(t, err)
+	fx := &fixture{
+		configReload: New(path).(*configReload),
+		a:            new(app.App),
+		path:         path,
+		reloadable:   &testReloadable{},
+	}
+	fx.a.Register(conf).Register(fx.configReload).Register(fx.reloadable)
+	// the app is not started: Run registers the global http handler
+	require.NoError(t, fx.configReload.Init(fx.a))
+	return fx
+}
+
+func (fx *fixture) writeConf(t *testing.T, data string) {
+	require.NoError(t, os.WriteFile(fx.path, []byte(requiredConf+data), 0644))
+}
+
+func (fx *fixture) Finish(t *testing.T) {
+	require.NoError(t, fx.Close(ctx))
+}
+
+type testReloadable struct {
+	conf *config.Config
+}
+
+func (r *testReloadable) Init(a *app.App) (err error) {
+	return
+}
+
+func (r *testReloadable) Name() (name string) {
+	return "test.reloadable"
+}
+
+func (r *testReloadable) ReloadConfig(conf any) (err error) {
+	c := *conf.(*config.Config)
+	r.conf = &c
+	return
+}
+
+type failingReloadable struct{}
+
+func (r *failingReloadable) Init(a *app.App) (err error) {
+	return
+}
+
+func (r *failingReloadable) Name() (name string) {
+	return "test.failingReloadable"
+}
+
+func (r *failingReloadable) ReloadConfig(conf any) (err error) {
+	return errors.New("test error")
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 
+	"github.com/anyproto/any-sync-filenode/config"
 	"github.com/anyproto/any-sync-filenode/filenode"
 	"github.com/anyproto/any-sync-filenode/index"
 	"github.com/anyproto/any-sync-filenode/redisprovider"
@@ -26,6 +27,8 @@ const lastKey = "deletionLastId.{system}"
 
 const recordsLimit = 1000
 
+const purgeLimit = 100
+
 var log = logger.NewNamed(CName)
 
 func New() app.ComponentRunnable {
@@ -37,8 +40,10 @@ type deleteLog struct {
 	coordinatorClient coordinatorclient.CoordinatorClient
 	redsync           *redsync.Redsync
 	ticker            periodicsync.PeriodicSync
+	purgeTicker       periodicsync.PeriodicSync
 	index             index.Index
 	filenode          filenode.Service
+	retention         time.Duration
 	disableTicker     bool
 }
 
@@ -48,6 +53,7 @@ func (d *deleteLog) Init(a *app.App) (err error) {
 	d.redsync = redsync.New(goredis.NewPool(d.redis))
 	d.index = a.MustComponent(index.CName).(index.Index)
 	d.filenode = a.MustComponent(filenode.CName).(filenode.Service)
+	d.retention = app.MustComponent[*config.Config](a).GetSpaceDeleteRetention()
 	return
 }
 
@@ -59,11 +65,17 @@ func (d *deleteLog) Run(ctx context.Context) (err error) {
 	if !d.disableTicker {
 		d.ticker = periodicsync.NewPeriodicSync(30, time.Hour*2, d.checkLog, log)
 		d.ticker.Run()
+		d.purgeTicker = periodicsync.NewPeriodicSync(600, time.Hour, d.purgeSpaces, log)
+		d.purgeTicker.Run()
 	}
 	return
 }
 
 func (d *deleteLog) checkLog(ctx context.Context) (err error) {
+	// the deletions are writes, the log is handled after the maintenance from the last handled id
+	if d.index.InMaintenance() {
+		return
+	}
 	mu := d.redsync.NewMutex("_lock:deletion", redsync.WithExpiry(time.Hour*2))
 	if err = mu.LockContext(ctx); err != nil {
 		return
@@ -87,6 +99,8 @@ func (d *deleteLog) checkLog(ctx context.Context) (err error) {
 		switch rec.Status {
 		case coordinatorproto.DeletionLogRecordStatus_Remove:
 			ok, err = d.handleDeletion(ctx, rec)
+		case coordinatorproto.DeletionLogRecordStatus_Ok:
+			err = d.handleRestore(ctx, rec)
 		case coordinatorproto.DeletionLogRecordStatus_OwnershipChange:
 			err = d.handleOwnershipTransfer(ctx, rec)
 		}
@@ -118,14 +132,79 @@ func (d *deleteLog) handleDeletion(ctx context.Context, rec *coordinatorproto.De
 		GroupId: rec.FileGroup,
 		SpaceId: rec.SpaceId,
 	}
-	ok, err = d.index.SpaceDelete(ctx, key)
-	if err != nil && !errors.Is(err, redis.Nil) && !errors.Is(err, index.ErrSpaceIsDeleted) {
+	// the space will be physically removed by purgeSpaces after the retention period
+	return d.index.SpaceFreeze(ctx, key)
+}
+
+func (d *deleteLog) handleRestore(ctx context.Context, rec *coordinatorproto.DeletionLogRecord) (err error) {
+	if rec.FileGroup == "" {
+		return
+	}
+	key := index.Key{
+		GroupId: rec.FileGroup,
+		SpaceId: rec.SpaceId,
+	}
+	ok, err := d.index.SpaceRestore(ctx, key)
+	if err != nil {
+		return
+	}
+	if ok {
+		log.Info("space restored by the deletion log", zap.String("spaceId", rec.SpaceId), zap.String("recordId", rec.Id))
+	}
+	return
+}
+
+func (d *deleteLog) purgeSpaces(ctx context.Context) (err error) {
+	// the frozen spaces wait for the end of the maintenance
+	if d.index.InMaintenance() {
 		return
 	}
-	if _, err = d.index.MarkSpaceAsDeleted(ctx, key); err != nil {
+	mu := d.redsync.NewMutex("_lock:purge", redsync.WithExpiry(time.Hour))
+	if err = mu.LockContext(ctx); err != nil {
 		return
 	}
-	return ok, nil
+	defer func() {
+		_, _ = mu.Unlock()
+	}()
+	st := time.Now()
+	var purgedCount, handledCount int
+	// the spaces that can't be purged stay in the frozen list, they are skipped until the next run
+	var skipped = make(map[index.Key]struct{})
+	for {
+		limit := purgeLimit + len(skipped)
+		keys, err := d.index.FrozenSpaces(ctx, time.Now().Add(-d.retention), limit)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if _, ok := skipped[key]; ok {
+				continue
+			}
+			ok, err := d.index.SpacePurge(ctx, key)
+			if err != nil {
+				if ctx.Err() != nil {
+					return err
+				}
+				log.Warn("can't purge the space", zap.String("spaceId", key.SpaceId), zap.String("groupId", key.GroupId), zap.Error(err))
+				skipped[key] = struct{}{}
+				continue
+			}
+			handledCount++
+			if ok {
+				purgedCount++
+			}
+		}
+		if len(keys) < limit {
+			break
+		}
+	}
+	log.Info("purge deleted spaces",
+		zap.Int("handled", handledCount),
+		zap.Int("purged", purgedCount),
+		zap.Int("skipped", len(skipped)),
+		zap.Duration("dur", time.Since(st)),
+	)
+	return
 }
 
 func (d *deleteLog) handleOwnershipTransfer(ctx context.Context, rec *coordinatorproto.DeletionLogRecord) (err error) {
@@ -136,5 +215,8 @@ func (d *deleteLog) Close(ctx context.Context) (err error) {
 	if d.ticker != nil {
 		d.ticker.Close()
 	}
+	if d.purgeTicker != nil {
+		d.purgeTicker.Close()
+	}
 	return
 }
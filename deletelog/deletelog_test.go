@@ -2,6 +2,7 @@ package deletelog
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -13,6 +14,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 
+	"github.com/anyproto/any-sync-filenode/config"
 	"github.com/anyproto/any-sync-filenode/filenode"
 	"github.com/anyproto/any-sync-filenode/filenode/mock_filenode"
 	"github.com/anyproto/any-sync-filenode/index"
@@ -29,6 +31,13 @@ func TestDeleteLog_checkLog(t *testing.T) {
 		fx.coord.EXPECT().DeletionLog(ctx, "", recordsLimit).Return(nil, nil)
 		require.NoError(t, fx.checkLog(ctx))
 	})
+	t.Run("maintenance", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.finish(t)
+		fx.maintenance = true
+		// the log isn't requested
+		require.NoError(t, fx.checkLog(ctx))
+	})
 	t.Run("success", func(t *testing.T) {
 		fx := newFixture(t)
 		defer fx.finish(t)
@@ -49,12 +58,8 @@ func TestDeleteLog_checkLog(t *testing.T) {
 				FileGroup: "f2",
 			},
 		}, nil)
-		key := index.Key{
-			GroupId: "f2",
-			SpaceId: "s2",
-		}
-		fx.index.EXPECT().SpaceDelete(ctx, key)
-		fx.index.EXPECT().MarkSpaceAsDeleted(ctx, key)
+		fx.index.EXPECT().SpaceRestore(ctx, index.Key{GroupId: "f1", SpaceId: "s1"})
+		fx.index.EXPECT().SpaceFreeze(ctx, index.Key{GroupId: "f2", SpaceId: "s2"})
 		require.NoError(t, fx.checkLog(ctx))
 		lastId, err := fx.redis.Get(ctx, lastKey).Result()
 		require.NoError(t, err)
@@ -83,6 +88,50 @@ func TestDeleteLog_checkLog(t *testing.T) {
 	})
 }
 
+func TestDeleteLog_purgeSpaces(t *testing.T) {
+	t.Run("no spaces", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.finish(t)
+		fx.index.EXPECT().FrozenSpaces(ctx, gomock.Any(), purgeLimit).Return(nil, nil)
+		require.NoError(t, fx.purgeSpaces(ctx))
+	})
+	t.Run("maintenance", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.finish(t)
+		fx.maintenance = true
+		// the frozen spaces aren't listed
+		require.NoError(t, fx.purgeSpaces(ctx))
+	})
+	t.Run("success", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.finish(t)
+		keys := []index.Key{
+			{GroupId: "f1", SpaceId: "s1"},
+			{GroupId: "f2", SpaceId: "s2"},
+		}
+		fx.index.EXPECT().FrozenSpaces(ctx, gomock.Any(), purgeLimit).DoAndReturn(func(_ context.Context, before time.Time, _ int) ([]index.Key, error) {
+			assert.WithinDuration(t, time.Now().Add(-fx.retention), before, time.Minute)
+			return keys, nil
+		})
+		fx.index.EXPECT().SpacePurge(ctx, keys[0]).Return(true, nil)
+		fx.index.EXPECT().SpacePurge(ctx, keys[1]).Return(false, nil)
+		require.NoError(t, fx.purgeSpaces(ctx))
+	})
+	t.Run("skip failed", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.finish(t)
+		var keys []index.Key
+		for i := range purgeLimit {
+			keys = append(keys, index.Key{GroupId: "f1", SpaceId: fmt.Sprint("s", i)})
+		}
+		// the failed spaces are kept in the list
+		fx.index.EXPECT().FrozenSpaces(ctx, gomock.Any(), purgeLimit).Return(keys, nil)
+		fx.index.EXPECT().SpacePurge(ctx, gomock.Any()).Return(false, fmt.Errorf("test error")).Times(purgeLimit)
+		fx.index.EXPECT().FrozenSpaces(ctx, gomock.Any(), purgeLimit*2).Return(keys, nil)
+		require.NoError(t, fx.purgeSpaces(ctx))
+	})
+}
+
 func newFixture(t *testing.T) *fixture {
 	ctrl := gomock.NewController(t)
 	fx := &fixture{
@@ -100,6 +149,9 @@ func newFixture(t *testing.T) *fixture {
 	fx.index.EXPECT().Init(gomock.Any()).AnyTimes()
 	fx.index.EXPECT().Run(gomock.Any()).AnyTimes()
 	fx.index.EXPECT().Close(gomock.Any()).AnyTimes()
+	fx.index.EXPECT().InMaintenance().DoAndReturn(func() bool {
+		return fx.maintenance
+	}).AnyTimes()
 	fx.filenode.EXPECT().Name().Return(filenode.CName).AnyTimes()
 	fx.filenode.EXPECT().Init(gomock.Any()).AnyTimes()
 
@@ -107,6 +159,7 @@ func newFixture(t *testing.T) *fixture {
 		Register(fx.coord).
 		Register(fx.index).
 		Register(fx.filenode).
+		Register(&config.Config{}).
 		Register(fx.deleteLog)
 	require.NoError(t, fx.a.Start(ctx))
 
@@ -120,6 +173,8 @@ type fixture struct {
 	index    *mock_index.MockIndex
 	filenode *mock_filenode.MockService
 	*deleteLog
+
+	maintenance bool
 }
 
 func (fx *fixture) finish(t *testing.T) {
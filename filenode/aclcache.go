@@ -0,0 +1,97 @@
+package filenode
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/anyproto/any-sync-filenode/index"
+)
+
+const defaultAclCacheSize = 10000
+
+type aclCacheKey struct {
+	identity string
+	spaceId  string
+}
+
+type aclCacheEntry struct {
+	key        aclCacheKey
+	storageKey index.Key
+	canWrite   bool
+	// aclHeadId is the acl head the entry was resolved with, the entry is stale when the head is changed
+	aclHeadId string
+}
+
+// aclCache is the LRU cache of the resolved storage keys and write permissions
+type aclCache struct {
+	mu    sync.Mutex
+	size  int
+	items map[aclCacheKey]*list.Element
+	lru   *list.List
+}
+
+func newAclCache(size int) *aclCache {
+	if size <= 0 {
+		size = defaultAclCacheSize
+	}
+	return &aclCache{
+		size:  size,
+		items: make(map[aclCacheKey]*list.Element),
+		lru:   list.New(),
+	}
+}
+
+// get returns the entry resolved with the given acl head, the result is hit, miss or stale
+func (c *aclCache) get(key aclCacheKey, aclHeadId string) (entry aclCacheEntry, result string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return entry, "miss"
+	}
+	entry = el.Value.(aclCacheEntry)
+	if entry.aclHeadId != aclHeadId {
+		c.remove(el)
+		return aclCacheEntry{}, "stale"
+	}
+	c.lru.MoveToFront(el)
+	return entry, "hit"
+}
+
+func (c *aclCache) set(entry aclCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[entry.key]; ok {
+		el.Value = entry
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.items[entry.key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+// invalidateSpace removes the entries of all the identities of the space and returns the count of the removed entries
+func (c *aclCache) invalidateSpace(spaceId string) (count int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, el := range c.items {
+		if key.spaceId == spaceId {
+			c.remove(el)
+			count++
+		}
+	}
+	return
+}
+
+func (c *aclCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+func (c *aclCache) remove(el *list.Element) {
+	delete(c.items, el.Value.(aclCacheEntry).key)
+	c.lru.Remove(el)
+}
@@ -0,0 +1,148 @@
+package filenode
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/anyproto/any-sync-filenode/filenode/filenodeproto/filenodeprotoerr"
+	"github.com/anyproto/any-sync-filenode/index"
+)
+
+var errDraining = errors.New("node is draining")
+
+// drainCancelTimeout is the time given to the canceled in-flight requests to finish after the drain deadline
+const drainCancelTimeout = time.Second * 5
+
+// drain tracks the in-flight requests and rejects the new writes while the node is shutting down
+type drain struct {
+	notReady atomic.Bool
+
+	mu        sync.Mutex
+	rejecting bool
+	inflight  int
+	idle      chan struct{}
+	// stopCtx is canceled when the in-flight requests are canceled
+	stopCtx context.Context
+	stop    context.CancelFunc
+}
+
+// begin registers the in-flight request, the returned ctx is canceled by cancelAll, end must be called when the request is finished
+func (d *drain) begin(ctx context.Context, write bool) (reqCtx context.Context, end func(), err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if write && d.rejecting {
+		return nil, nil, filenodeprotoerr.ErrUnavailable
+	}
+	if d.stopCtx == nil {
+		d.stopCtx, d.stop = context.WithCancel(context.Background())
+	}
+	d.inflight++
+	reqCtx, cancel := context.WithCancel(ctx)
+	stopAfter := context.AfterFunc(d.stopCtx, cancel)
+	return reqCtx, func() {
+		stopAfter()
+		cancel()
+		d.end()
+	}, nil
+}
+
+func (d *drain) end() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.inflight--
+	if d.inflight == 0 && d.idle != nil {
+		close(d.idle)
+		d.idle = nil
+	}
+}
+
+func (d *drain) rejectWrites() (inflight int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.rejecting = true
+	return d.inflight
+}
+
+// cancelAll cancels the contexts of the in-flight requests
+func (d *drain) cancelAll() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stop != nil {
+		d.stop()
+	}
+}
+
+// wait waits until all the in-flight requests are finished
+func (d *drain) wait(ctx context.Context) error {
+	d.mu.Lock()
+	if d.inflight == 0 {
+		d.mu.Unlock()
+		return nil
+	}
+	if d.idle == nil {
+		d.idle = make(chan struct{})
+	}
+	idle := d.idle
+	d.mu.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *drain) readyCheck(ctx context.Context) error {
+	if d.notReady.Load() {
+		return errDraining
+	}
+	return nil
+}
+
+// Drain prepares the node for the shutdown: it reports not-ready, gives the balancer the delay to stop routing,
+// rejects the new writes, waits for the in-flight requests until the ctx deadline and releases the redis locks held by the requests.
+// The requests that are not finished by the deadline are canceled, if they still hold the locks after drainCancelTimeout,
+// the locks are left to expire, so the running writes aren't left unprotected.
+func (fn *fileNode) Drain(ctx context.Context) (err error) {
+	st := time.Now()
+	fn.drain.notReady.Store(true)
+	log.Info("drain: reporting not ready", zap.Duration("delay", fn.drainDelay))
+	select {
+	case <-time.After(fn.drainDelay):
+	case <-ctx.Done():
+	}
+	inflight := fn.drain.rejectWrites()
+	log.Info("drain: rejecting writes", zap.Int("inflight", inflight))
+	if err = fn.drain.wait(ctx); err != nil {
+		log.Warn("drain: in-flight requests are not finished, canceling", zap.Error(err))
+		fn.drain.cancelAll()
+		cancelCtx, cancel := context.WithTimeout(context.Background(), fn.drainCancelTimeout)
+		defer cancel()
+		if wErr := fn.drain.wait(cancelCtx); wErr != nil {
+			log.Warn("drain: canceled requests are not finished, the held locks are left to expire", zap.Duration("dur", time.Since(st)))
+			return
+		}
+	}
+	released := fn.index.ReleaseLocks(context.Background())
+	log.Info("drain: done", zap.Int("releasedLocks", released), zap.Duration("dur", time.Since(st)))
+	return
+}
+
+// handleRequest runs the rpc handler as the in-flight request, the writes are rejected while the cluster is in maintenance or the node is draining.
+// The handler ctx is canceled when the request isn't finished by the drain deadline, the locks taken with it are released by the drain.
+func handleRequest[Req, Resp any](fn *fileNode, ctx context.Context, write bool, req Req, handle func(ctx context.Context, req Req) (Resp, error)) (resp Resp, err error) {
+	if write && fn.index.InMaintenance() {
+		return resp, filenodeprotoerr.ErrMaintenance
+	}
+	ctx, end, err := fn.drain.begin(ctx, write)
+	if err != nil {
+		return
+	}
+	defer end()
+	return handle(index.CtxWithTrackedLocks(ctx), req)
+}
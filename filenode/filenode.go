@@ -5,6 +5,8 @@ import (
 	"errors"
 	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/anyproto/any-sync/acl"
 	"github.com/anyproto/any-sync/app"
@@ -22,8 +24,14 @@ import (
 	"github.com/ipfs/go-cid"
 	"go.uber.org/zap"
 
+	"github.com/anyproto/any-sync-filenode/config"
+	"github.com/anyproto/any-sync-filenode/filenode/filenodeproto"
+	"github.com/anyproto/any-sync-filenode/filenode/filenodeproto/filenodeprotoerr"
+	"github.com/anyproto/any-sync-filenode/filenodemetric"
+	"github.com/anyproto/any-sync-filenode/health"
 	"github.com/anyproto/any-sync-filenode/index"
 	"github.com/anyproto/any-sync-filenode/store"
+	"github.com/anyproto/any-sync-filenode/tracing"
 )
 
 const CName = "filenode.filenode"
@@ -34,6 +42,9 @@ var (
 	ErrWrongHash = errors.New("wrong hash")
 )
 
+// batchAccountInfoThreads limits the count of the accounts read in parallel by BatchAccountInfo
+const batchAccountInfoThreads = 16
+
 func New() Service {
 	return new(fileNode)
 }
@@ -41,6 +52,8 @@ func New() Service {
 //go:generate mockgen -destination mock_filenode/mock_filenode.go github.com/anyproto/any-sync-filenode/filenode Service
 type Service interface {
 	OwnershipTransfer(ctx context.Context, spaceId, oldIdentity string, aclRecordId string) (err error)
+	// Drain rejects the new writes and waits for the in-flight requests, it must be called before the app close
+	Drain(ctx context.Context) (err error)
 	app.Component
 }
 
@@ -51,6 +64,13 @@ type fileNode struct {
 	metric   metric.Metric
 	nodeConf nodeconf.Service
 	handler  *rpcHandler
+
+	aclCache   *aclCache
+	nodeMetric filenodemetric.Metric
+
+	drain              drain
+	drainDelay         time.Duration
+	drainCancelTimeout time.Duration
 }
 
 func (fn *fileNode) Init(a *app.App) (err error) {
@@ -60,7 +80,26 @@ func (fn *fileNode) Init(a *app.App) (err error) {
 	fn.handler = &rpcHandler{f: fn}
 	fn.metric = a.MustComponent(metric.CName).(metric.Metric)
 	fn.nodeConf = a.MustComponent(nodeconf.CName).(nodeconf.Service)
-	return fileproto.DRPCRegisterFile(a.MustComponent(server.CName).(server.DRPCServer), fn.handler)
+	conf := app.MustComponent[*config.Config](a)
+	fn.drainDelay = time.Second * time.Duration(conf.DrainDelaySec)
+	fn.drainCancelTimeout = drainCancelTimeout
+	fn.nodeMetric = app.MustComponent[filenodemetric.Metric](a)
+	if !conf.AclCache.Disabled {
+		fn.aclCache = newAclCache(int(conf.AclCache.Size))
+		if err = fn.nodeMetric.RegisterGaugeFunc("acl", "cache_size", "count of the cached acl entries", func() float64 {
+			return float64(fn.aclCache.len())
+		}); err != nil {
+			return
+		}
+	}
+	if h, ok := a.Component(health.CName).(health.Health); ok {
+		h.AddCheck("drain", fn.drain.readyCheck)
+	}
+	drpcServer := a.MustComponent(server.CName).(server.DRPCServer)
+	if err = fileproto.DRPCRegisterFile(drpcServer, fn.handler); err != nil {
+		return
+	}
+	return filenodeproto.DRPCRegisterFileNode(drpcServer, fn.handler)
 }
 
 func (fn *fileNode) Name() (name string) {
@@ -84,7 +123,12 @@ func (fn *fileNode) Get(ctx context.Context, k cid.Cid, wait bool) (blocks.Block
 	return fn.store.Get(ctx, k)
 }
 
-func (fn *fileNode) Add(ctx context.Context, spaceId string, fileId string, bs []blocks.Block) error {
+// Add uploads the blocks and binds them to the file, the file metadata is replaced when meta isn't nil
+func (fn *fileNode) Add(ctx context.Context, spaceId string, fileId string, bs []blocks.Block, meta *index.FileMetadata) (err error) {
+	ctx, span := tracing.Start(ctx, "fileNode.add", tracing.SpaceId(spaceId), tracing.FileId(fileId), tracing.CidCount(len(bs)))
+	defer func() {
+		tracing.End(span, err)
+	}()
 	if spaceId == "" && fileId == "" {
 		peerId, _ := peer.CtxPeerId(ctx)
 		if len(fn.nodeConf.NodeTypes(peerId)) > 0 {
@@ -96,7 +140,7 @@ func (fn *fileNode) Add(ctx context.Context, spaceId string, fileId string, bs [
 	if err != nil {
 		return err
 	}
-	unlock, err := fn.index.BlocksLock(ctx, bs)
+	unlock, err := fn.index.BlocksLock(ctx, "add", bs)
 	if err != nil {
 		return err
 	}
@@ -119,7 +163,7 @@ func (fn *fileNode) Add(ctx context.Context, spaceId string, fileId string, bs [
 		return err
 	}
 	defer cidEntries.Release()
-	return fn.index.FileBind(ctx, storeKey, fileId, cidEntries)
+	return fn.fileBind(ctx, storeKey, fileId, cidEntries, meta)
 }
 
 func (fn *fileNode) Check(ctx context.Context, spaceId string, cids ...cid.Cid) (result []*fileproto.BlockAvailability, err error) {
@@ -160,7 +204,8 @@ func (fn *fileNode) Check(ctx context.Context, spaceId string, cids ...cid.Cid)
 	return
 }
 
-func (fn *fileNode) BlocksBind(ctx context.Context, spaceId, fileId string, cids ...cid.Cid) (err error) {
+// BlocksBind binds the existing cids to the file, the file metadata is replaced when meta isn't nil
+func (fn *fileNode) BlocksBind(ctx context.Context, spaceId, fileId string, meta *index.FileMetadata, cids ...cid.Cid) (err error) {
 	storeKey, err := fn.StoreKey(ctx, spaceId, true)
 	if err != nil {
 		return err
@@ -170,10 +215,172 @@ func (fn *fileNode) BlocksBind(ctx context.Context, spaceId, fileId string, cids
 		return err
 	}
 	defer cidEntries.Release()
+	return fn.fileBind(ctx, storeKey, fileId, cidEntries, meta)
+}
+
+func (fn *fileNode) fileBind(ctx context.Context, storeKey index.Key, fileId string, cidEntries *index.CidEntries, meta *index.FileMetadata) (err error) {
+	if meta != nil {
+		return fn.index.FileBindWithMetadata(ctx, storeKey, fileId, cidEntries, *meta)
+	}
 	return fn.index.FileBind(ctx, storeKey, fileId, cidEntries)
 }
 
+func (fn *fileNode) BlocksBindRevision(ctx context.Context, spaceId, fileId string, cids ...cid.Cid) (err error) {
+	storeKey, err := fn.StoreKey(ctx, spaceId, true)
+	if err != nil {
+		return err
+	}
+	cidEntries, err := fn.index.CidEntries(ctx, cids)
+	if err != nil {
+		return err
+	}
+	defer cidEntries.Release()
+	return fn.index.FileBindRevision(ctx, storeKey, fileId, cidEntries)
+}
+
+func (fn *fileNode) FileVersions(ctx context.Context, spaceId, fileId string) (versions []*filenodeproto.FileVersion, err error) {
+	storeKey, err := fn.StoreKey(ctx, spaceId, false)
+	if err != nil {
+		return
+	}
+	fvs, err := fn.index.FileVersions(ctx, storeKey, fileId)
+	if err != nil {
+		return nil, err
+	}
+	versions = make([]*filenodeproto.FileVersion, len(fvs))
+	for i, fv := range fvs {
+		versions[i] = &filenodeproto.FileVersion{
+			VersionId:  fv.VersionId,
+			UsageBytes: fv.BytesUsage,
+			CidsCount:  uint32(fv.CidsCount),
+			CreateTime: fv.CreateTime,
+		}
+	}
+	return
+}
+
+func (fn *fileNode) FileVersionRestore(ctx context.Context, spaceId, fileId string, versionId uint32) (err error) {
+	storeKey, err := fn.StoreKey(ctx, spaceId, true)
+	if err != nil {
+		return
+	}
+	if err = fn.index.FileVersionRestore(ctx, storeKey, fileId, versionId); err != nil {
+		if errors.Is(err, index.ErrVersionNotFound) {
+			return filenodeprotoerr.ErrVersionNotFound
+		}
+		return
+	}
+	return
+}
+
+func (fn *fileNode) SpaceVersionPolicySet(ctx context.Context, spaceId string, policy index.VersionPolicy) (err error) {
+	storeKey, err := fn.StoreKey(ctx, spaceId, false)
+	if err != nil {
+		return
+	}
+	identity, err := peer.CtxPubKey(ctx)
+	if err != nil {
+		return fileprotoerr.ErrForbidden
+	}
+	// the policy makes the prune pass delete the versions of all the space files, so only the owner can change it.
+	// The 1:1 space is stored per participant, so the participant is the owner of its storage key
+	if storeKey.GroupId != identity.Account() {
+		return fileprotoerr.ErrForbidden
+	}
+	return fn.index.SetSpaceVersionPolicy(ctx, storeKey, policy)
+}
+
+func (fn *fileNode) FilesMetadata(ctx context.Context, spaceId string, fileIds ...string) (info []*filenodeproto.FileMetadataInfo, err error) {
+	storeKey, err := fn.StoreKey(ctx, spaceId, false)
+	if err != nil {
+		return
+	}
+	fis, err := fn.index.FileInfo(ctx, storeKey, fileIds...)
+	if err != nil {
+		return nil, err
+	}
+	info = make([]*filenodeproto.FileMetadataInfo, len(fis))
+	for i, fi := range fis {
+		info[i] = &filenodeproto.FileMetadataInfo{
+			FileId:     fileIds[i],
+			UsageBytes: fi.BytesUsage,
+			CidsCount:  uint32(fi.CidsCount),
+		}
+		if fi.Metadata != nil {
+			info[i].Metadata = &filenodeproto.FileMetadata{
+				MimeType:     fi.Metadata.MimeType,
+				OriginalSize: fi.Metadata.OriginalSize,
+				RootCid:      fi.Metadata.RootCid,
+				Attributes:   fi.Metadata.Attributes,
+			}
+		}
+	}
+	return
+}
+
+func (fn *fileNode) FilesList(ctx context.Context, req *filenodeproto.FilesListRequest) (resp *filenodeproto.FilesListResponse, err error) {
+	storeKey, err := fn.StoreKey(ctx, req.SpaceId, false)
+	if err != nil {
+		return
+	}
+	page, err := fn.index.FilesListPaged(ctx, storeKey, index.FilesListParams{
+		Cursor: req.Cursor,
+		Limit:  int(req.Limit),
+		Sort:   index.FilesListSort(req.Sort),
+		Desc:   req.Desc,
+	})
+	if err != nil {
+		if errors.Is(err, index.ErrInvalidCursor) {
+			return nil, filenodeprotoerr.ErrInvalidCursor
+		}
+		return nil, err
+	}
+	resp = &filenodeproto.FilesListResponse{
+		Files:      make([]*filenodeproto.FileListItem, len(page.Files)),
+		NextCursor: page.NextCursor,
+	}
+	for i, f := range page.Files {
+		resp.Files[i] = &filenodeproto.FileListItem{
+			FileId:     f.FileId,
+			UsageBytes: f.Size,
+			CreateTime: f.CreateTime,
+			UpdateTime: f.UpdateTime,
+		}
+	}
+	return
+}
+
+func (fn *fileNode) FileCopy(ctx context.Context, srcSpaceId, srcFileId, dstSpaceId, dstFileId string) (err error) {
+	srcKey, err := fn.storeKey(ctx, srcSpaceId, false, false)
+	if err != nil {
+		return
+	}
+	dstKey, err := fn.StoreKey(ctx, dstSpaceId, true)
+	if err != nil {
+		return
+	}
+	if err = fn.index.FileCopy(ctx, srcKey, srcFileId, dstKey, dstFileId); err != nil {
+		if errors.Is(err, index.ErrFileNotFound) {
+			return filenodeprotoerr.ErrFileNotFound
+		}
+		if errors.Is(err, index.ErrLimitExceed) {
+			return fileprotoerr.ErrSpaceLimitExceeded
+		}
+		return
+	}
+	return
+}
+
 func (fn *fileNode) StoreKey(ctx context.Context, spaceId string, checkLimit bool) (storageKey index.Key, err error) {
+	return fn.storeKey(ctx, spaceId, checkLimit, true)
+}
+
+func (fn *fileNode) storeKey(ctx context.Context, spaceId string, checkLimit, needWrite bool) (storageKey index.Key, err error) {
+	ctx, span := tracing.Start(ctx, "fileNode.storeKey", tracing.SpaceId(spaceId))
+	defer func() {
+		span.SetAttributes(tracing.GroupId(storageKey.GroupId))
+		tracing.End(span, err)
+	}()
 	if spaceId == "" {
 		return storageKey, fileprotoerr.ErrForbidden
 	}
@@ -187,9 +394,22 @@ func (fn *fileNode) StoreKey(ctx context.Context, spaceId string, checkLimit boo
 		ownerPubKey      crypto.PubKey
 		ownerRecordIndex int
 		isOneToOne       bool
+		cacheKey         = aclCacheKey{identity: identity.Account(), spaceId: spaceId}
+		aclHeadId        string
+		cached           bool
+		canWrite         bool
 	)
 
 	err = fn.acl.ReadList(ctx, spaceId, func(aclList list.AclList) error {
+		aclHeadId = aclList.Head().Id
+		if fn.aclCache != nil {
+			entry, result := fn.aclCache.get(cacheKey, aclHeadId)
+			fn.nodeMetric.AclCache(result)
+			if result == "hit" {
+				storageKey, canWrite, cached = entry.storageKey, entry.canWrite, true
+				return nil
+			}
+		}
 		aclState := aclList.AclState()
 		var ownerRecordId string
 		if ownerPubKey, ownerRecordId, err = aclState.OwnerPubKeyWithRecordId(); err != nil {
@@ -233,15 +453,26 @@ func (fn *fileNode) StoreKey(ctx context.Context, spaceId string, checkLimit boo
 	if err != nil {
 		return
 	}
+	if cached {
+		if needWrite && !canWrite {
+			return storageKey, fileprotoerr.ErrForbidden
+		}
+		return storageKey, fn.checkLimit(ctx, storageKey, checkLimit)
+	}
 
 	// if it not owner
+	canWrite = true
 	if identity.Account() != storageKey.GroupId {
 		permissions, err := fn.acl.Permissions(ctx, identity, spaceId)
 		if err != nil {
 			log.WarnCtx(ctx, "acl permissions error", zap.Error(err))
 			return storageKey, fileprotoerr.ErrForbidden
 		}
-		if !permissions.CanWrite() {
+		if permissions.NoPermissions() {
+			return storageKey, fileprotoerr.ErrForbidden
+		}
+		canWrite = permissions.CanWrite()
+		if needWrite && !canWrite {
 			return storageKey, fileprotoerr.ErrForbidden
 		}
 	}
@@ -259,14 +490,27 @@ func (fn *fileNode) StoreKey(ctx context.Context, spaceId string, checkLimit boo
 			return storageKey, fileprotoerr.ErrUnexpected
 		}
 	}
-	if checkLimit {
-		if err = fn.index.CheckLimits(ctx, storageKey); err != nil {
-			if errors.Is(err, index.ErrLimitExceed) {
-				return storageKey, fileprotoerr.ErrSpaceLimitExceeded
-			} else {
-				log.WarnCtx(ctx, "check limit error", zap.Error(err))
-				return storageKey, fileprotoerr.ErrUnexpected
-			}
+	if fn.aclCache != nil {
+		fn.aclCache.set(aclCacheEntry{
+			key:        cacheKey,
+			storageKey: storageKey,
+			canWrite:   canWrite,
+			aclHeadId:  aclHeadId,
+		})
+	}
+	return storageKey, fn.checkLimit(ctx, storageKey, checkLimit)
+}
+
+func (fn *fileNode) checkLimit(ctx context.Context, storageKey index.Key, checkLimit bool) (err error) {
+	if !checkLimit {
+		return
+	}
+	if err = fn.index.CheckLimits(ctx, storageKey); err != nil {
+		if errors.Is(err, index.ErrLimitExceed) {
+			return fileprotoerr.ErrSpaceLimitExceeded
+		} else {
+			log.WarnCtx(ctx, "check limit error", zap.Error(err))
+			return fileprotoerr.ErrUnexpected
 		}
 	}
 	return
@@ -287,30 +531,54 @@ func (fn *fileNode) SpaceInfo(ctx context.Context, spaceId string) (info *filepr
 	return
 }
 
-func (fn *fileNode) BatchAccountInfo(ctx context.Context, identities []string) ([]*fileproto.AccountInfoResponse, error) {
-	accountInfos := make([]*fileproto.AccountInfoResponse, 0, len(identities))
-	for _, identity := range identities {
-		accountInfo, err := fn.AccountInfo(ctx, identity)
-		if err != nil {
-			return nil, err
-		}
-		if accountInfo == nil {
-			continue
+// BatchAccountInfo returns the account infos in the order of the identities using batchAccountInfoThreads goroutines.
+// The errors are reported per identity, both the info and the error are nil when the account is not found.
+// The identities not started before the ctx is done get the ctx error
+func (fn *fileNode) BatchAccountInfo(ctx context.Context, identities []string) (infos []*fileproto.AccountInfoResponse, errs []error) {
+	infos = make([]*fileproto.AccountInfoResponse, len(identities))
+	errs = make([]error, len(identities))
+	var wg sync.WaitGroup
+	var limiter = make(chan struct{}, batchAccountInfoThreads)
+	for i, identity := range identities {
+		select {
+		case limiter <- struct{}{}:
+		case <-ctx.Done():
+			// the request is canceled, the not started identities get the ctx error
+			for j := i; j < len(identities); j++ {
+				errs[j] = ctx.Err()
+			}
+			wg.Wait()
+			return
 		}
-		accountInfos = append(accountInfos, accountInfo)
-	}
-	return accountInfos, nil
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-limiter
+				wg.Done()
+			}()
+			infos[i], errs[i] = fn.AccountInfo(ctx, identity)
+		}()
+	}
+	wg.Wait()
+	return
 }
 
+// AccountInfo returns nil when the account is not found
 func (fn *fileNode) AccountInfo(ctx context.Context, identity string) (*fileproto.AccountInfoResponse, error) {
-	exists, err := fn.index.CheckKey(ctx, index.GroupKey(index.Key{GroupId: identity}))
-	if err != nil {
+	info, exists, err := fn.index.AccountInfo(ctx, identity)
+	if err != nil || !exists {
 		return nil, err
 	}
-	if !exists {
-		return nil, nil
+	resp := &fileproto.AccountInfoResponse{
+		TotalCidsCount:    info.Group.CidsCount,
+		TotalUsageBytes:   info.Group.BytesUsage,
+		LimitBytes:        info.Group.Limit,
+		AccountLimitBytes: info.Group.AccountLimit,
 	}
-	return fn.accountInfo(ctx, identity)
+	for i, spaceInfo := range info.Spaces {
+		resp.Spaces = append(resp.Spaces, newSpaceInfoResponse(info.Group.SpaceIds[i], spaceInfo, info.Group))
+	}
+	return resp, nil
 }
 
 func (fn *fileNode) AccountInfoCtx(ctx context.Context) (info *fileproto.AccountInfoResponse, err error) {
@@ -349,12 +617,16 @@ func removeOneToOneSuffix(spaceId string) string {
 }
 
 func (fn *fileNode) spaceInfo(ctx context.Context, key index.Key, groupInfo index.GroupInfo) (info *fileproto.SpaceInfoResponse, err error) {
-	info = &fileproto.SpaceInfoResponse{}
-	info.SpaceId = removeOneToOneSuffix(key.SpaceId)
 	spaceInfo, err := fn.index.SpaceInfo(ctx, key)
 	if err != nil {
 		return nil, err
 	}
+	return newSpaceInfoResponse(key.SpaceId, spaceInfo, groupInfo), nil
+}
+
+func newSpaceInfoResponse(spaceId string, spaceInfo index.SpaceInfo, groupInfo index.GroupInfo) (info *fileproto.SpaceInfoResponse) {
+	info = &fileproto.SpaceInfoResponse{}
+	info.SpaceId = removeOneToOneSuffix(spaceId)
 	if spaceInfo.Limit == 0 {
 		info.TotalUsageBytes = groupInfo.BytesUsage
 		info.LimitBytes = groupInfo.Limit
@@ -397,7 +669,7 @@ func (fn *fileNode) FileInfo(ctx context.Context, spaceId string, fileIds ...str
 }
 
 func (fn *fileNode) AddNoBind(ctx context.Context, bs []blocks.Block) error {
-	unlock, err := fn.index.BlocksLock(ctx, bs)
+	unlock, err := fn.index.BlocksLock(ctx, "addNoBind", bs)
 	if err != nil {
 		return err
 	}
@@ -491,5 +763,8 @@ func (fn *fileNode) OwnershipTransfer(ctx context.Context, spaceId, oldIdentity,
 	if err != nil {
 		return
 	}
+	if fn.aclCache != nil {
+		fn.aclCache.invalidateSpace(spaceId)
+	}
 	return fn.index.CheckAndMoveOwnership(ctx, index.Key{GroupId: ownerPubKey.Account(), SpaceId: spaceId}, oldIdentity, ownerRecordIndex)
 }
@@ -1,9 +1,13 @@
 package filenode
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/anyproto/any-sync/acl"
 	"github.com/anyproto/any-sync/acl/mock_acl"
@@ -25,6 +29,9 @@ import (
 	"go.uber.org/mock/gomock"
 
 	"github.com/anyproto/any-sync-filenode/config"
+	"github.com/anyproto/any-sync-filenode/filenode/filenodeproto"
+	"github.com/anyproto/any-sync-filenode/filenode/filenodeproto/filenodeprotoerr"
+	"github.com/anyproto/any-sync-filenode/filenodemetric"
 	"github.com/anyproto/any-sync-filenode/index"
 	"github.com/anyproto/any-sync-filenode/index/mock_index"
 	"github.com/anyproto/any-sync-filenode/store/mock_store"
@@ -51,16 +58,16 @@ func TestFileNode_Add(t *testing.T) {
 				return fn(aclList)
 			})
 
-		fx.index.EXPECT().CheckLimits(ctx, storeKey)
-		fx.index.EXPECT().Migrate(ctx, storeKey)
-		fx.index.EXPECT().CheckAndMoveOwnership(ctx, storeKey, storeKey.GroupId, gomock.Any()).Return(nil)
-		fx.index.EXPECT().BlocksLock(ctx, []blocks.Block{b}).Return(func() {}, nil)
-		fx.index.EXPECT().BlocksGetNonExistent(ctx, []blocks.Block{b}).Return([]blocks.Block{b}, nil)
-		fx.store.EXPECT().Add(ctx, []blocks.Block{b})
-		fx.index.EXPECT().BlocksAdd(ctx, []blocks.Block{b})
-		fx.index.EXPECT().CidEntriesByBlocks(ctx, []blocks.Block{b}).Return(&index.CidEntries{}, nil)
-		fx.index.EXPECT().FileBind(ctx, storeKey, fileId, gomock.Any())
-		fx.index.EXPECT().OnBlockUploaded(ctx, []blocks.Block{b})
+		fx.index.EXPECT().CheckLimits(reqCtx(ctx), storeKey)
+		fx.index.EXPECT().Migrate(reqCtx(ctx), storeKey)
+		fx.index.EXPECT().CheckAndMoveOwnership(reqCtx(ctx), storeKey, storeKey.GroupId, gomock.Any()).Return(nil)
+		fx.index.EXPECT().BlocksLock(reqCtx(ctx), "add", []blocks.Block{b}).Return(func() {}, nil)
+		fx.index.EXPECT().BlocksGetNonExistent(reqCtx(ctx), []blocks.Block{b}).Return([]blocks.Block{b}, nil)
+		fx.store.EXPECT().Add(reqCtx(ctx), []blocks.Block{b})
+		fx.index.EXPECT().BlocksAdd(reqCtx(ctx), []blocks.Block{b})
+		fx.index.EXPECT().CidEntriesByBlocks(reqCtx(ctx), []blocks.Block{b}).Return(&index.CidEntries{}, nil)
+		fx.index.EXPECT().FileBind(reqCtx(ctx), storeKey, fileId, gomock.Any())
+		fx.index.EXPECT().OnBlockUploaded(reqCtx(ctx), []blocks.Block{b})
 
 		resp, err := fx.handler.BlockPush(ctx, &fileproto.BlockPushRequest{
 			SpaceId: storeKey.SpaceId,
@@ -91,9 +98,9 @@ func TestFileNode_Add(t *testing.T) {
 				return fn(aclList)
 			})
 
-		fx.index.EXPECT().Migrate(ctx, storeKey)
-		fx.index.EXPECT().CheckAndMoveOwnership(ctx, storeKey, storeKey.GroupId, gomock.Any()).Return(nil)
-		fx.index.EXPECT().CheckLimits(ctx, storeKey).Return(index.ErrLimitExceed)
+		fx.index.EXPECT().Migrate(reqCtx(ctx), storeKey)
+		fx.index.EXPECT().CheckAndMoveOwnership(reqCtx(ctx), storeKey, storeKey.GroupId, gomock.Any()).Return(nil)
+		fx.index.EXPECT().CheckLimits(reqCtx(ctx), storeKey).Return(index.ErrLimitExceed)
 
 		resp, err := fx.handler.BlockPush(ctx, &fileproto.BlockPushRequest{
 			SpaceId: storeKey.SpaceId,
@@ -152,9 +159,9 @@ func TestFileNode_Add(t *testing.T) {
 		)
 
 		fx.nodeConf.EXPECT().NodeTypes(networkPeerId).Return([]nodeconf.NodeType{nodeconf.NodeTypeCoordinator})
-		fx.index.EXPECT().BlocksLock(ctx, []blocks.Block{b}).Return(func() {}, nil)
-		fx.store.EXPECT().Add(ctx, []blocks.Block{b})
-		fx.index.EXPECT().BlocksAdd(ctx, []blocks.Block{b})
+		fx.index.EXPECT().BlocksLock(reqCtx(ctx), "addNoBind", []blocks.Block{b}).Return(func() {}, nil)
+		fx.store.EXPECT().Add(reqCtx(ctx), []blocks.Block{b})
+		fx.index.EXPECT().BlocksAdd(reqCtx(ctx), []blocks.Block{b})
 		resp, err := fx.handler.BlockPush(ctx, &fileproto.BlockPushRequest{
 			Cid:  b.Cid().Bytes(),
 			Data: b.RawData(),
@@ -198,7 +205,7 @@ func TestFileNode_Get(t *testing.T) {
 		spaceId := key.SpaceId
 		b := testutil.NewRandBlock(10)
 		fx.index.EXPECT().CidExists(gomock.Any(), b.Cid()).Return(true, nil)
-		fx.store.EXPECT().Get(ctx, b.Cid()).Return(b, nil)
+		fx.store.EXPECT().Get(reqCtx(ctx), b.Cid()).Return(b, nil)
 		resp, err := fx.handler.BlockGet(ctx, &fileproto.BlockGetRequest{
 			SpaceId: spaceId,
 			Cid:     b.Cid().Bytes(),
@@ -242,11 +249,11 @@ func TestFileNode_Check(t *testing.T) {
 			return fn(aclList)
 		})
 
-	fx.index.EXPECT().Migrate(ctx, storeKey)
-	fx.index.EXPECT().CheckAndMoveOwnership(ctx, storeKey, storeKey.GroupId, gomock.Any()).Return(nil)
-	fx.index.EXPECT().CidExistsInSpace(ctx, storeKey, testutil.BlocksToKeys(bs)).Return(testutil.BlocksToKeys(bs[:1]), nil)
-	fx.index.EXPECT().CidExists(ctx, bs[1].Cid()).Return(true, nil)
-	fx.index.EXPECT().CidExists(ctx, bs[2].Cid()).Return(false, nil)
+	fx.index.EXPECT().Migrate(reqCtx(ctx), storeKey)
+	fx.index.EXPECT().CheckAndMoveOwnership(reqCtx(ctx), storeKey, storeKey.GroupId, gomock.Any()).Return(nil)
+	fx.index.EXPECT().CidExistsInSpace(reqCtx(ctx), storeKey, testutil.BlocksToKeys(bs)).Return(testutil.BlocksToKeys(bs[:1]), nil)
+	fx.index.EXPECT().CidExists(reqCtx(ctx), bs[1].Cid()).Return(true, nil)
+	fx.index.EXPECT().CidExists(reqCtx(ctx), bs[2].Cid()).Return(false, nil)
 	resp, err := fx.handler.BlocksCheck(ctx, &fileproto.BlocksCheckRequest{
 		SpaceId: storeKey.SpaceId,
 		Cids:    cids,
@@ -284,11 +291,11 @@ func TestFileNode_BlocksBind(t *testing.T) {
 			return fn(aclList)
 		})
 
-	fx.index.EXPECT().CheckLimits(ctx, storeKey)
-	fx.index.EXPECT().Migrate(ctx, storeKey)
-	fx.index.EXPECT().CheckAndMoveOwnership(ctx, storeKey, storeKey.GroupId, gomock.Any()).Return(nil)
-	fx.index.EXPECT().CidEntries(ctx, cids).Return(cidEntries, nil)
-	fx.index.EXPECT().FileBind(ctx, storeKey, fileId, cidEntries)
+	fx.index.EXPECT().CheckLimits(reqCtx(ctx), storeKey)
+	fx.index.EXPECT().Migrate(reqCtx(ctx), storeKey)
+	fx.index.EXPECT().CheckAndMoveOwnership(reqCtx(ctx), storeKey, storeKey.GroupId, gomock.Any()).Return(nil)
+	fx.index.EXPECT().CidEntries(reqCtx(ctx), cids).Return(cidEntries, nil)
+	fx.index.EXPECT().FileBind(reqCtx(ctx), storeKey, fileId, cidEntries)
 
 	resp, err := fx.handler.BlocksBind(ctx, &fileproto.BlocksBindRequest{
 		SpaceId: storeKey.SpaceId,
@@ -300,6 +307,62 @@ func TestFileNode_BlocksBind(t *testing.T) {
 
 }
 
+func TestFileNode_BlocksBindWithMetadata(t *testing.T) {
+	fx := newFixture(t)
+	defer fx.Finish(t)
+
+	var (
+		_, storeKey = newRandKey()
+		fileId      = testutil.NewRandCid().String()
+		b           = testutil.NewRandBlocks(1)[0]
+		cidEntries  = &index.CidEntries{}
+	)
+
+	aclList := defaultAclList(t, storeKey.SpaceId)
+	idRaw, _ := aclList.AclState().Identity().Marshall()
+	storeKey.GroupId = aclList.AclState().Identity().Account()
+	ctx, err := filenodeproto.WithFileMetadata(peer.CtxWithIdentity(context.Background(), idRaw), &filenodeproto.FileMetadata{
+		MimeType:   "image/png",
+		Attributes: map[string]string{"name": "cat.png"},
+	})
+	require.NoError(t, err)
+
+	fx.aclService.EXPECT().ReadList(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, spaceId string, fn func(list.AclList) error) error {
+			return fn(aclList)
+		})
+
+	fx.index.EXPECT().CheckLimits(reqCtx(ctx), storeKey)
+	fx.index.EXPECT().Migrate(reqCtx(ctx), storeKey)
+	fx.index.EXPECT().CheckAndMoveOwnership(reqCtx(ctx), storeKey, storeKey.GroupId, gomock.Any()).Return(nil)
+	fx.index.EXPECT().CidEntries(reqCtx(ctx), []cid.Cid{b.Cid()}).Return(cidEntries, nil)
+	fx.index.EXPECT().FileBindWithMetadata(reqCtx(ctx), storeKey, fileId, cidEntries, index.FileMetadata{
+		MimeType:   "image/png",
+		Attributes: map[string]string{"name": "cat.png"},
+	})
+
+	resp, err := fx.handler.BlocksBind(ctx, &fileproto.BlocksBindRequest{
+		SpaceId: storeKey.SpaceId,
+		FileId:  fileId,
+		Cids:    [][]byte{b.Cid().Bytes()},
+	})
+	require.NotNil(t, resp)
+	require.NoError(t, err)
+
+	t.Run("size exceeded", func(t *testing.T) {
+		ctx, err := filenodeproto.WithFileMetadata(ctx, &filenodeproto.FileMetadata{
+			Attributes: map[string]string{"name": strings.Repeat("a", fileMetadataSizeLimit)},
+		})
+		require.NoError(t, err)
+		_, err = fx.handler.BlocksBind(ctx, &fileproto.BlocksBindRequest{
+			SpaceId: storeKey.SpaceId,
+			FileId:  fileId,
+			Cids:    [][]byte{b.Cid().Bytes()},
+		})
+		require.ErrorIs(t, err, fileprotoerr.ErrQuerySizeExceeded)
+	})
+}
+
 func TestFileNode_FileInfo(t *testing.T) {
 	fx := newFixture(t)
 	defer fx.Finish(t)
@@ -320,9 +383,9 @@ func TestFileNode_FileInfo(t *testing.T) {
 			return fn(aclList)
 		})
 
-	fx.index.EXPECT().Migrate(ctx, storeKey)
-	fx.index.EXPECT().CheckAndMoveOwnership(ctx, storeKey, storeKey.GroupId, gomock.Any()).Return(nil)
-	fx.index.EXPECT().FileInfo(ctx, storeKey, fileId1, fileId2).Return([]index.FileInfo{{1, 1}, {2, 2}}, nil)
+	fx.index.EXPECT().Migrate(reqCtx(ctx), storeKey)
+	fx.index.EXPECT().CheckAndMoveOwnership(reqCtx(ctx), storeKey, storeKey.GroupId, gomock.Any()).Return(nil)
+	fx.index.EXPECT().FileInfo(reqCtx(ctx), storeKey, fileId1, fileId2).Return([]index.FileInfo{{BytesUsage: 1, CidsCount: 1}, {BytesUsage: 2, CidsCount: 2}}, nil)
 
 	resp, err := fx.handler.FilesInfo(ctx, &fileproto.FilesInfoRequest{
 		SpaceId: storeKey.SpaceId,
@@ -334,6 +397,121 @@ func TestFileNode_FileInfo(t *testing.T) {
 	assert.Equal(t, uint64(2), resp.FilesInfo[1].UsageBytes)
 }
 
+func TestFileNode_FileVersionRestore(t *testing.T) {
+	fx := newFixture(t)
+	defer fx.Finish(t)
+
+	var (
+		_, storeKey = newRandKey()
+		fileId      = testutil.NewRandCid().String()
+	)
+
+	aclList := defaultAclList(t, storeKey.SpaceId)
+	idRaw, _ := aclList.AclState().Identity().Marshall()
+	storeKey.GroupId = aclList.AclState().Identity().Account()
+	ctx := peer.CtxWithIdentity(context.Background(), idRaw)
+
+	fx.aclService.EXPECT().ReadList(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, spaceId string, fn func(list.AclList) error) error {
+			return fn(aclList)
+		})
+
+	fx.index.EXPECT().Migrate(reqCtx(ctx), storeKey)
+	fx.index.EXPECT().CheckAndMoveOwnership(reqCtx(ctx), storeKey, storeKey.GroupId, gomock.Any()).Return(nil)
+	fx.index.EXPECT().CheckLimits(reqCtx(ctx), storeKey)
+	fx.index.EXPECT().FileVersionRestore(reqCtx(ctx), storeKey, fileId, uint32(2)).Return(index.ErrVersionNotFound)
+
+	_, err := fx.handler.FileVersionRestore(ctx, &filenodeproto.FileVersionRestoreRequest{
+		SpaceId:   storeKey.SpaceId,
+		FileId:    fileId,
+		VersionId: 2,
+	})
+	require.ErrorIs(t, err, filenodeprotoerr.ErrVersionNotFound)
+}
+
+func TestFileNode_SpaceVersionPolicySet(t *testing.T) {
+	spaceId := testutil.NewRandSpaceId()
+	aclList := defaultAclList(t, spaceId)
+	idRaw, _ := aclList.AclState().Identity().Marshall()
+	storeKey := index.Key{GroupId: aclList.AclState().Identity().Account(), SpaceId: spaceId}
+	policy := index.VersionPolicy{KeepVersions: 1}
+	expectResolve := func(fx *fixture, ctx context.Context) {
+		fx.aclService.EXPECT().ReadList(gomock.Any(), spaceId, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, spaceId string, fn func(list.AclList) error) error {
+				return fn(aclList)
+			})
+		fx.index.EXPECT().Migrate(reqCtx(ctx), storeKey)
+		fx.index.EXPECT().CheckAndMoveOwnership(reqCtx(ctx), storeKey, storeKey.GroupId, gomock.Any()).Return(nil)
+	}
+	t.Run("owner", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.Finish(t)
+		ctx := peer.CtxWithIdentity(context.Background(), idRaw)
+		expectResolve(fx, ctx)
+		fx.index.EXPECT().SetSpaceVersionPolicy(reqCtx(ctx), storeKey, policy)
+
+		require.NoError(t, fx.SpaceVersionPolicySet(ctx, spaceId, policy))
+	})
+	t.Run("writer", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.Finish(t)
+		ctx, _ := newRandKey()
+		expectResolve(fx, ctx)
+		fx.aclService.EXPECT().Permissions(gomock.Any(), gomock.Any(), spaceId).Return(list.AclPermissionsWriter, nil)
+
+		assert.ErrorIs(t, fx.SpaceVersionPolicySet(ctx, spaceId, policy), fileprotoerr.ErrForbidden)
+	})
+}
+
+func TestFileNode_FileCopy(t *testing.T) {
+	fx := newFixture(t)
+	defer fx.Finish(t)
+
+	var (
+		_, srcKey = newRandKey()
+		dstKey    = index.Key{SpaceId: testutil.NewRandSpaceId()}
+		srcFileId = testutil.NewRandCid().String()
+		dstFileId = testutil.NewRandCid().String()
+	)
+
+	aclList := defaultAclList(t, srcKey.SpaceId)
+	idRaw, _ := aclList.AclState().Identity().Marshall()
+	srcKey.GroupId = aclList.AclState().Identity().Account()
+	dstKey.GroupId = srcKey.GroupId
+	ctx := peer.CtxWithIdentity(context.Background(), idRaw)
+
+	fx.aclService.EXPECT().ReadList(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, spaceId string, fn func(list.AclList) error) error {
+			return fn(aclList)
+		}).Times(4)
+
+	fx.index.EXPECT().Migrate(reqCtx(ctx), srcKey)
+	fx.index.EXPECT().Migrate(reqCtx(ctx), dstKey)
+	fx.index.EXPECT().CheckAndMoveOwnership(reqCtx(ctx), srcKey, srcKey.GroupId, gomock.Any()).Return(nil)
+	fx.index.EXPECT().CheckAndMoveOwnership(reqCtx(ctx), dstKey, dstKey.GroupId, gomock.Any()).Return(nil)
+	fx.index.EXPECT().CheckLimits(reqCtx(ctx), dstKey)
+	fx.index.EXPECT().FileCopy(reqCtx(ctx), srcKey, srcFileId, dstKey, dstFileId).Return(index.ErrFileNotFound)
+
+	_, err := fx.handler.FileCopy(ctx, &filenodeproto.FileCopyRequest{
+		SrcSpaceId: srcKey.SpaceId,
+		SrcFileId:  srcFileId,
+		DstSpaceId: dstKey.SpaceId,
+		DstFileId:  dstFileId,
+	})
+	require.ErrorIs(t, err, filenodeprotoerr.ErrFileNotFound)
+
+	// the keys are cached, the limit is checked again
+	fx.index.EXPECT().CheckLimits(reqCtx(ctx), dstKey)
+	fx.index.EXPECT().FileCopy(reqCtx(ctx), srcKey, srcFileId, dstKey, dstFileId).Return(index.ErrLimitExceed)
+	_, err = fx.handler.FileCopy(ctx, &filenodeproto.FileCopyRequest{
+		SrcSpaceId: srcKey.SpaceId,
+		SrcFileId:  srcFileId,
+		DstSpaceId: dstKey.SpaceId,
+		DstFileId:  dstFileId,
+	})
+	require.ErrorIs(t, err, fileprotoerr.ErrSpaceLimitExceeded)
+}
+
 func TestFileNode_AccountInfo(t *testing.T) {
 	fx := newFixture(t)
 	defer fx.Finish(t)
@@ -342,19 +520,19 @@ func TestFileNode_AccountInfo(t *testing.T) {
 		ctx, storeKey = newRandKey()
 		secondSpaceId = testutil.NewRandSpaceId()
 	)
-	fx.index.EXPECT().GroupInfo(ctx, storeKey.GroupId).Return(index.GroupInfo{
+	fx.index.EXPECT().GroupInfo(reqCtx(ctx), storeKey.GroupId).Return(index.GroupInfo{
 		BytesUsage:   100,
 		CidsCount:    10,
 		SpaceIds:     []string{storeKey.SpaceId, secondSpaceId},
 		Limit:        90000,
 		AccountLimit: 100000,
 	}, nil)
-	fx.index.EXPECT().SpaceInfo(ctx, storeKey).Return(index.SpaceInfo{
+	fx.index.EXPECT().SpaceInfo(reqCtx(ctx), storeKey).Return(index.SpaceInfo{
 		BytesUsage: 90,
 		CidsCount:  9,
 		FileCount:  1,
 	}, nil)
-	fx.index.EXPECT().SpaceInfo(ctx, index.Key{GroupId: storeKey.GroupId, SpaceId: secondSpaceId}).Return(index.SpaceInfo{
+	fx.index.EXPECT().SpaceInfo(reqCtx(ctx), index.Key{GroupId: storeKey.GroupId, SpaceId: secondSpaceId}).Return(index.SpaceInfo{
 		BytesUsage: 80,
 		CidsCount:  8,
 		FileCount:  2,
@@ -382,6 +560,79 @@ func TestFileNode_AccountInfo(t *testing.T) {
 	assert.Equal(t, uint64(80), resp.Spaces[1].TotalUsageBytes)
 }
 
+func TestFileNode_BatchAccountInfo(t *testing.T) {
+	fx := newFixture(t)
+	defer fx.Finish(t)
+
+	var identities = make([]string, 50)
+	for i := range identities {
+		_, key := newRandKey()
+		identities[i] = key.GroupId
+		switch i % 3 {
+		case 0:
+			fx.index.EXPECT().AccountInfo(gomock.Any(), key.GroupId).Return(index.AccountInfo{
+				Group: index.GroupInfo{
+					BytesUsage: uint64(i),
+					Limit:      100,
+					SpaceIds:   []string{key.SpaceId},
+				},
+				Spaces: []index.SpaceInfo{{BytesUsage: uint64(i), FileCount: 1}},
+			}, true, nil)
+		case 1:
+			fx.index.EXPECT().AccountInfo(gomock.Any(), key.GroupId).Return(index.AccountInfo{}, false, nil)
+		case 2:
+			fx.index.EXPECT().AccountInfo(gomock.Any(), key.GroupId).Return(index.AccountInfo{}, false, fmt.Errorf("error %d", i))
+		}
+	}
+
+	infos, errs := fx.BatchAccountInfo(context.Background(), identities)
+	require.Len(t, infos, len(identities))
+	require.Len(t, errs, len(identities))
+	for i := range identities {
+		switch i % 3 {
+		case 0:
+			require.NoError(t, errs[i])
+			require.NotNil(t, infos[i])
+			assert.Equal(t, uint64(i), infos[i].TotalUsageBytes)
+			require.Len(t, infos[i].Spaces, 1)
+			assert.Equal(t, uint64(1), infos[i].Spaces[0].FilesCount)
+			assert.Equal(t, uint64(100), infos[i].Spaces[0].LimitBytes)
+		case 1:
+			assert.NoError(t, errs[i])
+			assert.Nil(t, infos[i])
+		case 2:
+			assert.EqualError(t, errs[i], fmt.Sprintf("error %d", i))
+			assert.Nil(t, infos[i])
+		}
+	}
+}
+
+func TestFileNode_BatchAccountInfoCanceled(t *testing.T) {
+	fx := newFixture(t)
+	defer fx.Finish(t)
+
+	var identities = make([]string, batchAccountInfoThreads*3)
+	for i := range identities {
+		_, key := newRandKey()
+		identities[i] = key.GroupId
+	}
+	// the started requests wait for the cancel
+	fx.index.EXPECT().AccountInfo(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, identity string) (index.AccountInfo, bool, error) {
+		<-ctx.Done()
+		return index.AccountInfo{}, false, ctx.Err()
+	}).MaxTimes(len(identities))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	infos, errs := fx.BatchAccountInfo(ctx, identities)
+	require.Len(t, infos, len(identities))
+	require.Len(t, errs, len(identities))
+	for i := range identities {
+		assert.ErrorIs(t, errs[i], context.DeadlineExceeded)
+		assert.Nil(t, infos[i])
+	}
+}
+
 func TestFileNode_SpaceInfo(t *testing.T) {
 	t.Run("basic test", func(t *testing.T) {
 		fx := newFixture(t)
@@ -401,17 +652,17 @@ func TestFileNode_SpaceInfo(t *testing.T) {
 				return fn(aclList)
 			})
 
-		fx.index.EXPECT().Migrate(ctx, storeKey)
-		fx.index.EXPECT().CheckAndMoveOwnership(ctx, storeKey, storeKey.GroupId, gomock.Any()).Return(nil)
+		fx.index.EXPECT().Migrate(reqCtx(ctx), storeKey)
+		fx.index.EXPECT().CheckAndMoveOwnership(reqCtx(ctx), storeKey, storeKey.GroupId, gomock.Any()).Return(nil)
 
-		fx.index.EXPECT().GroupInfo(ctx, storeKey.GroupId).Return(index.GroupInfo{
+		fx.index.EXPECT().GroupInfo(reqCtx(ctx), storeKey.GroupId).Return(index.GroupInfo{
 			BytesUsage:   100,
 			CidsCount:    10,
 			SpaceIds:     []string{storeKey.SpaceId},
 			Limit:        90000,
 			AccountLimit: 100000,
 		}, nil)
-		fx.index.EXPECT().SpaceInfo(ctx, storeKey).Return(index.SpaceInfo{
+		fx.index.EXPECT().SpaceInfo(reqCtx(ctx), storeKey).Return(index.SpaceInfo{
 			BytesUsage: 90,
 			CidsCount:  9,
 			FileCount:  1,
@@ -446,17 +697,17 @@ func TestFileNode_SpaceInfo(t *testing.T) {
 				return fn(aclList)
 			})
 
-		fx.index.EXPECT().Migrate(ctx, storeKey)
-		fx.index.EXPECT().CheckAndMoveOwnership(ctx, storeKey, storeKey.GroupId, gomock.Any()).Return(nil)
+		fx.index.EXPECT().Migrate(reqCtx(ctx), storeKey)
+		fx.index.EXPECT().CheckAndMoveOwnership(reqCtx(ctx), storeKey, storeKey.GroupId, gomock.Any()).Return(nil)
 
-		fx.index.EXPECT().GroupInfo(ctx, storeKey.GroupId).Return(index.GroupInfo{
+		fx.index.EXPECT().GroupInfo(reqCtx(ctx), storeKey.GroupId).Return(index.GroupInfo{
 			BytesUsage:   100,
 			CidsCount:    10,
 			SpaceIds:     []string{storeKey.SpaceId},
 			Limit:        90000,
 			AccountLimit: 100000,
 		}, nil)
-		fx.index.EXPECT().SpaceInfo(ctx, storeKey).Return(index.SpaceInfo{
+		fx.index.EXPECT().SpaceInfo(reqCtx(ctx), storeKey).Return(index.SpaceInfo{
 			BytesUsage: 90,
 			CidsCount:  9,
 			FileCount:  1,
@@ -488,9 +739,9 @@ func TestFileNode_StoreKey(t *testing.T) {
 			SpaceId: "spaceId",
 		}
 
-		fx.index.EXPECT().Migrate(ctx, expectedStoreKey)
-		fx.index.EXPECT().CheckAndMoveOwnership(ctx, expectedStoreKey, expectedStoreKey.GroupId, gomock.Any()).Return(nil)
-		fx.index.EXPECT().CheckLimits(ctx, expectedStoreKey)
+		fx.index.EXPECT().Migrate(reqCtx(ctx), expectedStoreKey)
+		fx.index.EXPECT().CheckAndMoveOwnership(reqCtx(ctx), expectedStoreKey, expectedStoreKey.GroupId, gomock.Any()).Return(nil)
+		fx.index.EXPECT().CheckLimits(reqCtx(ctx), expectedStoreKey)
 		fx.aclService.EXPECT().ReadList(gomock.Any(), gomock.Any(), gomock.Any()).
 			DoAndReturn(func(ctx context.Context, spaceId string, fn func(list.AclList) error) error {
 				return fn(aclList)
@@ -529,7 +780,7 @@ func TestFileNode_StoreKey(t *testing.T) {
 			SpaceId: expectedSuffix,
 		}
 
-		fx.index.EXPECT().CheckLimits(ctx, expectedStoreKey)
+		fx.index.EXPECT().CheckLimits(reqCtx(ctx), expectedStoreKey)
 		fx.aclService.EXPECT().ReadList(gomock.Any(), gomock.Any(), gomock.Any()).
 			DoAndReturn(func(ctx context.Context, spaceId string, fn func(list.AclList) error) error {
 				return fn(aclList)
@@ -581,7 +832,7 @@ func TestFileNode_AccountLimitSet(t *testing.T) {
 	_, storeKey := newRandKey()
 	identity := storeKey.GroupId
 
-	fx.index.EXPECT().SetGroupLimit(ctx, identity, uint64(12345))
+	fx.index.EXPECT().SetGroupLimit(reqCtx(ctx), identity, uint64(12345))
 
 	require.NoError(t, fx.AccountLimitSet(ctx, identity, 12345))
 }
@@ -602,9 +853,9 @@ func TestFileNode_SpaceLimitSet(t *testing.T) {
 			return fn(aclList)
 		})
 
-	fx.index.EXPECT().Migrate(ctx, storeKey)
-	fx.index.EXPECT().CheckAndMoveOwnership(ctx, storeKey, storeKey.GroupId, gomock.Any()).Return(nil)
-	fx.index.EXPECT().SetSpaceLimit(ctx, storeKey, uint64(12345))
+	fx.index.EXPECT().Migrate(reqCtx(ctx), storeKey)
+	fx.index.EXPECT().CheckAndMoveOwnership(reqCtx(ctx), storeKey, storeKey.GroupId, gomock.Any()).Return(nil)
+	fx.index.EXPECT().SetSpaceLimit(reqCtx(ctx), storeKey, uint64(12345))
 	require.NoError(t, fx.SpaceLimitSet(ctx, storeKey.SpaceId, 12345))
 }
 
@@ -618,7 +869,7 @@ func TestFileNode_BlockDeleteUnbound(t *testing.T) {
 		ctx := peer.CtxWithPeerId(context.Background(), peerId)
 
 		fx.nodeConf.EXPECT().NodeTypes(peerId).Return([]nodeconf.NodeType{nodeconf.NodeTypeCoordinator})
-		fx.index.EXPECT().DeleteUnboundCid(ctx, c).Return(true, nil)
+		fx.index.EXPECT().DeleteUnboundCid(reqCtx(ctx), c).Return(true, nil)
 
 		resp, err := fx.handler.BlockDeleteUnbound(ctx, &fileproto.BlockDeleteUnboundRequest{Cid: c.Bytes()})
 		require.NoError(t, err)
@@ -632,7 +883,7 @@ func TestFileNode_BlockDeleteUnbound(t *testing.T) {
 		ctx := peer.CtxWithPeerId(context.Background(), peerId)
 
 		fx.nodeConf.EXPECT().NodeTypes(peerId).Return([]nodeconf.NodeType{nodeconf.NodeTypeCoordinator})
-		fx.index.EXPECT().DeleteUnboundCid(ctx, c).Return(false, nil)
+		fx.index.EXPECT().DeleteUnboundCid(reqCtx(ctx), c).Return(false, nil)
 
 		_, err := fx.handler.BlockDeleteUnbound(ctx, &fileproto.BlockDeleteUnboundRequest{Cid: c.Bytes()})
 		require.NoError(t, err)
@@ -645,7 +896,7 @@ func TestFileNode_BlockDeleteUnbound(t *testing.T) {
 		ctx := peer.CtxWithPeerId(context.Background(), peerId)
 
 		fx.nodeConf.EXPECT().NodeTypes(peerId).Return([]nodeconf.NodeType{nodeconf.NodeTypeCoordinator})
-		fx.index.EXPECT().DeleteUnboundCid(ctx, c).Return(false, index.ErrCidIsBound)
+		fx.index.EXPECT().DeleteUnboundCid(reqCtx(ctx), c).Return(false, index.ErrCidIsBound)
 
 		_, err := fx.handler.BlockDeleteUnbound(ctx, &fileproto.BlockDeleteUnboundRequest{Cid: c.Bytes()})
 		require.ErrorIs(t, err, fileprotoerr.ErrForbidden)
@@ -673,6 +924,165 @@ func TestFileNode_BlockDeleteUnbound(t *testing.T) {
 	})
 }
 
+func TestFileNode_Drain(t *testing.T) {
+	t.Run("wait in-flight", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.Finish(t)
+		ctx := context.Background()
+
+		// emulate the in-flight request
+		_, end, err := fx.drain.begin(ctx, false)
+		require.NoError(t, err)
+		fx.index.EXPECT().ReleaseLocks(gomock.Any()).Return(0)
+
+		var drained = make(chan error)
+		go func() {
+			drained <- fx.Drain(ctx)
+		}()
+		require.Eventually(t, func() bool {
+			_, err := fx.handler.BlockDeleteUnbound(ctx, &fileproto.BlockDeleteUnboundRequest{})
+			return errors.Is(err, filenodeprotoerr.ErrUnavailable)
+		}, time.Second, time.Millisecond*10)
+		assert.ErrorIs(t, fx.drain.readyCheck(ctx), errDraining)
+
+		select {
+		case <-drained:
+			t.Fatal("drain should wait for the in-flight request")
+		case <-time.After(time.Millisecond * 50):
+		}
+		end()
+		require.NoError(t, <-drained)
+	})
+	t.Run("deadline", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.Finish(t)
+
+		// the request finishes when its ctx is canceled
+		reqCtx, end, err := fx.drain.begin(context.Background(), true)
+		require.NoError(t, err)
+		go func() {
+			<-reqCtx.Done()
+			end()
+		}()
+		fx.index.EXPECT().ReleaseLocks(gomock.Any()).Return(2)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+		assert.ErrorIs(t, fx.Drain(ctx), context.DeadlineExceeded)
+		// reads are still served
+		_, _, err = fx.drain.begin(context.Background(), false)
+		require.NoError(t, err)
+	})
+	t.Run("canceled request not finished", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.Finish(t)
+		fx.drainCancelTimeout = time.Millisecond * 50
+
+		// the request ignores the cancel, so its locks must not be released
+		_, _, err := fx.drain.begin(context.Background(), true)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+		assert.ErrorIs(t, fx.Drain(ctx), context.DeadlineExceeded)
+	})
+}
+
+func TestFileNode_Maintenance(t *testing.T) {
+	fx := newFixture(t)
+	defer fx.Finish(t)
+	fx.maintenance = true
+
+	ctx, storeKey := newRandKey()
+	_, err := fx.handler.BlockPush(ctx, &fileproto.BlockPushRequest{SpaceId: storeKey.SpaceId})
+	assert.ErrorIs(t, err, filenodeprotoerr.ErrMaintenance)
+	_, err = fx.handler.FilesDelete(ctx, &fileproto.FilesDeleteRequest{SpaceId: storeKey.SpaceId})
+	assert.ErrorIs(t, err, filenodeprotoerr.ErrMaintenance)
+	_, err = fx.handler.AccountLimitSet(ctx, &fileproto.AccountLimitSetRequest{})
+	assert.ErrorIs(t, err, filenodeprotoerr.ErrMaintenance)
+
+	// reads are served
+	c := testutil.NewRandCid()
+	fx.index.EXPECT().CidExists(gomock.Any(), c).Return(false, nil)
+	_, err = fx.handler.BlockGet(ctx, &fileproto.BlockGetRequest{Cid: c.Bytes()})
+	assert.ErrorIs(t, err, fileprotoerr.ErrCIDNotFound)
+}
+
+func TestFileNode_AclCache(t *testing.T) {
+	fx := newFixture(t)
+	defer fx.Finish(t)
+
+	spaceId := testutil.NewRandSpaceId()
+	executor := list.NewAclExecutor(spaceId)
+	require.NoError(t, executor.Execute("a.init::a"))
+	aclList := executor.ActualAccounts()["a"].Acl
+	idRaw, _ := aclList.AclState().Identity().Marshall()
+	ctx := peer.CtxWithIdentity(context.Background(), idRaw)
+	storeKey := index.Key{GroupId: aclList.AclState().Identity().Account(), SpaceId: spaceId}
+
+	fx.aclService.EXPECT().ReadList(gomock.Any(), spaceId, gomock.Any()).
+		DoAndReturn(func(ctx context.Context, spaceId string, fn func(aclList list.AclList) error) error {
+			return fn(aclList)
+		}).AnyTimes()
+	expectResolve := func() {
+		fx.index.EXPECT().Migrate(reqCtx(ctx), storeKey)
+		fx.index.EXPECT().CheckAndMoveOwnership(reqCtx(ctx), storeKey, storeKey.GroupId, gomock.Any()).Return(nil)
+	}
+
+	// the first call resolves the key, the second one is served from the cache
+	expectResolve()
+	for range 2 {
+		key, err := fx.StoreKey(ctx, spaceId, false)
+		require.NoError(t, err)
+		assert.Equal(t, storeKey, key)
+	}
+
+	// the limit is checked on every call
+	fx.index.EXPECT().CheckLimits(reqCtx(ctx), storeKey).Return(index.ErrLimitExceed)
+	_, err := fx.StoreKey(ctx, spaceId, true)
+	assert.ErrorIs(t, err, fileprotoerr.ErrSpaceLimitExceeded)
+
+	// the acl head is changed
+	require.NoError(t, executor.Execute("a.invite::invId"))
+	expectResolve()
+	for range 2 {
+		_, err = fx.StoreKey(ctx, spaceId, false)
+		require.NoError(t, err)
+	}
+
+	// the ownership transfer invalidates the space
+	fx.index.EXPECT().CheckAndMoveOwnership(gomock.Any(), storeKey, "", 0).Return(nil)
+	require.NoError(t, fx.OwnershipTransfer(ctx, spaceId, "", aclList.Head().Id))
+	expectResolve()
+	_, err = fx.StoreKey(ctx, spaceId, false)
+	require.NoError(t, err)
+}
+
+func TestAclCache(t *testing.T) {
+	c := newAclCache(2)
+	entry := func(identity, spaceId string) aclCacheEntry {
+		return aclCacheEntry{key: aclCacheKey{identity: identity, spaceId: spaceId}, aclHeadId: "head"}
+	}
+	c.set(entry("a", "s1"))
+	c.set(entry("a", "s2"))
+	_, res := c.get(entry("a", "s1").key, "head")
+	assert.Equal(t, "hit", res)
+
+	// s2 is the least recently used
+	c.set(entry("b", "s1"))
+	assert.Equal(t, 2, c.len())
+	_, res = c.get(entry("a", "s2").key, "head")
+	assert.Equal(t, "miss", res)
+
+	_, res = c.get(entry("b", "s1").key, "newHead")
+	assert.Equal(t, "stale", res)
+	assert.Equal(t, 1, c.len())
+
+	c.set(entry("b", "s1"))
+	assert.Equal(t, 2, c.invalidateSpace("s1"))
+	assert.Equal(t, 0, c.len())
+}
+
 func newAclList(t *testing.T, spaceId string, initCmd string) list.AclList {
 	a := list.NewAclExecutor(spaceId)
 	cmds := []string{
@@ -709,6 +1119,9 @@ func newFixture(t *testing.T) *fixture {
 	fx.index.EXPECT().Init(gomock.Any()).AnyTimes()
 	fx.index.EXPECT().Run(gomock.Any()).AnyTimes()
 	fx.index.EXPECT().Close(gomock.Any()).AnyTimes()
+	fx.index.EXPECT().InMaintenance().DoAndReturn(func() bool {
+		return fx.maintenance
+	}).AnyTimes()
 
 	fx.store.EXPECT().Name().Return(fileblockstore.CName).AnyTimes()
 	fx.store.EXPECT().Init(gomock.Any()).AnyTimes()
@@ -724,6 +1137,7 @@ func newFixture(t *testing.T) *fixture {
 	fx.nodeConf.EXPECT().Close(gomock.Any()).AnyTimes()
 
 	fx.a.Register(metric.New()).
+		Register(filenodemetric.New()).
 		Register(fx.serv).
 		Register(fx.index).
 		Register(fx.store).
@@ -744,6 +1158,8 @@ type fixture struct {
 	aclService *mock_acl.MockAclService
 	serv       server.DRPCServer
 	nodeConf   *mock_nodeconf.MockService
+
+	maintenance bool
 }
 
 func (fx *fixture) Finish(t *testing.T) {
@@ -767,3 +1183,32 @@ func mustPubKey(ctx context.Context) crypto.PubKey {
 	}
 	return pubKey
 }
+
+// reqCtx matches the ctx and the request contexts the rpc handler derives from it
+func reqCtx(ctx context.Context) gomock.Matcher {
+	return reqCtxMatcher{ctx: ctx}
+}
+
+type reqCtxMatcher struct {
+	ctx context.Context
+}
+
+func (m reqCtxMatcher) Matches(x any) bool {
+	ctx, ok := x.(context.Context)
+	if !ok {
+		return false
+	}
+	if ctx == m.ctx {
+		return true
+	}
+	// the derived contexts return the values of the parent
+	identity, _ := peer.CtxIdentity(ctx)
+	expectedIdentity, _ := peer.CtxIdentity(m.ctx)
+	peerId, _ := peer.CtxPeerId(ctx)
+	expectedPeerId, _ := peer.CtxPeerId(m.ctx)
+	return bytes.Equal(identity, expectedIdentity) && peerId == expectedPeerId
+}
+
+func (m reqCtxMatcher) String() string {
+	return fmt.Sprintf("is derived from %v", m.ctx)
+}
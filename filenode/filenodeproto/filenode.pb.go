@@ -0,0 +1,1214 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v3.21.12
+// source: filenode.proto
+
+package filenodeproto
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ErrCodes int32
+
+const (
+	ErrCodes_Unexpected      ErrCodes = 0
+	ErrCodes_VersionNotFound ErrCodes = 1
+	ErrCodes_FileNotFound    ErrCodes = 2
+	ErrCodes_InvalidCursor   ErrCodes = 3
+	// Unavailable means the node doesn't accept the request now, the client should retry it later or on another node
+	ErrCodes_Unavailable ErrCodes = 4
+	// Maintenance means the cluster is in the read-only maintenance mode, the writes should be retried later
+	ErrCodes_Maintenance ErrCodes = 5
+	ErrCodes_ErrorOffset ErrCodes = 1100
+)
+
+// Enum value maps for ErrCodes.
+var (
+	ErrCodes_name = map[int32]string{
+		0:    "Unexpected",
+		1:    "VersionNotFound",
+		2:    "FileNotFound",
+		3:    "InvalidCursor",
+		4:    "Unavailable",
+		5:    "Maintenance",
+		1100: "ErrorOffset",
+	}
+	ErrCodes_value = map[string]int32{
+		"Unexpected":      0,
+		"VersionNotFound": 1,
+		"FileNotFound":    2,
+		"InvalidCursor":   3,
+		"Unavailable":     4,
+		"Maintenance":     5,
+		"ErrorOffset":     1100,
+	}
+)
+
+func (x ErrCodes) Enum() *ErrCodes {
+	p := new(ErrCodes)
+	*p = x
+	return p
+}
+
+func (x ErrCodes) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ErrCodes) Descriptor() protoreflect.EnumDescriptor {
+	return file_filenode_proto_enumTypes[0].Descriptor()
+}
+
+func (ErrCodes) Type() protoreflect.EnumType {
+	return &file_filenode_proto_enumTypes[0]
+}
+
+func (x ErrCodes) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ErrCodes.Descriptor instead.
+func (ErrCodes) EnumDescriptor() ([]byte, []int) {
+	return file_filenode_proto_rawDescGZIP(), []int{0}
+}
+
+type FilesListSort int32
+
+const (
+	FilesListSort_Unsorted   FilesListSort = 0
+	FilesListSort_Size       FilesListSort = 1
+	FilesListSort_CreateTime FilesListSort = 2
+	FilesListSort_UpdateTime FilesListSort = 3
+)
+
+// Enum value maps for FilesListSort.
+var (
+	FilesListSort_name = map[int32]string{
+		0: "Unsorted",
+		1: "Size",
+		2: "CreateTime",
+		3: "UpdateTime",
+	}
+	FilesListSort_value = map[string]int32{
+		"Unsorted":   0,
+		"Size":       1,
+		"CreateTime": 2,
+		"UpdateTime": 3,
+	}
+)
+
+func (x FilesListSort) Enum() *FilesListSort {
+	p := new(FilesListSort)
+	*p = x
+	return p
+}
+
+func (x FilesListSort) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (FilesListSort) Descriptor() protoreflect.EnumDescriptor {
+	return file_filenode_proto_enumTypes[1].Descriptor()
+}
+
+func (FilesListSort) Type() protoreflect.EnumType {
+	return &file_filenode_proto_enumTypes[1]
+}
+
+func (x FilesListSort) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use FilesListSort.Descriptor instead.
+func (FilesListSort) EnumDescriptor() ([]byte, []int) {
+	return file_filenode_proto_rawDescGZIP(), []int{1}
+}
+
+type Ok struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Ok) Reset() {
+	*x = Ok{}
+	mi := &file_filenode_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Ok) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Ok) ProtoMessage() {}
+
+func (x *Ok) ProtoReflect() protoreflect.Message {
+	mi := &file_filenode_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Ok.ProtoReflect.Descriptor instead.
+func (*Ok) Descriptor() ([]byte, []int) {
+	return file_filenode_proto_rawDescGZIP(), []int{0}
+}
+
+type FileBindRevisionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SpaceId       string                 `protobuf:"bytes,1,opt,name=spaceId,proto3" json:"spaceId,omitempty"`
+	FileId        string                 `protobuf:"bytes,2,opt,name=fileId,proto3" json:"fileId,omitempty"`
+	Cids          [][]byte               `protobuf:"bytes,3,rep,name=cids,proto3" json:"cids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileBindRevisionRequest) Reset() {
+	*x = FileBindRevisionRequest{}
+	mi := &file_filenode_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileBindRevisionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileBindRevisionRequest) ProtoMessage() {}
+
+func (x *FileBindRevisionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_filenode_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileBindRevisionRequest.ProtoReflect.Descriptor instead.
+func (*FileBindRevisionRequest) Descriptor() ([]byte, []int) {
+	return file_filenode_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *FileBindRevisionRequest) GetSpaceId() string {
+	if x != nil {
+		return x.SpaceId
+	}
+	return ""
+}
+
+func (x *FileBindRevisionRequest) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+func (x *FileBindRevisionRequest) GetCids() [][]byte {
+	if x != nil {
+		return x.Cids
+	}
+	return nil
+}
+
+type FileVersionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SpaceId       string                 `protobuf:"bytes,1,opt,name=spaceId,proto3" json:"spaceId,omitempty"`
+	FileId        string                 `protobuf:"bytes,2,opt,name=fileId,proto3" json:"fileId,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileVersionsRequest) Reset() {
+	*x = FileVersionsRequest{}
+	mi := &file_filenode_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileVersionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileVersionsRequest) ProtoMessage() {}
+
+func (x *FileVersionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_filenode_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileVersionsRequest.ProtoReflect.Descriptor instead.
+func (*FileVersionsRequest) Descriptor() ([]byte, []int) {
+	return file_filenode_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *FileVersionsRequest) GetSpaceId() string {
+	if x != nil {
+		return x.SpaceId
+	}
+	return ""
+}
+
+func (x *FileVersionsRequest) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+type FileVersionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Versions      []*FileVersion         `protobuf:"bytes,1,rep,name=versions,proto3" json:"versions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileVersionsResponse) Reset() {
+	*x = FileVersionsResponse{}
+	mi := &file_filenode_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileVersionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileVersionsResponse) ProtoMessage() {}
+
+func (x *FileVersionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_filenode_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileVersionsResponse.ProtoReflect.Descriptor instead.
+func (*FileVersionsResponse) Descriptor() ([]byte, []int) {
+	return file_filenode_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *FileVersionsResponse) GetVersions() []*FileVersion {
+	if x != nil {
+		return x.Versions
+	}
+	return nil
+}
+
+type FileVersion struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	VersionId     uint32                 `protobuf:"varint,1,opt,name=versionId,proto3" json:"versionId,omitempty"`
+	UsageBytes    uint64                 `protobuf:"varint,2,opt,name=usageBytes,proto3" json:"usageBytes,omitempty"`
+	CidsCount     uint32                 `protobuf:"varint,3,opt,name=cidsCount,proto3" json:"cidsCount,omitempty"`
+	CreateTime    int64                  `protobuf:"varint,4,opt,name=createTime,proto3" json:"createTime,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileVersion) Reset() {
+	*x = FileVersion{}
+	mi := &file_filenode_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileVersion) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileVersion) ProtoMessage() {}
+
+func (x *FileVersion) ProtoReflect() protoreflect.Message {
+	mi := &file_filenode_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileVersion.ProtoReflect.Descriptor instead.
+func (*FileVersion) Descriptor() ([]byte, []int) {
+	return file_filenode_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *FileVersion) GetVersionId() uint32 {
+	if x != nil {
+		return x.VersionId
+	}
+	return 0
+}
+
+func (x *FileVersion) GetUsageBytes() uint64 {
+	if x != nil {
+		return x.UsageBytes
+	}
+	return 0
+}
+
+func (x *FileVersion) GetCidsCount() uint32 {
+	if x != nil {
+		return x.CidsCount
+	}
+	return 0
+}
+
+func (x *FileVersion) GetCreateTime() int64 {
+	if x != nil {
+		return x.CreateTime
+	}
+	return 0
+}
+
+type FileVersionRestoreRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SpaceId       string                 `protobuf:"bytes,1,opt,name=spaceId,proto3" json:"spaceId,omitempty"`
+	FileId        string                 `protobuf:"bytes,2,opt,name=fileId,proto3" json:"fileId,omitempty"`
+	VersionId     uint32                 `protobuf:"varint,3,opt,name=versionId,proto3" json:"versionId,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileVersionRestoreRequest) Reset() {
+	*x = FileVersionRestoreRequest{}
+	mi := &file_filenode_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileVersionRestoreRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileVersionRestoreRequest) ProtoMessage() {}
+
+func (x *FileVersionRestoreRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_filenode_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileVersionRestoreRequest.ProtoReflect.Descriptor instead.
+func (*FileVersionRestoreRequest) Descriptor() ([]byte, []int) {
+	return file_filenode_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *FileVersionRestoreRequest) GetSpaceId() string {
+	if x != nil {
+		return x.SpaceId
+	}
+	return ""
+}
+
+func (x *FileVersionRestoreRequest) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+func (x *FileVersionRestoreRequest) GetVersionId() uint32 {
+	if x != nil {
+		return x.VersionId
+	}
+	return 0
+}
+
+type SpaceVersionPolicySetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SpaceId       string                 `protobuf:"bytes,1,opt,name=spaceId,proto3" json:"spaceId,omitempty"`
+	KeepVersions  uint32                 `protobuf:"varint,2,opt,name=keepVersions,proto3" json:"keepVersions,omitempty"`
+	KeepDays      uint32                 `protobuf:"varint,3,opt,name=keepDays,proto3" json:"keepDays,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SpaceVersionPolicySetRequest) Reset() {
+	*x = SpaceVersionPolicySetRequest{}
+	mi := &file_filenode_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SpaceVersionPolicySetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SpaceVersionPolicySetRequest) ProtoMessage() {}
+
+func (x *SpaceVersionPolicySetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_filenode_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SpaceVersionPolicySetRequest.ProtoReflect.Descriptor instead.
+func (*SpaceVersionPolicySetRequest) Descriptor() ([]byte, []int) {
+	return file_filenode_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *SpaceVersionPolicySetRequest) GetSpaceId() string {
+	if x != nil {
+		return x.SpaceId
+	}
+	return ""
+}
+
+func (x *SpaceVersionPolicySetRequest) GetKeepVersions() uint32 {
+	if x != nil {
+		return x.KeepVersions
+	}
+	return 0
+}
+
+func (x *SpaceVersionPolicySetRequest) GetKeepDays() uint32 {
+	if x != nil {
+		return x.KeepDays
+	}
+	return 0
+}
+
+// FileMetadata is the client-supplied metadata of the file, it's passed along with the BlocksBind or BlockPush request
+// in the drpc invocation metadata, see filenodeproto.WithFileMetadata
+type FileMetadata struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MimeType      string                 `protobuf:"bytes,1,opt,name=mimeType,proto3" json:"mimeType,omitempty"`
+	OriginalSize  uint64                 `protobuf:"varint,2,opt,name=originalSize,proto3" json:"originalSize,omitempty"`
+	RootCid       string                 `protobuf:"bytes,3,opt,name=rootCid,proto3" json:"rootCid,omitempty"`
+	Attributes    map[string]string      `protobuf:"bytes,4,rep,name=attributes,proto3" json:"attributes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileMetadata) Reset() {
+	*x = FileMetadata{}
+	mi := &file_filenode_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileMetadata) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileMetadata) ProtoMessage() {}
+
+func (x *FileMetadata) ProtoReflect() protoreflect.Message {
+	mi := &file_filenode_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileMetadata.ProtoReflect.Descriptor instead.
+func (*FileMetadata) Descriptor() ([]byte, []int) {
+	return file_filenode_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *FileMetadata) GetMimeType() string {
+	if x != nil {
+		return x.MimeType
+	}
+	return ""
+}
+
+func (x *FileMetadata) GetOriginalSize() uint64 {
+	if x != nil {
+		return x.OriginalSize
+	}
+	return 0
+}
+
+func (x *FileMetadata) GetRootCid() string {
+	if x != nil {
+		return x.RootCid
+	}
+	return ""
+}
+
+func (x *FileMetadata) GetAttributes() map[string]string {
+	if x != nil {
+		return x.Attributes
+	}
+	return nil
+}
+
+type FilesMetadataRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SpaceId       string                 `protobuf:"bytes,1,opt,name=spaceId,proto3" json:"spaceId,omitempty"`
+	FileIds       []string               `protobuf:"bytes,2,rep,name=fileIds,proto3" json:"fileIds,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FilesMetadataRequest) Reset() {
+	*x = FilesMetadataRequest{}
+	mi := &file_filenode_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FilesMetadataRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FilesMetadataRequest) ProtoMessage() {}
+
+func (x *FilesMetadataRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_filenode_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FilesMetadataRequest.ProtoReflect.Descriptor instead.
+func (*FilesMetadataRequest) Descriptor() ([]byte, []int) {
+	return file_filenode_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *FilesMetadataRequest) GetSpaceId() string {
+	if x != nil {
+		return x.SpaceId
+	}
+	return ""
+}
+
+func (x *FilesMetadataRequest) GetFileIds() []string {
+	if x != nil {
+		return x.FileIds
+	}
+	return nil
+}
+
+type FilesMetadataResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Files         []*FileMetadataInfo    `protobuf:"bytes,1,rep,name=files,proto3" json:"files,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FilesMetadataResponse) Reset() {
+	*x = FilesMetadataResponse{}
+	mi := &file_filenode_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FilesMetadataResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FilesMetadataResponse) ProtoMessage() {}
+
+func (x *FilesMetadataResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_filenode_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FilesMetadataResponse.ProtoReflect.Descriptor instead.
+func (*FilesMetadataResponse) Descriptor() ([]byte, []int) {
+	return file_filenode_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *FilesMetadataResponse) GetFiles() []*FileMetadataInfo {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+type FileMetadataInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FileId        string                 `protobuf:"bytes,1,opt,name=fileId,proto3" json:"fileId,omitempty"`
+	UsageBytes    uint64                 `protobuf:"varint,2,opt,name=usageBytes,proto3" json:"usageBytes,omitempty"`
+	CidsCount     uint32                 `protobuf:"varint,3,opt,name=cidsCount,proto3" json:"cidsCount,omitempty"`
+	Metadata      *FileMetadata          `protobuf:"bytes,4,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileMetadataInfo) Reset() {
+	*x = FileMetadataInfo{}
+	mi := &file_filenode_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileMetadataInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileMetadataInfo) ProtoMessage() {}
+
+func (x *FileMetadataInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_filenode_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileMetadataInfo.ProtoReflect.Descriptor instead.
+func (*FileMetadataInfo) Descriptor() ([]byte, []int) {
+	return file_filenode_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *FileMetadataInfo) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+func (x *FileMetadataInfo) GetUsageBytes() uint64 {
+	if x != nil {
+		return x.UsageBytes
+	}
+	return 0
+}
+
+func (x *FileMetadataInfo) GetCidsCount() uint32 {
+	if x != nil {
+		return x.CidsCount
+	}
+	return 0
+}
+
+func (x *FileMetadataInfo) GetMetadata() *FileMetadata {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+type FilesListRequest struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	SpaceId string                 `protobuf:"bytes,1,opt,name=spaceId,proto3" json:"spaceId,omitempty"`
+	// cursor is the nextCursor of the previous response, empty for the first page
+	Cursor        string        `protobuf:"bytes,2,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	Limit         uint32        `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+	Sort          FilesListSort `protobuf:"varint,4,opt,name=sort,proto3,enum=filenodeProto.FilesListSort" json:"sort,omitempty"`
+	Desc          bool          `protobuf:"varint,5,opt,name=desc,proto3" json:"desc,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FilesListRequest) Reset() {
+	*x = FilesListRequest{}
+	mi := &file_filenode_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FilesListRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FilesListRequest) ProtoMessage() {}
+
+func (x *FilesListRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_filenode_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FilesListRequest.ProtoReflect.Descriptor instead.
+func (*FilesListRequest) Descriptor() ([]byte, []int) {
+	return file_filenode_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *FilesListRequest) GetSpaceId() string {
+	if x != nil {
+		return x.SpaceId
+	}
+	return ""
+}
+
+func (x *FilesListRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+func (x *FilesListRequest) GetLimit() uint32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *FilesListRequest) GetSort() FilesListSort {
+	if x != nil {
+		return x.Sort
+	}
+	return FilesListSort_Unsorted
+}
+
+func (x *FilesListRequest) GetDesc() bool {
+	if x != nil {
+		return x.Desc
+	}
+	return false
+}
+
+type FilesListResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Files []*FileListItem        `protobuf:"bytes,1,rep,name=files,proto3" json:"files,omitempty"`
+	// nextCursor is empty when there are no more files
+	NextCursor    string `protobuf:"bytes,2,opt,name=nextCursor,proto3" json:"nextCursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FilesListResponse) Reset() {
+	*x = FilesListResponse{}
+	mi := &file_filenode_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FilesListResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FilesListResponse) ProtoMessage() {}
+
+func (x *FilesListResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_filenode_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FilesListResponse.ProtoReflect.Descriptor instead.
+func (*FilesListResponse) Descriptor() ([]byte, []int) {
+	return file_filenode_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *FilesListResponse) GetFiles() []*FileListItem {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+func (x *FilesListResponse) GetNextCursor() string {
+	if x != nil {
+		return x.NextCursor
+	}
+	return ""
+}
+
+type FileListItem struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FileId        string                 `protobuf:"bytes,1,opt,name=fileId,proto3" json:"fileId,omitempty"`
+	UsageBytes    uint64                 `protobuf:"varint,2,opt,name=usageBytes,proto3" json:"usageBytes,omitempty"`
+	CreateTime    int64                  `protobuf:"varint,3,opt,name=createTime,proto3" json:"createTime,omitempty"`
+	UpdateTime    int64                  `protobuf:"varint,4,opt,name=updateTime,proto3" json:"updateTime,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileListItem) Reset() {
+	*x = FileListItem{}
+	mi := &file_filenode_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileListItem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileListItem) ProtoMessage() {}
+
+func (x *FileListItem) ProtoReflect() protoreflect.Message {
+	mi := &file_filenode_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileListItem.ProtoReflect.Descriptor instead.
+func (*FileListItem) Descriptor() ([]byte, []int) {
+	return file_filenode_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *FileListItem) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+func (x *FileListItem) GetUsageBytes() uint64 {
+	if x != nil {
+		return x.UsageBytes
+	}
+	return 0
+}
+
+func (x *FileListItem) GetCreateTime() int64 {
+	if x != nil {
+		return x.CreateTime
+	}
+	return 0
+}
+
+func (x *FileListItem) GetUpdateTime() int64 {
+	if x != nil {
+		return x.UpdateTime
+	}
+	return 0
+}
+
+type FileCopyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SrcSpaceId    string                 `protobuf:"bytes,1,opt,name=srcSpaceId,proto3" json:"srcSpaceId,omitempty"`
+	SrcFileId     string                 `protobuf:"bytes,2,opt,name=srcFileId,proto3" json:"srcFileId,omitempty"`
+	DstSpaceId    string                 `protobuf:"bytes,3,opt,name=dstSpaceId,proto3" json:"dstSpaceId,omitempty"`
+	DstFileId     string                 `protobuf:"bytes,4,opt,name=dstFileId,proto3" json:"dstFileId,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileCopyRequest) Reset() {
+	*x = FileCopyRequest{}
+	mi := &file_filenode_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileCopyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileCopyRequest) ProtoMessage() {}
+
+func (x *FileCopyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_filenode_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileCopyRequest.ProtoReflect.Descriptor instead.
+func (*FileCopyRequest) Descriptor() ([]byte, []int) {
+	return file_filenode_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *FileCopyRequest) GetSrcSpaceId() string {
+	if x != nil {
+		return x.SrcSpaceId
+	}
+	return ""
+}
+
+func (x *FileCopyRequest) GetSrcFileId() string {
+	if x != nil {
+		return x.SrcFileId
+	}
+	return ""
+}
+
+func (x *FileCopyRequest) GetDstSpaceId() string {
+	if x != nil {
+		return x.DstSpaceId
+	}
+	return ""
+}
+
+func (x *FileCopyRequest) GetDstFileId() string {
+	if x != nil {
+		return x.DstFileId
+	}
+	return ""
+}
+
+var File_filenode_proto protoreflect.FileDescriptor
+
+const file_filenode_proto_rawDesc = "" +
+	"\n" +
+	"\x0efilenode.proto\x12\rfilenodeProto\"\x04\n" +
+	"\x02Ok\"_\n" +
+	"\x17FileBindRevisionRequest\x12\x18\n" +
+	"\aspaceId\x18\x01 \x01(\tR\aspaceId\x12\x16\n" +
+	"\x06fileId\x18\x02 \x01(\tR\x06fileId\x12\x12\n" +
+	"\x04cids\x18\x03 \x03(\fR\x04cids\"G\n" +
+	"\x13FileVersionsRequest\x12\x18\n" +
+	"\aspaceId\x18\x01 \x01(\tR\aspaceId\x12\x16\n" +
+	"\x06fileId\x18\x02 \x01(\tR\x06fileId\"N\n" +
+	"\x14FileVersionsResponse\x126\n" +
+	"\bversions\x18\x01 \x03(\v2\x1a.filenodeProto.FileVersionR\bversions\"\x89\x01\n" +
+	"\vFileVersion\x12\x1c\n" +
+	"\tversionId\x18\x01 \x01(\rR\tversionId\x12\x1e\n" +
+	"\n" +
+	"usageBytes\x18\x02 \x01(\x04R\n" +
+	"usageBytes\x12\x1c\n" +
+	"\tcidsCount\x18\x03 \x01(\rR\tcidsCount\x12\x1e\n" +
+	"\n" +
+	"createTime\x18\x04 \x01(\x03R\n" +
+	"createTime\"k\n" +
+	"\x19FileVersionRestoreRequest\x12\x18\n" +
+	"\aspaceId\x18\x01 \x01(\tR\aspaceId\x12\x16\n" +
+	"\x06fileId\x18\x02 \x01(\tR\x06fileId\x12\x1c\n" +
+	"\tversionId\x18\x03 \x01(\rR\tversionId\"x\n" +
+	"\x1cSpaceVersionPolicySetRequest\x12\x18\n" +
+	"\aspaceId\x18\x01 \x01(\tR\aspaceId\x12\"\n" +
+	"\fkeepVersions\x18\x02 \x01(\rR\fkeepVersions\x12\x1a\n" +
+	"\bkeepDays\x18\x03 \x01(\rR\bkeepDays\"\xf4\x01\n" +
+	"\fFileMetadata\x12\x1a\n" +
+	"\bmimeType\x18\x01 \x01(\tR\bmimeType\x12\"\n" +
+	"\foriginalSize\x18\x02 \x01(\x04R\foriginalSize\x12\x18\n" +
+	"\arootCid\x18\x03 \x01(\tR\arootCid\x12K\n" +
+	"\n" +
+	"attributes\x18\x04 \x03(\v2+.filenodeProto.FileMetadata.AttributesEntryR\n" +
+	"attributes\x1a=\n" +
+	"\x0fAttributesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"J\n" +
+	"\x14FilesMetadataRequest\x12\x18\n" +
+	"\aspaceId\x18\x01 \x01(\tR\aspaceId\x12\x18\n" +
+	"\afileIds\x18\x02 \x03(\tR\afileIds\"N\n" +
+	"\x15FilesMetadataResponse\x125\n" +
+	"\x05files\x18\x01 \x03(\v2\x1f.filenodeProto.FileMetadataInfoR\x05files\"\xa1\x01\n" +
+	"\x10FileMetadataInfo\x12\x16\n" +
+	"\x06fileId\x18\x01 \x01(\tR\x06fileId\x12\x1e\n" +
+	"\n" +
+	"usageBytes\x18\x02 \x01(\x04R\n" +
+	"usageBytes\x12\x1c\n" +
+	"\tcidsCount\x18\x03 \x01(\rR\tcidsCount\x127\n" +
+	"\bmetadata\x18\x04 \x01(\v2\x1b.filenodeProto.FileMetadataR\bmetadata\"\xa0\x01\n" +
+	"\x10FilesListRequest\x12\x18\n" +
+	"\aspaceId\x18\x01 \x01(\tR\aspaceId\x12\x16\n" +
+	"\x06cursor\x18\x02 \x01(\tR\x06cursor\x12\x14\n" +
+	"\x05limit\x18\x03 \x01(\rR\x05limit\x120\n" +
+	"\x04sort\x18\x04 \x01(\x0e2\x1c.filenodeProto.FilesListSortR\x04sort\x12\x12\n" +
+	"\x04desc\x18\x05 \x01(\bR\x04desc\"f\n" +
+	"\x11FilesListResponse\x121\n" +
+	"\x05files\x18\x01 \x03(\v2\x1b.filenodeProto.FileListItemR\x05files\x12\x1e\n" +
+	"\n" +
+	"nextCursor\x18\x02 \x01(\tR\n" +
+	"nextCursor\"\x86\x01\n" +
+	"\fFileListItem\x12\x16\n" +
+	"\x06fileId\x18\x01 \x01(\tR\x06fileId\x12\x1e\n" +
+	"\n" +
+	"usageBytes\x18\x02 \x01(\x04R\n" +
+	"usageBytes\x12\x1e\n" +
+	"\n" +
+	"createTime\x18\x03 \x01(\x03R\n" +
+	"createTime\x12\x1e\n" +
+	"\n" +
+	"updateTime\x18\x04 \x01(\x03R\n" +
+	"updateTime\"\x8d\x01\n" +
+	"\x0fFileCopyRequest\x12\x1e\n" +
+	"\n" +
+	"srcSpaceId\x18\x01 \x01(\tR\n" +
+	"srcSpaceId\x12\x1c\n" +
+	"\tsrcFileId\x18\x02 \x01(\tR\tsrcFileId\x12\x1e\n" +
+	"\n" +
+	"dstSpaceId\x18\x03 \x01(\tR\n" +
+	"dstSpaceId\x12\x1c\n" +
+	"\tdstFileId\x18\x04 \x01(\tR\tdstFileId*\x88\x01\n" +
+	"\bErrCodes\x12\x0e\n" +
+	"\n" +
+	"Unexpected\x10\x00\x12\x13\n" +
+	"\x0fVersionNotFound\x10\x01\x12\x10\n" +
+	"\fFileNotFound\x10\x02\x12\x11\n" +
+	"\rInvalidCursor\x10\x03\x12\x0f\n" +
+	"\vUnavailable\x10\x04\x12\x0f\n" +
+	"\vMaintenance\x10\x05\x12\x10\n" +
+	"\vErrorOffset\x10\xcc\b*G\n" +
+	"\rFilesListSort\x12\f\n" +
+	"\bUnsorted\x10\x00\x12\b\n" +
+	"\x04Size\x10\x01\x12\x0e\n" +
+	"\n" +
+	"CreateTime\x10\x02\x12\x0e\n" +
+	"\n" +
+	"UpdateTime\x10\x032\xc9\x04\n" +
+	"\bFileNode\x12M\n" +
+	"\x10FileBindRevision\x12&.filenodeProto.FileBindRevisionRequest\x1a\x11.filenodeProto.Ok\x12W\n" +
+	"\fFileVersions\x12\".filenodeProto.FileVersionsRequest\x1a#.filenodeProto.FileVersionsResponse\x12Q\n" +
+	"\x12FileVersionRestore\x12(.filenodeProto.FileVersionRestoreRequest\x1a\x11.filenodeProto.Ok\x12W\n" +
+	"\x15SpaceVersionPolicySet\x12+.filenodeProto.SpaceVersionPolicySetRequest\x1a\x11.filenodeProto.Ok\x12Z\n" +
+	"\rFilesMetadata\x12#.filenodeProto.FilesMetadataRequest\x1a$.filenodeProto.FilesMetadataResponse\x12N\n" +
+	"\tFilesList\x12\x1f.filenodeProto.FilesListRequest\x1a .filenodeProto.FilesListResponse\x12=\n" +
+	"\bFileCopy\x12\x1e.filenodeProto.FileCopyRequest\x1a\x11.filenodeProto.OkB\x18Z\x16filenode/filenodeprotob\x06proto3"
+
+var (
+	file_filenode_proto_rawDescOnce sync.Once
+	file_filenode_proto_rawDescData []byte
+)
+
+func file_filenode_proto_rawDescGZIP() []byte {
+	file_filenode_proto_rawDescOnce.Do(func() {
+		file_filenode_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_filenode_proto_rawDesc), len(file_filenode_proto_rawDesc)))
+	})
+	return file_filenode_proto_rawDescData
+}
+
+var file_filenode_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_filenode_proto_msgTypes = make([]protoimpl.MessageInfo, 16)
+var file_filenode_proto_goTypes = []any{
+	(ErrCodes)(0),                        // 0: filenodeProto.ErrCodes
+	(FilesListSort)(0),                   // 1: filenodeProto.FilesListSort
+	(*Ok)(nil),                           // 2: filenodeProto.Ok
+	(*FileBindRevisionRequest)(nil),      // 3: filenodeProto.FileBindRevisionRequest
+	(*FileVersionsRequest)(nil),          // 4: filenodeProto.FileVersionsRequest
+	(*FileVersionsResponse)(nil),         // 5: filenodeProto.FileVersionsResponse
+	(*FileVersion)(nil),                  // 6: filenodeProto.FileVersion
+	(*FileVersionRestoreRequest)(nil),    // 7: filenodeProto.FileVersionRestoreRequest
+	(*SpaceVersionPolicySetRequest)(nil), // 8: filenodeProto.SpaceVersionPolicySetRequest
+	(*FileMetadata)(nil),                 // 9: filenodeProto.FileMetadata
+	(*FilesMetadataRequest)(nil),         // 10: filenodeProto.FilesMetadataRequest
+	(*FilesMetadataResponse)(nil),        // 11: filenodeProto.FilesMetadataResponse
+	(*FileMetadataInfo)(nil),             // 12: filenodeProto.FileMetadataInfo
+	(*FilesListRequest)(nil),             // 13: filenodeProto.FilesListRequest
+	(*FilesListResponse)(nil),            // 14: filenodeProto.FilesListResponse
+	(*FileListItem)(nil),                 // 15: filenodeProto.FileListItem
+	(*FileCopyRequest)(nil),              // 16: filenodeProto.FileCopyRequest
+	nil,                                  // 17: filenodeProto.FileMetadata.AttributesEntry
+}
+var file_filenode_proto_depIdxs = []int32{
+	6,  // 0: filenodeProto.FileVersionsResponse.versions:type_name -> filenodeProto.FileVersion
+	17, // 1: filenodeProto.FileMetadata.attributes:type_name -> filenodeProto.FileMetadata.AttributesEntry
+	12, // 2: filenodeProto.FilesMetadataResponse.files:type_name -> filenodeProto.FileMetadataInfo
+	9,  // 3: filenodeProto.FileMetadataInfo.metadata:type_name -> filenodeProto.FileMetadata
+	1,  // 4: filenodeProto.FilesListRequest.sort:type_name -> filenodeProto.FilesListSort
+	15, // 5: filenodeProto.FilesListResponse.files:type_name -> filenodeProto.FileListItem
+	3,  // 6: filenodeProto.FileNode.FileBindRevision:input_type -> filenodeProto.FileBindRevisionRequest
+	4,  // 7: filenodeProto.FileNode.FileVersions:input_type -> filenodeProto.FileVersionsRequest
+	7,  // 8: filenodeProto.FileNode.FileVersionRestore:input_type -> filenodeProto.FileVersionRestoreRequest
+	8,  // 9: filenodeProto.FileNode.SpaceVersionPolicySet:input_type -> filenodeProto.SpaceVersionPolicySetRequest
+	10, // 10: filenodeProto.FileNode.FilesMetadata:input_type -> filenodeProto.FilesMetadataRequest
+	13, // 11: filenodeProto.FileNode.FilesList:input_type -> filenodeProto.FilesListRequest
+	16, // 12: filenodeProto.FileNode.FileCopy:input_type -> filenodeProto.FileCopyRequest
+	2,  // 13: filenodeProto.FileNode.FileBindRevision:output_type -> filenodeProto.Ok
+	5,  // 14: filenodeProto.FileNode.FileVersions:output_type -> filenodeProto.FileVersionsResponse
+	2,  // 15: filenodeProto.FileNode.FileVersionRestore:output_type -> filenodeProto.Ok
+	2,  // 16: filenodeProto.FileNode.SpaceVersionPolicySet:output_type -> filenodeProto.Ok
+	11, // 17: filenodeProto.FileNode.FilesMetadata:output_type -> filenodeProto.FilesMetadataResponse
+	14, // 18: filenodeProto.FileNode.FilesList:output_type -> filenodeProto.FilesListResponse
+	2,  // 19: filenodeProto.FileNode.FileCopy:output_type -> filenodeProto.Ok
+	13, // [13:20] is the sub-list for method output_type
+	6,  // [6:13] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_filenode_proto_init() }
+func file_filenode_proto_init() {
+	if File_filenode_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_filenode_proto_rawDesc), len(file_filenode_proto_rawDesc)),
+			NumEnums:      2,
+			NumMessages:   16,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_filenode_proto_goTypes,
+		DependencyIndexes: file_filenode_proto_depIdxs,
+		EnumInfos:         file_filenode_proto_enumTypes,
+		MessageInfos:      file_filenode_proto_msgTypes,
+	}.Build()
+	File_filenode_proto = out.File
+	file_filenode_proto_goTypes = nil
+	file_filenode_proto_depIdxs = nil
+}
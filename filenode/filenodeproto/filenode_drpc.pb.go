@@ -0,0 +1,374 @@
+// Code generated by protoc-gen-go-drpc. DO NOT EDIT.
+// protoc-gen-go-drpc version: v1.0.0
+// source: filenode.proto
+
+package filenodeproto
+
+import (
+	context "context"
+	errors "errors"
+	drpc1 "github.com/planetscale/vtprotobuf/codec/drpc"
+	drpc "storj.io/drpc"
+	drpcerr "storj.io/drpc/drpcerr"
+)
+
+type drpcEncoding_File_filenode_proto struct{}
+
+func (drpcEncoding_File_filenode_proto) Marshal(msg drpc.Message) ([]byte, error) {
+	return drpc1.Marshal(msg)
+}
+
+func (drpcEncoding_File_filenode_proto) Unmarshal(buf []byte, msg drpc.Message) error {
+	return drpc1.Unmarshal(buf, msg)
+}
+
+func (drpcEncoding_File_filenode_proto) JSONMarshal(msg drpc.Message) ([]byte, error) {
+	return drpc1.JSONMarshal(msg)
+}
+
+func (drpcEncoding_File_filenode_proto) JSONUnmarshal(buf []byte, msg drpc.Message) error {
+	return drpc1.JSONUnmarshal(buf, msg)
+}
+
+type DRPCFileNodeClient interface {
+	DRPCConn() drpc.Conn
+
+	FileBindRevision(ctx context.Context, in *FileBindRevisionRequest) (*Ok, error)
+	FileVersions(ctx context.Context, in *FileVersionsRequest) (*FileVersionsResponse, error)
+	FileVersionRestore(ctx context.Context, in *FileVersionRestoreRequest) (*Ok, error)
+	SpaceVersionPolicySet(ctx context.Context, in *SpaceVersionPolicySetRequest) (*Ok, error)
+	FilesMetadata(ctx context.Context, in *FilesMetadataRequest) (*FilesMetadataResponse, error)
+	FilesList(ctx context.Context, in *FilesListRequest) (*FilesListResponse, error)
+	FileCopy(ctx context.Context, in *FileCopyRequest) (*Ok, error)
+}
+
+type drpcFileNodeClient struct {
+	cc drpc.Conn
+}
+
+func NewDRPCFileNodeClient(cc drpc.Conn) DRPCFileNodeClient {
+	return &drpcFileNodeClient{cc}
+}
+
+func (c *drpcFileNodeClient) DRPCConn() drpc.Conn { return c.cc }
+
+func (c *drpcFileNodeClient) FileBindRevision(ctx context.Context, in *FileBindRevisionRequest) (*Ok, error) {
+	out := new(Ok)
+	err := c.cc.Invoke(ctx, "/filenodeProto.FileNode/FileBindRevision", drpcEncoding_File_filenode_proto{}, in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *drpcFileNodeClient) FileVersions(ctx context.Context, in *FileVersionsRequest) (*FileVersionsResponse, error) {
+	out := new(FileVersionsResponse)
+	err := c.cc.Invoke(ctx, "/filenodeProto.FileNode/FileVersions", drpcEncoding_File_filenode_proto{}, in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *drpcFileNodeClient) FileVersionRestore(ctx context.Context, in *FileVersionRestoreRequest) (*Ok, error) {
+	out := new(Ok)
+	err := c.cc.Invoke(ctx, "/filenodeProto.FileNode/FileVersionRestore", drpcEncoding_File_filenode_proto{}, in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *drpcFileNodeClient) SpaceVersionPolicySet(ctx context.Context, in *SpaceVersionPolicySetRequest) (*Ok, error) {
+	out := new(Ok)
+	err := c.cc.Invoke(ctx, "/filenodeProto.FileNode/SpaceVersionPolicySet", drpcEncoding_File_filenode_proto{}, in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *drpcFileNodeClient) FilesMetadata(ctx context.Context, in *FilesMetadataRequest) (*FilesMetadataResponse, error) {
+	out := new(FilesMetadataResponse)
+	err := c.cc.Invoke(ctx, "/filenodeProto.FileNode/FilesMetadata", drpcEncoding_File_filenode_proto{}, in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *drpcFileNodeClient) FilesList(ctx context.Context, in *FilesListRequest) (*FilesListResponse, error) {
+	out := new(FilesListResponse)
+	err := c.cc.Invoke(ctx, "/filenodeProto.FileNode/FilesList", drpcEncoding_File_filenode_proto{}, in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *drpcFileNodeClient) FileCopy(ctx context.Context, in *FileCopyRequest) (*Ok, error) {
+	out := new(Ok)
+	err := c.cc.Invoke(ctx, "/filenodeProto.FileNode/FileCopy", drpcEncoding_File_filenode_proto{}, in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+type DRPCFileNodeServer interface {
+	FileBindRevision(context.Context, *FileBindRevisionRequest) (*Ok, error)
+	FileVersions(context.Context, *FileVersionsRequest) (*FileVersionsResponse, error)
+	FileVersionRestore(context.Context, *FileVersionRestoreRequest) (*Ok, error)
+	SpaceVersionPolicySet(context.Context, *SpaceVersionPolicySetRequest) (*Ok, error)
+	FilesMetadata(context.Context, *FilesMetadataRequest) (*FilesMetadataResponse, error)
+	FilesList(context.Context, *FilesListRequest) (*FilesListResponse, error)
+	FileCopy(context.Context, *FileCopyRequest) (*Ok, error)
+}
+
+type DRPCFileNodeUnimplementedServer struct{}
+
+func (s *DRPCFileNodeUnimplementedServer) FileBindRevision(context.Context, *FileBindRevisionRequest) (*Ok, error) {
+	return nil, drpcerr.WithCode(errors.New("Unimplemented"), drpcerr.Unimplemented)
+}
+
+func (s *DRPCFileNodeUnimplementedServer) FileVersions(context.Context, *FileVersionsRequest) (*FileVersionsResponse, error) {
+	return nil, drpcerr.WithCode(errors.New("Unimplemented"), drpcerr.Unimplemented)
+}
+
+func (s *DRPCFileNodeUnimplementedServer) FileVersionRestore(context.Context, *FileVersionRestoreRequest) (*Ok, error) {
+	return nil, drpcerr.WithCode(errors.New("Unimplemented"), drpcerr.Unimplemented)
+}
+
+func (s *DRPCFileNodeUnimplementedServer) SpaceVersionPolicySet(context.Context, *SpaceVersionPolicySetRequest) (*Ok, error) {
+	return nil, drpcerr.WithCode(errors.New("Unimplemented"), drpcerr.Unimplemented)
+}
+
+func (s *DRPCFileNodeUnimplementedServer) FilesMetadata(context.Context, *FilesMetadataRequest) (*FilesMetadataResponse, error) {
+	return nil, drpcerr.WithCode(errors.New("Unimplemented"), drpcerr.Unimplemented)
+}
+
+func (s *DRPCFileNodeUnimplementedServer) FilesList(context.Context, *FilesListRequest) (*FilesListResponse, error) {
+	return nil, drpcerr.WithCode(errors.New("Unimplemented"), drpcerr.Unimplemented)
+}
+
+func (s *DRPCFileNodeUnimplementedServer) FileCopy(context.Context, *FileCopyRequest) (*Ok, error) {
+	return nil, drpcerr.WithCode(errors.New("Unimplemented"), drpcerr.Unimplemented)
+}
+
+type DRPCFileNodeDescription struct{}
+
+func (DRPCFileNodeDescription) NumMethods() int { return 7 }
+
+func (DRPCFileNodeDescription) Method(n int) (string, drpc.Encoding, drpc.Receiver, interface{}, bool) {
+	switch n {
+	case 0:
+		return "/filenodeProto.FileNode/FileBindRevision", drpcEncoding_File_filenode_proto{},
+			func(srv interface{}, ctx context.Context, in1, in2 interface{}) (drpc.Message, error) {
+				return srv.(DRPCFileNodeServer).
+					FileBindRevision(
+						ctx,
+						in1.(*FileBindRevisionRequest),
+					)
+			}, DRPCFileNodeServer.FileBindRevision, true
+	case 1:
+		return "/filenodeProto.FileNode/FileVersions", drpcEncoding_File_filenode_proto{},
+			func(srv interface{}, ctx context.Context, in1, in2 interface{}) (drpc.Message, error) {
+				return srv.(DRPCFileNodeServer).
+					FileVersions(
+						ctx,
+						in1.(*FileVersionsRequest),
+					)
+			}, DRPCFileNodeServer.FileVersions, true
+	case 2:
+		return "/filenodeProto.FileNode/FileVersionRestore", drpcEncoding_File_filenode_proto{},
+			func(srv interface{}, ctx context.Context, in1, in2 interface{}) (drpc.Message, error) {
+				return srv.(DRPCFileNodeServer).
+					FileVersionRestore(
+						ctx,
+						in1.(*FileVersionRestoreRequest),
+					)
+			}, DRPCFileNodeServer.FileVersionRestore, true
+	case 3:
+		return "/filenodeProto.FileNode/SpaceVersionPolicySet", drpcEncoding_File_filenode_proto{},
+			func(srv interface{}, ctx context.Context, in1, in2 interface{}) (drpc.Message, error) {
+				return srv.(DRPCFileNodeServer).
+					SpaceVersionPolicySet(
+						ctx,
+						in1.(*SpaceVersionPolicySetRequest),
+					)
+			}, DRPCFileNodeServer.SpaceVersionPolicySet, true
+	case 4:
+		return "/filenodeProto.FileNode/FilesMetadata", drpcEncoding_File_filenode_proto{},
+			func(srv interface{}, ctx context.Context, in1, in2 interface{}) (drpc.Message, error) {
+				return srv.(DRPCFileNodeServer).
+					FilesMetadata(
+						ctx,
+						in1.(*FilesMetadataRequest),
+					)
+			}, DRPCFileNodeServer.FilesMetadata, true
+	case 5:
+		return "/filenodeProto.FileNode/FilesList", drpcEncoding_File_filenode_proto{},
+			func(srv interface{}, ctx context.Context, in1, in2 interface{}) (drpc.Message, error) {
+				return srv.(DRPCFileNodeServer).
+					FilesList(
+						ctx,
+						in1.(*FilesListRequest),
+					)
+			}, DRPCFileNodeServer.FilesList, true
+	case 6:
+		return "/filenodeProto.FileNode/FileCopy", drpcEncoding_File_filenode_proto{},
+			func(srv interface{}, ctx context.Context, in1, in2 interface{}) (drpc.Message, error) {
+				return srv.(DRPCFileNodeServer).
+					FileCopy(
+						ctx,
+						in1.(*FileCopyRequest),
+					)
+			}, DRPCFileNodeServer.FileCopy, true
+	default:
+		return "", nil, nil, nil, false
+	}
+}
+
+func DRPCRegisterFileNode(mux drpc.Mux, impl DRPCFileNodeServer) error {
+	return mux.Register(impl, DRPCFileNodeDescription{})
+}
+
+type DRPCFileNode_FileBindRevisionStream interface {
+	drpc.Stream
+	SendAndClose(*Ok) error
+}
+
+type drpcFileNode_FileBindRevisionStream struct {
+	drpc.Stream
+}
+
+func (x *drpcFileNode_FileBindRevisionStream) GetStream() drpc.Stream {
+	return x.Stream
+}
+
+func (x *drpcFileNode_FileBindRevisionStream) SendAndClose(m *Ok) error {
+	if err := x.MsgSend(m, drpcEncoding_File_filenode_proto{}); err != nil {
+		return err
+	}
+	return x.CloseSend()
+}
+
+type DRPCFileNode_FileVersionsStream interface {
+	drpc.Stream
+	SendAndClose(*FileVersionsResponse) error
+}
+
+type drpcFileNode_FileVersionsStream struct {
+	drpc.Stream
+}
+
+func (x *drpcFileNode_FileVersionsStream) GetStream() drpc.Stream {
+	return x.Stream
+}
+
+func (x *drpcFileNode_FileVersionsStream) SendAndClose(m *FileVersionsResponse) error {
+	if err := x.MsgSend(m, drpcEncoding_File_filenode_proto{}); err != nil {
+		return err
+	}
+	return x.CloseSend()
+}
+
+type DRPCFileNode_FileVersionRestoreStream interface {
+	drpc.Stream
+	SendAndClose(*Ok) error
+}
+
+type drpcFileNode_FileVersionRestoreStream struct {
+	drpc.Stream
+}
+
+func (x *drpcFileNode_FileVersionRestoreStream) GetStream() drpc.Stream {
+	return x.Stream
+}
+
+func (x *drpcFileNode_FileVersionRestoreStream) SendAndClose(m *Ok) error {
+	if err := x.MsgSend(m, drpcEncoding_File_filenode_proto{}); err != nil {
+		return err
+	}
+	return x.CloseSend()
+}
+
+type DRPCFileNode_SpaceVersionPolicySetStream interface {
+	drpc.Stream
+	SendAndClose(*Ok) error
+}
+
+type drpcFileNode_SpaceVersionPolicySetStream struct {
+	drpc.Stream
+}
+
+func (x *drpcFileNode_SpaceVersionPolicySetStream) GetStream() drpc.Stream {
+	return x.Stream
+}
+
+func (x *drpcFileNode_SpaceVersionPolicySetStream) SendAndClose(m *Ok) error {
+	if err := x.MsgSend(m, drpcEncoding_File_filenode_proto{}); err != nil {
+		return err
+	}
+	return x.CloseSend()
+}
+
+type DRPCFileNode_FilesMetadataStream interface {
+	drpc.Stream
+	SendAndClose(*FilesMetadataResponse) error
+}
+
+type drpcFileNode_FilesMetadataStream struct {
+	drpc.Stream
+}
+
+func (x *drpcFileNode_FilesMetadataStream) GetStream() drpc.Stream {
+	return x.Stream
+}
+
+func (x *drpcFileNode_FilesMetadataStream) SendAndClose(m *FilesMetadataResponse) error {
+	if err := x.MsgSend(m, drpcEncoding_File_filenode_proto{}); err != nil {
+		return err
+	}
+	return x.CloseSend()
+}
+
+type DRPCFileNode_FilesListStream interface {
+	drpc.Stream
+	SendAndClose(*FilesListResponse) error
+}
+
+type drpcFileNode_FilesListStream struct {
+	drpc.Stream
+}
+
+func (x *drpcFileNode_FilesListStream) GetStream() drpc.Stream {
+	return x.Stream
+}
+
+func (x *drpcFileNode_FilesListStream) SendAndClose(m *FilesListResponse) error {
+	if err := x.MsgSend(m, drpcEncoding_File_filenode_proto{}); err != nil {
+		return err
+	}
+	return x.CloseSend()
+}
+
+type DRPCFileNode_FileCopyStream interface {
+	drpc.Stream
+	SendAndClose(*Ok) error
+}
+
+type drpcFileNode_FileCopyStream struct {
+	drpc.Stream
+}
+
+func (x *drpcFileNode_FileCopyStream) GetStream() drpc.Stream {
+	return x.Stream
+}
+
+func (x *drpcFileNode_FileCopyStream) SendAndClose(m *Ok) error {
+	if err := x.MsgSend(m, drpcEncoding_File_filenode_proto{}); err != nil {
+		return err
+	}
+	return x.CloseSend()
+}
@@ -0,0 +1,19 @@
+package filenodeprotoerr
+
+import (
+	"fmt"
+
+	"github.com/anyproto/any-sync/net/rpc/rpcerr"
+
+	"github.com/anyproto/any-sync-filenode/filenode/filenodeproto"
+)
+
+var (
+	errGroup           = rpcerr.ErrGroup(filenodeproto.ErrCodes_ErrorOffset)
+	ErrUnexpected      = errGroup.Register(fmt.Errorf("unexpected filenodeproto error"), uint64(filenodeproto.ErrCodes_Unexpected))
+	ErrVersionNotFound = errGroup.Register(fmt.Errorf("file version not found"), uint64(filenodeproto.ErrCodes_VersionNotFound))
+	ErrFileNotFound    = errGroup.Register(fmt.Errorf("file not found"), uint64(filenodeproto.ErrCodes_FileNotFound))
+	ErrInvalidCursor   = errGroup.Register(fmt.Errorf("invalid cursor"), uint64(filenodeproto.ErrCodes_InvalidCursor))
+	ErrUnavailable     = errGroup.Register(fmt.Errorf("node is unavailable, retry later"), uint64(filenodeproto.ErrCodes_Unavailable))
+	ErrMaintenance     = errGroup.Register(fmt.Errorf("maintenance mode, writes are disabled, retry later"), uint64(filenodeproto.ErrCodes_Maintenance))
+)
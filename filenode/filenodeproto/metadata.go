@@ -0,0 +1,41 @@
+package filenodeproto
+
+import (
+	"context"
+	"encoding/base64"
+
+	"storj.io/drpc/drpcmetadata"
+)
+
+// FileMetadataKey is the drpc invocation metadata key of the file metadata passed along with BlocksBind or BlockPush
+const FileMetadataKey = "anysync-file-metadata"
+
+// WithFileMetadata returns the context which makes the next BlocksBind or BlockPush call set the metadata of the bound file
+func WithFileMetadata(ctx context.Context, meta *FileMetadata) (context.Context, error) {
+	data, err := meta.MarshalVT()
+	if err != nil {
+		return nil, err
+	}
+	return drpcmetadata.Add(ctx, FileMetadataKey, base64.StdEncoding.EncodeToString(data)), nil
+}
+
+// FileMetadataFromContext returns the file metadata of the incoming call, nil if the call has no metadata
+func FileMetadataFromContext(ctx context.Context) (meta *FileMetadata, err error) {
+	md, ok := drpcmetadata.Get(ctx)
+	if !ok {
+		return nil, nil
+	}
+	encoded, ok := md[FileMetadataKey]
+	if !ok {
+		return nil, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	meta = &FileMetadata{}
+	if err = meta.UnmarshalVT(data); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
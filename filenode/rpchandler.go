@@ -10,19 +10,32 @@ import (
 	blocks "github.com/ipfs/go-block-format"
 	"github.com/ipfs/go-cid"
 	"go.uber.org/zap"
+
+	"github.com/anyproto/any-sync-filenode/filenode/filenodeproto"
+	"github.com/anyproto/any-sync-filenode/index"
+	"github.com/anyproto/any-sync-filenode/tracing"
 )
 
 const (
 	blockPushManyTotalLimit = 20 << 20 // 20 Mb
 	cidSizeLimit            = 4 << 20  // 4 Mb
 	fileInfoReqLimit        = 1000
+	fileMetadataSizeLimit   = 4 << 10 // 4 Kb
 )
 
 type rpcHandler struct {
 	f *fileNode
 }
 
-func (r rpcHandler) BlockGet(ctx context.Context, req *fileproto.BlockGetRequest) (resp *fileproto.BlockGetResponse, err error) {
+func (r rpcHandler) BlockGet(ctx context.Context, req *fileproto.BlockGetRequest) (*fileproto.BlockGetResponse, error) {
+	return handleRequest(r.f, ctx, false, req, r.blockGet)
+}
+
+func (r rpcHandler) blockGet(ctx context.Context, req *fileproto.BlockGetRequest) (resp *fileproto.BlockGetResponse, err error) {
+	ctx, span := tracing.Start(ctx, "file.blockGet", tracing.SpaceId(req.SpaceId))
+	defer func() {
+		tracing.End(span, err)
+	}()
 	var c cid.Cid
 	st := time.Now()
 	defer func() {
@@ -37,6 +50,7 @@ func (r rpcHandler) BlockGet(ctx context.Context, req *fileproto.BlockGetRequest
 			metric.Size(size),
 			metric.Cid(c.String()),
 			zap.Bool("wait", req.Wait),
+			tracing.TraceId(ctx),
 			zap.Error(err),
 		)
 	}()
@@ -56,7 +70,15 @@ func (r rpcHandler) BlockGet(ctx context.Context, req *fileproto.BlockGetRequest
 	return resp, nil
 }
 
-func (r rpcHandler) BlockPush(ctx context.Context, req *fileproto.BlockPushRequest) (resp *fileproto.Ok, err error) {
+func (r rpcHandler) BlockPush(ctx context.Context, req *fileproto.BlockPushRequest) (*fileproto.Ok, error) {
+	return handleRequest(r.f, ctx, true, req, r.blockPush)
+}
+
+func (r rpcHandler) blockPush(ctx context.Context, req *fileproto.BlockPushRequest) (resp *fileproto.Ok, err error) {
+	ctx, span := tracing.Start(ctx, "file.blockPush", tracing.SpaceId(req.SpaceId), tracing.FileId(req.FileId))
+	defer func() {
+		tracing.End(span, err)
+	}()
 	var c cid.Cid
 	st := time.Now()
 	defer func() {
@@ -67,6 +89,7 @@ func (r rpcHandler) BlockPush(ctx context.Context, req *fileproto.BlockPushReque
 			metric.Size(len(req.Data)),
 			metric.Cid(c.String()),
 			metric.FileId(req.FileId),
+			tracing.TraceId(ctx),
 			zap.Error(err),
 		)
 	}()
@@ -90,13 +113,25 @@ func (r rpcHandler) BlockPush(ctx context.Context, req *fileproto.BlockPushReque
 		return nil, ErrWrongHash
 	}
 
-	if err = r.f.Add(ctx, req.SpaceId, req.FileId, []blocks.Block{b}); err != nil {
+	meta, err := fileMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.f.Add(ctx, req.SpaceId, req.FileId, []blocks.Block{b}, meta); err != nil {
 		return nil, err
 	}
 	return &fileproto.Ok{}, nil
 }
 
-func (r rpcHandler) BlockPushMany(ctx context.Context, req *fileproto.BlockPushManyRequest) (resp *fileproto.Ok, err error) {
+func (r rpcHandler) BlockPushMany(ctx context.Context, req *fileproto.BlockPushManyRequest) (*fileproto.Ok, error) {
+	return handleRequest(r.f, ctx, true, req, r.blockPushMany)
+}
+
+func (r rpcHandler) blockPushMany(ctx context.Context, req *fileproto.BlockPushManyRequest) (resp *fileproto.Ok, err error) {
+	ctx, span := tracing.Start(ctx, "file.blockPushMany")
+	defer func() {
+		tracing.End(span, err)
+	}()
 	var (
 		st        = time.Now()
 		dataSum   int
@@ -104,12 +139,14 @@ func (r rpcHandler) BlockPushMany(ctx context.Context, req *fileproto.BlockPushM
 		fileCount int
 	)
 	defer func() {
+		span.SetAttributes(tracing.CidCount(cidCount), tracing.Bytes(dataSum))
 		r.f.metric.RequestLog(ctx,
 			"file.blockPushMany",
 			metric.TotalDur(time.Since(st)),
 			metric.Size(dataSum),
 			zap.Int("cidCount", cidCount),
 			zap.Int("fileCount", fileCount),
+			tracing.TraceId(ctx),
 			zap.Error(err),
 		)
 	}()
@@ -143,7 +180,7 @@ func (r rpcHandler) BlockPushMany(ctx context.Context, req *fileproto.BlockPushM
 			bs = append(bs, b)
 			cidCount++
 		}
-		if err = r.f.Add(ctx, fileBlock.SpaceId, fileBlock.FileId, bs); err != nil {
+		if err = r.f.Add(ctx, fileBlock.SpaceId, fileBlock.FileId, bs, nil); err != nil {
 			return nil, err
 		}
 		fileCount++
@@ -151,7 +188,15 @@ func (r rpcHandler) BlockPushMany(ctx context.Context, req *fileproto.BlockPushM
 	return &fileproto.Ok{}, nil
 }
 
-func (r rpcHandler) BlocksCheck(ctx context.Context, req *fileproto.BlocksCheckRequest) (resp *fileproto.BlocksCheckResponse, err error) {
+func (r rpcHandler) BlocksCheck(ctx context.Context, req *fileproto.BlocksCheckRequest) (*fileproto.BlocksCheckResponse, error) {
+	return handleRequest(r.f, ctx, false, req, r.blocksCheck)
+}
+
+func (r rpcHandler) blocksCheck(ctx context.Context, req *fileproto.BlocksCheckRequest) (resp *fileproto.BlocksCheckResponse, err error) {
+	ctx, span := tracing.Start(ctx, "file.blocksCheck", tracing.SpaceId(req.SpaceId))
+	defer func() {
+		tracing.End(span, err)
+	}()
 	st := time.Now()
 	defer func() {
 		r.f.metric.RequestLog(ctx,
@@ -159,6 +204,7 @@ func (r rpcHandler) BlocksCheck(ctx context.Context, req *fileproto.BlocksCheckR
 			metric.TotalDur(time.Since(st)),
 			metric.SpaceId(req.SpaceId),
 			metric.Size(len(req.Cids)),
+			tracing.TraceId(ctx),
 			zap.Error(err),
 		)
 	}()
@@ -171,7 +217,15 @@ func (r rpcHandler) BlocksCheck(ctx context.Context, req *fileproto.BlocksCheckR
 	}, nil
 }
 
-func (r rpcHandler) BlocksBind(ctx context.Context, req *fileproto.BlocksBindRequest) (resp *fileproto.Ok, err error) {
+func (r rpcHandler) BlocksBind(ctx context.Context, req *fileproto.BlocksBindRequest) (*fileproto.Ok, error) {
+	return handleRequest(r.f, ctx, true, req, r.blocksBind)
+}
+
+func (r rpcHandler) blocksBind(ctx context.Context, req *fileproto.BlocksBindRequest) (resp *fileproto.Ok, err error) {
+	ctx, span := tracing.Start(ctx, "file.blocksBind", tracing.SpaceId(req.SpaceId), tracing.FileId(req.FileId))
+	defer func() {
+		tracing.End(span, err)
+	}()
 	st := time.Now()
 	defer func() {
 		r.f.metric.RequestLog(ctx,
@@ -180,16 +234,29 @@ func (r rpcHandler) BlocksBind(ctx context.Context, req *fileproto.BlocksBindReq
 			metric.SpaceId(req.SpaceId),
 			metric.FileId(req.FileId),
 			metric.Size(len(req.Cids)),
+			tracing.TraceId(ctx),
 			zap.Error(err),
 		)
 	}()
-	if err = r.f.BlocksBind(ctx, req.SpaceId, req.FileId, convertCids(req.Cids)...); err != nil {
+	meta, err := fileMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.f.BlocksBind(ctx, req.SpaceId, req.FileId, meta, convertCids(req.Cids)...); err != nil {
 		return nil, err
 	}
 	return &fileproto.Ok{}, nil
 }
 
-func (r rpcHandler) FilesDelete(ctx context.Context, req *fileproto.FilesDeleteRequest) (resp *fileproto.FilesDeleteResponse, err error) {
+func (r rpcHandler) FilesDelete(ctx context.Context, req *fileproto.FilesDeleteRequest) (*fileproto.FilesDeleteResponse, error) {
+	return handleRequest(r.f, ctx, true, req, r.filesDelete)
+}
+
+func (r rpcHandler) filesDelete(ctx context.Context, req *fileproto.FilesDeleteRequest) (resp *fileproto.FilesDeleteResponse, err error) {
+	ctx, span := tracing.Start(ctx, "file.filesDelete", tracing.SpaceId(req.SpaceId))
+	defer func() {
+		tracing.End(span, err)
+	}()
 	st := time.Now()
 	defer func() {
 		r.f.metric.RequestLog(ctx,
@@ -197,6 +264,7 @@ func (r rpcHandler) FilesDelete(ctx context.Context, req *fileproto.FilesDeleteR
 			metric.TotalDur(time.Since(st)),
 			metric.SpaceId(req.SpaceId),
 			metric.Size(len(req.FileIds)),
+			tracing.TraceId(ctx),
 			zap.Error(err),
 		)
 	}()
@@ -206,7 +274,15 @@ func (r rpcHandler) FilesDelete(ctx context.Context, req *fileproto.FilesDeleteR
 	return &fileproto.FilesDeleteResponse{}, nil
 }
 
-func (r rpcHandler) FilesInfo(ctx context.Context, req *fileproto.FilesInfoRequest) (resp *fileproto.FilesInfoResponse, err error) {
+func (r rpcHandler) FilesInfo(ctx context.Context, req *fileproto.FilesInfoRequest) (*fileproto.FilesInfoResponse, error) {
+	return handleRequest(r.f, ctx, false, req, r.filesInfo)
+}
+
+func (r rpcHandler) filesInfo(ctx context.Context, req *fileproto.FilesInfoRequest) (resp *fileproto.FilesInfoResponse, err error) {
+	ctx, span := tracing.Start(ctx, "file.filesInfo", tracing.SpaceId(req.SpaceId))
+	defer func() {
+		tracing.End(span, err)
+	}()
 	st := time.Now()
 	defer func() {
 		r.f.metric.RequestLog(ctx,
@@ -214,6 +290,7 @@ func (r rpcHandler) FilesInfo(ctx context.Context, req *fileproto.FilesInfoReque
 			metric.TotalDur(time.Since(st)),
 			metric.SpaceId(req.SpaceId),
 			metric.Size(len(req.FileIds)),
+			tracing.TraceId(ctx),
 			zap.Error(err),
 		)
 	}()
@@ -230,13 +307,24 @@ func (r rpcHandler) FilesInfo(ctx context.Context, req *fileproto.FilesInfoReque
 }
 
 func (r rpcHandler) FilesGet(req *fileproto.FilesGetRequest, stream fileproto.DRPCFile_FilesGetStream) (err error) {
-	ctx := stream.Context()
+	_, err = handleRequest(r.f, stream.Context(), false, req, func(ctx context.Context, req *fileproto.FilesGetRequest) (struct{}, error) {
+		return struct{}{}, r.filesGet(ctx, req, stream)
+	})
+	return
+}
+
+func (r rpcHandler) filesGet(ctx context.Context, req *fileproto.FilesGetRequest, stream fileproto.DRPCFile_FilesGetStream) (err error) {
+	ctx, span := tracing.Start(ctx, "file.filesGet", tracing.SpaceId(req.SpaceId))
+	defer func() {
+		tracing.End(span, err)
+	}()
 	st := time.Now()
 	defer func() {
 		r.f.metric.RequestLog(ctx,
 			"file.filesGet",
 			metric.TotalDur(time.Since(st)),
 			metric.SpaceId(req.SpaceId),
+			tracing.TraceId(ctx),
 			zap.Error(err),
 		)
 	}()
@@ -257,6 +345,10 @@ func (r rpcHandler) FilesGet(req *fileproto.FilesGetRequest, stream fileproto.DR
 }
 
 func (r rpcHandler) Check(ctx context.Context, req *fileproto.CheckRequest) (*fileproto.CheckResponse, error) {
+	return handleRequest(r.f, ctx, false, req, r.check)
+}
+
+func (r rpcHandler) check(ctx context.Context, req *fileproto.CheckRequest) (*fileproto.CheckResponse, error) {
 	st := time.Now()
 	defer func() {
 		r.f.metric.RequestLog(ctx,
@@ -270,13 +362,22 @@ func (r rpcHandler) Check(ctx context.Context, req *fileproto.CheckRequest) (*fi
 	}, nil
 }
 
-func (r rpcHandler) SpaceInfo(ctx context.Context, req *fileproto.SpaceInfoRequest) (resp *fileproto.SpaceInfoResponse, err error) {
+func (r rpcHandler) SpaceInfo(ctx context.Context, req *fileproto.SpaceInfoRequest) (*fileproto.SpaceInfoResponse, error) {
+	return handleRequest(r.f, ctx, false, req, r.spaceInfo)
+}
+
+func (r rpcHandler) spaceInfo(ctx context.Context, req *fileproto.SpaceInfoRequest) (resp *fileproto.SpaceInfoResponse, err error) {
+	ctx, span := tracing.Start(ctx, "file.spaceInfo", tracing.SpaceId(req.SpaceId))
+	defer func() {
+		tracing.End(span, err)
+	}()
 	st := time.Now()
 	defer func() {
 		r.f.metric.RequestLog(ctx,
 			"file.spaceInfo",
 			metric.TotalDur(time.Since(st)),
 			metric.SpaceId(req.SpaceId),
+			tracing.TraceId(ctx),
 			zap.Error(err),
 		)
 	}()
@@ -286,12 +387,21 @@ func (r rpcHandler) SpaceInfo(ctx context.Context, req *fileproto.SpaceInfoReque
 	return
 }
 
-func (r rpcHandler) AccountInfo(ctx context.Context, req *fileproto.AccountInfoRequest) (resp *fileproto.AccountInfoResponse, err error) {
+func (r rpcHandler) AccountInfo(ctx context.Context, req *fileproto.AccountInfoRequest) (*fileproto.AccountInfoResponse, error) {
+	return handleRequest(r.f, ctx, false, req, r.accountInfo)
+}
+
+func (r rpcHandler) accountInfo(ctx context.Context, req *fileproto.AccountInfoRequest) (resp *fileproto.AccountInfoResponse, err error) {
+	ctx, span := tracing.Start(ctx, "file.accountInfo")
+	defer func() {
+		tracing.End(span, err)
+	}()
 	st := time.Now()
 	defer func() {
 		r.f.metric.RequestLog(ctx,
 			"file.accountInfo",
 			metric.TotalDur(time.Since(st)),
+			tracing.TraceId(ctx),
 			zap.Error(err),
 		)
 	}()
@@ -301,12 +411,21 @@ func (r rpcHandler) AccountInfo(ctx context.Context, req *fileproto.AccountInfoR
 	return
 }
 
-func (r rpcHandler) AccountLimitSet(ctx context.Context, req *fileproto.AccountLimitSetRequest) (resp *fileproto.Ok, err error) {
+func (r rpcHandler) AccountLimitSet(ctx context.Context, req *fileproto.AccountLimitSetRequest) (*fileproto.Ok, error) {
+	return handleRequest(r.f, ctx, true, req, r.accountLimitSet)
+}
+
+func (r rpcHandler) accountLimitSet(ctx context.Context, req *fileproto.AccountLimitSetRequest) (resp *fileproto.Ok, err error) {
+	ctx, span := tracing.Start(ctx, "file.accountLimitSet")
+	defer func() {
+		tracing.End(span, err)
+	}()
 	st := time.Now()
 	defer func() {
 		r.f.metric.RequestLog(ctx,
 			"file.accountLimitSet",
 			metric.TotalDur(time.Since(st)),
+			tracing.TraceId(ctx),
 			zap.Error(err),
 		)
 	}()
@@ -316,7 +435,15 @@ func (r rpcHandler) AccountLimitSet(ctx context.Context, req *fileproto.AccountL
 	return &fileproto.Ok{}, nil
 }
 
-func (r rpcHandler) BlockDeleteUnbound(ctx context.Context, req *fileproto.BlockDeleteUnboundRequest) (resp *fileproto.Ok, err error) {
+func (r rpcHandler) BlockDeleteUnbound(ctx context.Context, req *fileproto.BlockDeleteUnboundRequest) (*fileproto.Ok, error) {
+	return handleRequest(r.f, ctx, true, req, r.blockDeleteUnbound)
+}
+
+func (r rpcHandler) blockDeleteUnbound(ctx context.Context, req *fileproto.BlockDeleteUnboundRequest) (resp *fileproto.Ok, err error) {
+	ctx, span := tracing.Start(ctx, "file.blockDeleteUnbound")
+	defer func() {
+		tracing.End(span, err)
+	}()
 	var c cid.Cid
 	st := time.Now()
 	defer func() {
@@ -324,6 +451,7 @@ func (r rpcHandler) BlockDeleteUnbound(ctx context.Context, req *fileproto.Block
 			"file.blockDeleteUnbound",
 			metric.TotalDur(time.Since(st)),
 			metric.Cid(c.String()),
+			tracing.TraceId(ctx),
 			zap.Error(err),
 		)
 	}()
@@ -336,12 +464,21 @@ func (r rpcHandler) BlockDeleteUnbound(ctx context.Context, req *fileproto.Block
 	return &fileproto.Ok{}, nil
 }
 
-func (r rpcHandler) SpaceLimitSet(ctx context.Context, req *fileproto.SpaceLimitSetRequest) (resp *fileproto.Ok, err error) {
+func (r rpcHandler) SpaceLimitSet(ctx context.Context, req *fileproto.SpaceLimitSetRequest) (*fileproto.Ok, error) {
+	return handleRequest(r.f, ctx, true, req, r.spaceLimitSet)
+}
+
+func (r rpcHandler) spaceLimitSet(ctx context.Context, req *fileproto.SpaceLimitSetRequest) (resp *fileproto.Ok, err error) {
+	ctx, span := tracing.Start(ctx, "file.spaceLimitSet")
+	defer func() {
+		tracing.End(span, err)
+	}()
 	st := time.Now()
 	defer func() {
 		r.f.metric.RequestLog(ctx,
 			"file.spaceLimitSet",
 			metric.TotalDur(time.Since(st)),
+			tracing.TraceId(ctx),
 			zap.Error(err),
 		)
 	}()
@@ -351,6 +488,218 @@ func (r rpcHandler) SpaceLimitSet(ctx context.Context, req *fileproto.SpaceLimit
 	return &fileproto.Ok{}, nil
 }
 
+func (r rpcHandler) FileBindRevision(ctx context.Context, req *filenodeproto.FileBindRevisionRequest) (*filenodeproto.Ok, error) {
+	return handleRequest(r.f, ctx, true, req, r.fileBindRevision)
+}
+
+func (r rpcHandler) fileBindRevision(ctx context.Context, req *filenodeproto.FileBindRevisionRequest) (resp *filenodeproto.Ok, err error) {
+	ctx, span := tracing.Start(ctx, "filenode.fileBindRevision", tracing.SpaceId(req.SpaceId), tracing.FileId(req.FileId))
+	defer func() {
+		tracing.End(span, err)
+	}()
+	st := time.Now()
+	defer func() {
+		r.f.metric.RequestLog(ctx,
+			"filenode.fileBindRevision",
+			metric.TotalDur(time.Since(st)),
+			metric.SpaceId(req.SpaceId),
+			metric.FileId(req.FileId),
+			metric.Size(len(req.Cids)),
+			tracing.TraceId(ctx),
+			zap.Error(err),
+		)
+	}()
+	if err = r.f.BlocksBindRevision(ctx, req.SpaceId, req.FileId, convertCids(req.Cids)...); err != nil {
+		return nil, err
+	}
+	return &filenodeproto.Ok{}, nil
+}
+
+func (r rpcHandler) FileVersions(ctx context.Context, req *filenodeproto.FileVersionsRequest) (*filenodeproto.FileVersionsResponse, error) {
+	return handleRequest(r.f, ctx, false, req, r.fileVersions)
+}
+
+func (r rpcHandler) fileVersions(ctx context.Context, req *filenodeproto.FileVersionsRequest) (resp *filenodeproto.FileVersionsResponse, err error) {
+	ctx, span := tracing.Start(ctx, "filenode.fileVersions", tracing.SpaceId(req.SpaceId), tracing.FileId(req.FileId))
+	defer func() {
+		tracing.End(span, err)
+	}()
+	st := time.Now()
+	defer func() {
+		r.f.metric.RequestLog(ctx,
+			"filenode.fileVersions",
+			metric.TotalDur(time.Since(st)),
+			metric.SpaceId(req.SpaceId),
+			metric.FileId(req.FileId),
+			tracing.TraceId(ctx),
+			zap.Error(err),
+		)
+	}()
+	resp = &filenodeproto.FileVersionsResponse{}
+	if resp.Versions, err = r.f.FileVersions(ctx, req.SpaceId, req.FileId); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r rpcHandler) FileVersionRestore(ctx context.Context, req *filenodeproto.FileVersionRestoreRequest) (*filenodeproto.Ok, error) {
+	return handleRequest(r.f, ctx, true, req, r.fileVersionRestore)
+}
+
+func (r rpcHandler) fileVersionRestore(ctx context.Context, req *filenodeproto.FileVersionRestoreRequest) (resp *filenodeproto.Ok, err error) {
+	ctx, span := tracing.Start(ctx, "filenode.fileVersionRestore", tracing.SpaceId(req.SpaceId), tracing.FileId(req.FileId))
+	defer func() {
+		tracing.End(span, err)
+	}()
+	st := time.Now()
+	defer func() {
+		r.f.metric.RequestLog(ctx,
+			"filenode.fileVersionRestore",
+			metric.TotalDur(time.Since(st)),
+			metric.SpaceId(req.SpaceId),
+			metric.FileId(req.FileId),
+			zap.Uint32("versionId", req.VersionId),
+			tracing.TraceId(ctx),
+			zap.Error(err),
+		)
+	}()
+	if err = r.f.FileVersionRestore(ctx, req.SpaceId, req.FileId, req.VersionId); err != nil {
+		return nil, err
+	}
+	return &filenodeproto.Ok{}, nil
+}
+
+func (r rpcHandler) SpaceVersionPolicySet(ctx context.Context, req *filenodeproto.SpaceVersionPolicySetRequest) (*filenodeproto.Ok, error) {
+	return handleRequest(r.f, ctx, true, req, r.spaceVersionPolicySet)
+}
+
+func (r rpcHandler) spaceVersionPolicySet(ctx context.Context, req *filenodeproto.SpaceVersionPolicySetRequest) (resp *filenodeproto.Ok, err error) {
+	ctx, span := tracing.Start(ctx, "filenode.spaceVersionPolicySet", tracing.SpaceId(req.SpaceId))
+	defer func() {
+		tracing.End(span, err)
+	}()
+	st := time.Now()
+	defer func() {
+		r.f.metric.RequestLog(ctx,
+			"filenode.spaceVersionPolicySet",
+			metric.TotalDur(time.Since(st)),
+			metric.SpaceId(req.SpaceId),
+			tracing.TraceId(ctx),
+			zap.Error(err),
+		)
+	}()
+	if err = r.f.SpaceVersionPolicySet(ctx, req.SpaceId, index.VersionPolicy{
+		KeepVersions: req.KeepVersions,
+		KeepDays:     req.KeepDays,
+	}); err != nil {
+		return nil, err
+	}
+	return &filenodeproto.Ok{}, nil
+}
+
+func (r rpcHandler) FilesMetadata(ctx context.Context, req *filenodeproto.FilesMetadataRequest) (*filenodeproto.FilesMetadataResponse, error) {
+	return handleRequest(r.f, ctx, false, req, r.filesMetadata)
+}
+
+func (r rpcHandler) filesMetadata(ctx context.Context, req *filenodeproto.FilesMetadataRequest) (resp *filenodeproto.FilesMetadataResponse, err error) {
+	ctx, span := tracing.Start(ctx, "filenode.filesMetadata", tracing.SpaceId(req.SpaceId))
+	defer func() {
+		tracing.End(span, err)
+	}()
+	st := time.Now()
+	defer func() {
+		r.f.metric.RequestLog(ctx,
+			"filenode.filesMetadata",
+			metric.TotalDur(time.Since(st)),
+			metric.SpaceId(req.SpaceId),
+			metric.Size(len(req.FileIds)),
+			tracing.TraceId(ctx),
+			zap.Error(err),
+		)
+	}()
+	if len(req.FileIds) > fileInfoReqLimit {
+		err = fileprotoerr.ErrQuerySizeExceeded
+		return
+	}
+	resp = &filenodeproto.FilesMetadataResponse{}
+	if resp.Files, err = r.f.FilesMetadata(ctx, req.SpaceId, req.FileIds...); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r rpcHandler) FilesList(ctx context.Context, req *filenodeproto.FilesListRequest) (*filenodeproto.FilesListResponse, error) {
+	return handleRequest(r.f, ctx, false, req, r.filesList)
+}
+
+func (r rpcHandler) filesList(ctx context.Context, req *filenodeproto.FilesListRequest) (resp *filenodeproto.FilesListResponse, err error) {
+	ctx, span := tracing.Start(ctx, "filenode.filesList", tracing.SpaceId(req.SpaceId))
+	defer func() {
+		tracing.End(span, err)
+	}()
+	st := time.Now()
+	defer func() {
+		r.f.metric.RequestLog(ctx,
+			"filenode.filesList",
+			metric.TotalDur(time.Since(st)),
+			metric.SpaceId(req.SpaceId),
+			metric.Size(int(req.Limit)),
+			tracing.TraceId(ctx),
+			zap.Error(err),
+		)
+	}()
+	if req.Limit > fileInfoReqLimit {
+		err = fileprotoerr.ErrQuerySizeExceeded
+		return
+	}
+	return r.f.FilesList(ctx, req)
+}
+
+func (r rpcHandler) FileCopy(ctx context.Context, req *filenodeproto.FileCopyRequest) (*filenodeproto.Ok, error) {
+	return handleRequest(r.f, ctx, true, req, r.fileCopy)
+}
+
+func (r rpcHandler) fileCopy(ctx context.Context, req *filenodeproto.FileCopyRequest) (resp *filenodeproto.Ok, err error) {
+	ctx, span := tracing.Start(ctx, "filenode.fileCopy", tracing.SpaceId(req.DstSpaceId))
+	defer func() {
+		tracing.End(span, err)
+	}()
+	st := time.Now()
+	defer func() {
+		r.f.metric.RequestLog(ctx,
+			"filenode.fileCopy",
+			metric.TotalDur(time.Since(st)),
+			metric.SpaceId(req.DstSpaceId),
+			metric.FileId(req.DstFileId),
+			zap.String("srcSpaceId", req.SrcSpaceId),
+			zap.String("srcFileId", req.SrcFileId),
+			tracing.TraceId(ctx),
+			zap.Error(err),
+		)
+	}()
+	if err = r.f.FileCopy(ctx, req.SrcSpaceId, req.SrcFileId, req.DstSpaceId, req.DstFileId); err != nil {
+		return nil, err
+	}
+	return &filenodeproto.Ok{}, nil
+}
+
+// fileMetadata returns the file metadata passed with the bind request, see filenodeproto.WithFileMetadata
+func fileMetadata(ctx context.Context) (meta *index.FileMetadata, err error) {
+	pbMeta, err := filenodeproto.FileMetadataFromContext(ctx)
+	if err != nil || pbMeta == nil {
+		return nil, err
+	}
+	if pbMeta.SizeVT() > fileMetadataSizeLimit {
+		return nil, fileprotoerr.ErrQuerySizeExceeded
+	}
+	return &index.FileMetadata{
+		MimeType:     pbMeta.MimeType,
+		OriginalSize: pbMeta.OriginalSize,
+		RootCid:      pbMeta.RootCid,
+		Attributes:   pbMeta.Attributes,
+	}, nil
+}
+
 func convertCids(bCids [][]byte) (cids []cid.Cid) {
 	cids = make([]cid.Cid, 0, len(bCids))
 	var uniqMap map[string]struct{}
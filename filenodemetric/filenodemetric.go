@@ -0,0 +1,273 @@
+package filenodemetric
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/anyproto/any-sync/app"
+	"github.com/anyproto/any-sync/app/logger"
+	"github.com/anyproto/any-sync/metric"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+)
+
+const CName = "filenode.metric"
+
+const namespace = "filenode"
+
+var log = logger.NewNamed(CName)
+
+type configSource interface {
+	GetMetricAddr() string
+}
+
+func New() Metric {
+	return new(filenodeMetric)
+}
+
+// Metric collects the index, store and persistence metrics and registers them in the metric.Metric registry
+type Metric interface {
+	// LockWait observes the time spent waiting for the index key lock
+	LockWait(d time.Duration)
+	// LockHold observes the time the index key or blocks lock is held
+	LockHold(d time.Duration)
+	// IndexOp observes the duration of the index operation, like fileBind or fileUnbind
+	IndexOp(op string, d time.Duration)
+	// S3Request observes the total duration of the s3 request and the time spent waiting for the limiter
+	S3Request(op string, total, wait time.Duration)
+	// Persist adds the count of persisted keys by the result: moved, missed, deleted or error,
+	// the pressure_ prefixed results are counted by the memory pressure persistence
+	Persist(result string, count int)
+	// BloomCheck counts the bloom filter checks by the result: negative, hit or miss (false positive)
+	BloomCheck(result string)
+	// AclCache counts the acl cache lookups by the result: hit, miss or stale (the acl head is changed)
+	AclCache(result string)
+	// Anomaly counts the detected refcount anomalies by the reason, the affected groups are queued for the self-heal
+	Anomaly(reason string)
+	// SelfHeal counts the groups checked by the self-heal by the result: fixed, clean or error
+	SelfHeal(result string)
+	// RegisterGaugeFunc registers the gauge which value is calculated on every scrape
+	RegisterGaugeFunc(subsystem, name, help string, f func() float64) error
+	app.Component
+}
+
+type filenodeMetric struct {
+	registry  *prometheus.Registry
+	lockWait  prometheus.Histogram
+	lockHold  prometheus.Histogram
+	indexOp   *prometheus.HistogramVec
+	s3Request *prometheus.HistogramVec
+	s3Wait    *prometheus.HistogramVec
+	persist   *prometheus.CounterVec
+	bloom     *prometheus.CounterVec
+	aclCache  *prometheus.CounterVec
+	anomaly   *prometheus.CounterVec
+	selfHeal  *prometheus.CounterVec
+
+	// the http listener serves /metrics and the handlers of the default mux, e.g. /stat
+	handler http.Handler
+	srvMu   sync.Mutex
+	addr    string
+	srv     *http.Server
+}
+
+func (m *filenodeMetric) Init(a *app.App) (err error) {
+	m.registry = app.MustComponent[metric.Metric](a).Registry()
+	m.addr = a.MustComponent("config").(configSource).GetMetricAddr()
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+	mux.Handle("/", http.DefaultServeMux)
+	m.handler = mux
+	m.lockWait = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "index",
+		Name:      "lock_wait_seconds",
+		Help:      "time spent waiting for the key lock",
+		Buckets:   []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30},
+	})
+	m.lockHold = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "index",
+		Name:      "lock_hold_seconds",
+		Help:      "time the key lock is held",
+		Buckets:   []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300},
+	})
+	m.indexOp = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "index",
+		Name:      "op_duration_seconds",
+		Help:      "index operation duration",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"op"})
+	m.s3Request = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "s3",
+		Name:      "request_duration_seconds",
+		Help:      "s3 request duration including the limiter wait",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"op"})
+	m.s3Wait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "s3",
+		Name:      "limiter_wait_seconds",
+		Help:      "time spent waiting for the s3 limiter",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"op"})
+	m.persist = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "persist",
+		Name:      "keys_total",
+		Help:      "count of handled keys by the persist result",
+	}, []string{"result"})
+	m.bloom = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "index",
+		Name:      "bloom_checks_total",
+		Help:      "count of the bloom filter checks by the result, miss means the false positive",
+	}, []string{"result"})
+	m.aclCache = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "acl",
+		Name:      "cache_lookups_total",
+		Help:      "count of the acl cache lookups by the result, stale means the acl head was changed",
+	}, []string{"result"})
+	m.anomaly = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "index",
+		Name:      "anomalies_total",
+		Help:      "count of the detected refcount anomalies by the reason",
+	}, []string{"reason"})
+	m.selfHeal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "index",
+		Name:      "self_heal_checks_total",
+		Help:      "count of the groups checked by the self-heal by the result",
+	}, []string{"result"})
+	for _, c := range []prometheus.Collector{m.lockWait, m.lockHold, m.indexOp, m.s3Request, m.s3Wait, m.persist, m.bloom, m.aclCache, m.anomaly, m.selfHeal} {
+		if err = m.registry.Register(c); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (m *filenodeMetric) Name() (name string) {
+	return CName
+}
+
+func (m *filenodeMetric) Run(ctx context.Context) (err error) {
+	m.srvMu.Lock()
+	defer m.srvMu.Unlock()
+	if m.addr == "" {
+		return
+	}
+	return m.listen(m.addr)
+}
+
+// ReloadConfig restarts the listener on the new metric.addr, the previous listener is closed after the new one is started
+func (m *filenodeMetric) ReloadConfig(conf any) (err error) {
+	addr := conf.(configSource).GetMetricAddr()
+	m.srvMu.Lock()
+	defer m.srvMu.Unlock()
+	if addr == m.addr {
+		return
+	}
+	if addr == "" {
+		m.shutdown()
+	} else if err = m.listen(addr); err != nil {
+		return
+	}
+	log.Info("metric listener restarted", zap.String("addr", addr), zap.String("prevAddr", m.addr))
+	m.addr = addr
+	return
+}
+
+// listen starts the listener on the addr and replaces the current one, the caller must hold srvMu
+func (m *filenodeMetric) listen(addr string) (err error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return
+	}
+	srv := &http.Server{Handler: m.handler}
+	go func() {
+		if sErr := srv.Serve(ln); sErr != nil && !errors.Is(sErr, http.ErrServerClosed) {
+			log.Warn("metric listener error", zap.String("addr", addr), zap.Error(sErr))
+		}
+	}()
+	m.shutdown()
+	m.srv = srv
+	return
+}
+
+// shutdown closes the current listener waiting for the scrapes in progress, the caller must hold srvMu
+func (m *filenodeMetric) shutdown() {
+	if m.srv == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	if err := m.srv.Shutdown(ctx); err != nil {
+		log.Warn("metric listener shutdown error", zap.Error(err))
+	}
+	m.srv = nil
+}
+
+func (m *filenodeMetric) Close(ctx context.Context) (err error) {
+	m.srvMu.Lock()
+	defer m.srvMu.Unlock()
+	m.shutdown()
+	return
+}
+
+func (m *filenodeMetric) LockWait(d time.Duration) {
+	m.lockWait.Observe(d.Seconds())
+}
+
+func (m *filenodeMetric) LockHold(d time.Duration) {
+	m.lockHold.Observe(d.Seconds())
+}
+
+func (m *filenodeMetric) IndexOp(op string, d time.Duration) {
+	m.indexOp.WithLabelValues(op).Observe(d.Seconds())
+}
+
+func (m *filenodeMetric) S3Request(op string, total, wait time.Duration) {
+	m.s3Request.WithLabelValues(op).Observe(total.Seconds())
+	m.s3Wait.WithLabelValues(op).Observe(wait.Seconds())
+}
+
+func (m *filenodeMetric) Persist(result string, count int) {
+	if count > 0 {
+		m.persist.WithLabelValues(result).Add(float64(count))
+	}
+}
+
+func (m *filenodeMetric) BloomCheck(result string) {
+	m.bloom.WithLabelValues(result).Inc()
+}
+
+func (m *filenodeMetric) AclCache(result string) {
+	m.aclCache.WithLabelValues(result).Inc()
+}
+
+func (m *filenodeMetric) Anomaly(reason string) {
+	m.anomaly.WithLabelValues(reason).Inc()
+}
+
+func (m *filenodeMetric) SelfHeal(result string) {
+	m.selfHeal.WithLabelValues(result).Inc()
+}
+
+func (m *filenodeMetric) RegisterGaugeFunc(subsystem, name, help string, f func() float64) error {
+	return m.registry.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      name,
+		Help:      help,
+	}, f))
+}
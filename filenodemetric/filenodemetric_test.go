@@ -0,0 +1,123 @@
+package filenodemetric
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/anyproto/any-sync/app"
+	"github.com/anyproto/any-sync/metric"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+func TestFilenodeMetric(t *testing.T) {
+	a := new(app.App)
+	m := metric.New()
+	fm := New()
+	a.Register(config{}).Register(m).Register(fm)
+	require.NoError(t, a.Start(ctx))
+	defer func() {
+		require.NoError(t, a.Close(ctx))
+	}()
+
+	fm.LockWait(time.Millisecond)
+	fm.LockHold(time.Second)
+	fm.IndexOp("fileBind", time.Millisecond)
+	fm.S3Request("get", time.Second, time.Millisecond)
+	fm.Persist("moved", 2)
+	fm.BloomCheck("miss")
+	fm.AclCache("hit")
+	fm.Anomaly("cidZeroRef")
+	fm.SelfHeal("fixed")
+	require.NoError(t, fm.RegisterGaugeFunc("index", "test", "test gauge", func() float64 { return 42 }))
+
+	families, err := m.Registry().Gather()
+	require.NoError(t, err)
+	var names = make(map[string]float64)
+	for _, f := range families {
+		for _, mtr := range f.GetMetric() {
+			switch {
+			case mtr.GetGauge() != nil:
+				names[f.GetName()] = mtr.GetGauge().GetValue()
+			case mtr.GetCounter() != nil:
+				names[f.GetName()] = mtr.GetCounter().GetValue()
+			case mtr.GetHistogram() != nil:
+				names[f.GetName()] = float64(mtr.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	assert.Equal(t, float64(1), names["filenode_index_lock_wait_seconds"])
+	assert.Equal(t, float64(1), names["filenode_index_lock_hold_seconds"])
+	assert.Equal(t, float64(1), names["filenode_index_op_duration_seconds"])
+	assert.Equal(t, float64(1), names["filenode_s3_request_duration_seconds"])
+	assert.Equal(t, float64(1), names["filenode_s3_limiter_wait_seconds"])
+	assert.Equal(t, float64(2), names["filenode_persist_keys_total"])
+	assert.Equal(t, float64(1), names["filenode_index_bloom_checks_total"])
+	assert.Equal(t, float64(1), names["filenode_acl_cache_lookups_total"])
+	assert.Equal(t, float64(1), names["filenode_index_anomalies_total"])
+	assert.Equal(t, float64(1), names["filenode_index_self_heal_checks_total"])
+	assert.Equal(t, float64(42), names["filenode_index_test"])
+}
+
+func TestFilenodeMetric_ReloadConfig(t *testing.T) {
+	freeAddr := func() string {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer ln.Close()
+		return ln.Addr().String()
+	}
+	scrape := func(addr string) error {
+		resp, err := http.Get("http://" + addr + "/metrics")
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+		}
+		return nil
+	}
+
+	a := new(app.App)
+	fm := New()
+	addr := freeAddr()
+	a.Register(config{addr: addr}).Register(metric.New()).Register(fm)
+	require.NoError(t, a.Start(ctx))
+	defer func() {
+		require.NoError(t, a.Close(ctx))
+	}()
+	require.NoError(t, scrape(addr))
+
+	newAddr := freeAddr()
+	require.NoError(t, fm.(*filenodeMetric).ReloadConfig(config{addr: newAddr}))
+	require.NoError(t, scrape(newAddr))
+	assert.Error(t, scrape(addr))
+
+	// the busy address keeps the current listener
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	require.Error(t, fm.(*filenodeMetric).ReloadConfig(config{addr: ln.Addr().String()}))
+	require.NoError(t, scrape(newAddr))
+}
+
+type config struct {
+	addr string
+}
+
+func (c config) Init(a *app.App) error { return nil }
+func (c config) Name() string          { return "config" }
+
+func (c config) GetMetric() metric.Config {
+	return metric.Config{}
+}
+
+func (c config) GetMetricAddr() string {
+	return c.addr
+}
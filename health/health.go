@@ -0,0 +1,189 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/anyproto/any-sync/app"
+	"github.com/anyproto/any-sync/app/logger"
+	"github.com/anyproto/any-sync/commonfile/fileblockstore"
+	"github.com/anyproto/any-sync/nodeconf"
+	"go.uber.org/zap"
+
+	"github.com/anyproto/any-sync-filenode/index"
+	"github.com/anyproto/any-sync-filenode/redisprovider"
+)
+
+const CName = "filenode.health"
+
+const checkTimeout = time.Second * 5
+
+var log = logger.NewNamed(CName)
+
+var errNotSupported = errors.New("not supported")
+
+// CheckFunc returns nil when the dependency is usable
+type CheckFunc func(ctx context.Context) error
+
+type CheckResult struct {
+	Name     string `json:"name"`
+	Ok       bool   `json:"ok"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+type Report struct {
+	Ok     bool          `json:"ok"`
+	Checks []CheckResult `json:"checks,omitempty"`
+}
+
+func New() Health {
+	return new(health)
+}
+
+// Health serves the /health/live and /health/ready endpoints
+type Health interface {
+	// AddCheck adds the readiness check, it must be called before Run
+	AddCheck(name string, check CheckFunc)
+	// Ready runs all readiness checks in parallel
+	Ready(ctx context.Context) Report
+	app.ComponentRunnable
+}
+
+type storeChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+type subscriptionChecker interface {
+	SubscriptionCheck(ctx context.Context) error
+}
+
+type namedCheck struct {
+	name  string
+	check CheckFunc
+}
+
+type health struct {
+	checks []namedCheck
+}
+
+func (h *health) Init(a *app.App) (err error) {
+	redis := app.MustComponent[redisprovider.RedisProvider](a)
+	h.AddCheck("redis", redis.Health)
+	h.AddCheck("redis_bloom", func(ctx context.Context) error {
+		// the command fails if the module is not loaded
+		return redis.Redis().BFExists(ctx, "_health_bf", "_").Err()
+	})
+
+	h.AddCheck("s3", func(ctx context.Context) error {
+		if sc, ok := a.MustComponent(fileblockstore.CName).(storeChecker); ok {
+			return sc.HealthCheck(ctx)
+		}
+		return errNotSupported
+	})
+
+	idx := app.MustComponent[index.Index](a)
+	h.AddCheck("pubsub", func(ctx context.Context) error {
+		if sc, ok := idx.(subscriptionChecker); ok {
+			return sc.SubscriptionCheck(ctx)
+		}
+		return errNotSupported
+	})
+
+	nodeConf := app.MustComponent[nodeconf.Service](a)
+	h.AddCheck("coordinator", func(ctx context.Context) error {
+		return nodeConfStatusErr(nodeConf.NetworkCompatibilityStatus())
+	})
+	return
+}
+
+func (h *health) Name() (name string) {
+	return CName
+}
+
+func (h *health) AddCheck(name string, check CheckFunc) {
+	h.checks = append(h.checks, namedCheck{name: name, check: check})
+}
+
+func (h *health) Run(ctx context.Context) (err error) {
+	http.HandleFunc("/health/live", func(writer http.ResponseWriter, request *http.Request) {
+		writeReport(writer, Report{Ok: true})
+	})
+	http.HandleFunc("/health/ready", func(writer http.ResponseWriter, request *http.Request) {
+		report := h.Ready(request.Context())
+		if !report.Ok {
+			log.Warn("node is not ready", zap.Any("checks", report.Checks))
+		}
+		writeReport(writer, report)
+	})
+	return
+}
+
+func (h *health) Ready(ctx context.Context) Report {
+	return runChecks(ctx, h.checks)
+}
+
+func (h *health) Close(ctx context.Context) (err error) {
+	return
+}
+
+func runChecks(ctx context.Context, checks []namedCheck) (report Report) {
+	report.Checks = make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cctx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+			st := time.Now()
+			res := CheckResult{Name: c.name, Ok: true}
+			err := c.check(cctx)
+			if err != nil {
+				res.Error = err.Error()
+				// the optional checks don't affect the readiness
+				res.Ok = errors.Is(err, errNotSupported)
+			}
+			res.Duration = time.Since(st).String()
+			report.Checks[i] = res
+		}()
+	}
+	wg.Wait()
+	report.Ok = true
+	for _, res := range report.Checks {
+		if !res.Ok {
+			report.Ok = false
+		}
+	}
+	return
+}
+
+func nodeConfStatusErr(status nodeconf.NetworkCompatibilityStatus) error {
+	switch status {
+	case nodeconf.NetworkCompatibilityStatusOk, nodeconf.NetworkCompatibilityStatusNeedsUpdate:
+		return nil
+	case nodeconf.NetworkCompatibilityStatusUnknown:
+		return errors.New("network configuration is not checked yet")
+	case nodeconf.NetworkCompatibilityStatusIncompatible:
+		return errors.New("network is incompatible")
+	default:
+		return fmt.Errorf("coordinator is unreachable: status %d", status)
+	}
+}
+
+func writeReport(writer http.ResponseWriter, report Report) {
+	writer.Header().Set("Content-Type", "application/json")
+	if report.Ok {
+		writer.WriteHeader(http.StatusOK)
+	} else {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(writer).Encode(report); err != nil {
+		log.Warn("can't write health report", zap.Error(err))
+	}
+}
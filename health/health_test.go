@@ -0,0 +1,68 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anyproto/any-sync/nodeconf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+func TestRunChecks(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	t.Run("ready", func(t *testing.T) {
+		report := runChecks(ctx, []namedCheck{
+			{name: "a", check: ok},
+			{name: "b", check: func(ctx context.Context) error { return errNotSupported }},
+		})
+		assert.True(t, report.Ok)
+		require.Len(t, report.Checks, 2)
+		assert.Equal(t, "a", report.Checks[0].Name)
+		assert.Equal(t, errNotSupported.Error(), report.Checks[1].Error)
+	})
+	t.Run("not ready", func(t *testing.T) {
+		report := runChecks(ctx, []namedCheck{
+			{name: "a", check: ok},
+			{name: "b", check: func(ctx context.Context) error { return errors.New("b failed") }},
+		})
+		assert.False(t, report.Ok)
+		assert.True(t, report.Checks[0].Ok)
+		assert.False(t, report.Checks[1].Ok)
+		assert.Equal(t, "b failed", report.Checks[1].Error)
+	})
+	t.Run("timeout", func(t *testing.T) {
+		st := time.Now()
+		report := runChecks(ctx, []namedCheck{
+			{name: "slow", check: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			}},
+		})
+		assert.False(t, report.Ok)
+		assert.Less(t, time.Since(st), checkTimeout*2)
+	})
+}
+
+func TestWriteReport(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeReport(rec, Report{Ok: false, Checks: []CheckResult{{Name: "redis", Error: "down"}}})
+	assert.Equal(t, 503, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"error":"down"`)
+
+	rec = httptest.NewRecorder()
+	writeReport(rec, Report{Ok: true})
+	assert.Equal(t, 200, rec.Code)
+}
+
+func TestNodeConfStatusErr(t *testing.T) {
+	assert.NoError(t, nodeConfStatusErr(nodeconf.NetworkCompatibilityStatusOk))
+	assert.NoError(t, nodeConfStatusErr(nodeconf.NetworkCompatibilityStatusNeedsUpdate))
+	assert.Error(t, nodeConfStatusErr(nodeconf.NetworkCompatibilityStatusUnknown))
+	assert.Error(t, nodeConfStatusErr(nodeconf.NetworkCompatibilityStatusError))
+}
@@ -0,0 +1,158 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/anyproto/any-sync/util/periodicsync"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const (
+	anomalyQueueKey = "anomalyQueue.{system}"
+
+	defaultSelfHealIntervalSec = 10
+	defaultSelfHealBatchSize   = 10
+
+	// anomalyCheckDelay postpones the check, so the operation that found the anomaly releases the locks
+	// and the anomalies found by the same operation are fixed with one check
+	anomalyCheckDelay = time.Second * 5
+	// anomalyRetryDelay postpones the next check of the group which check is failed
+	anomalyRetryDelay = time.Minute
+	// anomalyMaxDelay limits the backoff of the group that is reported again and again
+	anomalyMaxDelay = time.Hour
+	// anomalyBackoffReset is the time after the last check when the backoff of the group is dropped
+	anomalyBackoffReset = time.Hour * 6
+
+	checkSourceSelfHeal = "selfHeal"
+)
+
+// anomaly reasons
+const (
+	anomalyCidZeroRef      = "cidZeroRef"
+	anomalyEntryUnderflow  = "entryUnderflow"
+	anomalyScriptUnderflow = "scriptUnderflow"
+)
+
+// anomalyBackoffKey counts the recent self-heal checks of the group
+func anomalyBackoffKey(groupId string) string {
+	return "anomalyBackoff:" + groupId + ".{system}"
+}
+
+// reportAnomaly queues the group for the self-heal check, the group already in the queue keeps its check time
+func (ri *redisIndex) reportAnomaly(ctx context.Context, key Key, reason string) {
+	ri.metric.Anomaly(reason)
+	delay := ri.anomalyDelay(ctx, key.GroupId, anomalyCheckDelay)
+	if err := ri.queueAnomaly(ctx, key.GroupId, delay); err != nil {
+		log.WarnCtx(ctx, "can't queue the anomaly", zap.String("groupId", key.GroupId), zap.String("reason", reason), zap.Error(err))
+		return
+	}
+	log.InfoCtx(ctx, "anomaly is queued for the self-heal", zap.String("groupId", key.GroupId), zap.String("spaceId", key.SpaceId), zap.String("reason", reason), zap.Duration("delay", delay))
+}
+
+// anomalyDelay doubles the delay for every recent check of the group, so the group that the check can't fix
+// or that gets the anomalies again right after the check isn't checked over and over
+func (ri *redisIndex) anomalyDelay(ctx context.Context, groupId string, delay time.Duration) time.Duration {
+	checks, err := ri.cl.Get(ctx, anomalyBackoffKey(groupId)).Int()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		log.WarnCtx(ctx, "can't get the anomaly backoff", zap.String("groupId", groupId), zap.Error(err))
+	}
+	for range checks {
+		if delay *= 2; delay >= anomalyMaxDelay {
+			return anomalyMaxDelay
+		}
+	}
+	return delay
+}
+
+// countAnomalyCheck increments the count of the recent checks of the group
+func (ri *redisIndex) countAnomalyCheck(ctx context.Context, groupId string) error {
+	key := anomalyBackoffKey(groupId)
+	_, err := ri.cl.TxPipelined(ctx, func(tx redis.Pipeliner) error {
+		tx.Incr(ctx, key)
+		tx.Expire(ctx, key, anomalyBackoffReset)
+		return nil
+	})
+	return err
+}
+
+func (ri *redisIndex) queueAnomaly(ctx context.Context, groupId string, delay time.Duration) error {
+	return ri.cl.ZAddNX(ctx, anomalyQueueKey, redis.Z{
+		Score:  float64(time.Now().Add(delay).UnixMilli()),
+		Member: groupId,
+	}).Err()
+}
+
+func (ri *redisIndex) runSelfHeal() {
+	if ri.selfHealConf.Disabled {
+		return
+	}
+	interval := int(ri.selfHealConf.IntervalSec)
+	if interval == 0 {
+		interval = defaultSelfHealIntervalSec
+	}
+	ri.selfHealTicker = periodicsync.NewPeriodicSync(interval, time.Minute*10, ri.selfHealPeriodic, log)
+	ri.selfHealTicker.Run()
+}
+
+// selfHealPeriodic checks and fixes the queued groups which check time has come, at most BatchSize groups per call
+func (ri *redisIndex) selfHealPeriodic(ctx context.Context) (err error) {
+	// the fixes are writes, the queue waits for the end of the maintenance
+	if ri.InMaintenance() {
+		return
+	}
+	batchSize := int64(ri.selfHealConf.BatchSize)
+	if batchSize == 0 {
+		batchSize = defaultSelfHealBatchSize
+	}
+	groupIds, err := ri.cl.ZRangeByScore(ctx, anomalyQueueKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(time.Now().UnixMilli(), 10),
+		Count: batchSize,
+	}).Result()
+	if err != nil {
+		return
+	}
+	for _, groupId := range groupIds {
+		// the instance that removes the group from the queue checks it
+		removed, rErr := ri.cl.ZRem(ctx, anomalyQueueKey, groupId).Result()
+		if rErr != nil {
+			return rErr
+		}
+		if removed == 0 {
+			continue
+		}
+		ri.selfHeal(ctx, groupId)
+	}
+	return
+}
+
+func (ri *redisIndex) selfHeal(ctx context.Context, groupId string) {
+	st := time.Now()
+	if err := ri.countAnomalyCheck(ctx, groupId); err != nil {
+		log.Warn("self-heal: can't count the check", zap.String("groupId", groupId), zap.Error(err))
+	}
+	fixed, err := ri.Check(context.WithValue(ctx, ctxCheckSource, checkSourceSelfHeal), Key{GroupId: groupId}, true)
+	if err != nil {
+		ri.metric.SelfHeal("error")
+		log.Warn("self-heal: check error", zap.String("groupId", groupId), zap.Error(err))
+		if qErr := ri.queueAnomaly(ctx, groupId, ri.anomalyDelay(ctx, groupId, anomalyRetryDelay)); qErr != nil {
+			log.Warn("self-heal: can't queue the group again", zap.String("groupId", groupId), zap.Error(qErr))
+		}
+		return
+	}
+	if len(fixed) == 0 {
+		ri.metric.SelfHeal("clean")
+		log.Info("self-heal: nothing to fix", zap.String("groupId", groupId), zap.Duration("dur", time.Since(st)))
+		return
+	}
+	ri.metric.SelfHeal("fixed")
+	kinds := make([]string, len(fixed))
+	for i, res := range fixed {
+		kinds[i] = string(res.Kind)
+	}
+	log.Info("self-heal: group is fixed", zap.String("groupId", groupId), zap.Strings("kinds", kinds), zap.Duration("dur", time.Since(st)))
+}
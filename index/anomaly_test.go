@@ -0,0 +1,106 @@
+package index
+
+import (
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyproto/any-sync-filenode/config"
+	"github.com/anyproto/any-sync-filenode/testutil"
+)
+
+func TestRedisIndex_SelfHeal(t *testing.T) {
+	// the periodic self-heal is disabled, so the queue is processed only by the test
+	fx := newFixtureConfig(t, &config.Config{DefaultLimit: 1024, PersistTtl: 3600, SelfHeal: config.SelfHeal{Disabled: true}})
+	defer fx.Finish(t)
+
+	key := newRandKey()
+	bs := testutil.NewRandBlocks(4)
+	require.NoError(t, fx.BlocksAdd(ctx, bs))
+	fileId1 := testutil.NewRandCid().String()
+	fileId2 := testutil.NewRandCid().String()
+
+	cids, err := fx.CidEntriesByBlocks(ctx, bs[:2])
+	require.NoError(t, err)
+	require.NoError(t, fx.FileBind(ctx, key, fileId1, cids))
+	cids.Release()
+	cids, err = fx.CidEntriesByBlocks(ctx, bs[2:])
+	require.NoError(t, err)
+	require.NoError(t, fx.FileBind(ctx, key, fileId2, cids))
+	cids.Release()
+
+	t.Run("queue on 0-ref", func(t *testing.T) {
+		cids, err := fx.CidEntriesByBlocks(ctx, bs[:1])
+		require.NoError(t, err)
+		cids.entries[0].Refs = 0
+		require.NoError(t, cids.entries[0].Save(ctx, fx.cl))
+		cids.Release()
+
+		require.NoError(t, fx.FileUnbind(ctx, key, fileId1))
+
+		score, err := fx.cl.ZScore(ctx, anomalyQueueKey, key.GroupId).Result()
+		require.NoError(t, err)
+		assert.Greater(t, score, float64(time.Now().UnixMilli()))
+
+		// the check time isn't in the past, the group is kept in the queue
+		require.NoError(t, fx.selfHealPeriodic(ctx))
+		require.NoError(t, fx.cl.ZScore(ctx, anomalyQueueKey, key.GroupId).Err())
+		require.NoError(t, fx.cl.Del(ctx, anomalyQueueKey).Err())
+	})
+	t.Run("heal", func(t *testing.T) {
+		require.NoError(t, fx.cl.HSet(ctx, SpaceKey(key), CidKey(bs[2].Cid()), 33).Err())
+		require.NoError(t, fx.cl.ZAdd(ctx, anomalyQueueKey, redis.Z{Score: 0, Member: key.GroupId}).Err())
+
+		require.NoError(t, fx.selfHealPeriodic(ctx))
+
+		assert.ErrorIs(t, fx.cl.ZScore(ctx, anomalyQueueKey, key.GroupId).Err(), redis.Nil)
+
+		res, err := fx.Check(ctx, key, false)
+		require.NoError(t, err)
+		assert.Len(t, res, 0)
+
+		records, err := fx.CheckAudit(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		assert.Equal(t, CheckSpaceCidRefDrift, records[0].Kind)
+		assert.Equal(t, checkSourceSelfHeal, records[0].Source)
+	})
+	t.Run("maintenance", func(t *testing.T) {
+		require.NoError(t, fx.cl.ZAdd(ctx, anomalyQueueKey, redis.Z{Score: 0, Member: key.GroupId}).Err())
+		fx.maintenance.Store(true)
+		defer fx.maintenance.Store(false)
+
+		require.NoError(t, fx.selfHealPeriodic(ctx))
+		require.NoError(t, fx.cl.ZScore(ctx, anomalyQueueKey, key.GroupId).Err())
+		require.NoError(t, fx.cl.Del(ctx, anomalyQueueKey).Err())
+	})
+	t.Run("backoff", func(t *testing.T) {
+		// the group is checked by the heal subtest
+		require.NoError(t, fx.cl.Set(ctx, anomalyBackoffKey(key.GroupId), 2, anomalyBackoffReset).Err())
+		assert.Equal(t, anomalyCheckDelay*4, fx.anomalyDelay(ctx, key.GroupId, anomalyCheckDelay))
+
+		fx.reportAnomaly(ctx, key, anomalyCidZeroRef)
+		score, err := fx.cl.ZScore(ctx, anomalyQueueKey, key.GroupId).Result()
+		require.NoError(t, err)
+		assert.Greater(t, score, float64(time.Now().Add(anomalyCheckDelay*3).UnixMilli()))
+		require.NoError(t, fx.cl.Del(ctx, anomalyQueueKey).Err())
+
+		require.NoError(t, fx.cl.Set(ctx, anomalyBackoffKey(key.GroupId), 100, anomalyBackoffReset).Err())
+		assert.Equal(t, anomalyMaxDelay, fx.anomalyDelay(ctx, key.GroupId, anomalyCheckDelay))
+		require.NoError(t, fx.cl.Del(ctx, anomalyBackoffKey(key.GroupId)).Err())
+		assert.Equal(t, anomalyCheckDelay, fx.anomalyDelay(ctx, key.GroupId, anomalyCheckDelay))
+	})
+	t.Run("logical size before backfill", func(t *testing.T) {
+		fx.logicalSizeBackfilled.Store(false)
+		assert.Equal(t, uint64(1), fx.decrLogicalSize(ctx, "group logical", 1, 2, key))
+		assert.ErrorIs(t, fx.cl.ZScore(ctx, anomalyQueueKey, key.GroupId).Err(), redis.Nil)
+
+		fx.logicalSizeBackfilled.Store(true)
+		assert.Equal(t, uint64(1), fx.decrLogicalSize(ctx, "group logical", 1, 2, key))
+		require.NoError(t, fx.cl.ZScore(ctx, anomalyQueueKey, key.GroupId).Err())
+		require.NoError(t, fx.cl.Del(ctx, anomalyQueueKey).Err())
+	})
+}
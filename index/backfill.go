@@ -0,0 +1,214 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-redsync/redsync/v4"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/anyproto/any-sync-filenode/index/indexproto"
+)
+
+const (
+	// logicalSizeBackfillKey keeps the time of the finished backfill
+	logicalSizeBackfillKey = "logicalSizeBackfill.{system}"
+	// logicalSizeBackfillPendingKey keeps the ids of the persisted groups which are backfilled on restore
+	logicalSizeBackfillPendingKey = "logicalSizeBackfillPending.{system}"
+)
+
+// backfillLogicalSizePeriodic runs the one-time calculation of the logical sizes of the groups and spaces created
+// before the logical size was tracked. The pass runs on one instance, the others wait for the finish mark.
+func (ri *redisIndex) backfillLogicalSizePeriodic(ctx context.Context) (err error) {
+	if ri.logicalSizeBackfilled.Load() {
+		return
+	}
+	done, err := ri.cl.Exists(ctx, logicalSizeBackfillKey).Result()
+	if err != nil {
+		return
+	}
+	if done > 0 {
+		ri.logicalSizeBackfilled.Store(true)
+		return
+	}
+	mu := ri.redsync.NewMutex(lockKeyPrefix+"logicalSizeBackfill", redsync.WithExpiry(time.Hour), redsync.WithGenValueFunc(ri.lockValueFunc("logicalSizeBackfill")))
+	if err = mu.TryLockContext(ctx); err != nil {
+		var errTaken *redsync.ErrTaken
+		if errors.As(err, &errTaken) || errors.Is(err, redsync.ErrFailed) {
+			// another node is running the backfill
+			return nil
+		}
+		return
+	}
+	defer func() {
+		_, _ = mu.Unlock()
+	}()
+	return ri.backfillLogicalSize(ctx, func() error {
+		_, eErr := mu.ExtendContext(ctx)
+		return eErr
+	})
+}
+
+// backfillLogicalSize calculates the logical sizes of the live groups, extend is called after every batch of keys.
+// The persisted groups aren't restored by the pass, they are marked as pending and backfilled when restored.
+func (ri *redisIndex) backfillLogicalSize(ctx context.Context, extend func() error) (err error) {
+	st := time.Now()
+	var groups, pending int
+	// the pending groups are marked first, so the group persisted during the live pass is not missed
+	if err = ri.persistStore.IndexList(ctx, func(keys []string) error {
+		var groupIds []any
+		for _, k := range keys {
+			if strings.HasPrefix(k, "g:") {
+				groupIds = append(groupIds, groupIdFromKey(k))
+			}
+		}
+		if len(groupIds) != 0 {
+			if aErr := ri.cl.SAdd(ctx, logicalSizeBackfillPendingKey, groupIds...).Err(); aErr != nil {
+				return aErr
+			}
+			pending += len(groupIds)
+		}
+		return extend()
+	}); err != nil {
+		return
+	}
+	for part := range partitionCount {
+		if _, err = ri.scanLiveKeys(ctx, part, func(keys []string) error {
+			for _, k := range keys {
+				if !strings.HasPrefix(k, "g:") {
+					continue
+				}
+				groupId := groupIdFromKey(k)
+				if bErr := ri.backfillGroupLogicalSize(ctx, groupId); bErr != nil {
+					return bErr
+				}
+				// the live group is also listed when it was persisted before, it's done now
+				if rErr := ri.cl.SRem(ctx, logicalSizeBackfillPendingKey, groupId).Err(); rErr != nil {
+					return rErr
+				}
+				groups++
+			}
+			return extend()
+		}); err != nil {
+			return
+		}
+	}
+	if err = ri.cl.Set(ctx, logicalSizeBackfillKey, time.Now().Unix(), 0).Err(); err != nil {
+		return
+	}
+	ri.logicalSizeBackfilled.Store(true)
+	log.Info("logical sizes backfilled", zap.Int("groups", groups), zap.Int("pending", pending), zap.Duration("dur", time.Since(st)))
+	return
+}
+
+// backfillRestoredGroup backfills the logical size of the restored group in the background when the group is pending.
+// It runs outside the restore because the caller may hold the group lock.
+func (ri *redisIndex) backfillRestoredGroup(groupKey string) {
+	go func() {
+		groupId := groupIdFromKey(groupKey)
+		removed, err := ri.cl.SRem(ri.ctx, logicalSizeBackfillPendingKey, groupId).Result()
+		if err != nil {
+			log.Warn("can't remove the pending backfill group", zap.String("groupId", groupId), zap.Error(err))
+			return
+		}
+		if removed == 0 {
+			return
+		}
+		if err = ri.backfillGroupLogicalSize(ri.ctx, groupId); err != nil {
+			log.Warn("restored group logical size backfill error", zap.String("groupId", groupId), zap.Error(err))
+			// leave the group pending for the next restore
+			_ = ri.cl.SAdd(ri.ctx, logicalSizeBackfillPendingKey, groupId).Err()
+		}
+	}()
+}
+
+func groupIdFromKey(groupKey string) string {
+	return groupKey[2:strings.LastIndex(groupKey, ".{")]
+}
+
+// backfillGroupLogicalSize sets the logical sizes of the group and its spaces to the sum of the file sizes
+// and applies the group difference to the node-wide counter
+func (ri *redisIndex) backfillGroupLogicalSize(ctx context.Context, groupId string) (err error) {
+	key := Key{GroupId: groupId}
+	// the writes to the spaces take the group lock first, so the sizes don't change during the calculation
+	gExists, gRelease, err := ri.AcquireKey(ctx, "logicalSizeBackfill", GroupKey(key))
+	if err != nil {
+		return
+	}
+	defer gRelease()
+	if !gExists {
+		return
+	}
+	gEntry, err := ri.getGroupEntry(ctx, key)
+	if err != nil {
+		return
+	}
+	var groupLogicalSize uint64
+	for _, spaceId := range gEntry.SpaceIds {
+		var spaceLogicalSize uint64
+		if spaceLogicalSize, err = ri.backfillSpaceLogicalSize(ctx, Key{GroupId: groupId, SpaceId: spaceId}); err != nil {
+			return
+		}
+		groupLogicalSize += spaceLogicalSize
+	}
+	if gEntry.LogicalSize == groupLogicalSize {
+		return
+	}
+	delta := int64(groupLogicalSize) - int64(gEntry.LogicalSize)
+	gEntry.LogicalSize = groupLogicalSize
+	gEntry.Save(ctx, ri.cl)
+	return ri.incrFileSizeSum(ctx, delta)
+}
+
+func (ri *redisIndex) backfillSpaceLogicalSize(ctx context.Context, key Key) (logicalSize uint64, err error) {
+	sExists, sRelease, err := ri.AcquireKey(ctx, "logicalSizeBackfill", SpaceKey(key))
+	if err != nil {
+		return
+	}
+	defer sRelease()
+	if !sExists {
+		return
+	}
+	sEntry, err := ri.getSpaceEntry(ctx, key)
+	if err != nil {
+		return
+	}
+	if logicalSize, err = ri.spaceFilesSize(ctx, key); err != nil {
+		return
+	}
+	if sEntry.LogicalSize != logicalSize {
+		sEntry.LogicalSize = logicalSize
+		_, err = ri.cl.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			sEntry.Save(ctx, key, pipe)
+			return nil
+		})
+	}
+	return
+}
+
+// spaceFilesSize returns the sum of the file sizes of the space
+func (ri *redisIndex) spaceFilesSize(ctx context.Context, key Key) (size uint64, err error) {
+	sk := SpaceKey(key)
+	var cursor uint64
+	for {
+		var res []string
+		if res, cursor, err = ri.cl.HScan(ctx, sk, cursor, "f:*", 1000).Result(); err != nil {
+			return
+		}
+		// the result contains fields and values
+		for i := 1; i < len(res); i += 2 {
+			fileEntryProto := &indexproto.FileEntry{}
+			if uErr := fileEntryProto.UnmarshalVT([]byte(res[i])); uErr != nil {
+				log.WarnCtx(ctx, "can't unmarshal file entry", zap.String("spaceId", key.SpaceId), zap.String("fileId", res[i-1][2:]), zap.Error(uErr))
+				continue
+			}
+			size += fileEntryProto.Size
+		}
+		if cursor == 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package index
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/anyproto/any-sync-filenode/testutil"
+)
+
+func TestRedisIndex_BackfillLogicalSize(t *testing.T) {
+	fx := newFixture(t)
+	defer fx.Finish(t)
+
+	bs := testutil.NewRandBlocks(3)
+	var sumSize uint64
+	for _, b := range bs {
+		sumSize += uint64(len(b.RawData()))
+	}
+	require.NoError(t, fx.BlocksAdd(ctx, bs))
+	key := newRandKey()
+	key2 := Key{GroupId: key.GroupId, SpaceId: testutil.NewRandSpaceId()}
+	for _, k := range []Key{key, key2} {
+		cids, err := fx.CidEntriesByBlocks(ctx, bs)
+		require.NoError(t, err)
+		require.NoError(t, fx.FileBind(ctx, k, testutil.NewRandCid().String(), cids))
+		cids.Release()
+	}
+	before, err := fx.DedupInfo(ctx)
+	require.NoError(t, err)
+
+	// make the group look like created before the logical size tracking
+	for _, k := range []Key{key, key2} {
+		se, err := fx.getSpaceEntry(ctx, k)
+		require.NoError(t, err)
+		se.LogicalSize = 0
+		seData, _ := se.MarshalVT()
+		require.NoError(t, fx.cl.HSet(ctx, SpaceKey(k), infoKey, seData).Err())
+	}
+	ge, err := fx.getGroupEntry(ctx, key)
+	require.NoError(t, err)
+	ge.LogicalSize = 0
+	geData, _ := ge.MarshalVT()
+	require.NoError(t, fx.cl.HSet(ctx, GroupKey(key), infoKey, geData).Err())
+	require.NoError(t, fx.cl.DecrBy(ctx, fileSizeSumKey, int64(sumSize*2)).Err())
+
+	// the live group is listed as persisted too, the other group is persisted only
+	coldKey := newRandKey()
+	fx.persistStore.EXPECT().IndexList(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, f func(keys []string) error) error {
+		return f([]string{GroupKey(key), GroupKey(coldKey), CidKey(bs[0].Cid())})
+	})
+	fx.logicalSizeBackfilled.Store(false)
+	var extended int
+	require.NoError(t, fx.backfillLogicalSize(ctx, func() error {
+		extended++
+		return nil
+	}))
+	assert.True(t, fx.logicalSizeBackfilled.Load())
+	assert.NotZero(t, extended)
+	require.NoError(t, fx.cl.Get(ctx, logicalSizeBackfillKey).Err())
+	pending, err := fx.cl.SMembers(ctx, logicalSizeBackfillPendingKey).Result()
+	require.NoError(t, err)
+	assert.Equal(t, []string{coldKey.GroupId}, pending)
+
+	info, err := fx.GroupDedupInfo(ctx, key.GroupId)
+	require.NoError(t, err)
+	assert.Equal(t, sumSize*2, info.LogicalBytes)
+	for _, k := range []Key{key, key2} {
+		se, err := fx.getSpaceEntry(ctx, k)
+		require.NoError(t, err)
+		assert.Equal(t, sumSize, se.LogicalSize)
+	}
+	after, err := fx.DedupInfo(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, before.LogicalBytes, after.LogicalBytes)
+
+	// the finished backfill isn't started again
+	require.NoError(t, fx.backfillLogicalSizePeriodic(ctx))
+}
+
+func TestRedisIndex_BackfillRestoredGroup(t *testing.T) {
+	fx := newFixture(t)
+	defer fx.Finish(t)
+
+	bs := testutil.NewRandBlocks(3)
+	var sumSize uint64
+	for _, b := range bs {
+		sumSize += uint64(len(b.RawData()))
+	}
+	require.NoError(t, fx.BlocksAdd(ctx, bs))
+	key := newRandKey()
+	cids, err := fx.CidEntriesByBlocks(ctx, bs)
+	require.NoError(t, err)
+	require.NoError(t, fx.FileBind(ctx, key, testutil.NewRandCid().String(), cids))
+	cids.Release()
+
+	ge, err := fx.getGroupEntry(ctx, key)
+	require.NoError(t, err)
+	ge.LogicalSize = 0
+	geData, _ := ge.MarshalVT()
+	require.NoError(t, fx.cl.HSet(ctx, GroupKey(key), infoKey, geData).Err())
+
+	t.Run("not pending", func(t *testing.T) {
+		fx.backfillRestoredGroup(GroupKey(key))
+		time.Sleep(time.Millisecond * 100)
+		info, err := fx.GroupDedupInfo(ctx, key.GroupId)
+		require.NoError(t, err)
+		assert.Zero(t, info.LogicalBytes)
+	})
+	t.Run("pending", func(t *testing.T) {
+		require.NoError(t, fx.cl.SAdd(ctx, logicalSizeBackfillPendingKey, key.GroupId).Err())
+		fx.backfillRestoredGroup(GroupKey(key))
+		assert.Eventually(t, func() bool {
+			info, err := fx.GroupDedupInfo(ctx, key.GroupId)
+			return err == nil && info.LogicalBytes == sumSize
+		}, time.Second, time.Millisecond*10)
+		ex, err := fx.cl.Exists(ctx, logicalSizeBackfillPendingKey).Result()
+		require.NoError(t, err)
+		assert.Zero(t, ex)
+	})
+}
@@ -3,24 +3,42 @@ package index
 import (
 	"context"
 	"errors"
+	"time"
 
-	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/anyproto/any-sync-filenode/tracing"
 )
 
 func (ri *redisIndex) FileBind(ctx context.Context, key Key, fileId string, cids *CidEntries) (err error) {
-	entry, release, err := ri.AcquireSpace(ctx, key)
+	entry, release, err := ri.AcquireSpace(ctx, "fileBind", key)
 	if err != nil {
 		return
 	}
 	defer release()
 
-	return ri.fileBind(ctx, key, fileId, cids, entry)
+	return ri.fileBind(ctx, key, fileId, cids, entry, nil)
 }
 
-func (ri *redisIndex) fileBind(ctx context.Context, key Key, fileId string, cids *CidEntries, entry groupSpaceEntry) (err error) {
-	var gk = GroupKey(key)
-	var sk = SpaceKey(key)
+// FileBindWithMetadata works as FileBind and sets the client-supplied metadata of the file in the same write
+func (ri *redisIndex) FileBindWithMetadata(ctx context.Context, key Key, fileId string, cids *CidEntries, meta FileMetadata) (err error) {
+	entry, release, err := ri.AcquireSpace(ctx, "fileBindWithMetadata", key)
+	if err != nil {
+		return
+	}
+	defer release()
+
+	return ri.fileBind(ctx, key, fileId, cids, entry, &meta)
+}
+
+// fileBind binds the cids to the file, the metadata is replaced when meta isn't nil
+func (ri *redisIndex) fileBind(ctx context.Context, key Key, fileId string, cids *CidEntries, entry groupSpaceEntry, meta *FileMetadata) (err error) {
+	ctx, span := tracing.Start(ctx, "index.fileBind", tracing.SpaceId(key.SpaceId), tracing.GroupId(key.GroupId), tracing.CidCount(len(cids.entries)))
+	defer func(st time.Time) {
+		ri.metric.IndexOp("fileBind", time.Since(st))
+		tracing.End(span, err)
+	}(time.Now())
 
 	// get file entry
 	fileInfo, isNewFile, err := ri.getFileEntry(ctx, key, fileId)
@@ -28,7 +46,18 @@ func (ri *redisIndex) fileBind(ctx context.Context, key Key, fileId string, cids
 		return
 	}
 
-	isolatedSpace := entry.space.Limit != 0
+	prevSize := fileInfo.Size
+	var prevIndexMembers []string
+	if !isNewFile {
+		prevIndexMembers = filesIndexMembers(fileId, fileInfo.FileEntry)
+	}
+	var metadataChanged bool
+	if meta != nil {
+		if metaProto := meta.proto(); !proto.Equal(fileInfo.Metadata, metaProto) {
+			fileInfo.Metadata = metaProto
+			metadataChanged = true
+		}
+	}
 
 	// make a list of indexes of non-exists cids
 	var newFileCidIdx = make([]int, 0, len(cids.entries))
@@ -40,82 +69,39 @@ func (ri *redisIndex) fileBind(ctx context.Context, key Key, fileId string, cids
 		}
 	}
 
-	// all cids exists, nothing to do
 	if len(newFileCidIdx) == 0 {
-		return
+		// all cids exists, nothing to do
+		if !metadataChanged || isNewFile {
+			return
+		}
+		return ri.fileUpdateRefs(ctx, key, entry, fileId, fileInfo, fileInfo.AllCids(), prevSize, prevIndexMembers)
 	}
 
-	var affectedCidIdx = make([]int, 0, len(cids.entries))
-
-	// get all cids from space and group in one pipeline
-	var (
-		cidExistSpaceCmds = make([]*redis.BoolCmd, len(newFileCidIdx))
-		cidExistGroupCmds = make([]*redis.BoolCmd, len(newFileCidIdx))
-	)
-	_, err = ri.cl.Pipelined(ctx, func(pipe redis.Pipeliner) error {
-		for i, idx := range newFileCidIdx {
-			ck := CidKey(cids.entries[idx].Cid)
-			cidExistSpaceCmds[i] = pipe.HExists(ctx, sk, ck)
-			if !isolatedSpace {
-				cidExistGroupCmds[i] = pipe.HExists(ctx, gk, ck)
-			}
-		}
-		return nil
-	})
+	fileData, err := fileInfo.Marshal()
 	if err != nil {
 		return
 	}
-
-	// calculate new group and space stats
-	for i, idx := range newFileCidIdx {
-		if !isolatedSpace {
-			ex, err := cidExistGroupCmds[i].Result()
-			if err != nil {
-				return err
-			}
-			if !ex {
-				entry.group.CidCount++
-				entry.group.Size += cids.entries[idx].Size
-			}
-		}
-		ex, err := cidExistSpaceCmds[i].Result()
-		if err != nil {
-			return err
-		}
-		if !ex {
-			entry.space.CidCount++
-			entry.space.Size += cids.entries[idx].Size
-			affectedCidIdx = append(affectedCidIdx, idx)
-		}
-	}
-	entry.group.AddSpaceId(key.SpaceId)
+	var newFile = "0"
 	if isNewFile {
-		entry.space.FileCount++
+		newFile = "1"
+	}
+	var args = make([]any, 0, 2+len(newFileCidIdx)*2)
+	args = append(args, fileData, newFile)
+	for _, idx := range newFileCidIdx {
+		args = append(args, CidKey(cids.entries[idx].Cid), cids.entries[idx].Size)
 	}
 
-	// make group and space updates in one tx
-	_, err = ri.cl.TxPipelined(ctx, func(tx redis.Pipeliner) error {
-		// increment cid refs
-		for _, idx := range newFileCidIdx {
-			ck := CidKey(cids.entries[idx].Cid)
-			if !isolatedSpace {
-				tx.HIncrBy(ctx, gk, ck, 1)
-			}
-			tx.HIncrBy(ctx, sk, ck, 1)
-		}
-		// save info
-		entry.space.Save(ctx, key, tx)
-		entry.group.Save(ctx, tx)
-		fileInfo.Save(ctx, key, fileId, tx)
-		return nil
-	})
+	// increment refs and update group and space stats in one atomic script call
+	indexUpdate := newFilesIndexUpdate(prevIndexMembers, filesIndexMembers(fileId, fileInfo.FileEntry))
+	affected, err := ri.evalEntryScript(ctx, fileBindScript, key, entry, fileId, indexUpdate, int64(fileInfo.Size)-int64(prevSize), args...)
 	if err != nil {
 		return
 	}
 
 	// update cids
 	var saveErrs []error
-	for _, idx := range affectedCidIdx {
+	for _, pos := range affected {
+		idx := newFileCidIdx[pos]
 		cids.entries[idx].Refs++
 		if saveErr := cids.entries[idx].Save(ctx, ri.cl); saveErr != nil {
 			log.WarnCtx(ctx, "unable to save cid info", zap.Error(saveErr), zap.String("cid", cids.entries[idx].Cid.String()))
@@ -131,5 +117,5 @@ func (ri *redisIndex) fileBindCidStrings(ctx context.Context, key Key, fileId st
 		return
 	}
 	defer cidEntries.Release()
-	return ri.fileBind(ctx, key, fileId, cidEntries, entry)
+	return ri.fileBind(ctx, key, fileId, cidEntries, entry, nil)
 }
@@ -68,8 +68,8 @@ func TestRedisIndex_Bind(t *testing.T) {
 		assert.Equal(t, GroupInfo{
 			BytesUsage:   sumSize,
 			CidsCount:    uint64(len(bs)),
-			AccountLimit: fx.defaultLimit,
-			Limit:        fx.defaultLimit,
+			AccountLimit: fx.defaultLimit.Load(),
+			Limit:        fx.defaultLimit.Load(),
 			SpaceIds:     []string{key.SpaceId},
 		}, groupInfo)
 
@@ -0,0 +1,327 @@
+package index
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+var ErrBlocksLockTimeout = errors.New("blocks lock timeout")
+
+const (
+	blocksUnlockChannel = "blocksUnlockChan"
+	blockLockTtl        = time.Minute
+	// blockLockMaxWait limits the wait between attempts in case the unlock notification was lost,
+	// e.g. the holder died and the lock expired
+	blockLockMaxWait = time.Second
+	blockLockSlots   = 16384
+)
+
+// blockLockScript takes all the given locks or none of them.
+// KEYS: sorted lock keys of the same cluster slot; ARGV: token, ttl in milliseconds.
+// Returns {1} on success or {0, pttl of the first taken lock}
+var blockLockScript = redis.NewScript(`
+for _, key in ipairs(KEYS) do
+	if redis.call('EXISTS', key) == 1 then
+		return {0, redis.call('PTTL', key)}
+	end
+end
+for _, key in ipairs(KEYS) do
+	redis.call('SET', key, ARGV[1], 'PX', ARGV[2])
+end
+return {1}
+`)
+
+// blockUnlockScript releases the locks owned by the token and notifies the waiters.
+// KEYS: lock keys of the same cluster slot; ARGV: token, notification channel
+var blockUnlockScript = redis.NewScript(`
+local released = {}
+for _, key in ipairs(KEYS) do
+	if redis.call('GET', key) == ARGV[1] then
+		redis.call('DEL', key)
+		table.insert(released, key)
+	end
+end
+if #released > 0 then
+	redis.call('PUBLISH', ARGV[2], table.concat(released, ' '))
+end
+return #released
+`)
+
+const blockLockKeyPrefix = lockKeyPrefix + "b:"
+
+func blockLockKey(k cid.Cid) string {
+	return blockLockKeyPrefix + k.String()
+}
+
+// lockBlocks takes the given block locks: first in the node-local fifo queue, which makes the waiting fair,
+// and then in redis with a single script call per cluster slot
+func (ri *redisIndex) lockBlocks(ctx context.Context, op string, keys []string) (unlock func(), err error) {
+	keys = slices.Clone(keys)
+	slices.Sort(keys)
+	keys = slices.Compact(keys)
+
+	ctx, cancel := context.WithTimeoutCause(ctx, time.Duration(ri.blocksLockTimeout.Load()), ErrBlocksLockTimeout)
+	defer cancel()
+
+	// the keys are sorted so the local queue can't deadlock
+	for i, key := range keys {
+		if err = ri.blockQueue.lock(ctx, key); err != nil {
+			ri.blockQueue.unlock(keys[:i]...)
+			return nil, context.Cause(ctx)
+		}
+	}
+
+	groups := ri.blockLockGroups(keys)
+	token, err := ri.newLockValue(op)
+	if err != nil {
+		ri.blockQueue.unlock(keys...)
+		return nil, err
+	}
+	if err = ri.acquireBlockLocks(ctx, keys, groups, token); err != nil {
+		ri.blockQueue.unlock(keys...)
+		if ctx.Err() != nil {
+			return nil, context.Cause(ctx)
+		}
+		return nil, err
+	}
+	return ri.trackLock(ctx, func() {
+		ri.releaseBlockLocks(ri.ctx, groups, token)
+		ri.blockQueue.unlock(keys...)
+	}), nil
+}
+
+func (ri *redisIndex) acquireBlockLocks(ctx context.Context, keys []string, groups [][]string, token string) error {
+	var wake chan struct{}
+	for {
+		ok, wait, err := ri.tryBlockLocks(ctx, groups, token)
+		if err != nil || ok {
+			return err
+		}
+		if wake == nil {
+			// subscribe and retry immediately: an unlock could happen before the subscription
+			wake = ri.subscribeBlocksUnlock(keys)
+			defer ri.unsubscribeBlocksUnlock(keys, wake)
+			continue
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// tryBlockLocks tries to take all the groups, in case of failure it releases the taken groups
+// and returns the time to wait before the next attempt
+func (ri *redisIndex) tryBlockLocks(ctx context.Context, groups [][]string, token string) (ok bool, wait time.Duration, err error) {
+	cmds := make([]*redis.Cmd, len(groups))
+	_, _ = ri.cl.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, keys := range groups {
+			cmds[i] = blockLockScript.Eval(ctx, pipe, keys, token, blockLockTtl.Milliseconds())
+		}
+		return nil
+	})
+	var taken = make([][]string, 0, len(groups))
+	wait = blockLockMaxWait
+	ok = true
+	for i, cmd := range cmds {
+		res, cmdErr := cmd.Slice()
+		if cmdErr != nil {
+			ok = false
+			err = errors.Join(err, cmdErr)
+			continue
+		}
+		if len(res) == 1 {
+			taken = append(taken, groups[i])
+			continue
+		}
+		ok = false
+		if pttl, _ := res[1].(int64); pttl >= 0 && time.Duration(pttl)*time.Millisecond < wait {
+			wait = time.Duration(pttl) * time.Millisecond
+		}
+	}
+	if !ok {
+		ri.releaseBlockLocks(ctx, taken, token)
+	}
+	return
+}
+
+func (ri *redisIndex) releaseBlockLocks(ctx context.Context, groups [][]string, token string) {
+	if len(groups) == 0 {
+		return
+	}
+	cmds := make([]*redis.Cmd, len(groups))
+	_, _ = ri.cl.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, keys := range groups {
+			cmds[i] = blockUnlockScript.Eval(ctx, pipe, keys, token, blocksUnlockChannel)
+		}
+		return nil
+	})
+	for _, cmd := range cmds {
+		if err := cmd.Err(); err != nil {
+			log.WarnCtx(ctx, "can't release block locks", zap.Error(err))
+		}
+	}
+}
+
+// blockLockGroups splits the sorted keys by the cluster slots, the script can't touch keys from different slots
+func (ri *redisIndex) blockLockGroups(keys []string) (groups [][]string) {
+	if _, ok := ri.cl.(*redis.ClusterClient); !ok {
+		return [][]string{keys}
+	}
+	var bySlot = make(map[int][]string)
+	var slots []int
+	for _, key := range keys {
+		slot := keySlot(key)
+		if _, ok := bySlot[slot]; !ok {
+			slots = append(slots, slot)
+		}
+		bySlot[slot] = append(bySlot[slot], key)
+	}
+	slices.Sort(slots)
+	groups = make([][]string, 0, len(slots))
+	for _, slot := range slots {
+		groups = append(groups, bySlot[slot])
+	}
+	return
+}
+
+func (ri *redisIndex) subscribeBlocksUnlock(keys []string) chan struct{} {
+	wake := make(chan struct{}, 1)
+	ri.blockUnlockSubsMu.Lock()
+	defer ri.blockUnlockSubsMu.Unlock()
+	for _, key := range keys {
+		m := ri.blockUnlockSubs[key]
+		if m == nil {
+			m = make(map[chan struct{}]struct{})
+			ri.blockUnlockSubs[key] = m
+		}
+		m[wake] = struct{}{}
+	}
+	return wake
+}
+
+func (ri *redisIndex) unsubscribeBlocksUnlock(keys []string, wake chan struct{}) {
+	ri.blockUnlockSubsMu.Lock()
+	defer ri.blockUnlockSubsMu.Unlock()
+	for _, key := range keys {
+		if m := ri.blockUnlockSubs[key]; m != nil {
+			delete(m, wake)
+			if len(m) == 0 {
+				delete(ri.blockUnlockSubs, key)
+			}
+		}
+	}
+}
+
+func (ri *redisIndex) handleBlocksUnlockMessage(msg string) {
+	ri.blockUnlockSubsMu.Lock()
+	defer ri.blockUnlockSubsMu.Unlock()
+	for _, key := range strings.Split(msg, " ") {
+		for wake := range ri.blockUnlockSubs[key] {
+			select {
+			case wake <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func newLockToken() (string, error) {
+	var b = make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("can't generate lock token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// keySlot returns the redis cluster slot of the key
+func keySlot(key string) int {
+	if s := strings.IndexByte(key, '{'); s > -1 {
+		if e := strings.IndexByte(key[s+1:], '}'); e > 0 {
+			key = key[s+1 : s+e+1]
+		}
+	}
+	// CRC16-CCITT (XMODEM), see the redis cluster spec
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc ^= uint16(key[i]) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return int(crc) % blockLockSlots
+}
+
+// blockQueue is a node-local lock with fifo ordering of the waiters
+type blockQueue struct {
+	mu sync.Mutex
+	// the key is present while the lock is held, the value is the queue of the waiters
+	keys map[string][]chan struct{}
+}
+
+func (q *blockQueue) lock(ctx context.Context, key string) error {
+	q.mu.Lock()
+	waiters, held := q.keys[key]
+	if !held {
+		q.keys[key] = nil
+		q.mu.Unlock()
+		return nil
+	}
+	ch := make(chan struct{})
+	q.keys[key] = append(waiters, ch)
+	q.mu.Unlock()
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+	}
+	q.mu.Lock()
+	waiters = q.keys[key]
+	if idx := slices.Index(waiters, ch); idx != -1 {
+		q.keys[key] = slices.Delete(waiters, idx, idx+1)
+		q.mu.Unlock()
+		return ctx.Err()
+	}
+	q.mu.Unlock()
+	// the lock was handed over concurrently, pass it to the next waiter
+	q.unlock(key)
+	return ctx.Err()
+}
+
+func (q *blockQueue) unlock(keys ...string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, key := range keys {
+		waiters, held := q.keys[key]
+		if !held {
+			continue
+		}
+		if len(waiters) == 0 {
+			delete(q.keys, key)
+			continue
+		}
+		close(waiters[0])
+		q.keys[key] = waiters[1:]
+	}
+}
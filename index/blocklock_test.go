@@ -0,0 +1,135 @@
+package index
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyproto/any-sync-filenode/config"
+	"github.com/anyproto/any-sync-filenode/testutil"
+)
+
+func TestRedisIndex_BlocksLockWait(t *testing.T) {
+	t.Run("wakeup on unlock", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.Finish(t)
+		bs := testutil.NewRandBlocks(5)
+
+		unlock, err := fx.BlocksLock(ctx, "test", bs[:3])
+		require.NoError(t, err)
+
+		locked := make(chan time.Time)
+		go func() {
+			unlock2, err := fx.BlocksLock(ctx, "test", bs[2:])
+			require.NoError(t, err)
+			locked <- time.Now()
+			unlock2()
+		}()
+		time.Sleep(time.Millisecond * 100)
+		select {
+		case <-locked:
+			t.Fatal("lock should wait")
+		default:
+		}
+		unlockedAt := time.Now()
+		unlock()
+		lockedAt := <-locked
+		assert.Less(t, lockedAt.Sub(unlockedAt), blockLockMaxWait/2)
+	})
+	t.Run("timeout", func(t *testing.T) {
+		fx := newFixtureConfig(t, &config.Config{PersistTtl: 3600, BlocksLockTimeoutSec: 1})
+		defer fx.Finish(t)
+		bs := testutil.NewRandBlocks(2)
+
+		unlock, err := fx.BlocksLock(ctx, "test", bs)
+		require.NoError(t, err)
+		defer unlock()
+
+		_, err = fx.BlocksLock(ctx, "test", bs[1:])
+		assert.ErrorIs(t, err, ErrBlocksLockTimeout)
+	})
+	t.Run("ctx done", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.Finish(t)
+		bs := testutil.NewRandBlocks(2)
+
+		unlock, err := fx.BlocksLock(ctx, "test", bs)
+		require.NoError(t, err)
+		defer unlock()
+
+		tCtx, cancel := context.WithTimeout(ctx, time.Millisecond*100)
+		defer cancel()
+		_, err = fx.BlocksLock(tCtx, "test", bs)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+	t.Run("lock by other node", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.Finish(t)
+		bs := testutil.NewRandBlocks(2)
+
+		// emulate a lock held by another node that never notifies
+		lockKey := blockLockKey(bs[1].Cid())
+		require.NoError(t, fx.cl.Set(ctx, lockKey, "other", time.Millisecond*300).Err())
+
+		st := time.Now()
+		unlock, err := fx.BlocksLock(ctx, "test", bs)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(st), time.Millisecond*200)
+		unlock()
+	})
+}
+
+func TestKeySlot(t *testing.T) {
+	// values from the redis cluster spec
+	assert.Equal(t, 0x31C3, keySlot("123456789"))
+	assert.Equal(t, keySlot("user1000"), keySlot("{user1000}.following"))
+}
+
+func TestBlockQueue(t *testing.T) {
+	t.Run("fifo", func(t *testing.T) {
+		q := &blockQueue{keys: make(map[string][]chan struct{})}
+		require.NoError(t, q.lock(ctx, "a"))
+
+		var order = make(chan int, 3)
+		for i := range 3 {
+			go func() {
+				require.NoError(t, q.lock(ctx, "a"))
+				order <- i
+				q.unlock("a")
+			}()
+			// wait for the goroutine to join the queue
+			for {
+				q.mu.Lock()
+				n := len(q.keys["a"])
+				q.mu.Unlock()
+				if n == i+1 {
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}
+		q.unlock("a")
+		for i := range 3 {
+			assert.Equal(t, i, <-order)
+		}
+		q.mu.Lock()
+		assert.Empty(t, q.keys)
+		q.mu.Unlock()
+	})
+	t.Run("ctx done", func(t *testing.T) {
+		q := &blockQueue{keys: make(map[string][]chan struct{})}
+		require.NoError(t, q.lock(ctx, "a"))
+
+		tCtx, cancel := context.WithTimeout(ctx, time.Millisecond*50)
+		defer cancel()
+		assert.ErrorIs(t, q.lock(tCtx, "a"), context.DeadlineExceeded)
+
+		q.unlock("a")
+		require.NoError(t, q.lock(ctx, "a"))
+		q.unlock("a")
+		assert.Empty(t, q.keys)
+	})
+}
@@ -0,0 +1,308 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/go-redsync/redsync/v4"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const (
+	bloomRebuildKey       = "bloomRebuild.{system}"
+	bloomRebuildSuffix    = ".rebuild"
+	bloomDefaultErrorRate = 0.01
+	bloomMinCapacity      = 1000
+	bloomExpansion        = 2
+)
+
+// bloomAddScript adds the key to the partition filter and, while the rebuild is in progress, to the new filter.
+// KEYS: filter key, rebuild filter key; ARGV: item
+var bloomAddScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[2]) == 1 then
+	redis.call('BF.ADD', KEYS[2], ARGV[1])
+end
+return redis.call('BF.ADD', KEYS[1], ARGV[1])
+`)
+
+type BloomPartitionInfo struct {
+	Partition int    `json:"partition"`
+	Items     int64  `json:"items"`
+	Capacity  int64  `json:"capacity"`
+	Filters   int64  `json:"filters"`
+	SizeBytes int64  `json:"sizeBytes"`
+	Negatives uint64 `json:"negatives"`
+	Hits      uint64 `json:"hits"`
+	// S3Misses is the count of the false positives: the filter has the key but the persistent store doesn't
+	S3Misses uint64 `json:"s3Misses"`
+	// EstimatedFpRate is calculated from the filter size and items count
+	EstimatedFpRate float64 `json:"estimatedFpRate"`
+	// ObservedFpRate is calculated from the lookups since the node start
+	ObservedFpRate float64 `json:"observedFpRate"`
+}
+
+type BloomRebuildResult struct {
+	Partitions int    `json:"partitions"`
+	StoreKeys  int    `json:"storeKeys"`
+	LiveKeys   int    `json:"liveKeys"`
+	Duration   string `json:"duration"`
+}
+
+type bloomStat struct {
+	negatives atomic.Uint64
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+}
+
+func keyPartition(key string) int {
+	return int(xxhash.Sum64String(key) % partitionCount)
+}
+
+func bloomPartitionKey(part int) string {
+	return "bf:{" + strconv.Itoa(part) + "}"
+}
+
+func bloomAdd(ctx context.Context, cl redis.Scripter, key string) {
+	bfKey := bloomFilterKey(key)
+	bloomAddScript.Eval(ctx, cl, []string{bfKey, bfKey + bloomRebuildSuffix}, key)
+}
+
+func (ri *redisIndex) bloomCheckResult(key string, result string) {
+	stat := &ri.bloomStats[keyPartition(key)]
+	switch result {
+	case "negative":
+		stat.negatives.Add(1)
+	case "hit":
+		stat.hits.Add(1)
+	case "miss":
+		stat.misses.Add(1)
+	}
+	ri.metric.BloomCheck(result)
+}
+
+// BloomInfo returns the stats of the bloom filter partitions
+func (ri *redisIndex) BloomInfo(ctx context.Context) (infos []BloomPartitionInfo, err error) {
+	var (
+		existsCmds = make([]*redis.IntCmd, partitionCount)
+		cmds       = make([]*redis.BFInfoCmd, partitionCount)
+	)
+	_, _ = ri.cl.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for part := range partitionCount {
+			existsCmds[part] = pipe.Exists(ctx, bloomPartitionKey(part))
+			cmds[part] = pipe.BFInfo(ctx, bloomPartitionKey(part))
+		}
+		return nil
+	})
+	infos = make([]BloomPartitionInfo, partitionCount)
+	for part, cmd := range cmds {
+		info := BloomPartitionInfo{Partition: part}
+		exists, cmdErr := existsCmds[part].Result()
+		if cmdErr != nil {
+			return nil, cmdErr
+		}
+		// a filter doesn't exist until the first key of the partition is persisted
+		if exists > 0 {
+			var bfInfo redis.BFInfo
+			if bfInfo, cmdErr = cmd.Result(); cmdErr != nil {
+				return nil, cmdErr
+			}
+			info.Items = bfInfo.ItemsInserted
+			info.Capacity = bfInfo.Capacity
+			info.Filters = bfInfo.Filters
+			info.SizeBytes = bfInfo.Size
+			info.EstimatedFpRate = estimateFpRate(bfInfo.Size*8, bfInfo.ItemsInserted)
+		}
+		stat := &ri.bloomStats[part]
+		info.Negatives = stat.negatives.Load()
+		info.Hits = stat.hits.Load()
+		info.S3Misses = stat.misses.Load()
+		if absent := info.Negatives + info.S3Misses; absent != 0 {
+			info.ObservedFpRate = float64(info.S3Misses) / float64(absent)
+		}
+		infos[part] = info
+	}
+	return
+}
+
+// BloomRebuild builds new bloom filters from the persistent store listing and the live keys and swaps them in per partition
+func (ri *redisIndex) BloomRebuild(ctx context.Context) (res BloomRebuildResult, err error) {
+	mu := ri.redsync.NewMutex(lockKeyPrefix+"bloomRebuild", redsync.WithExpiry(time.Hour*6), redsync.WithGenValueFunc(ri.lockValueFunc("bloomRebuild")))
+	if err = mu.TryLockContext(ctx); err != nil {
+		return
+	}
+	defer func() {
+		_, _ = mu.Unlock()
+	}()
+	return ri.bloomRebuild(ctx)
+}
+
+func (ri *redisIndex) bloomRebuild(ctx context.Context) (res BloomRebuildResult, err error) {
+	st := time.Now()
+	// create new filters first: from this moment the persisted keys are added to both filters,
+	// so a key persisted after the listing passed it will not be lost
+	if err = ri.bloomReserveRebuild(ctx); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			ri.bloomDropRebuild(ri.ctx)
+		}
+	}()
+
+	if err = ri.persistStore.IndexList(ctx, func(keys []string) error {
+		res.StoreKeys += len(keys)
+		return ri.bloomRebuildAdd(ctx, keys)
+	}); err != nil {
+		return
+	}
+
+	for part := range partitionCount {
+		var liveKeys int
+		if liveKeys, err = ri.bloomRebuildAddLive(ctx, part); err != nil {
+			return
+		}
+		res.LiveKeys += liveKeys
+	}
+
+	// swap the filters, the rename is atomic
+	_, err = ri.cl.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for part := range partitionCount {
+			bfKey := bloomPartitionKey(part)
+			pipe.Rename(ctx, bfKey+bloomRebuildSuffix, bfKey)
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	if err = ri.cl.Set(ctx, bloomRebuildKey, time.Now().Unix(), 0).Err(); err != nil {
+		return
+	}
+	res.Partitions = partitionCount
+	res.Duration = time.Since(st).String()
+	log.Info("bloom filters rebuilt",
+		zap.Int("storeKeys", res.StoreKeys),
+		zap.Int("liveKeys", res.LiveKeys),
+		zap.Duration("dur", time.Since(st)),
+	)
+	return
+}
+
+func (ri *redisIndex) bloomReserveRebuild(ctx context.Context) (err error) {
+	// the capacity of the new filter is based on the current items count
+	var infoCmds = make([]*redis.BFInfoCmd, partitionCount)
+	_, _ = ri.cl.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for part := range partitionCount {
+			infoCmds[part] = pipe.BFInfo(ctx, bloomPartitionKey(part))
+		}
+		return nil
+	})
+	_, err = ri.cl.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for part := range partitionCount {
+			capacity := int64(bloomMinCapacity)
+			if info, infoErr := infoCmds[part].Result(); infoErr == nil && info.ItemsInserted > capacity {
+				capacity = info.ItemsInserted
+			}
+			rebuildKey := bloomPartitionKey(part) + bloomRebuildSuffix
+			// remove the leftovers of the failed rebuild
+			pipe.Del(ctx, rebuildKey)
+			pipe.BFReserveExpansion(ctx, rebuildKey, ri.bloomErrorRate, capacity, bloomExpansion)
+		}
+		return nil
+	})
+	return
+}
+
+func (ri *redisIndex) bloomDropRebuild(ctx context.Context) {
+	_, err := ri.cl.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for part := range partitionCount {
+			pipe.Del(ctx, bloomPartitionKey(part)+bloomRebuildSuffix)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warn("can't remove the rebuild bloom filters", zap.Error(err))
+	}
+}
+
+func (ri *redisIndex) bloomRebuildAdd(ctx context.Context, keys []string) (err error) {
+	var byPart = make(map[int][]any)
+	for _, key := range keys {
+		part := keyPartition(key)
+		byPart[part] = append(byPart[part], key)
+	}
+	_, err = ri.cl.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for part, items := range byPart {
+			pipe.BFMAdd(ctx, bloomPartitionKey(part)+bloomRebuildSuffix, items...)
+		}
+		return nil
+	})
+	return
+}
+
+// bloomRebuildAddLive adds the keys that live in redis and are going to be persisted
+func (ri *redisIndex) bloomRebuildAddLive(ctx context.Context, part int) (count int, err error) {
+	return ri.scanLiveKeys(ctx, part, func(keys []string) error {
+		return ri.bloomRebuildAdd(ctx, keys)
+	})
+}
+
+// scanLiveKeys calls f with the batches of the keys that live in redis in the given store partition
+func (ri *redisIndex) scanLiveKeys(ctx context.Context, part int, f func(keys []string) error) (count int, err error) {
+	sk := "store:{" + strconv.Itoa(part) + "}"
+	var cursor uint64
+	for {
+		var res []string
+		if res, cursor, err = ri.cl.ZScan(ctx, sk, cursor, "", 1000).Result(); err != nil {
+			return
+		}
+		// the result contains members and scores
+		var keys = make([]string, 0, len(res)/2)
+		for i := 0; i < len(res); i += 2 {
+			keys = append(keys, res[i])
+		}
+		if len(keys) != 0 {
+			if err = f(keys); err != nil {
+				return
+			}
+			count += len(keys)
+		}
+		if cursor == 0 {
+			return
+		}
+	}
+}
+
+// bloomRebuildPeriodic rebuilds the filters if the last rebuild in the cluster was made earlier than the interval
+func (ri *redisIndex) bloomRebuildPeriodic(ctx context.Context) (err error) {
+	lastRebuild, err := ri.cl.Get(ctx, bloomRebuildKey).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return
+	}
+	if time.Since(time.Unix(lastRebuild, 0)) < ri.bloomRebuildInterval {
+		return nil
+	}
+	if _, err = ri.BloomRebuild(ctx); err != nil {
+		var errTaken *redsync.ErrTaken
+		if errors.As(err, &errTaken) || errors.Is(err, redsync.ErrFailed) {
+			// another node is rebuilding
+			return nil
+		}
+	}
+	return
+}
+
+// estimateFpRate estimates the false positive rate of the bloom filter with m bits and n items using the optimal hash count
+func estimateFpRate(m, n int64) float64 {
+	if m <= 0 || n <= 0 {
+		return 0
+	}
+	k := math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2))
+	return math.Pow(1-math.Exp(-k*float64(n)/float64(m)), k)
+}
@@ -0,0 +1,86 @@
+package index
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/anyproto/any-sync-filenode/config"
+	"github.com/anyproto/any-sync-filenode/testutil"
+)
+
+func TestRedisIndex_BloomRebuild(t *testing.T) {
+	fx := newFixtureConfig(t, &config.Config{PersistTtl: 3600, BloomErrorRate: 0.0001})
+	defer fx.Finish(t)
+
+	var storeKeys, staleKeys []string
+	for range 10 {
+		storeKeys = append(storeKeys, CidKey(testutil.NewRandCid()))
+		staleKeys = append(staleKeys, CidKey(testutil.NewRandCid()))
+	}
+	for _, k := range staleKeys {
+		require.NoError(t, fx.cl.BFAdd(ctx, bloomFilterKey(k), k).Err())
+	}
+	// the live key is in redis only and must be in the new filter as well
+	liveBlocks := testutil.NewRandBlocks(1)
+	require.NoError(t, fx.BlocksAdd(ctx, liveBlocks))
+	liveKey := CidKey(liveBlocks[0].Cid())
+
+	fx.persistStore.EXPECT().IndexList(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, f func(keys []string) error) error {
+		return f(storeKeys)
+	})
+
+	res, err := fx.BloomRebuild(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, partitionCount, res.Partitions)
+	assert.Equal(t, len(storeKeys), res.StoreKeys)
+	assert.GreaterOrEqual(t, res.LiveKeys, 1)
+
+	for _, k := range append(storeKeys, liveKey) {
+		ex, err := fx.cl.BFExists(ctx, bloomFilterKey(k), k).Result()
+		require.NoError(t, err)
+		assert.True(t, ex, k)
+	}
+	for _, k := range staleKeys {
+		ex, err := fx.cl.BFExists(ctx, bloomFilterKey(k), k).Result()
+		require.NoError(t, err)
+		assert.False(t, ex, k)
+	}
+	rebuildEx, err := fx.cl.Exists(ctx, bloomFilterKey(storeKeys[0])+bloomRebuildSuffix).Result()
+	require.NoError(t, err)
+	assert.Zero(t, rebuildEx)
+}
+
+func TestRedisIndex_BloomInfo(t *testing.T) {
+	fx := newFixture(t)
+	defer fx.Finish(t)
+
+	// the key is in the filter but not in the persistent store
+	k := CidKey(testutil.NewRandCid())
+	require.NoError(t, fx.cl.BFAdd(ctx, bloomFilterKey(k), k).Err())
+	fx.persistStore.EXPECT().IndexGet(gomock.Any(), k).Return(nil, nil)
+	ex, err := fx.CheckKey(ctx, "test", k)
+	require.NoError(t, err)
+	assert.False(t, ex)
+
+	infos, err := fx.BloomInfo(ctx)
+	require.NoError(t, err)
+	require.Len(t, infos, partitionCount)
+	info := infos[keyPartition(k)]
+	assert.Equal(t, uint64(1), info.S3Misses)
+	assert.NotZero(t, info.Items)
+	assert.NotZero(t, info.ObservedFpRate)
+}
+
+func TestEstimateFpRate(t *testing.T) {
+	// the optimal filter for 1% has ~9.59 bits per item
+	n := int64(1000)
+	m := int64(math.Ceil(-float64(n) * math.Log(0.01) / (math.Ln2 * math.Ln2)))
+	assert.InDelta(t, 0.01, estimateFpRate(m, n), 0.001)
+	assert.Zero(t, estimateFpRate(m, 0))
+	assert.Greater(t, estimateFpRate(m, n*10), 0.5)
+}
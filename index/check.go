@@ -2,15 +2,67 @@ package index
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/cespare/xxhash/v2"
+	"github.com/redis/go-redis/v9"
+
 	"github.com/anyproto/any-sync-filenode/index/indexproto"
 )
 
+// CheckKind is the type of the found inconsistency, it defines how the result is fixed
+type CheckKind string
+
+const (
+	CheckFileSizeMismatch    CheckKind = "fileSizeMismatch"
+	CheckSpaceCidRefDrift    CheckKind = "spaceCidRefDrift"
+	CheckSpaceCidRefExtra    CheckKind = "spaceCidRefExtra"
+	CheckCidZeroRef          CheckKind = "cidZeroRef"
+	CheckSpaceEntryMismatch  CheckKind = "spaceEntryMismatch"
+	CheckGroupCidRefMismatch CheckKind = "groupCidRefMismatch"
+	CheckGroupCidRefExtra    CheckKind = "groupCidRefExtra"
+	CheckGroupEntryMismatch  CheckKind = "groupEntryMismatch"
+)
+
+// Check checks the group and its spaces, with doFix all the found results are fixed
 func (ri *redisIndex) Check(ctx context.Context, key Key, doFix bool) (checkResults []CheckResult, err error) {
+	// the fixes are writes, the dry run is allowed in maintenance
+	if doFix && ri.InMaintenance() {
+		return nil, ErrMaintenance
+	}
+	return ri.check(ctx, key, func(results []CheckResult) []CheckResult {
+		if doFix {
+			return results
+		}
+		return nil
+	})
+}
+
+// CheckFix checks the group again and fixes only the results with the given ids, the ids are taken from the dry run (Check without fix).
+// The id depends on the stored and the expected values, so the result changed after the dry run is not fixed.
+// Returns the fixed results
+func (ri *redisIndex) CheckFix(ctx context.Context, key Key, ids []string) (fixed []CheckResult, err error) {
+	if ri.InMaintenance() {
+		return nil, ErrMaintenance
+	}
+	_, err = ri.check(ctx, key, func(results []CheckResult) []CheckResult {
+		for _, res := range results {
+			if slices.Contains(ids, res.Id) {
+				fixed = append(fixed, res)
+			}
+		}
+		return fixed
+	})
+	return
+}
+
+// check takes the group and space locks, finds the inconsistencies and fixes the selected ones under the same locks
+func (ri *redisIndex) check(ctx context.Context, key Key, selectFixes func(results []CheckResult) []CheckResult) (checkResults []CheckResult, err error) {
 	var toRelease []func()
 	defer func() {
 		for _, r := range toRelease {
@@ -19,7 +71,7 @@ func (ri *redisIndex) Check(ctx context.Context, key Key, doFix bool) (checkResu
 	}()
 
 	// acquire locks and fetch entries
-	gExists, gRelease, err := ri.AcquireKey(ctx, GroupKey(key))
+	gExists, gRelease, err := ri.AcquireKey(ctx, "check", GroupKey(key))
 	if err != nil {
 		return
 	}
@@ -37,7 +89,7 @@ func (ri *redisIndex) Check(ctx context.Context, key Key, doFix bool) (checkResu
 	// load spaces
 	for _, spaceId := range gEntry.GetSpaceIds() {
 		spaceKey := Key{GroupId: key.GroupId, SpaceId: spaceId}
-		sExists, sRelease, aErr := ri.AcquireKey(ctx, SpaceKey(spaceKey))
+		sExists, sRelease, aErr := ri.AcquireKey(ctx, "check", SpaceKey(spaceKey))
 		if aErr != nil {
 			return nil, aErr
 		}
@@ -60,7 +112,7 @@ func (ri *redisIndex) Check(ctx context.Context, key Key, doFix bool) (checkResu
 	}
 
 	var sumRefs = make(map[string]uint64)
-	var sumSize uint64
+	var sumSize, sumLogicalSize uint64
 	for _, check := range spaceChecks {
 		var results []CheckResult
 		results, err = check.Check(ctx, ri)
@@ -72,6 +124,7 @@ func (ri *redisIndex) Check(ctx context.Context, key Key, doFix bool) (checkResu
 			sumRefs[k] += ref
 			sumSize += check.cidEntries[k].Size
 		}
+		sumLogicalSize += check.entry.LogicalSize
 	}
 
 	groupCheck, err := ri.loadGroupContent(ctx, key, gEntry)
@@ -79,53 +132,76 @@ func (ri *redisIndex) Check(ctx context.Context, key Key, doFix bool) (checkResu
 		return
 	}
 
-	checkResults = append(checkResults, groupCheck.Check(sumRefs, sumSize)...)
-	if doFix {
-		err = ri.fix(ctx, key, checkResults)
+	checkResults = append(checkResults, groupCheck.Check(sumRefs, sumSize, sumLogicalSize)...)
+	for i := range checkResults {
+		checkResults[i].setId()
+	}
+	if toFix := selectFixes(checkResults); len(toFix) != 0 {
+		err = ri.fix(ctx, key, toFix)
 	}
 	return
 }
 
 func (ri *redisIndex) fix(ctx context.Context, key Key, checkResults []CheckResult) (err error) {
 	for _, check := range checkResults {
-		switch {
-		case check.SpaceId != "":
-			if check.Key == infoKey {
-				if err = ri.fixSpaceEntry(ctx, key, check); err != nil {
-					return
-				}
-			} else {
-				if err = ri.fixSpaceCid(ctx, key, check); err != nil {
-					return
-				}
-			}
-		case check.CidEntry != nil:
-			if err = ri.fixCid(ctx, check); err != nil {
-				return
-			}
-		case check.GroupEntry != nil:
-			if check.Key == infoKey {
-				if err = ri.fixGroupEntry(ctx, key, check); err != nil {
-					return
-				}
-			} else {
-				if err = ri.fixGroupCid(ctx, key, check); err != nil {
-					return
-				}
-			}
+		switch check.Kind {
+		case CheckFileSizeMismatch:
+			err = ri.fixFileEntry(ctx, key, check)
+		case CheckSpaceCidRefDrift, CheckSpaceCidRefExtra:
+			err = ri.fixSpaceCid(ctx, key, check)
+		case CheckCidZeroRef:
+			err = ri.fixCid(ctx, check)
+		case CheckSpaceEntryMismatch:
+			err = ri.fixSpaceEntry(ctx, key, check)
+		case CheckGroupCidRefMismatch, CheckGroupCidRefExtra:
+			err = ri.fixGroupCid(ctx, key, check)
+		case CheckGroupEntryMismatch:
+			err = ri.fixGroupEntry(ctx, key, check)
+		default:
+			err = fmt.Errorf("unexpected check kind: %q", check.Kind)
 		}
+		if err != nil {
+			return
+		}
+		if err = ri.auditFix(ctx, key, check); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (ri *redisIndex) fixFileEntry(ctx context.Context, key Key, check CheckResult) (err error) {
+	data, err := (&fileEntry{FileEntry: check.FileEntry}).Marshal()
+	if err != nil {
+		return
 	}
+	spaceKey := Key{GroupId: key.GroupId, SpaceId: check.SpaceId}
+	// the fixed entry can change the indexed values, so the files index is built again
+	_, err = ri.cl.TxPipelined(ctx, func(tx redis.Pipeliner) error {
+		tx.HSet(ctx, SpaceKey(spaceKey), check.Key, data)
+		tx.Del(ctx, FilesIndexKey(spaceKey))
+		return nil
+	})
 	return
 }
 
 func (ri *redisIndex) fixSpaceEntry(ctx context.Context, key Key, check CheckResult) (err error) {
+	spaceKey := Key{GroupId: key.GroupId, SpaceId: check.SpaceId}
+	stored, err := ri.getSpaceEntry(ctx, spaceKey)
+	if err != nil {
+		return
+	}
 	se := check.SpaceEntry
 	se.UpdateTime = time.Now().Unix()
 	data, err := se.MarshalVT()
 	if err != nil {
 		return
 	}
-	return ri.cl.HSet(ctx, SpaceKey(Key{GroupId: key.GroupId, SpaceId: check.SpaceId}), infoKey, data).Err()
+	if err = ri.cl.HSet(ctx, SpaceKey(spaceKey), infoKey, data).Err(); err != nil {
+		return
+	}
+	// keep the node-wide logical size in sync with the fixed space
+	return ri.incrFileSizeSum(ctx, int64(se.LogicalSize)-int64(stored.LogicalSize))
 }
 
 func (ri *redisIndex) fixSpaceCid(ctx context.Context, key Key, check CheckResult) (err error) {
@@ -196,7 +272,12 @@ type spaceContent struct {
 }
 
 type CheckResult struct {
+	// Id identifies the result for CheckFix, it's calculated from the kind, the key and the values
+	Id          string                 `json:"id"`
+	Kind        CheckKind              `json:"kind"`
 	Key         string                 `json:"key"`
+	Before      any                    `json:"before"`
+	After       any                    `json:"after"`
 	CidEntry    *indexproto.CidEntry   `json:"cid,omitempty"`
 	FileEntry   *indexproto.FileEntry  `json:"file,omitempty"`
 	SpaceEntry  *indexproto.SpaceEntry `json:"space,omitempty"`
@@ -206,11 +287,22 @@ type CheckResult struct {
 	SpaceId     string                 `json:"spaceId,omitempty"`
 }
 
+func (c *CheckResult) setId() {
+	before, _ := json.Marshal(c.Before)
+	after, _ := json.Marshal(c.After)
+	h := xxhash.New()
+	for _, part := range []string{string(c.Kind), c.SpaceId, c.Key, string(before), string(after)} {
+		_, _ = h.WriteString(part)
+		_, _ = h.WriteString("/")
+	}
+	c.Id = strconv.FormatUint(h.Sum64(), 36)
+}
+
 func (sc *spaceContent) Check(ctx context.Context, ri *redisIndex) (checkResults []CheckResult, err error) {
 	sc.actualRefs = make(map[string]uint64)
 	// calc file refs
 	for _, file := range sc.files {
-		for _, fCid := range file.Cids {
+		for _, fCid := range (&fileEntry{FileEntry: file}).AllCids() {
 			sc.actualRefs[fCid] += 1
 		}
 	}
@@ -230,6 +322,7 @@ func (sc *spaceContent) Check(ctx context.Context, ri *redisIndex) (checkResults
 	}
 
 	// calc files sizes
+	var logicalSize uint64
 	for fileId, file := range sc.files {
 		var fileSize uint64
 		for _, fCid := range file.Cids {
@@ -237,7 +330,10 @@ func (sc *spaceContent) Check(ctx context.Context, ri *redisIndex) (checkResults
 		}
 		if file.Size != fileSize {
 			fix := CheckResult{
+				Kind:        CheckFileSizeMismatch,
 				Key:         "f:" + fileId,
+				Before:      file.Size,
+				After:       fileSize,
 				Description: fmt.Sprintf("file size mismatch: %d -> %d", file.Size, fileSize),
 				SpaceId:     sc.entry.Id,
 			}
@@ -245,6 +341,7 @@ func (sc *spaceContent) Check(ctx context.Context, ri *redisIndex) (checkResults
 			fix.FileEntry = file
 			checkResults = append(checkResults, fix)
 		}
+		logicalSize += file.Size
 	}
 
 	// check cid refs
@@ -252,7 +349,10 @@ func (sc *spaceContent) Check(ctx context.Context, ri *redisIndex) (checkResults
 	for c, want := range sc.actualRefs {
 		if actual := sc.cids[c]; actual != want {
 			fix := CheckResult{
+				Kind:        CheckSpaceCidRefDrift,
 				Key:         "c:" + c,
+				Before:      actual,
+				After:       want,
 				CidRef:      want,
 				Description: fmt.Sprintf("space cid refs mismatch: stored: %d ->  %d", actual, want),
 				SpaceId:     sc.entry.Id,
@@ -261,29 +361,47 @@ func (sc *spaceContent) Check(ctx context.Context, ri *redisIndex) (checkResults
 		}
 		cEntry := sc.cidEntries[c]
 		if cEntry.Refs < 1 {
-			cEntry.CidEntry.Refs = 1
 			fix := CheckResult{
+				Kind:        CheckCidZeroRef,
 				Key:         "c:" + c,
+				Before:      cEntry.Refs,
+				After:       1,
 				CidEntry:    cEntry.CidEntry,
 				Description: "cid 0-ref",
 			}
+			cEntry.CidEntry.Refs = 1
 			checkResults = append(checkResults, fix)
 		}
 		sumSize += cEntry.Size
 	}
 
 	// check space entry
-	if sc.entry.Size != sumSize || sc.entry.FileCount != uint32(len(sc.files)) || sc.entry.CidCount != uint64(len(sc.actualRefs)) {
+	if sc.entry.Size != sumSize || sc.entry.FileCount != uint32(len(sc.files)) || sc.entry.CidCount != uint64(len(sc.actualRefs)) || sc.entry.LogicalSize != logicalSize {
 		fix := CheckResult{
-			Key: "info",
-			Description: fmt.Sprintf("space entry; size: %d -> %d; cidsCount: %d -> %d; filesCount: %d -> %d",
+			Kind: CheckSpaceEntryMismatch,
+			Key:  "info",
+			Before: map[string]uint64{
+				"size":        sc.entry.Size,
+				"cidCount":    sc.entry.CidCount,
+				"fileCount":   uint64(sc.entry.FileCount),
+				"logicalSize": sc.entry.LogicalSize,
+			},
+			After: map[string]uint64{
+				"size":        sumSize,
+				"cidCount":    uint64(len(sc.actualRefs)),
+				"fileCount":   uint64(len(sc.files)),
+				"logicalSize": logicalSize,
+			},
+			Description: fmt.Sprintf("space entry; size: %d -> %d; cidsCount: %d -> %d; filesCount: %d -> %d; logicalSize: %d -> %d",
 				sc.entry.Size, sumSize,
 				sc.entry.CidCount, len(sc.actualRefs),
 				sc.entry.FileCount, len(sc.files),
+				sc.entry.LogicalSize, logicalSize,
 			),
 			SpaceId: sc.entry.Id,
 		}
 		sc.entry.Size = sumSize
+		sc.entry.LogicalSize = logicalSize
 		sc.entry.FileCount = uint32(len(sc.files))
 		sc.entry.CidCount = uint64(len(sc.actualRefs))
 		fix.SpaceEntry = sc.entry.SpaceEntry
@@ -291,10 +409,13 @@ func (sc *spaceContent) Check(ctx context.Context, ri *redisIndex) (checkResults
 	}
 
 	// check for extra cids
-	for c := range sc.cids {
+	for c, ref := range sc.cids {
 		if _, ok := sc.actualRefs[c]; !ok {
 			fix := CheckResult{
+				Kind:        CheckSpaceCidRefExtra,
 				Key:         "c:" + c,
+				Before:      ref,
+				After:       0,
 				CidRef:      0,
 				Description: "extra cid",
 				SpaceId:     sc.entry.Id,
@@ -327,11 +448,14 @@ func (ri *redisIndex) loadGroupContent(ctx context.Context, key Key, ge *groupEn
 	return
 }
 
-func (gc *groupContent) Check(cidRefs map[string]uint64, sumSize uint64) (checkResults []CheckResult) {
+func (gc *groupContent) Check(cidRefs map[string]uint64, sumSize, sumLogicalSize uint64) (checkResults []CheckResult) {
 	for k, ref := range cidRefs {
 		if gRef := gc.cids[k]; gRef != ref {
 			fix := CheckResult{
+				Kind:        CheckGroupCidRefMismatch,
 				Key:         "c:" + k,
+				Before:      gRef,
+				After:       ref,
 				GroupEntry:  gc.entry.GroupEntry,
 				CidRef:      ref,
 				Description: fmt.Sprintf("group ref mismatch: %d -> %d", gRef, ref),
@@ -342,7 +466,10 @@ func (gc *groupContent) Check(cidRefs map[string]uint64, sumSize uint64) (checkR
 	for k, ref := range gc.cids {
 		if _, ok := cidRefs[k]; !ok {
 			fix := CheckResult{
+				Kind:        CheckGroupCidRefExtra,
 				Key:         "c:" + k,
+				Before:      ref,
+				After:       0,
 				GroupEntry:  gc.entry.GroupEntry,
 				CidRef:      0,
 				Description: fmt.Sprintf("group ref extra: %d -> %d", ref, 0),
@@ -350,13 +477,28 @@ func (gc *groupContent) Check(cidRefs map[string]uint64, sumSize uint64) (checkR
 			checkResults = append(checkResults, fix)
 		}
 	}
-	if gc.entry.Size != sumSize {
+	if gc.entry.Size != sumSize || gc.entry.LogicalSize != sumLogicalSize {
 		fix := CheckResult{
-			Key:         "info",
-			GroupEntry:  gc.entry.GroupEntry,
-			Description: fmt.Sprintf("group size mismatch: %d -> %d", gc.entry.Size, sumSize),
+			Kind: CheckGroupEntryMismatch,
+			Key:  "info",
+			Before: map[string]uint64{
+				"size":        gc.entry.Size,
+				"logicalSize": gc.entry.LogicalSize,
+				"cidCount":    gc.entry.CidCount,
+			},
+			After: map[string]uint64{
+				"size":        sumSize,
+				"logicalSize": sumLogicalSize,
+				"cidCount":    uint64(len(cidRefs)),
+			},
+			GroupEntry: gc.entry.GroupEntry,
+			Description: fmt.Sprintf("group size mismatch: %d -> %d; logicalSize: %d -> %d",
+				gc.entry.Size, sumSize,
+				gc.entry.LogicalSize, sumLogicalSize,
+			),
 		}
 		fix.GroupEntry.Size = sumSize
+		fix.GroupEntry.LogicalSize = sumLogicalSize
 		fix.GroupEntry.CidCount = uint64(len(cidRefs))
 		checkResults = append(checkResults, fix)
 	}
@@ -369,7 +511,10 @@ func (ri *redisIndex) CheckDeletedSpaces(
 	resolve func(spaceIds []string) (deletedIds []string, err error),
 	doFix bool,
 ) (toBeDeleted []string, err error) {
-	gExists, gRelease, err := ri.AcquireKey(ctx, GroupKey(key))
+	if doFix && ri.InMaintenance() {
+		return nil, ErrMaintenance
+	}
+	gExists, gRelease, err := ri.AcquireKey(ctx, "checkDeletedSpaces", GroupKey(key))
 	if err != nil {
 		return
 	}
@@ -392,13 +537,11 @@ func (ri *redisIndex) CheckDeletedSpaces(
 	gRelease()
 
 	if doFix {
-		ctx = context.WithValue(ctx, ctxForceSpaceGet, true)
+		// the spaces are frozen, so they can be restored until SpacePurge removes them after the retention period
 		for _, toDeleteSpaceId := range deletedIds {
-			key := Key{GroupId: key.GroupId, SpaceId: toDeleteSpaceId}
-			if _, err = ri.SpaceDelete(ctx, key); err != nil {
+			if _, err = ri.SpaceFreeze(ctx, Key{GroupId: key.GroupId, SpaceId: toDeleteSpaceId}); err != nil {
 				return nil, err
 			}
-			_, _ = ri.MarkSpaceAsDeleted(ctx, key)
 		}
 	}
 	return deletedIds, nil
@@ -2,6 +2,7 @@ package index
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -49,7 +50,8 @@ func TestRedisIndex_Check(t *testing.T) {
 
 		fixRes, err := fx.Check(ctx, key, true)
 		require.NoError(t, err)
-		assert.Len(t, fixRes, 2)
+		require.Len(t, fixRes, 2)
+		assert.ElementsMatch(t, []CheckKind{CheckSpaceEntryMismatch, CheckGroupEntryMismatch}, []CheckKind{fixRes[0].Kind, fixRes[1].Kind})
 		fixRes, err = fx.Check(ctx, key, false)
 		require.NoError(t, err)
 		assert.Len(t, fixRes, 0)
@@ -87,7 +89,98 @@ func TestRedisIndex_Check(t *testing.T) {
 		}
 		assert.Len(t, fixRes, 0)
 	})
+	t.Run("fix file size", func(t *testing.T) {
+		fe, _, err := fx.getFileEntry(ctx, key, fileId1)
+		require.NoError(t, err)
+		fe.Size += 10
+		feData, _ := fe.MarshalVT()
+		require.NoError(t, fx.cl.HSet(ctx, SpaceKey(key), FileKey(fileId1), feData).Err())
+
+		fixRes, err := fx.Check(ctx, key, true)
+		require.NoError(t, err)
+		var kinds []CheckKind
+		for _, f := range fixRes {
+			kinds = append(kinds, f.Kind)
+		}
+		assert.Contains(t, kinds, CheckFileSizeMismatch)
+
+		// the file is kept
+		fileIds, err := fx.FilesList(ctx, key)
+		require.NoError(t, err)
+		assert.Contains(t, fileIds, fileId1)
+		fixRes, err = fx.Check(ctx, key, false)
+		require.NoError(t, err)
+		assert.Len(t, fixRes, 0)
+	})
+	t.Run("dry run and selected fix", func(t *testing.T) {
+		cidKey := "c:" + bs[1].Cid().String()
+		require.NoError(t, fx.cl.HSet(ctx, SpaceKey(key), cidKey, 33).Err())
+		require.NoError(t, fx.cl.HSet(ctx, GroupKey(key), cidKey, 43).Err())
+
+		dryRun, err := fx.Check(ctx, key, false)
+		require.NoError(t, err)
+		require.Len(t, dryRun, 2)
+		var spaceRes, groupRes CheckResult
+		for _, res := range dryRun {
+			switch res.Kind {
+			case CheckSpaceCidRefDrift:
+				spaceRes = res
+			case CheckGroupCidRefMismatch:
+				groupRes = res
+			}
+		}
+		require.NotEmpty(t, spaceRes.Id)
+		require.NotEmpty(t, groupRes.Id)
+		assert.Equal(t, uint64(33), spaceRes.Before)
+		assert.Equal(t, uint64(2), spaceRes.After)
 
+		fixed, err := fx.CheckFix(ctx, key, []string{spaceRes.Id, "unknown"})
+		require.NoError(t, err)
+		require.Len(t, fixed, 1)
+		assert.Equal(t, spaceRes.Id, fixed[0].Id)
+
+		// the group result is not fixed and has the same id
+		dryRun, err = fx.Check(ctx, key, false)
+		require.NoError(t, err)
+		require.Len(t, dryRun, 1)
+		assert.Equal(t, groupRes.Id, dryRun[0].Id)
+
+		records, err := fx.CheckAudit(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		assert.Equal(t, spaceRes.Id, records[0].Id)
+		assert.Equal(t, CheckSpaceCidRefDrift, records[0].Kind)
+		assert.Equal(t, key.GroupId, records[0].GroupId)
+		assert.JSONEq(t, "33", string(records[0].Before))
+		assert.JSONEq(t, "2", string(records[0].After))
+
+		// the fixes wait for the end of the maintenance, the dry run works
+		fx.maintenance.Store(true)
+		_, err = fx.CheckFix(ctx, key, []string{groupRes.Id})
+		assert.ErrorIs(t, err, ErrMaintenance)
+		_, err = fx.Check(ctx, key, true)
+		assert.ErrorIs(t, err, ErrMaintenance)
+		dryRun, err = fx.Check(ctx, key, false)
+		require.NoError(t, err)
+		require.Len(t, dryRun, 1)
+		fx.maintenance.Store(false)
+
+		_, err = fx.CheckFix(ctx, key, []string{groupRes.Id})
+		require.NoError(t, err)
+		dryRun, err = fx.Check(ctx, key, false)
+		require.NoError(t, err)
+		assert.Len(t, dryRun, 0)
+	})
+}
+
+func TestCheckResult_Id(t *testing.T) {
+	newResult := func(after uint64) CheckResult {
+		res := CheckResult{Kind: CheckSpaceCidRefDrift, Key: "c:1", SpaceId: "space", Before: uint64(1), After: after}
+		res.setId()
+		return res
+	}
+	assert.Equal(t, newResult(2).Id, newResult(2).Id)
+	assert.NotEqual(t, newResult(2).Id, newResult(3).Id)
 }
 
 func TestRedisIndex_CheckDeletedSpaces(t *testing.T) {
@@ -110,7 +203,13 @@ func TestRedisIndex_CheckDeletedSpaces(t *testing.T) {
 	require.Len(t, result, 1)
 	assert.Equal(t, key.SpaceId, result[0])
 
+	// the space is frozen, not deleted
+	err = fx.FileBind(ctx, key, "file", &CidEntries{})
+	assert.ErrorIs(t, err, ErrSpaceIsDeleted)
+	keys, err := fx.FrozenSpaces(ctx, time.Now(), 10)
+	require.NoError(t, err)
+	assert.Contains(t, keys, key)
 	info, err := fx.GroupInfo(ctx, key.GroupId)
 	require.NoError(t, err)
-	assert.Len(t, info.SpaceIds, 0)
+	assert.Contains(t, info.SpaceIds, key.SpaceId)
 }
@@ -0,0 +1,87 @@
+package index
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const (
+	checkAuditKey = "checkAudit.{system}"
+	// checkAuditLimit is the count of the latest audit records kept in redis
+	checkAuditLimit = 10000
+)
+
+// CheckAuditRecord describes the applied check fix
+type CheckAuditRecord struct {
+	Time    time.Time       `json:"time"`
+	GroupId string          `json:"groupId"`
+	SpaceId string          `json:"spaceId,omitempty"`
+	Id      string          `json:"id"`
+	Kind    CheckKind       `json:"kind"`
+	Key     string          `json:"key"`
+	Before  json.RawMessage `json:"before"`
+	After   json.RawMessage `json:"after"`
+	// Source is the initiator of the fix, empty for the manual fixes
+	Source string `json:"source,omitempty"`
+}
+
+func (ri *redisIndex) auditFix(ctx context.Context, key Key, check CheckResult) (err error) {
+	rec := CheckAuditRecord{
+		Time:    time.Now(),
+		GroupId: key.GroupId,
+		SpaceId: check.SpaceId,
+		Id:      check.Id,
+		Kind:    check.Kind,
+		Key:     check.Key,
+	}
+	rec.Source, _ = ctx.Value(ctxCheckSource).(string)
+	if rec.Before, err = json.Marshal(check.Before); err != nil {
+		return
+	}
+	if rec.After, err = json.Marshal(check.After); err != nil {
+		return
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	log.InfoCtx(ctx, "check fix applied",
+		zap.String("groupId", rec.GroupId),
+		zap.String("spaceId", rec.SpaceId),
+		zap.String("kind", string(rec.Kind)),
+		zap.String("key", rec.Key),
+		zap.String("source", rec.Source),
+		zap.ByteString("before", rec.Before),
+		zap.ByteString("after", rec.After),
+	)
+	_, err = ri.cl.TxPipelined(ctx, func(tx redis.Pipeliner) error {
+		tx.LPush(ctx, checkAuditKey, data)
+		tx.LTrim(ctx, checkAuditKey, 0, checkAuditLimit-1)
+		return nil
+	})
+	return
+}
+
+// CheckAudit returns the latest applied check fixes, the newest first
+func (ri *redisIndex) CheckAudit(ctx context.Context, limit int) (records []CheckAuditRecord, err error) {
+	if limit <= 0 || limit > checkAuditLimit {
+		limit = checkAuditLimit
+	}
+	items, err := ri.cl.LRange(ctx, checkAuditKey, 0, int64(limit-1)).Result()
+	if err != nil {
+		return
+	}
+	records = make([]CheckAuditRecord, 0, len(items))
+	for _, item := range items {
+		var rec CheckAuditRecord
+		if err = json.Unmarshal([]byte(item), &rec); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return
+}
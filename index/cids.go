@@ -5,7 +5,6 @@ import (
 	"errors"
 	"time"
 
-	"github.com/go-redsync/redsync/v4"
 	blocks "github.com/ipfs/go-block-format"
 	"github.com/ipfs/go-cid"
 	"github.com/redis/go-redis/v9"
@@ -13,6 +12,7 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/anyproto/any-sync-filenode/index/indexproto"
+	"github.com/anyproto/any-sync-filenode/tracing"
 )
 
 const (
@@ -21,7 +21,7 @@ const (
 )
 
 func (ri *redisIndex) CidExists(ctx context.Context, c cid.Cid) (ok bool, err error) {
-	return ri.CheckKey(ctx, CidKey(c))
+	return ri.CheckKey(ctx, "cidExists", CidKey(c))
 }
 
 func (ri *redisIndex) CidEntries(ctx context.Context, cids []cid.Cid) (entries *CidEntries, err error) {
@@ -102,7 +102,7 @@ func (ri *redisIndex) getAndAddToEntries(ctx context.Context, entries *CidEntrie
 }
 
 func (ri *redisIndex) acquireCidEntry(ctx context.Context, c cid.Cid) (entry *cidEntry, err error) {
-	_, release, err := ri.AcquireKey(ctx, CidKey(c))
+	_, release, err := ri.AcquireKey(ctx, "cidEntries", CidKey(c))
 	if err != nil {
 		return
 	}
@@ -117,8 +117,12 @@ func (ri *redisIndex) acquireCidEntry(ctx context.Context, c cid.Cid) (entry *ci
 }
 
 func (ri *redisIndex) BlocksAdd(ctx context.Context, bs []blocks.Block) (err error) {
+	ctx, span := tracing.Start(ctx, "index.blocksAdd", tracing.CidCount(len(bs)))
+	defer func() {
+		tracing.End(span, err)
+	}()
 	for _, b := range bs {
-		exists, release, err := ri.AcquireKey(ctx, CidKey(b.Cid()))
+		exists, release, err := ri.AcquireKey(ctx, "blocksAdd", CidKey(b.Cid()))
 		if err != nil {
 			return err
 		}
@@ -136,7 +140,7 @@ func (ri *redisIndex) BlocksAdd(ctx context.Context, bs []blocks.Block) (err err
 }
 
 func (ri *redisIndex) CidExistsInSpace(ctx context.Context, k Key, cids []cid.Cid) (exists []cid.Cid, err error) {
-	_, release, err := ri.AcquireKey(ctx, SpaceKey(k))
+	_, release, err := ri.AcquireKey(ctx, "cidExistsInSpace", SpaceKey(k))
 	if err != nil {
 		return
 	}
@@ -164,25 +168,14 @@ func (ri *redisIndex) CidExistsInSpace(ctx context.Context, k Key, cids []cid.Ci
 // It returns ErrCidIsBound if the cid is referenced; ok is false if the cid doesn't exist.
 func (ri *redisIndex) DeleteUnboundCid(ctx context.Context, c cid.Cid) (ok bool, err error) {
 	// take the block lock to exclude a concurrent upload of the same cid
-	bMu := ri.redsync.NewMutex("_lock:b:"+c.String(), redsync.WithExpiry(time.Minute))
-	for {
-		err = bMu.LockContext(ctx)
-		var errTaken *redsync.ErrTaken
-		if errors.As(err, &errTaken) {
-			time.Sleep(time.Millisecond * 10)
-			continue
-		}
-		if err != nil {
-			return false, err
-		}
-		break
+	unlock, err := ri.lockBlocks(ctx, "deleteUnboundCid", []string{blockLockKey(c)})
+	if err != nil {
+		return false, err
 	}
-	defer func() {
-		_, _ = bMu.Unlock()
-	}()
+	defer unlock()
 
 	ck := CidKey(c)
-	exists, release, err := ri.AcquireKey(ctx, ck)
+	exists, release, err := ri.AcquireKey(ctx, "deleteUnboundCid", ck)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,83 @@
+package index
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// FileCopy binds the current content of the source file to the destination file.
+// The source file is read without the space lock and its cids are locked before the destination space is locked,
+// so copying between spaces of the same group can't deadlock. The copy fails with ErrLimitExceed
+// when the cids new to the destination don't fit its limit.
+func (ri *redisIndex) FileCopy(ctx context.Context, srcKey Key, srcFileId string, dstKey Key, dstFileId string) (err error) {
+	cidEntries, err := ri.fileCidEntries(ctx, srcKey, srcFileId)
+	if err != nil {
+		return
+	}
+	defer cidEntries.Release()
+
+	entry, release, err := ri.AcquireSpace(ctx, "fileCopy", dstKey)
+	if err != nil {
+		return
+	}
+	defer release()
+	if err = ri.checkBindLimit(ctx, dstKey, entry, cidEntries); err != nil {
+		return
+	}
+	return ri.fileBind(ctx, dstKey, dstFileId, cidEntries, entry, nil)
+}
+
+// fileCidEntries locks the cids of the current file content, the file is read through the lock-free read path
+func (ri *redisIndex) fileCidEntries(ctx context.Context, key Key, fileId string) (cidEntries *CidEntries, err error) {
+	// the deleted space keeps its files until the purge, but they can't be copied
+	deleted, err := ri.loadKey(ctx, DelKey(key))
+	if err != nil {
+		return
+	}
+	if deleted {
+		return nil, ErrSpaceIsDeleted
+	}
+	sk := SpaceKey(key)
+	var cmd *redis.StringCmd
+	if _, err = ri.readKey(ctx, sk, func(tx redis.Pipeliner) {
+		cmd = tx.HGet(ctx, sk, FileKey(fileId))
+	}); err != nil {
+		return
+	}
+	fileInfo, isNewFile, err := fileEntryFromCmd(cmd)
+	if err != nil {
+		return
+	}
+	if isNewFile {
+		return nil, ErrFileNotFound
+	}
+	return ri.CidEntriesByString(ctx, fileInfo.Cids)
+}
+
+// checkBindLimit returns ErrLimitExceed when the usage of the space (or of the group for the not isolated space)
+// with the cids it doesn't reference yet is over the limit, the caller must hold the space lock
+func (ri *redisIndex) checkBindLimit(ctx context.Context, key Key, entry groupSpaceEntry, cids *CidEntries) (err error) {
+	refsKey, size, limit := GroupKey(key), entry.group.Size, entry.group.Limit
+	if entry.space.Limit != 0 {
+		refsKey, size, limit = SpaceKey(key), entry.space.Size, entry.space.Limit
+	}
+	cmds := make([]*redis.BoolCmd, len(cids.entries))
+	if _, err = ri.cl.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, c := range cids.entries {
+			cmds[i] = pipe.HExists(ctx, refsKey, CidKey(c.Cid))
+		}
+		return nil
+	}); err != nil {
+		return
+	}
+	for i, c := range cids.entries {
+		if !cmds[i].Val() {
+			size += c.Size
+		}
+	}
+	if size > limit {
+		return ErrLimitExceed
+	}
+	return
+}
@@ -0,0 +1,82 @@
+package index
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyproto/any-sync-filenode/config"
+	"github.com/anyproto/any-sync-filenode/testutil"
+)
+
+func TestRedisIndex_FileCopy(t *testing.T) {
+	t.Run("file not exists", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.Finish(t)
+		err := fx.FileCopy(ctx, newRandKey(), "fileId", newRandKey(), "fileId")
+		assert.ErrorIs(t, err, ErrFileNotFound)
+	})
+	t.Run("success", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.Finish(t)
+		srcKey := newRandKey()
+		dstKey := Key{GroupId: srcKey.GroupId, SpaceId: testutil.NewRandSpaceId()}
+		bs := testutil.NewRandBlocks(3)
+		require.NoError(t, fx.BlocksAdd(ctx, bs))
+		fileId := testutil.NewRandCid().String()
+		cids, err := fx.CidEntriesByBlocks(ctx, bs)
+		require.NoError(t, err)
+		require.NoError(t, fx.FileBind(ctx, srcKey, fileId, cids))
+		cids.Release()
+
+		require.NoError(t, fx.FileCopy(ctx, srcKey, fileId, dstKey, fileId))
+
+		srcInfo, err := fx.FileInfo(ctx, srcKey, fileId)
+		require.NoError(t, err)
+		dstInfo, err := fx.FileInfo(ctx, dstKey, fileId)
+		require.NoError(t, err)
+		assert.Equal(t, srcInfo[0].BytesUsage, dstInfo[0].BytesUsage)
+		assert.Equal(t, srcInfo[0].CidsCount, dstInfo[0].CidsCount)
+
+		groupInfo, err := fx.GroupInfo(ctx, srcKey.GroupId)
+		require.NoError(t, err)
+		// cids are shared inside the group
+		assert.Equal(t, uint64(len(bs)), groupInfo.CidsCount)
+		assert.Len(t, groupInfo.SpaceIds, 2)
+
+		for _, b := range bs {
+			cidEntry, err := fx.getCidEntry(ctx, b.Cid())
+			require.NoError(t, err)
+			assert.Equal(t, int32(2), cidEntry.Refs)
+		}
+	})
+	t.Run("limit exceed", func(t *testing.T) {
+		fx := newFixtureConfig(t, &config.Config{DefaultLimit: 100, PersistTtl: 3600})
+		defer fx.Finish(t)
+		srcKey := newRandKey()
+		// 4 blocks of 40 bytes
+		bs := testutil.NewRandBlocks(4)
+		require.NoError(t, fx.BlocksAdd(ctx, bs))
+		fileId := testutil.NewRandCid().String()
+		cids, err := fx.CidEntriesByBlocks(ctx, bs[:2])
+		require.NoError(t, err)
+		require.NoError(t, fx.FileBind(ctx, srcKey, fileId, cids))
+		cids.Release()
+
+		// the cids of the same group don't add the usage
+		dstKey := Key{GroupId: srcKey.GroupId, SpaceId: testutil.NewRandSpaceId()}
+		require.NoError(t, fx.FileCopy(ctx, srcKey, fileId, dstKey, fileId))
+
+		// the destination already has one of the cids: 40 + 40 + 40 > 100
+		dstKey = newRandKey()
+		cids, err = fx.CidEntriesByBlocks(ctx, bs[1:3])
+		require.NoError(t, err)
+		require.NoError(t, fx.FileBind(ctx, dstKey, testutil.NewRandCid().String(), cids))
+		cids.Release()
+		assert.ErrorIs(t, fx.FileCopy(ctx, srcKey, fileId, dstKey, fileId), ErrLimitExceed)
+		fInfo, err := fx.FileInfo(ctx, dstKey, fileId)
+		require.NoError(t, err)
+		assert.Zero(t, fInfo[0].CidsCount)
+	})
+}
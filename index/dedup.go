@@ -0,0 +1,105 @@
+package index
+
+import (
+	"context"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const fileSizeSumKey = "fileSizeSum.{system}"
+
+// DedupInfo compares the logical bytes (the sum of the file sizes) with the physical bytes (the sum of the unique cid sizes)
+type DedupInfo struct {
+	LogicalBytes  uint64  `json:"logicalBytes"`
+	PhysicalBytes uint64  `json:"physicalBytes"`
+	SavedBytes    uint64  `json:"savedBytes"`
+	Ratio         float64 `json:"ratio"`
+}
+
+func newDedupInfo(logical, physical uint64) DedupInfo {
+	info := DedupInfo{
+		LogicalBytes:  logical,
+		PhysicalBytes: physical,
+	}
+	if logical > physical {
+		info.SavedBytes = logical - physical
+	}
+	if physical != 0 {
+		info.Ratio = float64(logical) / float64(physical)
+	}
+	return info
+}
+
+// DedupInfo returns the node-wide deduplication stats
+func (ri *redisIndex) DedupInfo(ctx context.Context) (info DedupInfo, err error) {
+	logical, err := ri.getSystemCounter(ctx, fileSizeSumKey)
+	if err != nil {
+		return
+	}
+	physical, err := ri.getSystemCounter(ctx, cidSizeSumKey)
+	if err != nil {
+		return
+	}
+	return newDedupInfo(logical, physical), nil
+}
+
+// GroupDedupInfo returns the deduplication stats of the group, the cids of isolated spaces are counted separately
+func (ri *redisIndex) GroupDedupInfo(ctx context.Context, groupId string) (info DedupInfo, err error) {
+	key := Key{GroupId: groupId}
+	_, release, err := ri.AcquireKey(ctx, "groupDedupInfo", GroupKey(key))
+	if err != nil {
+		return
+	}
+	defer release()
+	gEntry, err := ri.getGroupEntry(ctx, key)
+	if err != nil {
+		return
+	}
+	physical := gEntry.Size
+	for _, spaceId := range gEntry.SpaceIds {
+		sEntry, sErr := ri.getSpaceEntry(ctx, Key{GroupId: groupId, SpaceId: spaceId})
+		if sErr != nil {
+			return info, sErr
+		}
+		if sEntry.Limit != 0 {
+			physical += sEntry.Size
+		}
+	}
+	return newDedupInfo(gEntry.LogicalSize, physical), nil
+}
+
+func (ri *redisIndex) getSystemCounter(ctx context.Context, key string) (uint64, error) {
+	res, err := ri.cl.Get(ctx, key).Uint64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return res, err
+}
+
+// incrFileSizeSum applies the logical size delta to the node-wide counter.
+// The counter lives in another cluster slot, so it can't be a part of the group and space tx or script
+func (ri *redisIndex) incrFileSizeSum(ctx context.Context, delta int64) error {
+	if delta == 0 {
+		return nil
+	}
+	return ri.cl.IncrBy(ctx, fileSizeSumKey, delta).Err()
+}
+
+func (ri *redisIndex) decrLogicalSize(ctx context.Context, name string, size, decr uint64, key Key) uint64 {
+	if size-decr > size {
+		ri.logicalSizeUnderflow(ctx, key, anomalyEntryUnderflow, name+": unable to decrement size", zap.Uint64("before", size), zap.Uint64("size", decr))
+		return size
+	}
+	return size - decr
+}
+
+// logicalSizeUnderflow reports the anomaly only after the backfill, before it the logical sizes of the legacy groups are expected to be short
+func (ri *redisIndex) logicalSizeUnderflow(ctx context.Context, key Key, reason, msg string, fields ...zap.Field) {
+	if !ri.logicalSizeBackfilled.Load() {
+		return
+	}
+	log.WarnCtx(ctx, msg, append(fields, zap.String("spaceId", key.SpaceId))...)
+	ri.reportAnomaly(ctx, key, reason)
+}
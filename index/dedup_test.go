@@ -0,0 +1,72 @@
+package index
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyproto/any-sync-filenode/testutil"
+)
+
+func TestRedisIndex_DedupInfo(t *testing.T) {
+	fx := newFixture(t)
+	defer fx.Finish(t)
+
+	bs := testutil.NewRandBlocks(3)
+	var sumSize uint64
+	for _, b := range bs {
+		sumSize += uint64(len(b.RawData()))
+	}
+	require.NoError(t, fx.BlocksAdd(ctx, bs))
+	key := newRandKey()
+	key2 := Key{GroupId: key.GroupId, SpaceId: testutil.NewRandSpaceId()}
+
+	before, err := fx.DedupInfo(ctx)
+	require.NoError(t, err)
+
+	// the same content in two files of two spaces
+	for _, k := range []Key{key, key2} {
+		cids, err := fx.CidEntriesByBlocks(ctx, bs)
+		require.NoError(t, err)
+		require.NoError(t, fx.FileBind(ctx, k, testutil.NewRandCid().String(), cids))
+		cids.Release()
+	}
+
+	t.Run("group", func(t *testing.T) {
+		info, err := fx.GroupDedupInfo(ctx, key.GroupId)
+		require.NoError(t, err)
+		assert.Equal(t, sumSize*2, info.LogicalBytes)
+		assert.Equal(t, sumSize, info.PhysicalBytes)
+		assert.Equal(t, sumSize, info.SavedBytes)
+		assert.Equal(t, float64(2), info.Ratio)
+	})
+	t.Run("global", func(t *testing.T) {
+		info, err := fx.DedupInfo(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, before.LogicalBytes+sumSize*2, info.LogicalBytes)
+	})
+	t.Run("unbind", func(t *testing.T) {
+		fileIds, err := fx.FilesList(ctx, key2)
+		require.NoError(t, err)
+		require.NoError(t, fx.FileUnbind(ctx, key2, fileIds...))
+		info, err := fx.GroupDedupInfo(ctx, key.GroupId)
+		require.NoError(t, err)
+		assert.Equal(t, sumSize, info.LogicalBytes)
+		assert.Equal(t, uint64(0), info.SavedBytes)
+	})
+	t.Run("check fixes logical size", func(t *testing.T) {
+		se, err := fx.getSpaceEntry(ctx, key)
+		require.NoError(t, err)
+		se.LogicalSize += 100
+		seData, _ := se.MarshalVT()
+		require.NoError(t, fx.cl.HSet(ctx, SpaceKey(key), infoKey, seData).Err())
+
+		fixRes, err := fx.Check(ctx, key, true)
+		require.NoError(t, err)
+		assert.Len(t, fixRes, 1)
+		se, err = fx.getSpaceEntry(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, sumSize, se.LogicalSize)
+	})
+}
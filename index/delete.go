@@ -4,14 +4,18 @@ import (
 	"context"
 	"errors"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
 )
 
+const frozenSpacesKey = "frozenSpaces.{system}"
+
 func (ri *redisIndex) SpaceDelete(ctx context.Context, key Key) (ok bool, err error) {
-	entry, release, err := ri.AcquireSpace(ctx, key)
+	entry, release, err := ri.AcquireSpace(ctx, "spaceDelete", key)
 	if err != nil {
 		if errors.Is(err, ErrSpaceIsDeleted) {
 			return ri.removeSpaceFromGroup(ctx, key)
@@ -55,7 +59,7 @@ func (ri *redisIndex) spaceDelete(ctx context.Context, key Key, entry groupSpace
 
 	_, err = ri.cl.Pipelined(ctx, func(pipe redis.Pipeliner) error {
 		entry.group.Save(ctx, pipe)
-		pipe.Del(ctx, sk)
+		pipe.Del(ctx, sk, FilesIndexKey(key))
 		return nil
 	})
 	if err != nil {
@@ -65,7 +69,7 @@ func (ri *redisIndex) spaceDelete(ctx context.Context, key Key, entry groupSpace
 }
 
 func (ri *redisIndex) MarkSpaceAsDeleted(ctx context.Context, key Key) (ok bool, err error) {
-	exists, release, err := ri.AcquireKey(ctx, DelKey(key))
+	exists, release, err := ri.AcquireKey(ctx, "markSpaceAsDeleted", DelKey(key))
 	if err != nil {
 		return
 	}
@@ -79,8 +83,133 @@ func (ri *redisIndex) MarkSpaceAsDeleted(ctx context.Context, key Key) (ok bool,
 	return false, nil
 }
 
+// SpaceFreeze is the first phase of the space deletion: it marks the space as deleted, so it no longer accepts writes,
+// but keeps all the space data and cid refs. The space can be restored by SpaceRestore or removed by SpacePurge.
+//
+// The space is frozen when both the del key and the frozenSpaces member exist. They are in different cluster slots,
+// so they are written by separate commands: the member goes first, and a freeze interrupted between the commands
+// is completed by the next call. The member without the del key is ignored and removed by SpacePurge.
+func (ri *redisIndex) SpaceFreeze(ctx context.Context, key Key) (ok bool, err error) {
+	exists, release, err := ri.AcquireKey(ctx, "spaceFreeze", DelKey(key))
+	if err != nil {
+		return
+	}
+	defer release()
+	if exists {
+		return false, nil
+	}
+	now := time.Now().Unix()
+	// NX keeps the freeze time of the interrupted call
+	if err = ri.cl.ZAddNX(ctx, frozenSpacesKey, redis.Z{
+		Score:  float64(now),
+		Member: spaceMember(key),
+	}).Err(); err != nil {
+		return
+	}
+	if err = ri.cl.Set(ctx, DelKey(key), now, 0).Err(); err != nil {
+		return
+	}
+	return true, nil
+}
+
+// SpaceRestore removes the deletion mark from a frozen space. Returns false if the space is not frozen or already purged.
+func (ri *redisIndex) SpaceRestore(ctx context.Context, key Key) (ok bool, err error) {
+	_, release, err := ri.AcquireKey(ctx, "spaceRestore", DelKey(key))
+	if err != nil {
+		return
+	}
+	defer release()
+	if err = ri.cl.ZScore(ctx, frozenSpacesKey, spaceMember(key)).Err(); err != nil {
+		if errors.Is(err, redis.Nil) {
+			return false, nil
+		}
+		return
+	}
+	// the del key goes first, so the interrupted restore leaves the member without the del key:
+	// the next call completes the restore and SpacePurge doesn't purge such space
+	if err = ri.cl.Del(ctx, DelKey(key)).Err(); err != nil {
+		return
+	}
+	if err = ri.cl.ZRem(ctx, frozenSpacesKey, spaceMember(key)).Err(); err != nil {
+		return
+	}
+	log.InfoCtx(ctx, "space restored", zap.String("spaceId", key.SpaceId), zap.String("groupId", key.GroupId))
+	return true, nil
+}
+
+// SpacePurge is the second phase of the space deletion: it physically removes the frozen space.
+// Returns false if the space is not frozen (restored or already purged), the space that can't be found is removed from the frozen list.
+func (ri *redisIndex) SpacePurge(ctx context.Context, key Key) (ok bool, err error) {
+	entry, release, err := ri.AcquireSpace(context.WithValue(ctx, ctxForceSpaceGet, true), "spacePurge", key)
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			log.WarnCtx(ctx, "frozen space is not found", zap.String("spaceId", key.SpaceId), zap.String("groupId", key.GroupId))
+			return false, ri.cl.ZRem(ctx, frozenSpacesKey, spaceMember(key)).Err()
+		}
+		return
+	}
+	defer release()
+
+	// take the del key after the space keys - the same order as in AcquireSpace
+	delExists, delRelease, err := ri.AcquireKey(ctx, "spacePurge", DelKey(key))
+	if err != nil {
+		return
+	}
+	defer delRelease()
+
+	if err = ri.cl.ZScore(ctx, frozenSpacesKey, spaceMember(key)).Err(); err != nil {
+		if errors.Is(err, redis.Nil) {
+			return false, nil
+		}
+		return
+	}
+	if !delExists {
+		// the member is left by the interrupted restore
+		return false, ri.cl.ZRem(ctx, frozenSpacesKey, spaceMember(key)).Err()
+	}
+	if ok, err = ri.spaceDelete(ctx, key, entry); err != nil {
+		return
+	}
+	if err = ri.cl.ZRem(ctx, frozenSpacesKey, spaceMember(key)).Err(); err != nil {
+		return
+	}
+	return ok, nil
+}
+
+// FrozenSpaces returns up to limit spaces frozen before the given time
+func (ri *redisIndex) FrozenSpaces(ctx context.Context, before time.Time, limit int) (keys []Key, err error) {
+	members, err := ri.cl.ZRangeByScore(ctx, frozenSpacesKey, &redis.ZRangeBy{
+		Min:   "0",
+		Max:   strconv.FormatInt(before.Unix(), 10),
+		Count: int64(limit),
+	}).Result()
+	if err != nil {
+		return
+	}
+	keys = make([]Key, 0, len(members))
+	for _, m := range members {
+		key, found := parseSpaceMember(m)
+		if !found {
+			log.WarnCtx(ctx, "invalid frozen space member", zap.String("member", m))
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return
+}
+
+// spaceMember is the space key stored in the system sets
+func spaceMember(key Key) string {
+	return key.GroupId + "/" + key.SpaceId
+}
+
+func parseSpaceMember(m string) (key Key, ok bool) {
+	key.GroupId, key.SpaceId, ok = strings.Cut(m, "/")
+	return
+}
+
 func (ri *redisIndex) removeSpaceFromGroup(ctx context.Context, key Key) (ok bool, err error) {
-	gExists, gRelease, err := ri.AcquireKey(ctx, GroupKey(key))
+	gExists, gRelease, err := ri.AcquireKey(ctx, "spaceDelete", GroupKey(key))
 	if err != nil {
 		return
 	}
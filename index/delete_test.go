@@ -2,6 +2,7 @@ package index
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -120,3 +121,107 @@ func TestRedisIndex_MarkSpaceAsDeleted(t *testing.T) {
 	err = fx.FileBind(ctx, key, "file", &CidEntries{})
 	assert.ErrorIs(t, err, ErrSpaceIsDeleted)
 }
+
+func TestRedisIndex_SpaceFreeze(t *testing.T) {
+	newFrozenSpace := func(t *testing.T, fx *fixture) Key {
+		key := newRandKey()
+		bs := testutil.NewRandBlocks(3)
+		require.NoError(t, fx.BlocksAdd(ctx, bs))
+		cids, err := fx.CidEntriesByBlocks(ctx, bs)
+		require.NoError(t, err)
+		require.NoError(t, fx.FileBind(ctx, key, testutil.NewRandCid().String(), cids))
+		cids.Release()
+
+		ok, err := fx.SpaceFreeze(ctx, key)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		return key
+	}
+	t.Run("freeze", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.Finish(t)
+		key := newFrozenSpace(t, fx)
+
+		// second call
+		ok, err := fx.SpaceFreeze(ctx, key)
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		// writes are forbidden
+		err = fx.FileBind(ctx, key, "file", &CidEntries{})
+		assert.ErrorIs(t, err, ErrSpaceIsDeleted)
+
+		// data is still here
+		info, err := fx.SpaceInfo(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, uint32(1), info.FileCount)
+		assert.NotEmpty(t, info.BytesUsage)
+
+		keys, err := fx.FrozenSpaces(ctx, time.Now(), 10)
+		require.NoError(t, err)
+		assert.Equal(t, []Key{key}, keys)
+	})
+	t.Run("restore", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.Finish(t)
+		key := newFrozenSpace(t, fx)
+
+		ok, err := fx.SpaceRestore(ctx, key)
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		// second call
+		ok, err = fx.SpaceRestore(ctx, key)
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		require.NoError(t, fx.FileBind(ctx, key, "file", &CidEntries{}))
+
+		keys, err := fx.FrozenSpaces(ctx, time.Now(), 10)
+		require.NoError(t, err)
+		assert.Empty(t, keys)
+	})
+	t.Run("purge", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.Finish(t)
+		key := newFrozenSpace(t, fx)
+
+		ok, err := fx.SpacePurge(ctx, key)
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		groupInfo, err := fx.GroupInfo(ctx, key.GroupId)
+		require.NoError(t, err)
+		assert.Empty(t, groupInfo.BytesUsage)
+		assert.NotContains(t, groupInfo.SpaceIds, key.SpaceId)
+
+		// can't restore the purged space
+		ok, err = fx.SpaceRestore(ctx, key)
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		// second call
+		ok, err = fx.SpacePurge(ctx, key)
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+	t.Run("interrupted restore", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.Finish(t)
+		key := newFrozenSpace(t, fx)
+		// the restore is stopped after the del key removal
+		require.NoError(t, fx.cl.Del(ctx, DelKey(key)).Err())
+
+		ok, err := fx.SpacePurge(ctx, key)
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		// the space is kept and isn't frozen anymore
+		info, err := fx.SpaceInfo(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, uint32(1), info.FileCount)
+		keys, err := fx.FrozenSpaces(ctx, time.Now(), 10)
+		require.NoError(t, err)
+		assert.Empty(t, keys)
+	})
+}
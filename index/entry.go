@@ -19,17 +19,42 @@ func (f *fileEntry) Exists(c string) (ok bool) {
 	return slices.Contains(f.Cids, c)
 }
 
+// AllCids returns the distinct cids of the file content and all the file versions
+func (f *fileEntry) AllCids() []string {
+	if len(f.Versions) == 0 {
+		return f.Cids
+	}
+	var cids = slices.Clone(f.Cids)
+	for _, v := range f.Versions {
+		for _, c := range v.Cids {
+			if !slices.Contains(cids, c) {
+				cids = append(cids, c)
+			}
+		}
+	}
+	return cids
+}
+
 func (f *fileEntry) Save(ctx context.Context, k Key, fileId string, cl redis.Pipeliner) {
-	f.UpdateTime = time.Now().Unix()
-	data, err := f.MarshalVT()
+	data, err := f.Marshal()
 	if err != nil {
 		return
 	}
 	cl.HSet(ctx, SpaceKey(k), FileKey(fileId), data)
 }
 
+// Marshal updates the update time and marshals the entry
+func (f *fileEntry) Marshal() ([]byte, error) {
+	f.UpdateTime = time.Now().Unix()
+	return f.MarshalVT()
+}
+
 func (ri *redisIndex) getFileEntry(ctx context.Context, k Key, fileId string) (entry *fileEntry, isCreated bool, err error) {
-	result, err := ri.cl.HGet(ctx, SpaceKey(k), FileKey(fileId)).Result()
+	return fileEntryFromCmd(ri.cl.HGet(ctx, SpaceKey(k), FileKey(fileId)))
+}
+
+func fileEntryFromCmd(cmd *redis.StringCmd) (entry *fileEntry, isCreated bool, err error) {
+	result, err := cmd.Result()
 	if err != nil && !errors.Is(err, redis.Nil) {
 		return
 	}
@@ -62,7 +87,11 @@ func (f *spaceEntry) Save(ctx context.Context, k Key, cl redis.Pipeliner) {
 }
 
 func (ri *redisIndex) getSpaceEntry(ctx context.Context, key Key) (entry *spaceEntry, err error) {
-	result, err := ri.cl.HGet(ctx, SpaceKey(key), infoKey).Result()
+	return spaceEntryFromCmd(key, ri.cl.HGet(ctx, SpaceKey(key), infoKey))
+}
+
+func spaceEntryFromCmd(key Key, cmd *redis.StringCmd) (entry *spaceEntry, err error) {
+	result, err := cmd.Result()
 	if err != nil && !errors.Is(err, redis.Nil) {
 		return
 	}
@@ -104,7 +133,11 @@ func (f *groupEntry) AddSpaceId(spaceId string) {
 }
 
 func (ri *redisIndex) getGroupEntry(ctx context.Context, key Key) (entry *groupEntry, err error) {
-	result, err := ri.cl.HGet(ctx, GroupKey(key), infoKey).Result()
+	return ri.groupEntryFromCmd(key, ri.cl.HGet(ctx, GroupKey(key), infoKey))
+}
+
+func (ri *redisIndex) groupEntryFromCmd(key Key, cmd *redis.StringCmd) (entry *groupEntry, err error) {
+	result, err := cmd.Result()
 	if err != nil && !errors.Is(err, redis.Nil) {
 		return
 	}
@@ -116,8 +149,8 @@ func (ri *redisIndex) getGroupEntry(ctx context.Context, key Key) (entry *groupE
 				CreateTime:   now,
 				UpdateTime:   now,
 				Size:         0,
-				Limit:        ri.defaultLimit,
-				AccountLimit: ri.defaultLimit,
+				Limit:        ri.defaultLimit.Load(),
+				AccountLimit: ri.defaultLimit.Load(),
 			},
 		}, nil
 	}
@@ -125,12 +158,16 @@ func (ri *redisIndex) getGroupEntry(ctx context.Context, key Key) (entry *groupE
 	if err = groupEntryProto.UnmarshalVT([]byte(result)); err != nil {
 		return
 	}
+	return &groupEntry{GroupEntry: ri.groupEntryDefaults(key, groupEntryProto)}, nil
+}
+
+func (ri *redisIndex) groupEntryDefaults(key Key, groupEntryProto *indexproto.GroupEntry) *indexproto.GroupEntry {
 	groupEntryProto.GroupId = key.GroupId
 	if groupEntryProto.AccountLimit == 0 {
-		groupEntryProto.Limit = ri.defaultLimit
-		groupEntryProto.AccountLimit = ri.defaultLimit
+		groupEntryProto.Limit = ri.defaultLimit.Load()
+		groupEntryProto.AccountLimit = ri.defaultLimit.Load()
 	}
-	return &groupEntry{GroupEntry: groupEntryProto}, nil
+	return groupEntryProto
 }
 
 type groupSpaceEntry struct {
@@ -0,0 +1,184 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/anyproto/any-sync-filenode/index/indexproto"
+)
+
+// The files index is the lexicographically ordered zset next to the space key, it makes the sorted listing
+// and the metadata search not depend on the space size. The index is a cache of the file entries: the entry scripts update it when it exists,
+// it's removed together with the space key and built again by the first read that needs it.
+const (
+	// filesIndexBuilt marks the complete index, the index without the mark is built again
+	filesIndexBuilt = "#"
+	// filesIndexSep separates the indexed value from the file id
+	filesIndexSep = "\x00"
+
+	filesIndexSize       = "s:"
+	filesIndexCreateTime = "c:"
+	filesIndexUpdateTime = "u:"
+
+	filesIndexMetadata  = "h:"
+	filesIndexMimeType  = "m:"
+	filesIndexRootCid   = "r:"
+	filesIndexAttribute = "a:"
+)
+
+func FilesIndexKey(k Key) string {
+	return filesIndexKeyBySpaceKey(SpaceKey(k))
+}
+
+func filesIndexKeyBySpaceKey(spaceKey string) string {
+	return "fi:" + spaceKey[2:]
+}
+
+// filesIndexMembers returns the index members of the file entry
+func filesIndexMembers(fileId string, f *indexproto.FileEntry) (members []string) {
+	if f == nil {
+		return
+	}
+	members = append(members,
+		filesIndexSortMember(filesIndexSize, int64(f.Size), fileId),
+		filesIndexSortMember(filesIndexCreateTime, f.CreateTime, fileId),
+		filesIndexSortMember(filesIndexUpdateTime, f.UpdateTime, fileId),
+	)
+	if f.Metadata == nil {
+		return
+	}
+	m := f.Metadata
+	members = append(members, filesIndexMetadata+fileId)
+	if m.MimeType != "" {
+		members = append(members, filesIndexMimeType+m.MimeType+filesIndexSep+fileId)
+	}
+	if m.RootCid != "" {
+		members = append(members, filesIndexRootCid+m.RootCid+filesIndexSep+fileId)
+	}
+	for k, v := range m.Attributes {
+		members = append(members, filesIndexAttribute+k+filesIndexSep+v+filesIndexSep+fileId)
+	}
+	return
+}
+
+// filesIndexSortMember returns the member ordered by the value, the value is zero-padded to keep the numeric order
+func filesIndexSortMember(prefix string, value int64, fileId string) string {
+	return prefix + fmt.Sprintf("%020d", max(value, 0)) + filesIndexSep + fileId
+}
+
+// errFilesIndexNotBuilt means the index is removed by the concurrent persist every time it's built
+var errFilesIndexNotBuilt = errors.New("files index is not built")
+
+// filesIndexUpdate is the change of the files index applied by the entry scripts
+type filesIndexUpdate struct {
+	remove []string
+	add    []string
+}
+
+func newFilesIndexUpdate(prev, next []string) (u filesIndexUpdate) {
+	for _, m := range prev {
+		if !slices.Contains(next, m) {
+			u.remove = append(u.remove, m)
+		}
+	}
+	for _, m := range next {
+		if !slices.Contains(prev, m) {
+			u.add = append(u.add, m)
+		}
+	}
+	return
+}
+
+// readFilesIndex works like readKey for the space key, f can read the files index in the same transaction.
+// The index that isn't built is built under the space lock and read again.
+func (ri *redisIndex) readFilesIndex(ctx context.Context, key Key, f func(tx redis.Pipeliner)) (err error) {
+	sk, fk := SpaceKey(key), FilesIndexKey(key)
+	for range readKeyAttempts {
+		var builtCmd *redis.FloatCmd
+		exists, rErr := ri.readKey(ctx, sk, func(tx redis.Pipeliner) {
+			builtCmd = tx.ZScore(ctx, fk, filesIndexBuilt)
+			f(tx)
+		})
+		if rErr != nil {
+			return rErr
+		}
+		if !exists {
+			// no space - nothing to index
+			return
+		}
+		if err = builtCmd.Err(); !errors.Is(err, redis.Nil) {
+			return
+		}
+		if err = ri.ensureFilesIndex(ctx, key); err != nil {
+			return
+		}
+	}
+	return errFilesIndexNotBuilt
+}
+
+func (ri *redisIndex) ensureFilesIndex(ctx context.Context, key Key) (err error) {
+	exists, release, err := ri.AcquireKey(ctx, "filesIndexBuild", SpaceKey(key))
+	if err != nil {
+		return
+	}
+	defer release()
+	if !exists {
+		return
+	}
+	// another reader could build it while we were waiting for the lock
+	if err = ri.cl.ZScore(ctx, FilesIndexKey(key), filesIndexBuilt).Err(); !errors.Is(err, redis.Nil) {
+		return
+	}
+	return ri.buildFilesIndex(ctx, key)
+}
+
+// buildFilesIndex indexes all the space files, the caller must hold the space lock
+func (ri *redisIndex) buildFilesIndex(ctx context.Context, key Key) (err error) {
+	sk, fk := SpaceKey(key), FilesIndexKey(key)
+	// the interrupted build can leave the members without the mark
+	if err = ri.cl.Del(ctx, fk).Err(); err != nil {
+		return
+	}
+	var (
+		cursor uint64
+		files  int
+	)
+	for {
+		var res []string
+		if res, cursor, err = ri.cl.HScan(ctx, sk, cursor, "f:*", filesScanCount).Result(); err != nil {
+			return
+		}
+		var members []redis.Z
+		// the result contains fields and values
+		for i := 1; i < len(res); i += 2 {
+			fileEntryProto := &indexproto.FileEntry{}
+			if uErr := fileEntryProto.UnmarshalVT([]byte(res[i])); uErr != nil {
+				log.WarnCtx(ctx, "can't unmarshal file entry", zap.String("spaceId", key.SpaceId), zap.String("fileId", res[i-1][2:]), zap.Error(uErr))
+				continue
+			}
+			for _, m := range filesIndexMembers(res[i-1][2:], fileEntryProto) {
+				members = append(members, redis.Z{Member: m})
+			}
+			files++
+		}
+		if len(members) != 0 {
+			if err = ri.cl.ZAdd(ctx, fk, members...).Err(); err != nil {
+				return
+			}
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	// the mark goes last, so the index is used only when it's complete
+	if err = ri.cl.ZAdd(ctx, fk, redis.Z{Member: filesIndexBuilt}).Err(); err != nil {
+		return
+	}
+	log.InfoCtx(ctx, "files index built", zap.String("spaceId", key.SpaceId), zap.Int("files", files))
+	return
+}
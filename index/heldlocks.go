@@ -0,0 +1,58 @@
+package index
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// heldLocks tracks the redis locks taken by the requests, so they can be released before the shutdown
+type heldLocks struct {
+	mu      sync.Mutex
+	next    uint64
+	unlocks map[uint64]func()
+}
+
+// track registers the unlock function and returns the release func that can be called many times
+func (h *heldLocks) track(unlock func()) (release func()) {
+	h.mu.Lock()
+	id := h.next
+	h.next++
+	h.unlocks[id] = unlock
+	h.mu.Unlock()
+	return func() {
+		h.mu.Lock()
+		f, ok := h.unlocks[id]
+		delete(h.unlocks, id)
+		h.mu.Unlock()
+		if ok {
+			f()
+		}
+	}
+}
+
+func (h *heldLocks) releaseAll() (count int) {
+	h.mu.Lock()
+	unlocks := h.unlocks
+	h.unlocks = make(map[uint64]func())
+	h.mu.Unlock()
+	for _, unlock := range unlocks {
+		unlock()
+	}
+	return len(unlocks)
+}
+
+// CtxWithTrackedLocks marks the ctx of the request, so its locks are released by ReleaseLocks.
+// The locks of the background jobs are not marked, the jobs release them when they are closed.
+func CtxWithTrackedLocks(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxTrackLocks, true)
+}
+
+// ReleaseLocks releases the locks still held by the in-flight requests, the late release calls of the requests do nothing
+func (ri *redisIndex) ReleaseLocks(ctx context.Context) (count int) {
+	if count = ri.heldLocks.releaseAll(); count != 0 {
+		log.InfoCtx(ctx, "released the held locks", zap.Int("count", count))
+	}
+	return
+}
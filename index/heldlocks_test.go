@@ -0,0 +1,54 @@
+package index
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeldLocks(t *testing.T) {
+	h := &heldLocks{unlocks: make(map[uint64]func())}
+	var unlocked []int
+	release1 := h.track(func() { unlocked = append(unlocked, 1) })
+	release2 := h.track(func() { unlocked = append(unlocked, 2) })
+
+	release1()
+	release1()
+	assert.Equal(t, []int{1}, unlocked)
+
+	assert.Equal(t, 1, h.releaseAll())
+	assert.Equal(t, []int{1, 2}, unlocked)
+
+	// the late release does nothing
+	release2()
+	assert.Equal(t, []int{1, 2}, unlocked)
+	assert.Zero(t, h.releaseAll())
+}
+
+func TestRedisIndex_ReleaseLocks(t *testing.T) {
+	fx := newFixture(t)
+	defer fx.Finish(t)
+
+	key := newRandKey()
+	// the background job lock isn't tracked
+	_, bgRelease, err := fx.AcquireKey(ctx, "test", GroupKey(key))
+	require.NoError(t, err)
+	defer bgRelease()
+	_, reqRelease, err := fx.AcquireKey(CtxWithTrackedLocks(ctx), "test", SpaceKey(key))
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, fx.ReleaseLocks(ctx))
+	locks, err := fx.Locks(ctx)
+	require.NoError(t, err)
+	var lockKeys []string
+	for _, l := range locks {
+		lockKeys = append(lockKeys, l.Key)
+	}
+	assert.Contains(t, lockKeys, lockKeyPrefix+GroupKey(key))
+	assert.NotContains(t, lockKeys, lockKeyPrefix+SpaceKey(key))
+
+	// the late release of the request does nothing
+	reqRelease()
+	assert.Zero(t, fx.ReleaseLocks(ctx))
+}
@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/OneOfOne/xxhash"
@@ -20,8 +21,10 @@ import (
 	"github.com/redis/go-redis/v9"
 
 	"github.com/anyproto/any-sync-filenode/config"
+	"github.com/anyproto/any-sync-filenode/filenodemetric"
 	"github.com/anyproto/any-sync-filenode/redisprovider"
 	"github.com/anyproto/any-sync-filenode/store/s3store"
+	"github.com/anyproto/any-sync-filenode/tracing"
 )
 
 const CName = "filenode.index"
@@ -38,21 +41,50 @@ type ctxKey int
 
 const (
 	ctxForceSpaceGet ctxKey = iota
+	// ctxCheckSource is the initiator of the check fixes written to the audit log
+	ctxCheckSource
+	// ctxTrackLocks marks the request ctx, the locks taken with it are released by ReleaseLocks
+	ctxTrackLocks
 )
 
 type Index interface {
 	FileBind(ctx context.Context, key Key, fileId string, cidEntries *CidEntries) (err error)
+	FileBindWithMetadata(ctx context.Context, key Key, fileId string, cidEntries *CidEntries, meta FileMetadata) (err error)
 	FileUnbind(ctx context.Context, kye Key, fileIds ...string) (err error)
 	FileInfo(ctx context.Context, key Key, fileIds ...string) (fileInfo []FileInfo, err error)
 	FilesList(ctx context.Context, key Key) (fileIds []string, err error)
+	FilesListPaged(ctx context.Context, key Key, params FilesListParams) (page FilesListPage, err error)
+	FileCopy(ctx context.Context, srcKey Key, srcFileId string, dstKey Key, dstFileId string) (err error)
 
-	CheckKey(ctx context.Context, key string) (exists bool, err error)
+	DedupInfo(ctx context.Context) (info DedupInfo, err error)
+	GroupDedupInfo(ctx context.Context, groupId string) (info DedupInfo, err error)
+
+	BloomInfo(ctx context.Context) (infos []BloomPartitionInfo, err error)
+	BloomRebuild(ctx context.Context) (res BloomRebuildResult, err error)
+
+	FileBindRevision(ctx context.Context, key Key, fileId string, cidEntries *CidEntries) (err error)
+	FileVersions(ctx context.Context, key Key, fileId string) (versions []FileVersionInfo, err error)
+	FileVersionRestore(ctx context.Context, key Key, fileId string, versionId uint32) (err error)
+	SetSpaceVersionPolicy(ctx context.Context, key Key, policy VersionPolicy) (err error)
+
+	FilesFind(ctx context.Context, key Key, filter FileMetadataFilter, limit int) (fileInfos []FileInfo, err error)
+
+	CheckKey(ctx context.Context, op, key string) (exists bool, err error)
+	ReleaseLocks(ctx context.Context) (count int)
+	Locks(ctx context.Context) (locks []LockInfo, err error)
+	ForceReleaseLock(ctx context.Context, key string) (released bool, err error)
+
+	SetMaintenance(ctx context.Context, enabled bool, reason string) (err error)
+	MaintenanceStatus(ctx context.Context) (status MaintenanceStatus, err error)
+	InMaintenance() bool
 
 	GroupInfo(ctx context.Context, groupId string) (info GroupInfo, err error)
 	SpaceInfo(ctx context.Context, key Key) (info SpaceInfo, err error)
+	// AccountInfo reads the group and all its spaces in one round trip, exists is false when the group is not in the index
+	AccountInfo(ctx context.Context, groupId string) (info AccountInfo, exists bool, err error)
 
 	BlocksGetNonExistent(ctx context.Context, bs []blocks.Block) (nonExistent []blocks.Block, err error)
-	BlocksLock(ctx context.Context, bs []blocks.Block) (unlock func(), err error)
+	BlocksLock(ctx context.Context, op string, bs []blocks.Block) (unlock func(), err error)
 	BlocksAdd(ctx context.Context, bs []blocks.Block) (err error)
 	OnBlockUploaded(ctx context.Context, bs ...blocks.Block)
 
@@ -70,10 +102,16 @@ type Index interface {
 	Migrate(ctx context.Context, key Key) error
 
 	Check(ctx context.Context, key Key, doFix bool) (checkResults []CheckResult, err error)
+	CheckFix(ctx context.Context, key Key, ids []string) (fixed []CheckResult, err error)
+	CheckAudit(ctx context.Context, limit int) (records []CheckAuditRecord, err error)
 	CheckDeletedSpaces(ctx context.Context, key Key, resolve func(spaceIds []string) (deletedIds []string, err error), doFix bool) (toBeDeleted []string, err error)
 
 	SpaceDelete(ctx context.Context, key Key) (ok bool, err error)
 	MarkSpaceAsDeleted(ctx context.Context, key Key) (ok bool, err error)
+	SpaceFreeze(ctx context.Context, key Key) (ok bool, err error)
+	SpaceRestore(ctx context.Context, key Key) (ok bool, err error)
+	SpacePurge(ctx context.Context, key Key) (ok bool, err error)
+	FrozenSpaces(ctx context.Context, before time.Time, limit int) (keys []Key, err error)
 
 	CheckAndMoveOwnership(ctx context.Context, key Key, oldIdentity string, aclRecordIndex int) error
 
@@ -104,9 +142,18 @@ type SpaceInfo struct {
 	FileCount  uint32
 }
 
+type AccountInfo struct {
+	Group GroupInfo
+	// Spaces are in the order of Group.SpaceIds
+	Spaces []SpaceInfo
+}
+
 type FileInfo struct {
-	BytesUsage uint64
-	CidsCount  uint64
+	FileId        string        `json:"fileId"`
+	BytesUsage    uint64        `json:"bytesUsage"`
+	CidsCount     uint64        `json:"cidsCount"`
+	VersionsCount uint32        `json:"versionsCount,omitempty"`
+	Metadata      *FileMetadata `json:"metadata,omitempty"`
 }
 
 /*
@@ -115,6 +162,9 @@ type FileInfo struct {
 			c:{cid}: proto(Entry)
 			cidCount.{system}: int
 			cidSizeSum.{system}: int
+			fileSizeSum.{system}: int
+			logicalSizeBackfill.{system}: int(finish time), the logical sizes of the groups created before the tracking are calculated
+			logicalSizeBackfillPending.{system}: set(groupId), the persisted groups to backfill on restore
 		STORES:
 			g:{groupId}: map
 				c:{cidId} -> int(refCount)
@@ -123,6 +173,21 @@ type FileInfo struct {
 				f:{fileId}: proto(FileEntry)
 				c:{cidId} -> int(refCount)
 				info: proto(SpaceEntry)
+			fi:{spaceId}: zset(lex members), the files index of the space built on the first read, removed with the space key
+				#: the index is complete
+				s:{size}\0{fileId}, c:{createTime}\0{fileId}, u:{updateTime}\0{fileId}: the sort values, zero-padded to 20 digits
+				h:{fileId}, m:{mimeType}\0{fileId}, r:{rootCid}\0{fileId}, a:{key}\0{value}\0{fileId}: the file metadata
+		DELETION:
+			del:{spaceId}: int(deletion time)
+			frozenSpaces.{system}: zset({groupId}/{spaceId} -> deletion time)
+		VERSIONS:
+			versionedSpaces.{system}: set({groupId}/{spaceId}), the spaces with the expiring versions
+		MAINTENANCE:
+			maintenance.{system}: map(since, reason)
+		CHECK:
+			checkAudit.{system}: list(json CheckAuditRecord), the newest first
+			anomalyQueue.{system}: zset(groupId -> check time in ms)
+			anomalyBackoff:{groupId}.{system}: int(count of the self-heal checks of the group in the last 6 hours)
 
 */
 
@@ -130,13 +195,49 @@ type redisIndex struct {
 	cl           redis.UniversalClient
 	redsync      *redsync.Redsync
 	persistStore persistentStore
-	persistTtl   time.Duration
+	persistTtl   atomic.Int64
 	persistMu    sync.Mutex
 	ticker       periodicsync.PeriodicSync
-	defaultLimit uint64
+	defaultLimit atomic.Uint64
+	metric       filenodemetric.Metric
 
 	cidSubscriptionsMu sync.Mutex
 	cidSubscriptions   map[string]map[chan struct{}]struct{}
+	subMu              sync.Mutex
+	sub                *redis.PubSub
+
+	bloomStats           [partitionCount]bloomStat
+	bloomErrorRate       float64
+	bloomRebuildInterval time.Duration
+	bloomTicker          periodicsync.PeriodicSync
+
+	pressureConf   config.MemoryPressure
+	pressureTicker periodicsync.PeriodicSync
+	pressureActive atomic.Bool
+	memoryRatio    atomic.Uint64
+
+	prefetchConf    config.GroupPrefetch
+	prefetchLimiter chan struct{}
+	prefetching     sync.Map
+
+	heldLocks  heldLocks
+	instanceId string
+
+	// logicalSizeBackfilled is set when the logical sizes of the legacy groups are calculated
+	logicalSizeBackfilled atomic.Bool
+	backfillTicker        periodicsync.PeriodicSync
+	versionsPruneTicker   periodicsync.PeriodicSync
+
+	selfHealConf   config.SelfHeal
+	selfHealTicker periodicsync.PeriodicSync
+
+	maintenance       atomic.Bool
+	maintenanceTicker periodicsync.PeriodicSync
+
+	blocksLockTimeout atomic.Int64
+	blockQueue        *blockQueue
+	blockUnlockSubsMu sync.Mutex
+	blockUnlockSubs   map[string]map[chan struct{}]struct{}
 
 	ctx       context.Context
 	ctxCancel context.CancelFunc
@@ -148,19 +249,53 @@ func (ri *redisIndex) Init(a *app.App) (err error) {
 	ri.redsync = redsync.New(goredis.NewPool(ri.cl))
 	conf := app.MustComponent[*config.Config](a)
 
-	ri.persistTtl = time.Second * time.Duration(conf.PersistTtl)
-	if ri.persistTtl == 0 {
-		ri.persistTtl = time.Hour
+	ri.applyRuntimeConfig(conf)
+	ri.metric = app.MustComponent[filenodemetric.Metric](a)
+	ri.cidSubscriptions = make(map[string]map[chan struct{}]struct{})
+	ri.bloomErrorRate = conf.BloomErrorRate
+	if ri.bloomErrorRate <= 0 || ri.bloomErrorRate >= 1 {
+		ri.bloomErrorRate = bloomDefaultErrorRate
 	}
-	ri.defaultLimit = conf.DefaultLimit
-	if ri.defaultLimit == 0 {
-		ri.defaultLimit = 1 << 30
+	ri.bloomRebuildInterval = time.Hour * time.Duration(conf.BloomRebuildIntervalHours)
+	ri.pressureConf = conf.MemoryPressure
+	ri.prefetchConf = conf.GroupPrefetch
+	if ri.prefetchConf.Threads == 0 {
+		ri.prefetchConf.Threads = defaultPrefetchThreads
 	}
-	ri.cidSubscriptions = make(map[string]map[chan struct{}]struct{})
+	ri.prefetchLimiter = make(chan struct{}, ri.prefetchConf.Threads)
+	ri.selfHealConf = conf.SelfHeal
+	ri.heldLocks.unlocks = make(map[uint64]func())
+	ri.instanceId = instanceId()
+	ri.blockQueue = &blockQueue{keys: make(map[string][]chan struct{})}
+	ri.blockUnlockSubs = make(map[string]map[chan struct{}]struct{})
 	ri.ctx, ri.ctxCancel = context.WithCancel(context.Background())
 	return
 }
 
+// ReloadConfig applies the runtime settings: defaultLimit, persistTtl and blocksLockTimeoutSec
+func (ri *redisIndex) ReloadConfig(conf any) (err error) {
+	ri.applyRuntimeConfig(conf.(*config.Config))
+	return
+}
+
+func (ri *redisIndex) applyRuntimeConfig(conf *config.Config) {
+	persistTtl := time.Second * time.Duration(conf.PersistTtl)
+	if persistTtl == 0 {
+		persistTtl = time.Hour
+	}
+	ri.persistTtl.Store(int64(persistTtl))
+	defaultLimit := conf.DefaultLimit
+	if defaultLimit == 0 {
+		defaultLimit = 1 << 30
+	}
+	ri.defaultLimit.Store(defaultLimit)
+	blocksLockTimeout := time.Second * time.Duration(conf.BlocksLockTimeoutSec)
+	if blocksLockTimeout == 0 {
+		blocksLockTimeout = time.Minute
+	}
+	ri.blocksLockTimeout.Store(int64(blocksLockTimeout))
+}
+
 func (ri *redisIndex) Name() (name string) {
 	return CName
 }
@@ -171,42 +306,57 @@ func (ri *redisIndex) Run(ctx context.Context) (err error) {
 		return nil
 	}, log)
 	ri.ticker.Run()
+	if ri.bloomRebuildInterval > 0 {
+		ri.bloomTicker = periodicsync.NewPeriodicSync(3600, time.Hour*6, ri.bloomRebuildPeriodic, log)
+		ri.bloomTicker.Run()
+	}
+	ri.runMemoryPressure(ctx)
+	// the pub/sub notifications can be lost on reconnect, so the flag is refreshed periodically as well
+	ri.maintenanceTicker = periodicsync.NewPeriodicSync(30, time.Second*10, ri.refreshMaintenance, log)
+	ri.maintenanceTicker.Run()
+	ri.backfillTicker = periodicsync.NewPeriodicSync(600, time.Hour*24, ri.backfillLogicalSizePeriodic, log)
+	ri.backfillTicker.Run()
+	ri.versionsPruneTicker = periodicsync.NewPeriodicSync(3600, time.Hour, ri.pruneVersionsPeriodic, log)
+	ri.versionsPruneTicker.Run()
+	ri.runSelfHeal()
+	if err = ri.registerMetrics(); err != nil {
+		return
+	}
 	go ri.subscription(ctx)
 	return
 }
 
 func (ri *redisIndex) FileInfo(ctx context.Context, key Key, fileIds ...string) (fileInfos []FileInfo, err error) {
-	_, release, err := ri.AcquireKey(ctx, SpaceKey(key))
-	if err != nil {
+	sk := SpaceKey(key)
+	var cmds []*redis.StringCmd
+	if _, err = ri.readKey(ctx, sk, func(tx redis.Pipeliner) {
+		cmds = make([]*redis.StringCmd, len(fileIds))
+		for i, fileId := range fileIds {
+			cmds[i] = tx.HGet(ctx, sk, FileKey(fileId))
+		}
+	}); err != nil {
 		return
 	}
-	defer release()
 	fileInfos = make([]FileInfo, len(fileIds))
 	for i, fileId := range fileIds {
-		fEntry, _, err := ri.getFileEntry(ctx, key, fileId)
+		fEntry, _, err := fileEntryFromCmd(cmds[i])
 		if err != nil {
 			return nil, err
 		}
-		fileInfos[i] = FileInfo{
-			BytesUsage: fEntry.Size,
-			CidsCount:  uint64(len(fEntry.Cids)),
-		}
+		fileInfos[i] = newFileInfo(fileId, fEntry)
 	}
 	return
 }
 
 func (ri *redisIndex) FilesList(ctx context.Context, key Key) (fileIds []string, err error) {
 	sk := SpaceKey(key)
-	_, release, err := ri.AcquireKey(ctx, sk)
-	if err != nil {
-		return
-	}
-	defer release()
-	allKeys, err := ri.cl.HKeys(ctx, sk).Result()
-	if err != nil {
+	var keysCmd *redis.StringSliceCmd
+	if _, err = ri.readKey(ctx, sk, func(tx redis.Pipeliner) {
+		keysCmd = tx.HKeys(ctx, sk)
+	}); err != nil {
 		return
 	}
-	for _, k := range allKeys {
+	for _, k := range keysCmd.Val() {
 		if strings.HasPrefix(k, "f:") {
 			fileIds = append(fileIds, k[2:])
 		}
@@ -221,7 +371,7 @@ func (ri *redisIndex) BlocksGetNonExistent(ctx context.Context, bs []blocks.Bloc
 		if _, ok := checked[cidKey]; ok {
 			continue
 		}
-		ex, err := ri.CheckKey(ctx, cidKey)
+		ex, err := ri.CheckKey(ctx, "blocksGetNonExistent", cidKey)
 		if err != nil {
 			return nil, err
 		}
@@ -233,48 +383,27 @@ func (ri *redisIndex) BlocksGetNonExistent(ctx context.Context, bs []blocks.Bloc
 	return
 }
 
-func (ri *redisIndex) BlocksLock(ctx context.Context, bs []blocks.Block) (unlock func(), err error) {
-	var lockers = make([]*redsync.Mutex, 0, len(bs))
-	var blocked = make(map[string]struct{}, len(bs))
-
-	unlock = func() {
-		for _, l := range lockers {
-			_, _ = l.Unlock()
-		}
-	}
-
+func (ri *redisIndex) BlocksLock(ctx context.Context, op string, bs []blocks.Block) (unlock func(), err error) {
+	ctx, span := tracing.Start(ctx, "index.blocksLock", tracing.CidCount(len(bs)))
+	defer func() {
+		tracing.End(span, err)
+	}()
+	var keys = make([]string, 0, len(bs))
 	for _, b := range bs {
-		cidString := b.Cid().String()
-		if _, ok := blocked[cidString]; ok {
-			continue
-		}
-		l := ri.redsync.NewMutex("_lock:b:"+cidString, redsync.WithExpiry(time.Minute))
-		for {
-			err = l.LockContext(ctx)
-			var errTaken *redsync.ErrTaken
-			if errors.As(err, &errTaken) {
-				time.Sleep(time.Millisecond * 10)
-				continue
-			}
-			if err != nil {
-				unlock()
-				return nil, err
-			}
-			blocked[cidString] = struct{}{}
-			lockers = append(lockers, l)
-			break
-		}
+		keys = append(keys, blockLockKey(b.Cid()))
 	}
-	return
+	return ri.lockBlocks(ctx, op, keys)
 }
 
 func (ri *redisIndex) GroupInfo(ctx context.Context, groupId string) (info GroupInfo, err error) {
-	_, release, err := ri.AcquireKey(ctx, GroupKey(Key{GroupId: groupId}))
-	if err != nil {
+	gk := GroupKey(Key{GroupId: groupId})
+	var infoCmd *redis.StringCmd
+	if _, err = ri.readKey(ctx, gk, func(tx redis.Pipeliner) {
+		infoCmd = tx.HGet(ctx, gk, infoKey)
+	}); err != nil {
 		return
 	}
-	defer release()
-	sEntry, err := ri.getGroupEntry(ctx, Key{GroupId: groupId})
+	sEntry, err := ri.groupEntryFromCmd(Key{GroupId: groupId}, infoCmd)
 	if err != nil {
 		return
 	}
@@ -288,12 +417,14 @@ func (ri *redisIndex) GroupInfo(ctx context.Context, groupId string) (info Group
 }
 
 func (ri *redisIndex) SpaceInfo(ctx context.Context, key Key) (info SpaceInfo, err error) {
-	_, release, err := ri.AcquireKey(ctx, SpaceKey(key))
-	if err != nil {
+	sk := SpaceKey(key)
+	var infoCmd *redis.StringCmd
+	if _, err = ri.readKey(ctx, sk, func(tx redis.Pipeliner) {
+		infoCmd = tx.HGet(ctx, sk, infoKey)
+	}); err != nil {
 		return
 	}
-	defer release()
-	sEntry, err := ri.getSpaceEntry(ctx, key)
+	sEntry, err := spaceEntryFromCmd(key, infoCmd)
 	if err != nil {
 		return
 	}
@@ -305,10 +436,86 @@ func (ri *redisIndex) SpaceInfo(ctx context.Context, key Key) (info SpaceInfo, e
 	}, nil
 }
 
+func (ri *redisIndex) AccountInfo(ctx context.Context, groupId string) (info AccountInfo, exists bool, err error) {
+	groupKey := Key{GroupId: groupId}
+	gk := GroupKey(groupKey)
+	var groupCmd *redis.StringCmd
+	if exists, err = ri.readKey(ctx, gk, func(tx redis.Pipeliner) {
+		groupCmd = tx.HGet(ctx, gk, infoKey)
+	}); err != nil || !exists {
+		return
+	}
+	gEntry, err := ri.groupEntryFromCmd(groupKey, groupCmd)
+	if err != nil {
+		return
+	}
+	info.Group = GroupInfo{
+		BytesUsage:   gEntry.Size,
+		CidsCount:    gEntry.CidCount,
+		AccountLimit: gEntry.AccountLimit,
+		Limit:        gEntry.Limit,
+		SpaceIds:     gEntry.SpaceIds,
+	}
+	if len(gEntry.SpaceIds) == 0 {
+		return
+	}
+
+	// the space keys share the hash slot with the group key, so they are read in one transaction
+	var (
+		spaceKeys = make([]Key, len(gEntry.SpaceIds))
+		keys      = make([]string, len(gEntry.SpaceIds))
+		spaceCmds []*redis.StringCmd
+	)
+	for i, spaceId := range gEntry.SpaceIds {
+		spaceKeys[i] = Key{GroupId: groupId, SpaceId: spaceId}
+		keys[i] = SpaceKey(spaceKeys[i])
+	}
+	if _, err = ri.readKeys(ctx, keys, func(tx redis.Pipeliner) {
+		spaceCmds = make([]*redis.StringCmd, len(keys))
+		for i, sk := range keys {
+			spaceCmds[i] = tx.HGet(ctx, sk, infoKey)
+		}
+	}); err != nil {
+		return
+	}
+	info.Spaces = make([]SpaceInfo, len(keys))
+	for i, cmd := range spaceCmds {
+		sEntry, sErr := spaceEntryFromCmd(spaceKeys[i], cmd)
+		if sErr != nil {
+			return info, exists, sErr
+		}
+		info.Spaces[i] = SpaceInfo{
+			BytesUsage: sEntry.Size,
+			CidsCount:  sEntry.CidCount,
+			Limit:      sEntry.Limit,
+			FileCount:  sEntry.FileCount,
+		}
+	}
+	return
+}
+
 func (ri *redisIndex) Close(ctx context.Context) error {
 	if ri.ticker != nil {
 		ri.ticker.Close()
 	}
+	if ri.bloomTicker != nil {
+		ri.bloomTicker.Close()
+	}
+	if ri.pressureTicker != nil {
+		ri.pressureTicker.Close()
+	}
+	if ri.maintenanceTicker != nil {
+		ri.maintenanceTicker.Close()
+	}
+	if ri.backfillTicker != nil {
+		ri.backfillTicker.Close()
+	}
+	if ri.versionsPruneTicker != nil {
+		ri.versionsPruneTicker.Close()
+	}
+	if ri.selfHealTicker != nil {
+		ri.selfHealTicker.Close()
+	}
 	if ri.ctxCancel != nil {
 		ri.ctxCancel()
 	}
@@ -5,12 +5,14 @@ import (
 	"testing"
 
 	"github.com/anyproto/any-sync/app"
+	"github.com/anyproto/any-sync/metric"
 	blocks "github.com/ipfs/go-block-format"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 
 	"github.com/anyproto/any-sync-filenode/config"
+	"github.com/anyproto/any-sync-filenode/filenodemetric"
 	"github.com/anyproto/any-sync-filenode/redisprovider/testredisprovider"
 	"github.com/anyproto/any-sync-filenode/store/mock_store"
 	"github.com/anyproto/any-sync-filenode/store/s3store"
@@ -48,7 +50,7 @@ func TestRedisIndex_BlocksLock(t *testing.T) {
 	bs := testutil.NewRandBlocks(3)
 	bs = append([]blocks.Block{bs[0]}, bs...)
 	for range 3 {
-		unlock, err := fx.BlocksLock(ctx, bs)
+		unlock, err := fx.BlocksLock(ctx, "test", bs)
 		require.NoError(t, err)
 		unlock()
 	}
@@ -66,6 +68,10 @@ func newFixture(t *testing.T) (fx *fixture) {
 }
 
 func newFixtureConfig(t *testing.T, conf *config.Config) (fx *fixture) {
+	return newFixtureProvider(t, conf, testredisprovider.NewTestRedisProvider())
+}
+
+func newFixtureProvider(t *testing.T, conf *config.Config, provider *testredisprovider.TestRedisProvider) (fx *fixture) {
 	ctrl := gomock.NewController(t)
 	fx = &fixture{
 		redisIndex:   New().(*redisIndex),
@@ -73,12 +79,19 @@ func newFixtureConfig(t *testing.T, conf *config.Config) (fx *fixture) {
 		persistStore: mock_store.NewMockStore(ctrl),
 		a:            new(app.App),
 	}
+	// the tests run on the empty db, there are no groups to backfill
+	fx.logicalSizeBackfilled.Store(true)
 	fx.persistStore.EXPECT().Name().Return(s3store.CName).AnyTimes()
 	fx.persistStore.EXPECT().Init(gomock.Any()).AnyTimes()
 	if conf == nil {
 		conf = &config.Config{DefaultLimit: 1024, PersistTtl: 3600}
 	}
-	fx.a.Register(testredisprovider.NewTestRedisProvider()).Register(fx.redisIndex).Register(fx.persistStore).Register(conf)
+	fx.a.Register(provider).
+		Register(metric.New()).
+		Register(filenodemetric.New()).
+		Register(fx.redisIndex).
+		Register(fx.persistStore).
+		Register(conf)
 	require.NoError(t, fx.a.Start(ctx))
 	return
 }
@@ -1,6 +1,6 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
 // versions:
-// 	protoc-gen-go v1.36.10
+// 	protoc-gen-go v1.36.11
 // 	protoc        v3.21.12
 // source: index.proto
 
@@ -142,15 +142,17 @@ func (x *CidList) GetCids() [][]byte {
 }
 
 type GroupEntry struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	GroupId       string                 `protobuf:"bytes,1,opt,name=groupId,proto3" json:"groupId,omitempty"`
-	CreateTime    int64                  `protobuf:"varint,2,opt,name=createTime,proto3" json:"createTime,omitempty"`
-	UpdateTime    int64                  `protobuf:"varint,3,opt,name=updateTime,proto3" json:"updateTime,omitempty"`
-	Size          uint64                 `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
-	CidCount      uint64                 `protobuf:"varint,5,opt,name=cidCount,proto3" json:"cidCount,omitempty"`
-	SpaceIds      []string               `protobuf:"bytes,6,rep,name=spaceIds,proto3" json:"spaceIds,omitempty"`
-	Limit         uint64                 `protobuf:"varint,7,opt,name=limit,proto3" json:"limit,omitempty"`
-	AccountLimit  uint64                 `protobuf:"varint,8,opt,name=accountLimit,proto3" json:"accountLimit,omitempty"`
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	GroupId      string                 `protobuf:"bytes,1,opt,name=groupId,proto3" json:"groupId,omitempty"`
+	CreateTime   int64                  `protobuf:"varint,2,opt,name=createTime,proto3" json:"createTime,omitempty"`
+	UpdateTime   int64                  `protobuf:"varint,3,opt,name=updateTime,proto3" json:"updateTime,omitempty"`
+	Size         uint64                 `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
+	CidCount     uint64                 `protobuf:"varint,5,opt,name=cidCount,proto3" json:"cidCount,omitempty"`
+	SpaceIds     []string               `protobuf:"bytes,6,rep,name=spaceIds,proto3" json:"spaceIds,omitempty"`
+	Limit        uint64                 `protobuf:"varint,7,opt,name=limit,proto3" json:"limit,omitempty"`
+	AccountLimit uint64                 `protobuf:"varint,8,opt,name=accountLimit,proto3" json:"accountLimit,omitempty"`
+	// logicalSize is the sum of the file sizes of all group spaces
+	LogicalSize   uint64 `protobuf:"varint,9,opt,name=logicalSize,proto3" json:"logicalSize,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -241,6 +243,13 @@ func (x *GroupEntry) GetAccountLimit() uint64 {
 	return 0
 }
 
+func (x *GroupEntry) GetLogicalSize() uint64 {
+	if x != nil {
+		return x.LogicalSize
+	}
+	return 0
+}
+
 type SpaceEntry struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	GroupId       string                 `protobuf:"bytes,1,opt,name=groupId,proto3" json:"groupId,omitempty"`
@@ -250,6 +259,9 @@ type SpaceEntry struct {
 	FileCount     uint32                 `protobuf:"varint,5,opt,name=fileCount,proto3" json:"fileCount,omitempty"`
 	CidCount      uint64                 `protobuf:"varint,6,opt,name=cidCount,proto3" json:"cidCount,omitempty"`
 	Limit         uint64                 `protobuf:"varint,7,opt,name=limit,proto3" json:"limit,omitempty"`
+	VersionPolicy *VersionPolicy         `protobuf:"bytes,8,opt,name=versionPolicy,proto3" json:"versionPolicy,omitempty"`
+	// logicalSize is the sum of the file sizes
+	LogicalSize   uint64 `protobuf:"varint,9,opt,name=logicalSize,proto3" json:"logicalSize,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -333,19 +345,88 @@ func (x *SpaceEntry) GetLimit() uint64 {
 	return 0
 }
 
+func (x *SpaceEntry) GetVersionPolicy() *VersionPolicy {
+	if x != nil {
+		return x.VersionPolicy
+	}
+	return nil
+}
+
+func (x *SpaceEntry) GetLogicalSize() uint64 {
+	if x != nil {
+		return x.LogicalSize
+	}
+	return 0
+}
+
+type VersionPolicy struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	KeepVersions  uint32                 `protobuf:"varint,1,opt,name=keepVersions,proto3" json:"keepVersions,omitempty"`
+	KeepDays      uint32                 `protobuf:"varint,2,opt,name=keepDays,proto3" json:"keepDays,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VersionPolicy) Reset() {
+	*x = VersionPolicy{}
+	mi := &file_index_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VersionPolicy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VersionPolicy) ProtoMessage() {}
+
+func (x *VersionPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_index_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VersionPolicy.ProtoReflect.Descriptor instead.
+func (*VersionPolicy) Descriptor() ([]byte, []int) {
+	return file_index_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *VersionPolicy) GetKeepVersions() uint32 {
+	if x != nil {
+		return x.KeepVersions
+	}
+	return 0
+}
+
+func (x *VersionPolicy) GetKeepDays() uint32 {
+	if x != nil {
+		return x.KeepDays
+	}
+	return 0
+}
+
 type FileEntry struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Cids          []string               `protobuf:"bytes,1,rep,name=cids,proto3" json:"cids,omitempty"`
 	Size          uint64                 `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
 	CreateTime    int64                  `protobuf:"varint,3,opt,name=createTime,proto3" json:"createTime,omitempty"`
 	UpdateTime    int64                  `protobuf:"varint,4,opt,name=updateTime,proto3" json:"updateTime,omitempty"`
+	Versions      []*FileVersion         `protobuf:"bytes,5,rep,name=versions,proto3" json:"versions,omitempty"`
+	LastVersionId uint32                 `protobuf:"varint,6,opt,name=lastVersionId,proto3" json:"lastVersionId,omitempty"`
+	Metadata      *FileMetadata          `protobuf:"bytes,7,opt,name=metadata,proto3" json:"metadata,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *FileEntry) Reset() {
 	*x = FileEntry{}
-	mi := &file_index_proto_msgTypes[4]
+	mi := &file_index_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -357,7 +438,7 @@ func (x *FileEntry) String() string {
 func (*FileEntry) ProtoMessage() {}
 
 func (x *FileEntry) ProtoReflect() protoreflect.Message {
-	mi := &file_index_proto_msgTypes[4]
+	mi := &file_index_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -370,7 +451,7 @@ func (x *FileEntry) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use FileEntry.ProtoReflect.Descriptor instead.
 func (*FileEntry) Descriptor() ([]byte, []int) {
-	return file_index_proto_rawDescGZIP(), []int{4}
+	return file_index_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *FileEntry) GetCids() []string {
@@ -401,6 +482,163 @@ func (x *FileEntry) GetUpdateTime() int64 {
 	return 0
 }
 
+func (x *FileEntry) GetVersions() []*FileVersion {
+	if x != nil {
+		return x.Versions
+	}
+	return nil
+}
+
+func (x *FileEntry) GetLastVersionId() uint32 {
+	if x != nil {
+		return x.LastVersionId
+	}
+	return 0
+}
+
+func (x *FileEntry) GetMetadata() *FileMetadata {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+type FileMetadata struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MimeType      string                 `protobuf:"bytes,1,opt,name=mimeType,proto3" json:"mimeType,omitempty"`
+	OriginalSize  uint64                 `protobuf:"varint,2,opt,name=originalSize,proto3" json:"originalSize,omitempty"`
+	RootCid       string                 `protobuf:"bytes,3,opt,name=rootCid,proto3" json:"rootCid,omitempty"`
+	Attributes    map[string]string      `protobuf:"bytes,4,rep,name=attributes,proto3" json:"attributes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileMetadata) Reset() {
+	*x = FileMetadata{}
+	mi := &file_index_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileMetadata) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileMetadata) ProtoMessage() {}
+
+func (x *FileMetadata) ProtoReflect() protoreflect.Message {
+	mi := &file_index_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileMetadata.ProtoReflect.Descriptor instead.
+func (*FileMetadata) Descriptor() ([]byte, []int) {
+	return file_index_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *FileMetadata) GetMimeType() string {
+	if x != nil {
+		return x.MimeType
+	}
+	return ""
+}
+
+func (x *FileMetadata) GetOriginalSize() uint64 {
+	if x != nil {
+		return x.OriginalSize
+	}
+	return 0
+}
+
+func (x *FileMetadata) GetRootCid() string {
+	if x != nil {
+		return x.RootCid
+	}
+	return ""
+}
+
+func (x *FileMetadata) GetAttributes() map[string]string {
+	if x != nil {
+		return x.Attributes
+	}
+	return nil
+}
+
+type FileVersion struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Cids          []string               `protobuf:"bytes,2,rep,name=cids,proto3" json:"cids,omitempty"`
+	Size          uint64                 `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	CreateTime    int64                  `protobuf:"varint,4,opt,name=createTime,proto3" json:"createTime,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileVersion) Reset() {
+	*x = FileVersion{}
+	mi := &file_index_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileVersion) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileVersion) ProtoMessage() {}
+
+func (x *FileVersion) ProtoReflect() protoreflect.Message {
+	mi := &file_index_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileVersion.ProtoReflect.Descriptor instead.
+func (*FileVersion) Descriptor() ([]byte, []int) {
+	return file_index_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *FileVersion) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *FileVersion) GetCids() []string {
+	if x != nil {
+		return x.Cids
+	}
+	return nil
+}
+
+func (x *FileVersion) GetSize() uint64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *FileVersion) GetCreateTime() int64 {
+	if x != nil {
+		return x.CreateTime
+	}
+	return 0
+}
+
 type OwnershipRecord struct {
 	state          protoimpl.MessageState `protogen:"open.v1"`
 	OwnerId        string                 `protobuf:"bytes,1,opt,name=ownerId,proto3" json:"ownerId,omitempty"`
@@ -411,7 +649,7 @@ type OwnershipRecord struct {
 
 func (x *OwnershipRecord) Reset() {
 	*x = OwnershipRecord{}
-	mi := &file_index_proto_msgTypes[5]
+	mi := &file_index_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -423,7 +661,7 @@ func (x *OwnershipRecord) String() string {
 func (*OwnershipRecord) ProtoMessage() {}
 
 func (x *OwnershipRecord) ProtoReflect() protoreflect.Message {
-	mi := &file_index_proto_msgTypes[5]
+	mi := &file_index_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -436,7 +674,7 @@ func (x *OwnershipRecord) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use OwnershipRecord.ProtoReflect.Descriptor instead.
 func (*OwnershipRecord) Descriptor() ([]byte, []int) {
-	return file_index_proto_rawDescGZIP(), []int{5}
+	return file_index_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *OwnershipRecord) GetOwnerId() string {
@@ -469,7 +707,7 @@ const file_index_proto_rawDesc = "" +
 	"\x04refs\x18\x04 \x01(\x05R\x04refs\x12\x18\n" +
 	"\aversion\x18\x05 \x01(\rR\aversion\"\x1d\n" +
 	"\aCidList\x12\x12\n" +
-	"\x04cids\x18\x01 \x03(\fR\x04cids\"\xec\x01\n" +
+	"\x04cids\x18\x01 \x03(\fR\x04cids\"\x8e\x02\n" +
 	"\n" +
 	"GroupEntry\x12\x18\n" +
 	"\agroupId\x18\x01 \x01(\tR\agroupId\x12\x1e\n" +
@@ -483,7 +721,8 @@ const file_index_proto_rawDesc = "" +
 	"\bcidCount\x18\x05 \x01(\x04R\bcidCount\x12\x1a\n" +
 	"\bspaceIds\x18\x06 \x03(\tR\bspaceIds\x12\x14\n" +
 	"\x05limit\x18\a \x01(\x04R\x05limit\x12\"\n" +
-	"\faccountLimit\x18\b \x01(\x04R\faccountLimit\"\xca\x01\n" +
+	"\faccountLimit\x18\b \x01(\x04R\faccountLimit\x12 \n" +
+	"\vlogicalSize\x18\t \x01(\x04R\vlogicalSize\"\xb1\x02\n" +
 	"\n" +
 	"SpaceEntry\x12\x18\n" +
 	"\agroupId\x18\x01 \x01(\tR\agroupId\x12\x1e\n" +
@@ -496,7 +735,12 @@ const file_index_proto_rawDesc = "" +
 	"\x04size\x18\x04 \x01(\x04R\x04size\x12\x1c\n" +
 	"\tfileCount\x18\x05 \x01(\rR\tfileCount\x12\x1a\n" +
 	"\bcidCount\x18\x06 \x01(\x04R\bcidCount\x12\x14\n" +
-	"\x05limit\x18\a \x01(\x04R\x05limit\"s\n" +
+	"\x05limit\x18\a \x01(\x04R\x05limit\x12C\n" +
+	"\rversionPolicy\x18\b \x01(\v2\x1d.fileIndexProto.VersionPolicyR\rversionPolicy\x12 \n" +
+	"\vlogicalSize\x18\t \x01(\x04R\vlogicalSize\"O\n" +
+	"\rVersionPolicy\x12\"\n" +
+	"\fkeepVersions\x18\x01 \x01(\rR\fkeepVersions\x12\x1a\n" +
+	"\bkeepDays\x18\x02 \x01(\rR\bkeepDays\"\x8c\x02\n" +
 	"\tFileEntry\x12\x12\n" +
 	"\x04cids\x18\x01 \x03(\tR\x04cids\x12\x12\n" +
 	"\x04size\x18\x02 \x01(\x04R\x04size\x12\x1e\n" +
@@ -505,7 +749,27 @@ const file_index_proto_rawDesc = "" +
 	"createTime\x12\x1e\n" +
 	"\n" +
 	"updateTime\x18\x04 \x01(\x03R\n" +
-	"updateTime\"S\n" +
+	"updateTime\x127\n" +
+	"\bversions\x18\x05 \x03(\v2\x1b.fileIndexProto.FileVersionR\bversions\x12$\n" +
+	"\rlastVersionId\x18\x06 \x01(\rR\rlastVersionId\x128\n" +
+	"\bmetadata\x18\a \x01(\v2\x1c.fileIndexProto.FileMetadataR\bmetadata\"\xf5\x01\n" +
+	"\fFileMetadata\x12\x1a\n" +
+	"\bmimeType\x18\x01 \x01(\tR\bmimeType\x12\"\n" +
+	"\foriginalSize\x18\x02 \x01(\x04R\foriginalSize\x12\x18\n" +
+	"\arootCid\x18\x03 \x01(\tR\arootCid\x12L\n" +
+	"\n" +
+	"attributes\x18\x04 \x03(\v2,.fileIndexProto.FileMetadata.AttributesEntryR\n" +
+	"attributes\x1a=\n" +
+	"\x0fAttributesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"e\n" +
+	"\vFileVersion\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\rR\x02id\x12\x12\n" +
+	"\x04cids\x18\x02 \x03(\tR\x04cids\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x04R\x04size\x12\x1e\n" +
+	"\n" +
+	"createTime\x18\x04 \x01(\x03R\n" +
+	"createTime\"S\n" +
 	"\x0fOwnershipRecord\x12\x18\n" +
 	"\aownerId\x18\x01 \x01(\tR\aownerId\x12&\n" +
 	"\x0eaclRecordIndex\x18\x02 \x01(\x03R\x0eaclRecordIndexB\x12Z\x10index/indexprotob\x06proto3"
@@ -522,21 +786,29 @@ func file_index_proto_rawDescGZIP() []byte {
 	return file_index_proto_rawDescData
 }
 
-var file_index_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_index_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
 var file_index_proto_goTypes = []any{
 	(*CidEntry)(nil),        // 0: fileIndexProto.CidEntry
 	(*CidList)(nil),         // 1: fileIndexProto.CidList
 	(*GroupEntry)(nil),      // 2: fileIndexProto.GroupEntry
 	(*SpaceEntry)(nil),      // 3: fileIndexProto.SpaceEntry
-	(*FileEntry)(nil),       // 4: fileIndexProto.FileEntry
-	(*OwnershipRecord)(nil), // 5: fileIndexProto.OwnershipRecord
+	(*VersionPolicy)(nil),   // 4: fileIndexProto.VersionPolicy
+	(*FileEntry)(nil),       // 5: fileIndexProto.FileEntry
+	(*FileMetadata)(nil),    // 6: fileIndexProto.FileMetadata
+	(*FileVersion)(nil),     // 7: fileIndexProto.FileVersion
+	(*OwnershipRecord)(nil), // 8: fileIndexProto.OwnershipRecord
+	nil,                     // 9: fileIndexProto.FileMetadata.AttributesEntry
 }
 var file_index_proto_depIdxs = []int32{
-	0, // [0:0] is the sub-list for method output_type
-	0, // [0:0] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	4, // 0: fileIndexProto.SpaceEntry.versionPolicy:type_name -> fileIndexProto.VersionPolicy
+	7, // 1: fileIndexProto.FileEntry.versions:type_name -> fileIndexProto.FileVersion
+	6, // 2: fileIndexProto.FileEntry.metadata:type_name -> fileIndexProto.FileMetadata
+	9, // 3: fileIndexProto.FileMetadata.attributes:type_name -> fileIndexProto.FileMetadata.AttributesEntry
+	4, // [4:4] is the sub-list for method output_type
+	4, // [4:4] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
 }
 
 func init() { file_index_proto_init() }
@@ -550,7 +822,7 @@ func file_index_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_index_proto_rawDesc), len(file_index_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   6,
+			NumMessages:   10,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
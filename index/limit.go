@@ -15,7 +15,7 @@ import (
 var ErrLimitExceed = errors.New("limit exceed")
 
 func (ri *redisIndex) CheckLimits(ctx context.Context, key Key) (err error) {
-	entry, release, err := ri.AcquireSpace(ctx, key)
+	entry, release, err := ri.AcquireSpace(ctx, "checkLimits", key)
 	if err != nil {
 		return
 	}
@@ -58,7 +58,7 @@ type spaceLimitOp struct {
 }
 
 func (op *spaceLimitOp) SetGroupLimit(ctx context.Context, groupId string, limit uint64) (err error) {
-	_, release, err := op.AcquireKey(ctx, GroupKey(Key{GroupId: groupId}))
+	_, release, err := op.AcquireKey(ctx, "setGroupLimit", GroupKey(Key{GroupId: groupId}))
 	if err != nil {
 		return
 	}
@@ -106,7 +106,7 @@ func (op *spaceLimitOp) decreaseIsolatedLimit(ctx context.Context, k float64) (n
 
 func (op *spaceLimitOp) decreaseIsolatedLimitForSpace(ctx context.Context, spaceId string, k float64) (newIsolatedLimit uint64, err error) {
 	key := Key{GroupId: op.groupEntry.GroupId, SpaceId: spaceId}
-	_, release, err := op.AcquireKey(ctx, SpaceKey(key))
+	_, release, err := op.AcquireKey(ctx, "setGroupLimit", SpaceKey(key))
 	if err != nil {
 		return
 	}
@@ -127,7 +127,7 @@ func (op *spaceLimitOp) decreaseIsolatedLimitForSpace(ctx context.Context, space
 }
 
 func (op *spaceLimitOp) SetSpaceLimit(ctx context.Context, key Key, limit uint64) (err error) {
-	entry, release, err := op.AcquireSpace(ctx, key)
+	entry, release, err := op.AcquireSpace(ctx, "setSpaceLimit", key)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,207 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/anyproto/any-sync-filenode/index/indexproto"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const filesListDefaultLimit = 1000
+
+type FilesListSort int
+
+const (
+	FilesListUnsorted FilesListSort = iota
+	FilesListBySize
+	FilesListByCreateTime
+	FilesListByUpdateTime
+)
+
+type FilesListParams struct {
+	// Cursor is the NextCursor of the previous page, empty for the first page
+	Cursor string
+	Limit  int
+	Sort   FilesListSort
+	Desc   bool
+}
+
+type FileListItem struct {
+	FileId     string `json:"fileId"`
+	Size       uint64 `json:"size"`
+	CreateTime int64  `json:"createTime"`
+	UpdateTime int64  `json:"updateTime"`
+}
+
+type FilesListPage struct {
+	Files []FileListItem `json:"files"`
+	// NextCursor is empty when there are no more files
+	NextCursor string `json:"nextCursor"`
+}
+
+// FilesListPaged returns the space files page by page, it reads the space without the key lock.
+// Unsorted listing follows the HSCAN cursor, so the page size is approximate and a file changed during the iteration may be returned twice.
+// Sorted listing reads the page from the files index.
+func (ri *redisIndex) FilesListPaged(ctx context.Context, key Key, params FilesListParams) (page FilesListPage, err error) {
+	if params.Limit <= 0 {
+		params.Limit = filesListDefaultLimit
+	}
+	if params.Sort == FilesListUnsorted {
+		return ri.filesListScan(ctx, key, params)
+	}
+	return ri.filesListSorted(ctx, key, params)
+}
+
+func (ri *redisIndex) filesListScan(ctx context.Context, key Key, params FilesListParams) (page FilesListPage, err error) {
+	var cursor uint64
+	if params.Cursor != "" {
+		if cursor, err = strconv.ParseUint(params.Cursor, 10, 64); err != nil {
+			return page, ErrInvalidCursor
+		}
+	}
+	sk := SpaceKey(key)
+	for {
+		var scanCmd *redis.ScanCmd
+		if _, err = ri.readKey(ctx, sk, func(tx redis.Pipeliner) {
+			scanCmd = tx.HScan(ctx, sk, cursor, "f:*", int64(params.Limit))
+		}); err != nil {
+			return
+		}
+		var kvs []string
+		if kvs, cursor, err = scanCmd.Result(); err != nil {
+			return
+		}
+		for i := 0; i+1 < len(kvs); i += 2 {
+			if item, ok := newFileListItem(ctx, key, kvs[i], kvs[i+1]); ok {
+				page.Files = append(page.Files, item)
+			}
+		}
+		if cursor == 0 {
+			return
+		}
+		if len(page.Files) >= params.Limit {
+			page.NextCursor = strconv.FormatUint(cursor, 10)
+			return
+		}
+	}
+}
+
+func (ri *redisIndex) filesListSorted(ctx context.Context, key Key, params FilesListParams) (page FilesListPage, err error) {
+	prefix := params.Sort.indexPrefix()
+	if prefix == "" {
+		return page, fmt.Errorf("unexpected files list sort: %d", params.Sort)
+	}
+	// the page is read with one extra member to know if there are more files
+	rangeBy := &redis.ZRangeBy{Min: "[" + prefix, Max: "(" + prefix + "\xff", Count: int64(params.Limit) + 1}
+	if params.Cursor != "" {
+		after, cErr := parseSortedCursor(params.Cursor, params.Sort)
+		if cErr != nil {
+			return page, cErr
+		}
+		if params.Desc {
+			rangeBy.Max = "(" + after
+		} else {
+			rangeBy.Min = "(" + after
+		}
+	}
+	var (
+		sk, fk     = SpaceKey(key), FilesIndexKey(key)
+		membersCmd *redis.StringSliceCmd
+	)
+	if err = ri.readFilesIndex(ctx, key, func(tx redis.Pipeliner) {
+		if params.Desc {
+			membersCmd = tx.ZRevRangeByLex(ctx, fk, rangeBy)
+		} else {
+			membersCmd = tx.ZRangeByLex(ctx, fk, rangeBy)
+		}
+	}); err != nil {
+		return
+	}
+	members := membersCmd.Val()
+	if len(members) > params.Limit {
+		members = members[:params.Limit]
+		page.NextCursor = sortedCursor(members[len(members)-1], prefix)
+	}
+	if len(members) == 0 {
+		return
+	}
+
+	fileKeys := make([]string, len(members))
+	for i, m := range members {
+		fileKeys[i] = FileKey(filesIndexFileId(m))
+	}
+	var entriesCmd *redis.SliceCmd
+	if _, err = ri.readKey(ctx, sk, func(tx redis.Pipeliner) {
+		entriesCmd = tx.HMGet(ctx, sk, fileKeys...)
+	}); err != nil {
+		return
+	}
+	page.Files = make([]FileListItem, 0, len(members))
+	for i, v := range entriesCmd.Val() {
+		// the file can be removed after the index read
+		if data, ok := v.(string); ok {
+			if item, ok := newFileListItem(ctx, key, fileKeys[i], data); ok {
+				page.Files = append(page.Files, item)
+			}
+		}
+	}
+	return
+}
+
+// indexPrefix returns the prefix of the files index members ordered by the sort field
+func (sort FilesListSort) indexPrefix() string {
+	switch sort {
+	case FilesListBySize:
+		return filesIndexSize
+	case FilesListByCreateTime:
+		return filesIndexCreateTime
+	case FilesListByUpdateTime:
+		return filesIndexUpdateTime
+	}
+	return ""
+}
+
+// sortedCursor converts the files index member to the cursor {value}:{fileId}
+func sortedCursor(member, prefix string) string {
+	padded, fileId, _ := strings.Cut(member[len(prefix):], filesIndexSep)
+	value, _ := strconv.ParseInt(padded, 10, 64)
+	return strconv.FormatInt(value, 10) + ":" + fileId
+}
+
+// parseSortedCursor converts the cursor to the files index member
+func parseSortedCursor(cursor string, sort FilesListSort) (string, error) {
+	v, fileId, ok := strings.Cut(cursor, ":")
+	if !ok {
+		return "", ErrInvalidCursor
+	}
+	value, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return "", ErrInvalidCursor
+	}
+	return filesIndexSortMember(sort.indexPrefix(), value, fileId), nil
+}
+
+func newFileListItem(ctx context.Context, key Key, k, v string) (item FileListItem, ok bool) {
+	if !strings.HasPrefix(k, "f:") {
+		return
+	}
+	fileEntryProto := &indexproto.FileEntry{}
+	if err := fileEntryProto.UnmarshalVT([]byte(v)); err != nil {
+		log.WarnCtx(ctx, "can't unmarshal file entry", zap.String("spaceId", key.SpaceId), zap.String("fileId", k[2:]), zap.Error(err))
+		return
+	}
+	return FileListItem{
+		FileId:     k[2:],
+		Size:       fileEntryProto.Size,
+		CreateTime: fileEntryProto.CreateTime,
+		UpdateTime: fileEntryProto.UpdateTime,
+	}, true
+}
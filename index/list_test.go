@@ -0,0 +1,102 @@
+package index
+
+import (
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyproto/any-sync-filenode/testutil"
+)
+
+func TestRedisIndex_FilesListPaged(t *testing.T) {
+	fx := newFixture(t)
+	defer fx.Finish(t)
+	key := newRandKey()
+
+	bs := testutil.NewRandBlocks(5)
+	require.NoError(t, fx.BlocksAdd(ctx, bs))
+	var fileIds = make(map[string]struct{})
+	for i := range bs {
+		fileId := testutil.NewRandCid().String()
+		cids, err := fx.CidEntriesByBlocks(ctx, []blocks.Block{bs[i]})
+		require.NoError(t, err)
+		require.NoError(t, fx.FileBind(ctx, key, fileId, cids))
+		cids.Release()
+		fileIds[fileId] = struct{}{}
+	}
+
+	listAll := func(t *testing.T, params FilesListParams) (items []FileListItem) {
+		for {
+			page, err := fx.FilesListPaged(ctx, key, params)
+			require.NoError(t, err)
+			items = append(items, page.Files...)
+			if page.NextCursor == "" {
+				return
+			}
+			params.Cursor = page.NextCursor
+		}
+	}
+
+	t.Run("unsorted", func(t *testing.T) {
+		items := listAll(t, FilesListParams{Limit: 2})
+		var got = make(map[string]struct{})
+		for _, item := range items {
+			got[item.FileId] = struct{}{}
+		}
+		assert.Equal(t, fileIds, got)
+	})
+	t.Run("by size", func(t *testing.T) {
+		items := listAll(t, FilesListParams{Limit: 2, Sort: FilesListBySize})
+		require.Len(t, items, len(fileIds))
+		for i := 1; i < len(items); i++ {
+			assert.LessOrEqual(t, items[i-1].Size, items[i].Size)
+		}
+	})
+	t.Run("by size desc", func(t *testing.T) {
+		items := listAll(t, FilesListParams{Limit: 2, Sort: FilesListBySize, Desc: true})
+		require.Len(t, items, len(fileIds))
+		for i := 1; i < len(items); i++ {
+			assert.GreaterOrEqual(t, items[i-1].Size, items[i].Size)
+		}
+	})
+	t.Run("invalid cursor", func(t *testing.T) {
+		_, err := fx.FilesListPaged(ctx, key, FilesListParams{Cursor: "invalid", Sort: FilesListBySize})
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+	t.Run("by create time", func(t *testing.T) {
+		items := listAll(t, FilesListParams{Limit: 3, Sort: FilesListByCreateTime})
+		require.Len(t, items, len(fileIds))
+		for i := 1; i < len(items); i++ {
+			assert.LessOrEqual(t, items[i-1].CreateTime, items[i].CreateTime)
+		}
+	})
+	t.Run("index update", func(t *testing.T) {
+		require.NoError(t, fx.cl.ZScore(ctx, FilesIndexKey(key), filesIndexBuilt).Err())
+		var removed string
+		for fileId := range fileIds {
+			removed = fileId
+			break
+		}
+		require.NoError(t, fx.FileUnbind(ctx, key, removed))
+		delete(fileIds, removed)
+
+		// the new file is bigger than the others
+		bigBlock := testutil.NewRandBlock(1 << 20)
+		require.NoError(t, fx.BlocksAdd(ctx, []blocks.Block{bigBlock}))
+		cids, err := fx.CidEntriesByBlocks(ctx, []blocks.Block{bigBlock})
+		require.NoError(t, err)
+		added := testutil.NewRandCid().String()
+		require.NoError(t, fx.FileBind(ctx, key, added, cids))
+		cids.Release()
+		fileIds[added] = struct{}{}
+
+		items := listAll(t, FilesListParams{Limit: 2, Sort: FilesListBySize, Desc: true})
+		require.Len(t, items, len(fileIds))
+		assert.Equal(t, added, items[0].FileId)
+		for _, item := range items {
+			assert.NotEqual(t, removed, item.FileId)
+		}
+	})
+}
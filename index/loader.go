@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math/rand"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -15,7 +16,10 @@ import (
 	blocks "github.com/ipfs/go-block-format"
 	"github.com/ipfs/go-cid"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
+
+	"github.com/anyproto/any-sync-filenode/tracing"
 )
 
 const (
@@ -36,14 +40,14 @@ type persistentStore interface {
 	IndexGet(ctx context.Context, key string) (value []byte, err error)
 	IndexPut(ctx context.Context, key string, value []byte) (err error)
 	IndexDelete(ctx context.Context, key string) (err error)
+	IndexList(ctx context.Context, f func(keys []string) error) (err error)
 
 	Get(ctx context.Context, k cid.Cid) (blocks.Block, error)
 	DeleteMany(ctx context.Context, toDelete []cid.Cid) error
 }
 
 func bloomFilterKey(key string) string {
-	sum := xxhash.Sum64String(key) % partitionCount
-	return "bf:{" + strconv.FormatUint(sum, 10) + "}"
+	return bloomPartitionKey(keyPartition(key))
 }
 
 func storeKey(key string) string {
@@ -51,9 +55,9 @@ func storeKey(key string) string {
 	return "store:{" + strconv.FormatUint(sum, 10) + "}"
 }
 
-func (ri *redisIndex) CheckKey(ctx context.Context, key string) (exists bool, err error) {
+func (ri *redisIndex) CheckKey(ctx context.Context, op, key string) (exists bool, err error) {
 	var release func()
-	if exists, release, err = ri.acquireKey(ctx, key); err != nil {
+	if exists, release, err = ri.acquireKey(ctx, op, key); err != nil {
 		return
 	}
 	if exists {
@@ -66,8 +70,8 @@ func (ri *redisIndex) CheckKey(ctx context.Context, key string) (exists bool, er
 	return
 }
 
-func (ri *redisIndex) AcquireKey(ctx context.Context, key string) (exists bool, release func(), err error) {
-	if exists, release, err = ri.acquireKey(ctx, key); err != nil {
+func (ri *redisIndex) AcquireKey(ctx context.Context, op, key string) (exists bool, release func(), err error) {
+	if exists, release, err = ri.acquireKey(ctx, op, key); err != nil {
 		return
 	}
 	if err = ri.updateKeyUsage(ctx, key); err != nil {
@@ -77,12 +81,12 @@ func (ri *redisIndex) AcquireKey(ctx context.Context, key string) (exists bool,
 	return
 }
 
-func (ri *redisIndex) AcquireSpace(ctx context.Context, key Key) (entry groupSpaceEntry, release func(), err error) {
-	gExists, gRelease, err := ri.AcquireKey(ctx, GroupKey(key))
+func (ri *redisIndex) AcquireSpace(ctx context.Context, op string, key Key) (entry groupSpaceEntry, release func(), err error) {
+	gExists, gRelease, err := ri.AcquireKey(ctx, op, GroupKey(key))
 	if err != nil {
 		return
 	}
-	sExists, sRelease, err := ri.AcquireKey(ctx, SpaceKey(key))
+	sExists, sRelease, err := ri.AcquireKey(ctx, op, SpaceKey(key))
 	if err != nil {
 		gRelease()
 		return
@@ -96,7 +100,7 @@ func (ri *redisIndex) AcquireSpace(ctx context.Context, key Key) (entry groupSpa
 			delRelease func()
 			delExists  bool
 		)
-		if delExists, delRelease, err = ri.AcquireKey(ctx, delKey); err != nil {
+		if delExists, delRelease, err = ri.AcquireKey(ctx, op, delKey); err != nil {
 			gRelease()
 			sRelease()
 			return
@@ -140,54 +144,99 @@ func (ri *redisIndex) AcquireSpace(ctx context.Context, key Key) (entry groupSpa
 	return
 }
 
-func (ri *redisIndex) acquireKey(ctx context.Context, key string) (exists bool, release func(), err error) {
-	mu := ri.redsync.NewMutex("_lock:"+key, redsync.WithExpiry(time.Minute*20))
-	if err = mu.LockContext(ctx); err != nil {
+func (ri *redisIndex) acquireKey(ctx context.Context, op, key string) (exists bool, release func(), err error) {
+	mu := ri.redsync.NewMutex(lockKeyPrefix+key, redsync.WithExpiry(time.Minute*20), redsync.WithGenValueFunc(ri.lockValueFunc(op)))
+	st := time.Now()
+	_, span := tracing.Start(ctx, "index.lock", attribute.String("key", key))
+	err = mu.LockContext(ctx)
+	tracing.End(span, err)
+	if err != nil {
 		return
 	}
-	release = func() {
+	ri.metric.LockWait(time.Since(st))
+	release = ri.trackLock(ctx, func() {
 		_, _ = mu.Unlock()
+	})
+	if exists, err = ri.loadKey(ctx, key); err != nil {
+		release()
+		return false, nil, err
 	}
+	return exists, release, nil
+}
+
+// loadKey makes sure the key is in redis, restoring it from the persistent store if needed.
+// The restore is done under the load lock, so it doesn't require the key lock and is safe against the concurrent persist
+func (ri *redisIndex) loadKey(ctx context.Context, key string) (exists bool, err error) {
+	exists, _, err = ri.restoreKey(ctx, key)
+	return
+}
 
+// restoreKey works like loadKey, restored is true when the key was restored by this call
+func (ri *redisIndex) restoreKey(ctx context.Context, key string) (exists, restored bool, err error) {
 	// check in redis
 	ex, err := ri.cl.Exists(ctx, key).Result()
 	if err != nil {
-		release()
 		return
 	}
 	// already in redis
 	if ex > 0 {
-		return true, release, nil
+		return true, false, nil
 	}
 
 	// check bloom filter
 	bfKey := bloomFilterKey(key)
 	bloomEx, err := ri.cl.BFExists(ctx, bfKey, key).Result()
 	if err != nil {
-		release()
-		return false, nil, err
+		return
 	}
 	// not in bloom filter, item not exists
 	if !bloomEx {
-		return false, release, nil
+		ri.bloomCheckResult(key, "negative")
+		return false, false, nil
+	}
+
+	mu := ri.redsync.NewMutex(loadLockKey(key), redsync.WithExpiry(time.Minute), redsync.WithGenValueFunc(ri.lockValueFunc("load")))
+	if err = mu.LockContext(ctx); err != nil {
+		return
+	}
+	defer func() {
+		_, _ = mu.Unlock()
+	}()
+	// the key could be restored while we were waiting for the lock
+	if ex, err = ri.cl.Exists(ctx, key).Result(); err != nil {
+		return
+	}
+	if ex > 0 {
+		return true, false, nil
 	}
 
 	// try to load from persistent store
 	val, err := ri.persistStore.IndexGet(ctx, key)
 	if err != nil {
-		release()
-		return false, nil, err
+		return
 	}
 	// nil means not found
 	if val == nil {
-		return false, release, nil
+		ri.bloomCheckResult(key, "miss")
+		return false, false, nil
 	}
+	ri.bloomCheckResult(key, "hit")
 	if err = ri.cl.Restore(ctx, key, 0, string(val)).Err(); err != nil {
-		release()
-		return false, nil, err
+		return
+	}
+	if strings.HasPrefix(key, "g:") {
+		ri.backfillRestoredGroup(key)
+		if ri.prefetchConf.Enabled {
+			ri.prefetchGroup(key)
+		}
 	}
-	return true, release, nil
+	return true, true, nil
+}
+
+func loadLockKey(key string) string {
+	return lockKeyPrefix + "load:" + key
 }
+
 func (ri *redisIndex) updateKeyUsage(ctx context.Context, key string) (err error) {
 	sKey := storeKey(key)
 	return ri.cl.ZAdd(ctx, sKey, redis.Z{
@@ -204,6 +253,26 @@ func (ri *redisIndex) PersistKeys(ctx context.Context) {
 		partitions[i], partitions[j] = partitions[j], partitions[i]
 	})
 	stat := &persistStat{}
+	ri.persistPartitions(func(part int) error {
+		return ri.persistKeys(ctx, part, stat)
+	})
+	log.Info("persist",
+		zap.Duration("dur", time.Since(st)),
+		zap.Int32("handled", stat.handled.Load()),
+		zap.Int32("deleted", stat.deleted.Load()),
+		zap.Int32("missed", stat.missed.Load()),
+		zap.Int32("errors", stat.errors.Load()),
+		zap.Int32("moved", stat.moved.Load()),
+		zap.Int32("moved kbs", stat.movedBytes.Load()/1024),
+	)
+	ri.metric.Persist("moved", int(stat.moved.Load()))
+	ri.metric.Persist("missed", int(stat.missed.Load()))
+	ri.metric.Persist("deleted", int(stat.deleted.Load()))
+	ri.metric.Persist("error", int(stat.errors.Load()))
+}
+
+// persistPartitions calls f for every partition using persistThreads goroutines
+func (ri *redisIndex) persistPartitions(f func(part int) error) {
 	var wg sync.WaitGroup
 	wg.Add(len(partitions))
 	var limiter = make(chan struct{}, persistThreads)
@@ -214,25 +283,16 @@ func (ri *redisIndex) PersistKeys(ctx context.Context) {
 				<-limiter
 				wg.Done()
 			}()
-			if e := ri.persistKeys(ctx, p, stat); e != nil {
+			if e := f(p); e != nil {
 				log.Warn("persist part error", zap.Error(e), zap.Int("part", p))
 			}
 		}(part)
 	}
 	wg.Wait()
-	log.Info("persist",
-		zap.Duration("dur", time.Since(st)),
-		zap.Int32("handled", stat.handled.Load()),
-		zap.Int32("deleted", stat.deleted.Load()),
-		zap.Int32("missed", stat.missed.Load()),
-		zap.Int32("errors", stat.errors.Load()),
-		zap.Int32("moved", stat.moved.Load()),
-		zap.Int32("moved kbs", stat.movedBytes.Load()/1024),
-	)
 }
 
 func (ri *redisIndex) persistKeys(ctx context.Context, part int, stat *persistStat) (err error) {
-	deadline := time.Now().Add(-ri.persistTtl).Unix()
+	deadline := time.Now().Add(-time.Duration(ri.persistTtl.Load())).Unix()
 	sk := "store:{" + strconv.FormatInt(int64(part), 10) + "}"
 	keys, err := ri.cl.ZRangeByScore(ctx, sk, &redis.ZRangeBy{
 		Min: "0",
@@ -253,13 +313,21 @@ func (ri *redisIndex) persistKey(ctx context.Context, storeKey, key string, dead
 	// use the same expiry as acquireKey: the default 8s expiry can elapse during slow
 	// persistent-store IO, letting a concurrent acquireKey mutate the key between our
 	// dump and the final delete, losing the update
-	mu := ri.redsync.NewMutex("_lock:"+key, redsync.WithExpiry(time.Minute*20))
+	mu := ri.redsync.NewMutex(lockKeyPrefix+key, redsync.WithExpiry(time.Minute*20), redsync.WithGenValueFunc(ri.lockValueFunc("persist")))
 	if err = mu.LockContext(ctx); err != nil {
 		return
 	}
 	defer func() {
 		_, _ = mu.Unlock()
 	}()
+	// the load lock keeps the lock-free readers from restoring the key while it is moved to the persistent store
+	loadMu := ri.redsync.NewMutex(loadLockKey(key), redsync.WithExpiry(time.Minute*20), redsync.WithGenValueFunc(ri.lockValueFunc("persist")))
+	if err = loadMu.LockContext(ctx); err != nil {
+		return
+	}
+	defer func() {
+		_, _ = loadMu.Unlock()
+	}()
 
 	stat.handled.Add(1)
 
@@ -295,15 +363,19 @@ func (ri *redisIndex) persistKey(ctx context.Context, storeKey, key string, dead
 	// remove from queue and add to bloom filter
 	_, err = ri.cl.TxPipelined(ctx, func(tx redis.Pipeliner) error {
 		tx.ZRem(ctx, storeKey, key)
-		tx.BFAdd(ctx, bloomFilterKey(key), key)
+		bloomAdd(ctx, tx, key)
 		return nil
 	})
 
 	stat.moved.Add(1)
 	stat.movedBytes.Add(int32(len(dump)))
 
-	// remove key
-	return ri.cl.Del(ctx, key).Err()
+	// remove key, the files index isn't persisted, it's built again after the space is loaded
+	toDelete := []string{key}
+	if strings.HasPrefix(key, "s:") {
+		toDelete = append(toDelete, filesIndexKeyBySpaceKey(key))
+	}
+	return ri.cl.Del(ctx, toDelete...).Err()
 }
 
 type persistStat struct {
@@ -26,7 +26,7 @@ func TestRedisIndex_PersistKeys(t *testing.T) {
 		defer fx.Finish(t)
 		bs := testutil.NewRandBlocks(5)
 		for _, b := range bs {
-			_, release, _ := fx.AcquireKey(ctx, CidKey(b.Cid()))
+			_, release, _ := fx.AcquireKey(ctx, "test", CidKey(b.Cid()))
 			release()
 			fx.persistStore.EXPECT().IndexDelete(ctx, CidKey(b.Cid()))
 		}
@@ -75,7 +75,7 @@ func TestRedisIndex_AcquireKey(t *testing.T) {
 	fx.PersistKeys(ctx)
 
 	for i, b := range bs {
-		ex, release, err := fx.AcquireKey(ctx, CidKey(b.Cid()))
+		ex, release, err := fx.AcquireKey(ctx, "test", CidKey(b.Cid()))
 		require.NoError(t, err)
 		if i == 0 {
 			require.False(t, ex)
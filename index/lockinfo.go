@@ -0,0 +1,199 @@
+package index
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const lockKeyPrefix = "_lock:"
+
+var ErrNotLockKey = errors.New("not a lock key")
+
+// lockOwner is stored as the lock value, so the held locks can be inspected.
+// The token makes the value unique, redsync and the block unlock script compare the whole value
+type lockOwner struct {
+	Token     string `json:"token"`
+	Instance  string `json:"instance"`
+	Operation string `json:"op"`
+	Since     int64  `json:"since"`
+}
+
+// LockInfo describes the lock held in redis
+type LockInfo struct {
+	Key       string    `json:"key"`
+	Instance  string    `json:"instance,omitempty"`
+	Operation string    `json:"op,omitempty"`
+	Since     time.Time `json:"since,omitzero"`
+	Age       string    `json:"age,omitempty"`
+	Ttl       string    `json:"ttl"`
+	// Value is the raw lock value when it has no owner info, e.g. the locks taken by the other components
+	Value string `json:"value,omitempty"`
+}
+
+func instanceId() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}
+
+func (ri *redisIndex) newLockValue(op string) (string, error) {
+	token, err := newLockToken()
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(lockOwner{
+		Token:     token,
+		Instance:  ri.instanceId,
+		Operation: op,
+		Since:     time.Now().UnixMilli(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (ri *redisIndex) lockValueFunc(op string) func() (string, error) {
+	return func() (string, error) {
+		return ri.newLockValue(op)
+	}
+}
+
+// trackLock wraps the unlock func with the hold time metric and registers it in the held locks when the ctx is marked by CtxWithTrackedLocks
+func (ri *redisIndex) trackLock(ctx context.Context, unlock func()) (release func()) {
+	st := time.Now()
+	release = func() {
+		ri.metric.LockHold(time.Since(st))
+		unlock()
+	}
+	if tracked, _ := ctx.Value(ctxTrackLocks).(bool); !tracked {
+		return sync.OnceFunc(release)
+	}
+	return ri.heldLocks.track(release)
+}
+
+// Locks returns the locks currently held in redis by all the instances
+func (ri *redisIndex) Locks(ctx context.Context) (locks []LockInfo, err error) {
+	var mu sync.Mutex
+	scan := func(ctx context.Context, cl redis.Cmdable) error {
+		shardLocks, sErr := ri.shardLocks(ctx, cl)
+		mu.Lock()
+		locks = append(locks, shardLocks...)
+		mu.Unlock()
+		return sErr
+	}
+	if cc, ok := ri.cl.(*redis.ClusterClient); ok {
+		err = cc.ForEachMaster(ctx, func(ctx context.Context, shard *redis.Client) error {
+			return scan(ctx, shard)
+		})
+	} else {
+		err = scan(ctx, ri.cl)
+	}
+	slices.SortFunc(locks, func(a, b LockInfo) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return
+}
+
+func (ri *redisIndex) shardLocks(ctx context.Context, cl redis.Cmdable) (locks []LockInfo, err error) {
+	var cursor uint64
+	for {
+		var keys []string
+		if keys, cursor, err = cl.Scan(ctx, cursor, lockKeyPrefix+"*", 1000).Result(); err != nil {
+			return
+		}
+		if len(keys) != 0 {
+			var shardLocks []LockInfo
+			if shardLocks, err = ri.lockInfos(ctx, cl, keys); err != nil {
+				return
+			}
+			locks = append(locks, shardLocks...)
+		}
+		if cursor == 0 {
+			return
+		}
+	}
+}
+
+func (ri *redisIndex) lockInfos(ctx context.Context, cl redis.Cmdable, keys []string) (locks []LockInfo, err error) {
+	var (
+		valCmds = make([]*redis.StringCmd, len(keys))
+		ttlCmds = make([]*redis.DurationCmd, len(keys))
+	)
+	if _, err = cl.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			valCmds[i] = pipe.Get(ctx, key)
+			ttlCmds[i] = pipe.PTTL(ctx, key)
+		}
+		return nil
+	}); err != nil && !errors.Is(err, redis.Nil) {
+		return
+	}
+	err = nil
+	now := time.Now()
+	for i, key := range keys {
+		val, vErr := valCmds[i].Result()
+		if vErr != nil {
+			// the lock is released during the scan
+			continue
+		}
+		info := LockInfo{Key: key, Ttl: ttlCmds[i].Val().String()}
+		var owner lockOwner
+		if json.Unmarshal([]byte(val), &owner) == nil && owner.Instance != "" {
+			info.Instance = owner.Instance
+			info.Operation = owner.Operation
+			info.Since = time.UnixMilli(owner.Since)
+			info.Age = now.Sub(info.Since).Truncate(time.Millisecond).String()
+		} else {
+			info.Value = val
+		}
+		locks = append(locks, info)
+	}
+	return
+}
+
+// forceUnlockScript deletes the lock only if it still has the given value, so the lock taken again after the read isn't released.
+// KEYS: lock key; ARGV: lock value, notification channel or empty string
+var forceUnlockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+redis.call('DEL', KEYS[1])
+if ARGV[2] ~= '' then
+	redis.call('PUBLISH', ARGV[2], KEYS[1])
+end
+return 1
+`)
+
+// ForceReleaseLock deletes the lock regardless of the owner, the waiters of the block locks are notified
+func (ri *redisIndex) ForceReleaseLock(ctx context.Context, key string) (released bool, err error) {
+	if !strings.HasPrefix(key, lockKeyPrefix) {
+		return false, fmt.Errorf("%w: %q", ErrNotLockKey, key)
+	}
+	val, err := ri.cl.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return
+	}
+	var channel string
+	if strings.HasPrefix(key, blockLockKeyPrefix) {
+		channel = blocksUnlockChannel
+	}
+	deleted, err := forceUnlockScript.Run(ctx, ri.cl, []string{key}, val, channel).Int()
+	if err != nil || deleted == 0 {
+		return
+	}
+	log.WarnCtx(ctx, "lock is force released", zap.String("key", key), zap.String("value", val))
+	return true, nil
+}
@@ -0,0 +1,81 @@
+package index
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyproto/any-sync-filenode/testutil"
+)
+
+func TestNewLockValue(t *testing.T) {
+	ri := &redisIndex{instanceId: "host:1"}
+	val, err := ri.newLockValue("op")
+	require.NoError(t, err)
+	var owner lockOwner
+	require.NoError(t, json.Unmarshal([]byte(val), &owner))
+	assert.Equal(t, "host:1", owner.Instance)
+	assert.Equal(t, "op", owner.Operation)
+	assert.NotEmpty(t, owner.Token)
+}
+
+func TestRedisIndex_Locks(t *testing.T) {
+	fx := newFixture(t)
+	defer fx.Finish(t)
+
+	key := SpaceKey(newRandKey())
+	_, release, err := fx.AcquireKey(ctx, "test", key)
+	require.NoError(t, err)
+	defer release()
+	b := testutil.NewRandBlock(10)
+	unlock, err := fx.lockBlocks(ctx, "test", []string{blockLockKey(b.Cid())})
+	require.NoError(t, err)
+	defer unlock()
+
+	locks, err := fx.Locks(ctx)
+	require.NoError(t, err)
+	var found int
+	for _, lock := range locks {
+		if lock.Key == lockKeyPrefix+key || lock.Key == blockLockKey(b.Cid()) {
+			found++
+			assert.Equal(t, fx.instanceId, lock.Instance)
+			assert.Equal(t, "test", lock.Operation)
+			assert.NotEmpty(t, lock.Age)
+		}
+	}
+	assert.Equal(t, 2, found)
+
+	t.Run("force release", func(t *testing.T) {
+		released, err := fx.ForceReleaseLock(ctx, lockKeyPrefix+key)
+		require.NoError(t, err)
+		assert.True(t, released)
+
+		// the lock is available for the others
+		tCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		_, release2, err := fx.AcquireKey(tCtx, "test", key)
+		require.NoError(t, err)
+		release2()
+
+		released, err = fx.ForceReleaseLock(ctx, lockKeyPrefix+key)
+		require.NoError(t, err)
+		assert.False(t, released)
+	})
+	t.Run("taken again", func(t *testing.T) {
+		// the lock taken by another owner after the read is kept
+		require.NoError(t, fx.cl.Set(ctx, lockKeyPrefix+key, "new", time.Minute).Err())
+		deleted, err := forceUnlockScript.Run(ctx, fx.cl, []string{lockKeyPrefix + key}, "old", "").Int()
+		require.NoError(t, err)
+		assert.Zero(t, deleted)
+		assert.Equal(t, "new", fx.cl.Get(ctx, lockKeyPrefix+key).Val())
+		require.NoError(t, fx.cl.Del(ctx, lockKeyPrefix+key).Err())
+	})
+	t.Run("not a lock", func(t *testing.T) {
+		_, err := fx.ForceReleaseLock(ctx, key)
+		assert.ErrorIs(t, err, ErrNotLockKey)
+	})
+}
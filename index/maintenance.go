@@ -0,0 +1,68 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	maintenanceKey     = "maintenance.{system}"
+	maintenanceChannel = "maintenanceChan"
+)
+
+// ErrMaintenance is returned by the admin writes while the cluster is in maintenance
+var ErrMaintenance = errors.New("maintenance mode, writes are disabled")
+
+type MaintenanceStatus struct {
+	Enabled bool   `json:"enabled"`
+	Reason  string `json:"reason,omitempty"`
+	// Since is the unix time of the enabling
+	Since int64 `json:"since,omitempty"`
+}
+
+// SetMaintenance enables or disables the cluster-wide read-only mode and notifies all the instances
+func (ri *redisIndex) SetMaintenance(ctx context.Context, enabled bool, reason string) (err error) {
+	if enabled {
+		err = ri.cl.HSet(ctx, maintenanceKey, "since", time.Now().Unix(), "reason", reason).Err()
+	} else {
+		err = ri.cl.Del(ctx, maintenanceKey).Err()
+	}
+	if err != nil {
+		return
+	}
+	log.InfoCtx(ctx, "maintenance mode changed", zap.Bool("enabled", enabled), zap.String("reason", reason))
+	ri.maintenance.Store(enabled)
+	return ri.cl.Publish(ctx, maintenanceChannel, strconv.FormatBool(enabled)).Err()
+}
+
+// MaintenanceStatus reads the status from redis bypassing the instance cache
+func (ri *redisIndex) MaintenanceStatus(ctx context.Context) (status MaintenanceStatus, err error) {
+	res, err := ri.cl.HGetAll(ctx, maintenanceKey).Result()
+	if err != nil || len(res) == 0 {
+		return
+	}
+	status.Enabled = true
+	status.Reason = res["reason"]
+	status.Since, _ = strconv.ParseInt(res["since"], 10, 64)
+	return
+}
+
+// InMaintenance returns the cached flag, it's updated by the pub/sub notification and the periodic refresh
+func (ri *redisIndex) InMaintenance() bool {
+	return ri.maintenance.Load()
+}
+
+func (ri *redisIndex) refreshMaintenance(ctx context.Context) (err error) {
+	status, err := ri.MaintenanceStatus(ctx)
+	if err != nil {
+		return
+	}
+	if prev := ri.maintenance.Swap(status.Enabled); prev != status.Enabled {
+		log.Info("maintenance mode changed", zap.Bool("enabled", status.Enabled), zap.String("reason", status.Reason))
+	}
+	return
+}
@@ -0,0 +1,39 @@
+package index
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyproto/any-sync-filenode/redisprovider/testredisprovider"
+)
+
+func TestRedisIndex_Maintenance(t *testing.T) {
+	fx := newFixture(t)
+	defer fx.Finish(t)
+
+	// the second instance shares the redis db
+	fx2 := newFixtureProvider(t, nil, testredisprovider.NewTestRedisProvider().WithFLush(false))
+	defer fx2.Finish(t)
+
+	assert.False(t, fx.InMaintenance())
+	require.NoError(t, fx.SetMaintenance(ctx, true, "s3 migration"))
+	assert.True(t, fx.InMaintenance())
+
+	status, err := fx2.MaintenanceStatus(ctx)
+	require.NoError(t, err)
+	assert.True(t, status.Enabled)
+	assert.Equal(t, "s3 migration", status.Reason)
+	assert.NotZero(t, status.Since)
+	assert.Eventually(t, fx2.InMaintenance, time.Second, time.Millisecond*10)
+
+	require.NoError(t, fx2.SetMaintenance(ctx, false, ""))
+	assert.Eventually(t, func() bool {
+		return !fx.InMaintenance()
+	}, time.Second, time.Millisecond*10)
+	status, err = fx.MaintenanceStatus(ctx)
+	require.NoError(t, err)
+	assert.False(t, status.Enabled)
+}
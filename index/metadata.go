@@ -0,0 +1,157 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/anyproto/any-sync-filenode/index/indexproto"
+)
+
+var ErrFileNotFound = errors.New("file not found")
+
+const filesScanCount = 1000
+
+type FileMetadata struct {
+	MimeType     string            `json:"mimeType,omitempty"`
+	OriginalSize uint64            `json:"originalSize,omitempty"`
+	RootCid      string            `json:"rootCid,omitempty"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+}
+
+// FileMetadataFilter matches the file metadata, empty fields match any value, the files without metadata don't match
+type FileMetadataFilter struct {
+	MimeType   string
+	RootCid    string
+	Attributes map[string]string
+}
+
+func (f FileMetadataFilter) Match(m *FileMetadata) bool {
+	if m == nil {
+		return false
+	}
+	if f.MimeType != "" && f.MimeType != m.MimeType {
+		return false
+	}
+	if f.RootCid != "" && f.RootCid != m.RootCid {
+		return false
+	}
+	for k, v := range f.Attributes {
+		if mv, ok := m.Attributes[k]; !ok || mv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// indexPrefix returns the prefix of the files index members for the most selective field of the filter
+func (f FileMetadataFilter) indexPrefix() string {
+	if f.RootCid != "" {
+		return filesIndexRootCid + f.RootCid + filesIndexSep
+	}
+	if len(f.Attributes) != 0 {
+		k := slices.Min(slices.Collect(maps.Keys(f.Attributes)))
+		return filesIndexAttribute + k + filesIndexSep + f.Attributes[k] + filesIndexSep
+	}
+	if f.MimeType != "" {
+		return filesIndexMimeType + f.MimeType + filesIndexSep
+	}
+	return filesIndexMetadata
+}
+
+func (m FileMetadata) proto() *indexproto.FileMetadata {
+	return &indexproto.FileMetadata{
+		MimeType:     m.MimeType,
+		OriginalSize: m.OriginalSize,
+		RootCid:      m.RootCid,
+		Attributes:   m.Attributes,
+	}
+}
+
+// FilesFind returns info of the space files with metadata matched by the filter.
+// The files are looked up in the files index by the most selective field of the filter and checked by the rest of the fields.
+func (ri *redisIndex) FilesFind(ctx context.Context, key Key, filter FileMetadataFilter, limit int) (fileInfos []FileInfo, err error) {
+	var (
+		sk     = SpaceKey(key)
+		fk     = FilesIndexKey(key)
+		prefix = filter.indexPrefix()
+		// the values are utf-8 strings, they can't contain 0xff
+		rangeBy = &redis.ZRangeBy{Min: "[" + prefix, Max: "(" + prefix + "\xff", Count: filesScanCount}
+	)
+	for {
+		var membersCmd *redis.StringSliceCmd
+		if err = ri.readFilesIndex(ctx, key, func(tx redis.Pipeliner) {
+			membersCmd = tx.ZRangeByLex(ctx, fk, rangeBy)
+		}); err != nil {
+			return
+		}
+		members := membersCmd.Val()
+		if len(members) == 0 {
+			return
+		}
+		fileKeys := make([]string, len(members))
+		for i, m := range members {
+			fileKeys[i] = FileKey(filesIndexFileId(m))
+		}
+		var entriesCmd *redis.SliceCmd
+		if _, err = ri.readKey(ctx, sk, func(tx redis.Pipeliner) {
+			entriesCmd = tx.HMGet(ctx, sk, fileKeys...)
+		}); err != nil {
+			return
+		}
+		for i, v := range entriesCmd.Val() {
+			// the file can be removed after the index read
+			data, ok := v.(string)
+			if !ok {
+				continue
+			}
+			fileEntryProto := &indexproto.FileEntry{}
+			if uErr := fileEntryProto.UnmarshalVT([]byte(data)); uErr != nil {
+				log.WarnCtx(ctx, "can't unmarshal file entry", zap.String("spaceId", key.SpaceId), zap.String("fileId", fileKeys[i][2:]), zap.Error(uErr))
+				continue
+			}
+			fileInfo := newFileInfo(fileKeys[i][2:], &fileEntry{FileEntry: fileEntryProto})
+			if filter.Match(fileInfo.Metadata) {
+				fileInfos = append(fileInfos, fileInfo)
+				if limit > 0 && len(fileInfos) >= limit {
+					return
+				}
+			}
+		}
+		if len(members) < filesScanCount {
+			return
+		}
+		rangeBy.Min = "(" + members[len(members)-1]
+	}
+}
+
+// filesIndexFileId returns the file id of the files index member
+func filesIndexFileId(member string) string {
+	if i := strings.LastIndex(member, filesIndexSep); i != -1 {
+		return member[i+1:]
+	}
+	return member[len(filesIndexMetadata):]
+}
+
+func newFileInfo(fileId string, fEntry *fileEntry) FileInfo {
+	fileInfo := FileInfo{
+		FileId:        fileId,
+		BytesUsage:    fEntry.Size,
+		CidsCount:     uint64(len(fEntry.Cids)),
+		VersionsCount: uint32(len(fEntry.Versions)),
+	}
+	if fEntry.Metadata != nil {
+		fileInfo.Metadata = &FileMetadata{
+			MimeType:     fEntry.Metadata.MimeType,
+			OriginalSize: fEntry.Metadata.OriginalSize,
+			RootCid:      fEntry.Metadata.RootCid,
+			Attributes:   fEntry.Metadata.Attributes,
+		}
+	}
+	return fileInfo
+}
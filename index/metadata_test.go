@@ -0,0 +1,119 @@
+package index
+
+import (
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyproto/any-sync-filenode/testutil"
+)
+
+func TestRedisIndex_FileBindWithMetadata(t *testing.T) {
+	bindFiles := func(t *testing.T, fx *fixture, key Key, bs []blocks.Block, metas ...*FileMetadata) (fileIds []string) {
+		for i, b := range bs {
+			fileId := testutil.NewRandCid().String()
+			cids, err := fx.CidEntriesByBlocks(ctx, []blocks.Block{b})
+			require.NoError(t, err)
+			if i < len(metas) && metas[i] != nil {
+				require.NoError(t, fx.FileBindWithMetadata(ctx, key, fileId, cids, *metas[i]))
+			} else {
+				require.NoError(t, fx.FileBind(ctx, key, fileId, cids))
+			}
+			cids.Release()
+			fileIds = append(fileIds, fileId)
+		}
+		return
+	}
+	t.Run("bind and find", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.Finish(t)
+		key := newRandKey()
+		bs := testutil.NewRandBlocks(2)
+		require.NoError(t, fx.BlocksAdd(ctx, bs))
+
+		meta := FileMetadata{
+			MimeType:     "image/png",
+			OriginalSize: 42,
+			RootCid:      bs[0].Cid().String(),
+			Attributes:   map[string]string{"name": "cat.png"},
+		}
+		fileIds := bindFiles(t, fx, key, bs, &meta)
+
+		fInfo, err := fx.FileInfo(ctx, key, fileIds...)
+		require.NoError(t, err)
+		require.Len(t, fInfo, 2)
+		assert.Equal(t, &meta, fInfo[0].Metadata)
+		assert.Nil(t, fInfo[1].Metadata)
+
+		found, err := fx.FilesFind(ctx, key, FileMetadataFilter{Attributes: map[string]string{"name": "cat.png"}}, 0)
+		require.NoError(t, err)
+		require.Len(t, found, 1)
+		assert.Equal(t, fileIds[0], found[0].FileId)
+
+		found, err = fx.FilesFind(ctx, key, FileMetadataFilter{RootCid: bs[0].Cid().String(), MimeType: "image/png"}, 0)
+		require.NoError(t, err)
+		require.Len(t, found, 1)
+
+		found, err = fx.FilesFind(ctx, key, FileMetadataFilter{MimeType: "text/plain"}, 0)
+		require.NoError(t, err)
+		assert.Empty(t, found)
+
+		// the empty filter returns the files with metadata
+		found, err = fx.FilesFind(ctx, key, FileMetadataFilter{}, 0)
+		require.NoError(t, err)
+		assert.Len(t, found, 1)
+	})
+	t.Run("index update", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.Finish(t)
+		key := newRandKey()
+		bs := testutil.NewRandBlocks(3)
+		require.NoError(t, fx.BlocksAdd(ctx, bs))
+		pngMeta := &FileMetadata{MimeType: "image/png"}
+		fileIds := bindFiles(t, fx, key, bs[:2], pngMeta, pngMeta)
+
+		// the first search builds the index
+		found, err := fx.FilesFind(ctx, key, FileMetadataFilter{MimeType: "image/png"}, 0)
+		require.NoError(t, err)
+		assert.Len(t, found, 2)
+		require.NoError(t, fx.cl.ZScore(ctx, FilesIndexKey(key), filesIndexBuilt).Err())
+
+		// the next writes update the index
+		bindFiles(t, fx, key, bs[2:], pngMeta)
+		// the same cids with the new metadata
+		cids, err := fx.CidEntriesByBlocks(ctx, bs[:1])
+		require.NoError(t, err)
+		require.NoError(t, fx.FileBindWithMetadata(ctx, key, fileIds[0], cids, FileMetadata{MimeType: "text/plain"}))
+		cids.Release()
+		require.NoError(t, fx.FileUnbind(ctx, key, fileIds[1]))
+
+		found, err = fx.FilesFind(ctx, key, FileMetadataFilter{MimeType: "image/png"}, 0)
+		require.NoError(t, err)
+		require.Len(t, found, 1)
+		assert.NotEqual(t, fileIds[0], found[0].FileId)
+		assert.NotEqual(t, fileIds[1], found[0].FileId)
+
+		found, err = fx.FilesFind(ctx, key, FileMetadataFilter{MimeType: "text/plain"}, 0)
+		require.NoError(t, err)
+		require.Len(t, found, 1)
+		assert.Equal(t, fileIds[0], found[0].FileId)
+
+		// the metadata change doesn't change the usage
+		spaceInfo, err := fx.SpaceInfo(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, uint32(2), spaceInfo.FileCount)
+
+		res, err := fx.Check(ctx, key, false)
+		require.NoError(t, err)
+		assert.Empty(t, res)
+
+		// the index is removed with the space
+		_, err = fx.SpaceDelete(ctx, key)
+		require.NoError(t, err)
+		ex, err := fx.cl.Exists(ctx, FilesIndexKey(key)).Result()
+		require.NoError(t, err)
+		assert.Zero(t, ex)
+	})
+}
@@ -0,0 +1,57 @@
+package index
+
+import (
+	"math"
+
+	"go.uber.org/zap"
+)
+
+func (ri *redisIndex) registerMetrics() (err error) {
+	systemCounter := func(key string) func() float64 {
+		return func() float64 {
+			res, err := ri.getSystemCounter(ri.ctx, key)
+			if err != nil {
+				log.Warn("can't get system counter", zap.String("key", key), zap.Error(err))
+				return 0
+			}
+			return float64(res)
+		}
+	}
+	gauges := []struct {
+		subsystem, name, help string
+		f                     func() float64
+	}{
+		{"index", "cid_count", "count of stored cids", systemCounter(cidCount)},
+		{"index", "cid_size_bytes", "sum of the stored cid sizes", systemCounter(cidSizeSumKey)},
+		{"index", "cid_waiting", "count of cids waiting for the upload", func() float64 {
+			ri.cidSubscriptionsMu.Lock()
+			defer ri.cidSubscriptionsMu.Unlock()
+			return float64(len(ri.cidSubscriptions))
+		}},
+		{"persist", "memory_usage_ratio", "redis used memory to max memory ratio", func() float64 {
+			return math.Float64frombits(ri.memoryRatio.Load())
+		}},
+		{"persist", "memory_pressure_active", "1 while the keys are persisted because of the memory pressure", func() float64 {
+			if ri.pressureActive.Load() {
+				return 1
+			}
+			return 0
+		}},
+		{"dedup", "logical_bytes", "sum of the file sizes", systemCounter(fileSizeSumKey)},
+		{"dedup", "physical_bytes", "sum of the unique cid sizes", systemCounter(cidSizeSumKey)},
+		{"index", "anomaly_queue_size", "count of the groups waiting for the self-heal check", func() float64 {
+			res, err := ri.cl.ZCard(ri.ctx, anomalyQueueKey).Result()
+			if err != nil {
+				log.Warn("can't get the anomaly queue size", zap.Error(err))
+				return 0
+			}
+			return float64(res)
+		}},
+	}
+	for _, g := range gauges {
+		if err = ri.metric.RegisterGaugeFunc(g.subsystem, g.name, g.help, g.f); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -39,7 +39,7 @@ func (ri *redisIndex) Migrate(ctx context.Context, key Key) (err error) {
 	}
 
 	// lock the key
-	mu := ri.redsync.NewMutex("_lock:"+migrateKey, redsync.WithExpiry(time.Minute))
+	mu := ri.redsync.NewMutex(lockKeyPrefix+migrateKey, redsync.WithExpiry(time.Minute), redsync.WithGenValueFunc(ri.lockValueFunc("migrate")))
 	if err = mu.LockContext(ctx); err != nil {
 		return
 	}
@@ -12,6 +12,7 @@ package mock_index
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	index "github.com/anyproto/any-sync-filenode/index"
 	app "github.com/anyproto/any-sync/app"
@@ -44,6 +45,22 @@ func (m *MockIndex) EXPECT() *MockIndexMockRecorder {
 	return m.recorder
 }
 
+// AccountInfo mocks base method.
+func (m *MockIndex) AccountInfo(ctx context.Context, groupId string) (index.AccountInfo, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AccountInfo", ctx, groupId)
+	ret0, _ := ret[0].(index.AccountInfo)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// AccountInfo indicates an expected call of AccountInfo.
+func (mr *MockIndexMockRecorder) AccountInfo(ctx, groupId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AccountInfo", reflect.TypeOf((*MockIndex)(nil).AccountInfo), ctx, groupId)
+}
+
 // BlocksAdd mocks base method.
 func (m *MockIndex) BlocksAdd(ctx context.Context, bs []blocks.Block) error {
 	m.ctrl.T.Helper()
@@ -74,18 +91,48 @@ func (mr *MockIndexMockRecorder) BlocksGetNonExistent(ctx, bs any) *gomock.Call
 }
 
 // BlocksLock mocks base method.
-func (m *MockIndex) BlocksLock(ctx context.Context, bs []blocks.Block) (func(), error) {
+func (m *MockIndex) BlocksLock(ctx context.Context, op string, bs []blocks.Block) (func(), error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "BlocksLock", ctx, bs)
+	ret := m.ctrl.Call(m, "BlocksLock", ctx, op, bs)
 	ret0, _ := ret[0].(func())
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // BlocksLock indicates an expected call of BlocksLock.
-func (mr *MockIndexMockRecorder) BlocksLock(ctx, bs any) *gomock.Call {
+func (mr *MockIndexMockRecorder) BlocksLock(ctx, op, bs any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlocksLock", reflect.TypeOf((*MockIndex)(nil).BlocksLock), ctx, op, bs)
+}
+
+// BloomInfo mocks base method.
+func (m *MockIndex) BloomInfo(ctx context.Context) ([]index.BloomPartitionInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BloomInfo", ctx)
+	ret0, _ := ret[0].([]index.BloomPartitionInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BloomInfo indicates an expected call of BloomInfo.
+func (mr *MockIndexMockRecorder) BloomInfo(ctx any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlocksLock", reflect.TypeOf((*MockIndex)(nil).BlocksLock), ctx, bs)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BloomInfo", reflect.TypeOf((*MockIndex)(nil).BloomInfo), ctx)
+}
+
+// BloomRebuild mocks base method.
+func (m *MockIndex) BloomRebuild(ctx context.Context) (index.BloomRebuildResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BloomRebuild", ctx)
+	ret0, _ := ret[0].(index.BloomRebuildResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BloomRebuild indicates an expected call of BloomRebuild.
+func (mr *MockIndexMockRecorder) BloomRebuild(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BloomRebuild", reflect.TypeOf((*MockIndex)(nil).BloomRebuild), ctx)
 }
 
 // Check mocks base method.
@@ -117,6 +164,21 @@ func (mr *MockIndexMockRecorder) CheckAndMoveOwnership(ctx, key, oldIdentity, ac
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckAndMoveOwnership", reflect.TypeOf((*MockIndex)(nil).CheckAndMoveOwnership), ctx, key, oldIdentity, aclRecordIndex)
 }
 
+// CheckAudit mocks base method.
+func (m *MockIndex) CheckAudit(ctx context.Context, limit int) ([]index.CheckAuditRecord, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckAudit", ctx, limit)
+	ret0, _ := ret[0].([]index.CheckAuditRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CheckAudit indicates an expected call of CheckAudit.
+func (mr *MockIndexMockRecorder) CheckAudit(ctx, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckAudit", reflect.TypeOf((*MockIndex)(nil).CheckAudit), ctx, limit)
+}
+
 // CheckDeletedSpaces mocks base method.
 func (m *MockIndex) CheckDeletedSpaces(ctx context.Context, key index.Key, resolve func([]string) ([]string, error), doFix bool) ([]string, error) {
 	m.ctrl.T.Helper()
@@ -132,19 +194,34 @@ func (mr *MockIndexMockRecorder) CheckDeletedSpaces(ctx, key, resolve, doFix any
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckDeletedSpaces", reflect.TypeOf((*MockIndex)(nil).CheckDeletedSpaces), ctx, key, resolve, doFix)
 }
 
+// CheckFix mocks base method.
+func (m *MockIndex) CheckFix(ctx context.Context, key index.Key, ids []string) ([]index.CheckResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckFix", ctx, key, ids)
+	ret0, _ := ret[0].([]index.CheckResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CheckFix indicates an expected call of CheckFix.
+func (mr *MockIndexMockRecorder) CheckFix(ctx, key, ids any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckFix", reflect.TypeOf((*MockIndex)(nil).CheckFix), ctx, key, ids)
+}
+
 // CheckKey mocks base method.
-func (m *MockIndex) CheckKey(ctx context.Context, key string) (bool, error) {
+func (m *MockIndex) CheckKey(ctx context.Context, op, key string) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CheckKey", ctx, key)
+	ret := m.ctrl.Call(m, "CheckKey", ctx, op, key)
 	ret0, _ := ret[0].(bool)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CheckKey indicates an expected call of CheckKey.
-func (mr *MockIndexMockRecorder) CheckKey(ctx, key any) *gomock.Call {
+func (mr *MockIndexMockRecorder) CheckKey(ctx, op, key any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckKey", reflect.TypeOf((*MockIndex)(nil).CheckKey), ctx, key)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckKey", reflect.TypeOf((*MockIndex)(nil).CheckKey), ctx, op, key)
 }
 
 // CheckLimits mocks base method.
@@ -235,6 +312,21 @@ func (mr *MockIndexMockRecorder) Close(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockIndex)(nil).Close), ctx)
 }
 
+// DedupInfo mocks base method.
+func (m *MockIndex) DedupInfo(ctx context.Context) (index.DedupInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DedupInfo", ctx)
+	ret0, _ := ret[0].(index.DedupInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DedupInfo indicates an expected call of DedupInfo.
+func (mr *MockIndexMockRecorder) DedupInfo(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DedupInfo", reflect.TypeOf((*MockIndex)(nil).DedupInfo), ctx)
+}
+
 // DeleteUnboundCid mocks base method.
 func (m *MockIndex) DeleteUnboundCid(ctx context.Context, c cid.Cid) (bool, error) {
 	m.ctrl.T.Helper()
@@ -264,6 +356,48 @@ func (mr *MockIndexMockRecorder) FileBind(ctx, key, fileId, cidEntries any) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FileBind", reflect.TypeOf((*MockIndex)(nil).FileBind), ctx, key, fileId, cidEntries)
 }
 
+// FileBindRevision mocks base method.
+func (m *MockIndex) FileBindRevision(ctx context.Context, key index.Key, fileId string, cidEntries *index.CidEntries) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FileBindRevision", ctx, key, fileId, cidEntries)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FileBindRevision indicates an expected call of FileBindRevision.
+func (mr *MockIndexMockRecorder) FileBindRevision(ctx, key, fileId, cidEntries any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FileBindRevision", reflect.TypeOf((*MockIndex)(nil).FileBindRevision), ctx, key, fileId, cidEntries)
+}
+
+// FileBindWithMetadata mocks base method.
+func (m *MockIndex) FileBindWithMetadata(ctx context.Context, key index.Key, fileId string, cidEntries *index.CidEntries, meta index.FileMetadata) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FileBindWithMetadata", ctx, key, fileId, cidEntries, meta)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FileBindWithMetadata indicates an expected call of FileBindWithMetadata.
+func (mr *MockIndexMockRecorder) FileBindWithMetadata(ctx, key, fileId, cidEntries, meta any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FileBindWithMetadata", reflect.TypeOf((*MockIndex)(nil).FileBindWithMetadata), ctx, key, fileId, cidEntries, meta)
+}
+
+// FileCopy mocks base method.
+func (m *MockIndex) FileCopy(ctx context.Context, srcKey index.Key, srcFileId string, dstKey index.Key, dstFileId string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FileCopy", ctx, srcKey, srcFileId, dstKey, dstFileId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FileCopy indicates an expected call of FileCopy.
+func (mr *MockIndexMockRecorder) FileCopy(ctx, srcKey, srcFileId, dstKey, dstFileId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FileCopy", reflect.TypeOf((*MockIndex)(nil).FileCopy), ctx, srcKey, srcFileId, dstKey, dstFileId)
+}
+
 // FileInfo mocks base method.
 func (m *MockIndex) FileInfo(ctx context.Context, key index.Key, fileIds ...string) ([]index.FileInfo, error) {
 	m.ctrl.T.Helper()
@@ -303,6 +437,50 @@ func (mr *MockIndexMockRecorder) FileUnbind(ctx, kye any, fileIds ...any) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FileUnbind", reflect.TypeOf((*MockIndex)(nil).FileUnbind), varargs...)
 }
 
+// FileVersionRestore mocks base method.
+func (m *MockIndex) FileVersionRestore(ctx context.Context, key index.Key, fileId string, versionId uint32) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FileVersionRestore", ctx, key, fileId, versionId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FileVersionRestore indicates an expected call of FileVersionRestore.
+func (mr *MockIndexMockRecorder) FileVersionRestore(ctx, key, fileId, versionId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FileVersionRestore", reflect.TypeOf((*MockIndex)(nil).FileVersionRestore), ctx, key, fileId, versionId)
+}
+
+// FileVersions mocks base method.
+func (m *MockIndex) FileVersions(ctx context.Context, key index.Key, fileId string) ([]index.FileVersionInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FileVersions", ctx, key, fileId)
+	ret0, _ := ret[0].([]index.FileVersionInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FileVersions indicates an expected call of FileVersions.
+func (mr *MockIndexMockRecorder) FileVersions(ctx, key, fileId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FileVersions", reflect.TypeOf((*MockIndex)(nil).FileVersions), ctx, key, fileId)
+}
+
+// FilesFind mocks base method.
+func (m *MockIndex) FilesFind(ctx context.Context, key index.Key, filter index.FileMetadataFilter, limit int) ([]index.FileInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FilesFind", ctx, key, filter, limit)
+	ret0, _ := ret[0].([]index.FileInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FilesFind indicates an expected call of FilesFind.
+func (mr *MockIndexMockRecorder) FilesFind(ctx, key, filter, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FilesFind", reflect.TypeOf((*MockIndex)(nil).FilesFind), ctx, key, filter, limit)
+}
+
 // FilesList mocks base method.
 func (m *MockIndex) FilesList(ctx context.Context, key index.Key) ([]string, error) {
 	m.ctrl.T.Helper()
@@ -318,6 +496,66 @@ func (mr *MockIndexMockRecorder) FilesList(ctx, key any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FilesList", reflect.TypeOf((*MockIndex)(nil).FilesList), ctx, key)
 }
 
+// FilesListPaged mocks base method.
+func (m *MockIndex) FilesListPaged(ctx context.Context, key index.Key, params index.FilesListParams) (index.FilesListPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FilesListPaged", ctx, key, params)
+	ret0, _ := ret[0].(index.FilesListPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FilesListPaged indicates an expected call of FilesListPaged.
+func (mr *MockIndexMockRecorder) FilesListPaged(ctx, key, params any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FilesListPaged", reflect.TypeOf((*MockIndex)(nil).FilesListPaged), ctx, key, params)
+}
+
+// ForceReleaseLock mocks base method.
+func (m *MockIndex) ForceReleaseLock(ctx context.Context, key string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForceReleaseLock", ctx, key)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ForceReleaseLock indicates an expected call of ForceReleaseLock.
+func (mr *MockIndexMockRecorder) ForceReleaseLock(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForceReleaseLock", reflect.TypeOf((*MockIndex)(nil).ForceReleaseLock), ctx, key)
+}
+
+// FrozenSpaces mocks base method.
+func (m *MockIndex) FrozenSpaces(ctx context.Context, before time.Time, limit int) ([]index.Key, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FrozenSpaces", ctx, before, limit)
+	ret0, _ := ret[0].([]index.Key)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FrozenSpaces indicates an expected call of FrozenSpaces.
+func (mr *MockIndexMockRecorder) FrozenSpaces(ctx, before, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FrozenSpaces", reflect.TypeOf((*MockIndex)(nil).FrozenSpaces), ctx, before, limit)
+}
+
+// GroupDedupInfo mocks base method.
+func (m *MockIndex) GroupDedupInfo(ctx context.Context, groupId string) (index.DedupInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GroupDedupInfo", ctx, groupId)
+	ret0, _ := ret[0].(index.DedupInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GroupDedupInfo indicates an expected call of GroupDedupInfo.
+func (mr *MockIndexMockRecorder) GroupDedupInfo(ctx, groupId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GroupDedupInfo", reflect.TypeOf((*MockIndex)(nil).GroupDedupInfo), ctx, groupId)
+}
+
 // GroupInfo mocks base method.
 func (m *MockIndex) GroupInfo(ctx context.Context, groupId string) (index.GroupInfo, error) {
 	m.ctrl.T.Helper()
@@ -333,6 +571,20 @@ func (mr *MockIndexMockRecorder) GroupInfo(ctx, groupId any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GroupInfo", reflect.TypeOf((*MockIndex)(nil).GroupInfo), ctx, groupId)
 }
 
+// InMaintenance mocks base method.
+func (m *MockIndex) InMaintenance() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InMaintenance")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// InMaintenance indicates an expected call of InMaintenance.
+func (mr *MockIndexMockRecorder) InMaintenance() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InMaintenance", reflect.TypeOf((*MockIndex)(nil).InMaintenance))
+}
+
 // Init mocks base method.
 func (m *MockIndex) Init(a *app.App) error {
 	m.ctrl.T.Helper()
@@ -347,6 +599,36 @@ func (mr *MockIndexMockRecorder) Init(a any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Init", reflect.TypeOf((*MockIndex)(nil).Init), a)
 }
 
+// Locks mocks base method.
+func (m *MockIndex) Locks(ctx context.Context) ([]index.LockInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Locks", ctx)
+	ret0, _ := ret[0].([]index.LockInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Locks indicates an expected call of Locks.
+func (mr *MockIndexMockRecorder) Locks(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Locks", reflect.TypeOf((*MockIndex)(nil).Locks), ctx)
+}
+
+// MaintenanceStatus mocks base method.
+func (m *MockIndex) MaintenanceStatus(ctx context.Context) (index.MaintenanceStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MaintenanceStatus", ctx)
+	ret0, _ := ret[0].(index.MaintenanceStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MaintenanceStatus indicates an expected call of MaintenanceStatus.
+func (mr *MockIndexMockRecorder) MaintenanceStatus(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MaintenanceStatus", reflect.TypeOf((*MockIndex)(nil).MaintenanceStatus), ctx)
+}
+
 // MarkSpaceAsDeleted mocks base method.
 func (m *MockIndex) MarkSpaceAsDeleted(ctx context.Context, key index.Key) (bool, error) {
 	m.ctrl.T.Helper()
@@ -407,6 +689,20 @@ func (mr *MockIndexMockRecorder) OnBlockUploaded(ctx any, bs ...any) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnBlockUploaded", reflect.TypeOf((*MockIndex)(nil).OnBlockUploaded), varargs...)
 }
 
+// ReleaseLocks mocks base method.
+func (m *MockIndex) ReleaseLocks(ctx context.Context) int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseLocks", ctx)
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// ReleaseLocks indicates an expected call of ReleaseLocks.
+func (mr *MockIndexMockRecorder) ReleaseLocks(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseLocks", reflect.TypeOf((*MockIndex)(nil).ReleaseLocks), ctx)
+}
+
 // Run mocks base method.
 func (m *MockIndex) Run(ctx context.Context) error {
 	m.ctrl.T.Helper()
@@ -435,6 +731,20 @@ func (mr *MockIndexMockRecorder) SetGroupLimit(ctx, groupId, limit any) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetGroupLimit", reflect.TypeOf((*MockIndex)(nil).SetGroupLimit), ctx, groupId, limit)
 }
 
+// SetMaintenance mocks base method.
+func (m *MockIndex) SetMaintenance(ctx context.Context, enabled bool, reason string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetMaintenance", ctx, enabled, reason)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetMaintenance indicates an expected call of SetMaintenance.
+func (mr *MockIndexMockRecorder) SetMaintenance(ctx, enabled, reason any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMaintenance", reflect.TypeOf((*MockIndex)(nil).SetMaintenance), ctx, enabled, reason)
+}
+
 // SetSpaceLimit mocks base method.
 func (m *MockIndex) SetSpaceLimit(ctx context.Context, key index.Key, limit uint64) error {
 	m.ctrl.T.Helper()
@@ -449,6 +759,20 @@ func (mr *MockIndexMockRecorder) SetSpaceLimit(ctx, key, limit any) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSpaceLimit", reflect.TypeOf((*MockIndex)(nil).SetSpaceLimit), ctx, key, limit)
 }
 
+// SetSpaceVersionPolicy mocks base method.
+func (m *MockIndex) SetSpaceVersionPolicy(ctx context.Context, key index.Key, policy index.VersionPolicy) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetSpaceVersionPolicy", ctx, key, policy)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetSpaceVersionPolicy indicates an expected call of SetSpaceVersionPolicy.
+func (mr *MockIndexMockRecorder) SetSpaceVersionPolicy(ctx, key, policy any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSpaceVersionPolicy", reflect.TypeOf((*MockIndex)(nil).SetSpaceVersionPolicy), ctx, key, policy)
+}
+
 // SpaceDelete mocks base method.
 func (m *MockIndex) SpaceDelete(ctx context.Context, key index.Key) (bool, error) {
 	m.ctrl.T.Helper()
@@ -464,6 +788,21 @@ func (mr *MockIndexMockRecorder) SpaceDelete(ctx, key any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SpaceDelete", reflect.TypeOf((*MockIndex)(nil).SpaceDelete), ctx, key)
 }
 
+// SpaceFreeze mocks base method.
+func (m *MockIndex) SpaceFreeze(ctx context.Context, key index.Key) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SpaceFreeze", ctx, key)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SpaceFreeze indicates an expected call of SpaceFreeze.
+func (mr *MockIndexMockRecorder) SpaceFreeze(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SpaceFreeze", reflect.TypeOf((*MockIndex)(nil).SpaceFreeze), ctx, key)
+}
+
 // SpaceInfo mocks base method.
 func (m *MockIndex) SpaceInfo(ctx context.Context, key index.Key) (index.SpaceInfo, error) {
 	m.ctrl.T.Helper()
@@ -479,6 +818,36 @@ func (mr *MockIndexMockRecorder) SpaceInfo(ctx, key any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SpaceInfo", reflect.TypeOf((*MockIndex)(nil).SpaceInfo), ctx, key)
 }
 
+// SpacePurge mocks base method.
+func (m *MockIndex) SpacePurge(ctx context.Context, key index.Key) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SpacePurge", ctx, key)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SpacePurge indicates an expected call of SpacePurge.
+func (mr *MockIndexMockRecorder) SpacePurge(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SpacePurge", reflect.TypeOf((*MockIndex)(nil).SpacePurge), ctx, key)
+}
+
+// SpaceRestore mocks base method.
+func (m *MockIndex) SpaceRestore(ctx context.Context, key index.Key) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SpaceRestore", ctx, key)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SpaceRestore indicates an expected call of SpaceRestore.
+func (mr *MockIndexMockRecorder) SpaceRestore(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SpaceRestore", reflect.TypeOf((*MockIndex)(nil).SpaceRestore), ctx, key)
+}
+
 // WaitCidExists mocks base method.
 func (m *MockIndex) WaitCidExists(ctx context.Context, c cid.Cid) error {
 	m.ctrl.T.Helper()
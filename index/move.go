@@ -15,7 +15,7 @@ import (
 
 func (ri *redisIndex) CheckAndMoveOwnership(ctx context.Context, key Key, oldIdentity string, aclRecordIndex int) (err error) {
 	oKey := OwnerKey(key.SpaceId)
-	_, release, err := ri.AcquireKey(ctx, oKey)
+	_, release, err := ri.AcquireKey(ctx, "checkAndMoveOwnership", oKey)
 	if err != nil {
 		return
 	}
@@ -75,13 +75,13 @@ func (ri *redisIndex) Move(ctx context.Context, dest, src Key) (err error) {
 	if dest.SpaceId != src.SpaceId {
 		return fmt.Errorf("spaceId should be the same for both keys")
 	}
-	srcEntry, scrRelease, err := ri.AcquireSpace(ctx, src)
+	srcEntry, scrRelease, err := ri.AcquireSpace(ctx, "move", src)
 	if err != nil {
 		return
 	}
 	defer scrRelease()
 
-	_, destGRelease, err := ri.AcquireKey(ctx, GroupKey(dest))
+	_, destGRelease, err := ri.AcquireKey(ctx, "move", GroupKey(dest))
 	if err != nil {
 		return
 	}
@@ -96,7 +96,7 @@ func (ri *redisIndex) Move(ctx context.Context, dest, src Key) (err error) {
 	sSK := SpaceKey(src)
 	dSK := SpaceKey(dest)
 	if sSK != dSK {
-		_, destSRelease, err := ri.AcquireKey(ctx, SpaceKey(dest))
+		_, destSRelease, err := ri.AcquireKey(ctx, "move", SpaceKey(dest))
 		if err != nil {
 			return err
 		}
@@ -167,6 +167,7 @@ func (ri *redisIndex) Move(ctx context.Context, dest, src Key) (err error) {
 				pipe.HDel(ctx, sGK, CidKey(cidEntries.entries[i].Cid))
 			}
 		}
+		srcEntry.group.LogicalSize = ri.decrLogicalSize(ctx, "group logical", srcEntry.group.LogicalSize, srcEntry.space.LogicalSize, src)
 		srcEntry.space.GroupId = dest.GroupId
 		srcEntry.space.Save(ctx, src, pipe)
 		srcEntry.group.SpaceIds = slices.DeleteFunc(srcEntry.group.SpaceIds, func(spaceId string) bool {
@@ -181,13 +182,18 @@ func (ri *redisIndex) Move(ctx context.Context, dest, src Key) (err error) {
 
 	_, err = ri.cl.Pipelined(ctx, func(pipe redis.Pipeliner) error {
 		srcEntry.space.Save(ctx, dest, pipe)
+		destGroup.LogicalSize += srcEntry.space.LogicalSize
 		destGroup.AddSpaceId(src.SpaceId)
 		destGroup.Save(ctx, pipe)
 		return nil
 	})
 
 	if sSK != dSK {
-		if err = ri.cl.Del(ctx, sSK).Err(); err != nil {
+		if err = ri.cl.Del(ctx, sSK, FilesIndexKey(src)).Err(); err != nil {
+			return
+		}
+		// the dest files index is built again from the dest space, it's in another slot
+		if err = ri.cl.Del(ctx, FilesIndexKey(dest)).Err(); err != nil {
 			return
 		}
 	}
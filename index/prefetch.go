@@ -0,0 +1,119 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/anyproto/any-sync-filenode/index/indexproto"
+)
+
+const defaultPrefetchThreads = 8
+
+// prefetchGroup restores the space keys of the restored group and optionally their cid entries in the background
+func (ri *redisIndex) prefetchGroup(groupKey string) {
+	if _, running := ri.prefetching.LoadOrStore(groupKey, struct{}{}); running {
+		return
+	}
+	go func() {
+		defer ri.prefetching.Delete(groupKey)
+		st := time.Now()
+		count, err := ri.prefetchGroupKeys(ri.ctx, groupKey)
+		if err != nil {
+			log.Warn("group prefetch error", zap.String("key", groupKey), zap.Error(err))
+			return
+		}
+		log.Debug("group prefetched", zap.String("key", groupKey), zap.Int32("keys", count), zap.Duration("dur", time.Since(st)))
+	}()
+}
+
+func (ri *redisIndex) prefetchGroupKeys(ctx context.Context, groupKey string) (count int32, err error) {
+	data, err := ri.cl.HGet(ctx, groupKey, infoKey).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			err = nil
+		}
+		return
+	}
+	entry := &indexproto.GroupEntry{}
+	if err = entry.UnmarshalVT(data); err != nil {
+		return
+	}
+
+	// the space keys share the hashtag with the group key
+	hashTag := groupKey[strings.LastIndex(groupKey, ".{"):]
+	spaceKeys := make([]string, len(entry.SpaceIds))
+	for i, spaceId := range entry.SpaceIds {
+		spaceKeys[i] = "s:" + spaceId + hashTag
+	}
+	if count, err = ri.prefetchKeys(ctx, spaceKeys); err != nil || !ri.prefetchConf.Cids {
+		return
+	}
+
+	for _, spaceKey := range spaceKeys {
+		var fields []string
+		if fields, err = ri.cl.HKeys(ctx, spaceKey).Result(); err != nil {
+			return
+		}
+		// the cid ref fields have the same names as the cid keys
+		var cidKeys = make([]string, 0, len(fields))
+		for _, field := range fields {
+			if strings.HasPrefix(field, "c:") {
+				cidKeys = append(cidKeys, field)
+			}
+		}
+		var cidCount int32
+		if cidCount, err = ri.prefetchKeys(ctx, cidKeys); err != nil {
+			return
+		}
+		count += cidCount
+	}
+	return
+}
+
+// prefetchKeys restores the keys in parallel, the concurrency is limited node-wide by prefetchLimiter.
+// The keys are restored without the key lock, so the prefetch doesn't compete with the requests that hold the group lock.
+// Returns the count of the restored keys, the keys already in redis aren't counted.
+func (ri *redisIndex) prefetchKeys(ctx context.Context, keys []string) (count int32, err error) {
+	var (
+		wg       sync.WaitGroup
+		restored atomic.Int32
+	)
+	defer func() {
+		wg.Wait()
+		count = restored.Load()
+	}()
+	for _, key := range keys {
+		select {
+		case ri.prefetchLimiter <- struct{}{}:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-ri.prefetchLimiter
+				wg.Done()
+			}()
+			_, isRestored, restoreErr := ri.restoreKey(ctx, key)
+			if restoreErr == nil && isRestored {
+				// the restored key must be tracked to be persisted again
+				restoreErr = ri.updateKeyUsage(ctx, key)
+			}
+			if restoreErr != nil {
+				log.Debug("prefetch key error", zap.String("key", key), zap.Error(restoreErr))
+				return
+			}
+			if isRestored {
+				restored.Add(1)
+			}
+		}()
+	}
+	return
+}
@@ -0,0 +1,76 @@
+package index
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/anyproto/any-sync-filenode/config"
+	"github.com/anyproto/any-sync-filenode/testutil"
+)
+
+func TestRedisIndex_GroupPrefetch(t *testing.T) {
+	fx := newFixtureConfig(t, &config.Config{PersistTtl: 1, GroupPrefetch: config.GroupPrefetch{
+		Enabled: true,
+		Cids:    true,
+		Threads: 2,
+	}})
+	defer fx.Finish(t)
+
+	var (
+		persistedMu sync.Mutex
+		persisted   = map[string][]byte{}
+	)
+	fx.persistStore.EXPECT().IndexPut(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, key string, value []byte) error {
+		persistedMu.Lock()
+		defer persistedMu.Unlock()
+		persisted[key] = value
+		return nil
+	}).AnyTimes()
+	fx.persistStore.EXPECT().IndexGet(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, key string) ([]byte, error) {
+		persistedMu.Lock()
+		defer persistedMu.Unlock()
+		return persisted[key], nil
+	}).AnyTimes()
+
+	key := newRandKey()
+	key2 := Key{GroupId: key.GroupId, SpaceId: testutil.NewRandSpaceId()}
+	bs := testutil.NewRandBlocks(3)
+	require.NoError(t, fx.BlocksAdd(ctx, bs))
+	for _, k := range []Key{key, key2} {
+		cids, err := fx.CidEntriesByBlocks(ctx, bs)
+		require.NoError(t, err)
+		require.NoError(t, fx.FileBind(ctx, k, testutil.NewRandCid().String(), cids))
+		cids.Release()
+	}
+
+	time.Sleep(time.Second * 3)
+	fx.PersistKeys(ctx)
+
+	prefetched := []string{SpaceKey(key), SpaceKey(key2)}
+	for _, b := range bs {
+		prefetched = append(prefetched, CidKey(b.Cid()))
+	}
+	ex, err := fx.cl.Exists(ctx, prefetched...).Result()
+	require.NoError(t, err)
+	require.Zero(t, ex)
+
+	// restoring the group restores the spaces and cids in the background
+	ok, err := fx.CheckKey(ctx, "test", GroupKey(key))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Eventually(t, func() bool {
+		ex, err := fx.cl.Exists(ctx, prefetched...).Result()
+		return err == nil && ex == int64(len(prefetched))
+	}, time.Second*5, time.Millisecond*50)
+
+	// the keys already in redis aren't counted as restored
+	count, err := fx.prefetchKeys(ctx, prefetched)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
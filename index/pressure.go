@@ -0,0 +1,160 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anyproto/any-sync/util/periodicsync"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// pressureBatch is the count of the least recently used keys persisted from every partition per round
+const pressureBatch = 100
+
+var errNoMaxMemory = errors.New("redis maxmemory is not set and memoryPressure.maxMemory is not configured")
+
+func (ri *redisIndex) runMemoryPressure(ctx context.Context) {
+	if ri.pressureConf.HighWatermark <= 0 {
+		return
+	}
+	if _, err := ri.memoryUsage(ctx); errors.Is(err, errNoMaxMemory) {
+		log.Warn("memory pressure persistence is disabled", zap.Error(err))
+		return
+	}
+	interval := int(ri.pressureConf.CheckIntervalSec)
+	if interval == 0 {
+		interval = 10
+	}
+	ri.pressureTicker = periodicsync.NewPeriodicSync(interval, time.Minute*10, ri.checkMemoryPressure, log)
+	ri.pressureTicker.Run()
+}
+
+func (ri *redisIndex) checkMemoryPressure(ctx context.Context) (err error) {
+	ratio, err := ri.memoryUsage(ctx)
+	if err != nil {
+		return
+	}
+	if ratio < ri.pressureConf.HighWatermark {
+		return
+	}
+	return ri.persistUnderPressure(ctx, ratio)
+}
+
+// persistUnderPressure persists the least recently used keys until the memory usage drops below the low watermark
+func (ri *redisIndex) persistUnderPressure(ctx context.Context, ratio float64) (err error) {
+	ri.persistMu.Lock()
+	defer ri.persistMu.Unlock()
+	ri.pressureActive.Store(true)
+	defer ri.pressureActive.Store(false)
+
+	lowWatermark := ri.pressureConf.LowWatermark
+	if lowWatermark <= 0 || lowWatermark >= ri.pressureConf.HighWatermark {
+		lowWatermark = ri.pressureConf.HighWatermark * 0.8
+	}
+
+	st := time.Now()
+	log.Info("memory pressure: start persisting", zap.Float64("ratio", ratio))
+	var total int32
+	for ratio >= lowWatermark {
+		stat := &persistStat{}
+		now := time.Now().Unix()
+		ri.persistPartitions(func(part int) error {
+			return ri.persistLru(ctx, part, now, stat)
+		})
+		ri.metric.Persist("pressure_moved", int(stat.moved.Load()))
+		ri.metric.Persist("pressure_deleted", int(stat.deleted.Load()))
+		ri.metric.Persist("pressure_error", int(stat.errors.Load()))
+		freed := stat.moved.Load() + stat.deleted.Load()
+		total += freed
+		if freed == 0 {
+			log.Warn("memory pressure: nothing to persist", zap.Float64("ratio", ratio))
+			break
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if ratio, err = ri.memoryUsage(ctx); err != nil {
+			return
+		}
+	}
+	log.Info("memory pressure: done",
+		zap.Float64("ratio", ratio),
+		zap.Int32("persisted", total),
+		zap.Duration("dur", time.Since(st)),
+	)
+	return
+}
+
+func (ri *redisIndex) persistLru(ctx context.Context, part int, deadline int64, stat *persistStat) (err error) {
+	sk := "store:{" + strconv.Itoa(part) + "}"
+	keys, err := ri.cl.ZRange(ctx, sk, 0, pressureBatch-1).Result()
+	if err != nil {
+		return
+	}
+	for _, k := range keys {
+		if err = ri.persistKey(ctx, sk, k, deadline, stat); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// memoryUsage returns the used/max memory ratio, in case of cluster it's the maximum ratio of the masters
+func (ri *redisIndex) memoryUsage(ctx context.Context) (ratio float64, err error) {
+	if cc, ok := ri.cl.(*redis.ClusterClient); ok {
+		var mu sync.Mutex
+		err = cc.ForEachMaster(ctx, func(ctx context.Context, shard *redis.Client) error {
+			shardRatio, sErr := ri.shardMemoryUsage(ctx, shard)
+			if sErr != nil {
+				return sErr
+			}
+			mu.Lock()
+			ratio = max(ratio, shardRatio)
+			mu.Unlock()
+			return nil
+		})
+	} else {
+		ratio, err = ri.shardMemoryUsage(ctx, ri.cl)
+	}
+	if err == nil {
+		ri.memoryRatio.Store(math.Float64bits(ratio))
+	}
+	return
+}
+
+func (ri *redisIndex) shardMemoryUsage(ctx context.Context, cl redis.Cmdable) (ratio float64, err error) {
+	info, err := cl.Info(ctx, "memory").Result()
+	if err != nil {
+		return
+	}
+	used, maxMemory := parseMemoryInfo(info)
+	if maxMemory == 0 {
+		maxMemory = ri.pressureConf.MaxMemory
+	}
+	if maxMemory == 0 {
+		return 0, errNoMaxMemory
+	}
+	return float64(used) / float64(maxMemory), nil
+}
+
+func parseMemoryInfo(info string) (used, maxMemory uint64) {
+	for _, line := range strings.Split(info, "\n") {
+		k, v, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "used_memory":
+			used, _ = strconv.ParseUint(v, 10, 64)
+		case "maxmemory":
+			maxMemory, _ = strconv.ParseUint(v, 10, 64)
+		}
+	}
+	return
+}
@@ -0,0 +1,60 @@
+package index
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/anyproto/any-sync-filenode/config"
+	"github.com/anyproto/any-sync-filenode/testutil"
+)
+
+func TestRedisIndex_MemoryPressure(t *testing.T) {
+	t.Run("below watermark", func(t *testing.T) {
+		fx := newFixtureConfig(t, &config.Config{PersistTtl: 3600, MemoryPressure: config.MemoryPressure{
+			HighWatermark: 0.85,
+			MaxMemory:     1 << 50,
+		}})
+		defer fx.Finish(t)
+		require.NoError(t, fx.BlocksAdd(ctx, testutil.NewRandBlocks(5)))
+		require.NoError(t, fx.checkMemoryPressure(ctx))
+		assert.False(t, fx.pressureActive.Load())
+	})
+	t.Run("persist lru", func(t *testing.T) {
+		// the tiny max memory keeps the pressure until all keys are persisted
+		fx := newFixtureConfig(t, &config.Config{PersistTtl: 3600, MemoryPressure: config.MemoryPressure{
+			HighWatermark: 0.85,
+			LowWatermark:  0.7,
+			MaxMemory:     1,
+		}})
+		defer fx.Finish(t)
+		bs := testutil.NewRandBlocks(5)
+		require.NoError(t, fx.BlocksAdd(ctx, bs))
+		for _, b := range bs {
+			fx.persistStore.EXPECT().IndexPut(gomock.Any(), CidKey(b.Cid()), gomock.Any())
+		}
+		require.NoError(t, fx.checkMemoryPressure(ctx))
+
+		for _, b := range bs {
+			ex, err := fx.cl.Exists(ctx, CidKey(b.Cid())).Result()
+			require.NoError(t, err)
+			assert.Zero(t, ex)
+		}
+		ratio, err := fx.memoryUsage(ctx)
+		require.NoError(t, err)
+		assert.Greater(t, ratio, 1.0)
+	})
+}
+
+func TestParseMemoryInfo(t *testing.T) {
+	info := "# Memory\r\nused_memory:1048576\r\nused_memory_human:1.00M\r\nmaxmemory:4194304\r\nmaxmemory_human:4.00M\r\n"
+	used, maxMemory := parseMemoryInfo(info)
+	assert.Equal(t, uint64(1048576), used)
+	assert.Equal(t, uint64(4194304), maxMemory)
+
+	used, maxMemory = parseMemoryInfo("")
+	assert.Zero(t, used)
+	assert.Zero(t, maxMemory)
+}
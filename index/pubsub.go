@@ -2,6 +2,7 @@ package index
 
 import (
 	"context"
+	"errors"
 
 	blocks "github.com/ipfs/go-block-format"
 	"github.com/ipfs/go-cid"
@@ -11,9 +12,11 @@ import (
 
 const cidsChannel = "cidsChan"
 
+var errSubscriptionNotRunning = errors.New("pub/sub subscription is not running")
+
 func (ri *redisIndex) WaitCidExists(ctx context.Context, k cid.Cid) (err error) {
 	ck := CidKey(k)
-	exists, release, err := ri.acquireKey(ctx, ck)
+	exists, release, err := ri.acquireKey(ctx, "waitCidExists", ck)
 	if err != nil {
 		return err
 	}
@@ -66,14 +69,41 @@ func (ri *redisIndex) OnBlockUploaded(ctx context.Context, bs ...blocks.Block) {
 }
 
 func (ri *redisIndex) subscription(ctx context.Context) {
-	sub := ri.cl.Subscribe(ctx, cidsChannel)
+	sub := ri.cl.Subscribe(ctx, cidsChannel, blocksUnlockChannel, maintenanceChannel)
+	ri.subMu.Lock()
+	ri.sub = sub
+	ri.subMu.Unlock()
 	defer func() {
-		_ = sub.Unsubscribe(ctx, cidsChannel)
+		ri.subMu.Lock()
+		ri.sub = nil
+		ri.subMu.Unlock()
+		_ = sub.Unsubscribe(ctx, cidsChannel, blocksUnlockChannel, maintenanceChannel)
 	}()
 	ch := sub.Channel()
 	for msg := range ch {
-		ri.handleSubscriptionMessage(msg.Payload)
+		switch msg.Channel {
+		case cidsChannel:
+			ri.handleSubscriptionMessage(msg.Payload)
+		case blocksUnlockChannel:
+			ri.handleBlocksUnlockMessage(msg.Payload)
+		case maintenanceChannel:
+			// the payload can be outdated, so read the actual value
+			if err := ri.refreshMaintenance(ctx); err != nil {
+				log.Warn("can't refresh maintenance status", zap.Error(err))
+			}
+		}
+	}
+}
+
+// SubscriptionCheck checks that the pub/sub subscription is running and its connection is alive
+func (ri *redisIndex) SubscriptionCheck(ctx context.Context) error {
+	ri.subMu.Lock()
+	sub := ri.sub
+	ri.subMu.Unlock()
+	if sub == nil {
+		return errSubscriptionNotRunning
 	}
+	return sub.Ping(ctx)
 }
 
 func (ri *redisIndex) handleSubscriptionMessage(msg string) {
@@ -0,0 +1,93 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// readKeyAttempts limits the reads of the key that is moved to the persistent store during the read
+const readKeyAttempts = 3
+
+var errKeyUnloaded = errors.New("key was moved to the persistent store during the read")
+
+// readKey is the read-only alternative to AcquireKey, it doesn't take the key lock, so the readers don't wait for the long writes.
+// It makes sure the key is loaded and calls f inside MULTI/EXEC together with the existence check, so the commands queued by f read a consistent snapshot.
+// If the key was persisted between the load and the read, it is loaded and read again.
+// All the commands queued by f must use the given key, f can be called several times.
+func (ri *redisIndex) readKey(ctx context.Context, key string, f func(tx redis.Pipeliner)) (exists bool, err error) {
+	res, err := ri.readKeys(ctx, []string{key}, f)
+	if err != nil {
+		return
+	}
+	return res[0], nil
+}
+
+// readKeys works like readKey for several keys of the same hash slot, e.g. the group and its spaces, reading them in one round trip
+func (ri *redisIndex) readKeys(ctx context.Context, keys []string, f func(tx redis.Pipeliner)) (exists []bool, err error) {
+	exists = make([]bool, len(keys))
+	loaded := make([]bool, len(keys))
+	for range readKeyAttempts {
+		if err = ri.loadKeys(ctx, keys, loaded); err != nil {
+			return nil, err
+		}
+		existsCmds := make([]*redis.IntCmd, len(keys))
+		_, err = ri.cl.TxPipelined(ctx, func(tx redis.Pipeliner) error {
+			for i, key := range keys {
+				existsCmds[i] = tx.Exists(ctx, key)
+			}
+			f(tx)
+			return nil
+		})
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+		var unloaded bool
+		for i := range keys {
+			exists[i] = existsCmds[i].Val() > 0
+			if !exists[i] && loaded[i] {
+				unloaded = true
+			}
+		}
+		if !unloaded {
+			return exists, ri.updateKeysUsage(ctx, keys, exists)
+		}
+	}
+	return nil, errKeyUnloaded
+}
+
+// loadKeys checks the keys existence in one round trip and loads the missing keys
+func (ri *redisIndex) loadKeys(ctx context.Context, keys []string, loaded []bool) (err error) {
+	existsCmds := make([]*redis.IntCmd, len(keys))
+	if _, err = ri.cl.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			existsCmds[i] = pipe.Exists(ctx, key)
+		}
+		return nil
+	}); err != nil {
+		return
+	}
+	for i, key := range keys {
+		if existsCmds[i].Val() > 0 {
+			loaded[i] = true
+		} else if loaded[i], err = ri.loadKey(ctx, key); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (ri *redisIndex) updateKeysUsage(ctx context.Context, keys []string, exists []bool) (err error) {
+	score := float64(time.Now().Unix())
+	_, err = ri.cl.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			if exists[i] {
+				pipe.ZAdd(ctx, storeKey(key), redis.Z{Score: score, Member: key})
+			}
+		}
+		return nil
+	})
+	return
+}
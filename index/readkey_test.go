@@ -0,0 +1,128 @@
+package index
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/anyproto/any-sync-filenode/config"
+	"github.com/anyproto/any-sync-filenode/testutil"
+)
+
+func TestRedisIndex_ReadKey(t *testing.T) {
+	t.Run("read while the key is locked", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.Finish(t)
+		key := newRandKey()
+		bs := testutil.NewRandBlocks(3)
+		require.NoError(t, fx.BlocksAdd(ctx, bs))
+		cids, err := fx.CidEntriesByBlocks(ctx, bs)
+		require.NoError(t, err)
+		fileId := testutil.NewRandCid().String()
+		require.NoError(t, fx.FileBind(ctx, key, fileId, cids))
+		cids.Release()
+
+		// the long write holds the space and the group locks
+		_, gRelease, err := fx.AcquireKey(ctx, "test", GroupKey(key))
+		require.NoError(t, err)
+		defer gRelease()
+		_, sRelease, err := fx.AcquireKey(ctx, "test", SpaceKey(key))
+		require.NoError(t, err)
+		defer sRelease()
+
+		tCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		spaceInfo, err := fx.SpaceInfo(tCtx, key)
+		require.NoError(t, err)
+		assert.Equal(t, uint32(1), spaceInfo.FileCount)
+		groupInfo, err := fx.GroupInfo(tCtx, key.GroupId)
+		require.NoError(t, err)
+		assert.Equal(t, []string{key.SpaceId}, groupInfo.SpaceIds)
+		fileIds, err := fx.FilesList(tCtx, key)
+		require.NoError(t, err)
+		assert.Equal(t, []string{fileId}, fileIds)
+		fileInfos, err := fx.FileInfo(tCtx, key, fileId)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(len(bs)), fileInfos[0].CidsCount)
+	})
+	t.Run("load persisted", func(t *testing.T) {
+		fx := newFixtureConfig(t, &config.Config{PersistTtl: 1, DefaultLimit: 1024})
+		defer fx.Finish(t)
+		key := newRandKey()
+		bs := testutil.NewRandBlocks(3)
+		require.NoError(t, fx.BlocksAdd(ctx, bs))
+		cids, err := fx.CidEntriesByBlocks(ctx, bs)
+		require.NoError(t, err)
+		fileId := testutil.NewRandCid().String()
+		require.NoError(t, fx.FileBind(ctx, key, fileId, cids))
+		cids.Release()
+
+		var persisted = make(map[string][]byte)
+		fx.persistStore.EXPECT().IndexPut(ctx, gomock.Any(), gomock.Any()).Do(func(_ context.Context, k string, v []byte) {
+			persisted[k] = v
+		}).AnyTimes()
+		time.Sleep(time.Second * 3)
+		fx.PersistKeys(ctx)
+		require.Contains(t, persisted, SpaceKey(key))
+
+		fx.persistStore.EXPECT().IndexGet(gomock.Any(), SpaceKey(key)).Return(persisted[SpaceKey(key)], nil)
+		fileIds, err := fx.FilesList(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, []string{fileId}, fileIds)
+
+		// the key usage is updated, so the key is scheduled for the next persist
+		score, err := fx.cl.ZScore(ctx, storeKey(SpaceKey(key)), SpaceKey(key)).Result()
+		require.NoError(t, err)
+		assert.NotZero(t, score)
+	})
+	t.Run("not exists", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.Finish(t)
+		exists, err := fx.readKey(ctx, SpaceKey(newRandKey()), func(tx redis.Pipeliner) {})
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+}
+
+func TestRedisIndex_AccountInfo(t *testing.T) {
+	t.Run("not exists", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.Finish(t)
+		_, exists, err := fx.AccountInfo(ctx, newRandKey().GroupId)
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+	t.Run("group with spaces", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.Finish(t)
+		key := newRandKey()
+		key2 := Key{GroupId: key.GroupId, SpaceId: testutil.NewRandSpaceId()}
+		bs := testutil.NewRandBlocks(3)
+		require.NoError(t, fx.BlocksAdd(ctx, bs))
+		for i, k := range []Key{key, key2} {
+			cids, err := fx.CidEntriesByBlocks(ctx, bs[:i+1])
+			require.NoError(t, err)
+			require.NoError(t, fx.FileBind(ctx, k, testutil.NewRandCid().String(), cids))
+			cids.Release()
+		}
+		require.NoError(t, fx.SetSpaceLimit(ctx, key2, 100))
+
+		info, exists, err := fx.AccountInfo(ctx, key.GroupId)
+		require.NoError(t, err)
+		require.True(t, exists)
+		groupInfo, err := fx.GroupInfo(ctx, key.GroupId)
+		require.NoError(t, err)
+		assert.Equal(t, groupInfo, info.Group)
+		require.Len(t, info.Spaces, 2)
+		for i, spaceId := range info.Group.SpaceIds {
+			spaceInfo, err := fx.SpaceInfo(ctx, Key{GroupId: key.GroupId, SpaceId: spaceId})
+			require.NoError(t, err)
+			assert.Equal(t, spaceInfo, info.Spaces[i])
+		}
+	})
+}
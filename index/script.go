@@ -0,0 +1,346 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/anyproto/any-sync-filenode/index/indexproto"
+)
+
+// luaProto is a minimal protobuf codec for the flat SpaceEntry and GroupEntry messages.
+// Unchanged fields are kept as raw bytes, changed varint fields are re-encoded,
+// zero values are omitted like the proto3 marshaler does.
+const luaProto = `
+local function pbDecode(data)
+	local fields, pos = {}, 1
+	local function varint()
+		local v, mul = 0, 1
+		while true do
+			local b = string.byte(data, pos)
+			pos = pos + 1
+			v = v + (b % 128) * mul
+			if b < 128 then
+				return v
+			end
+			mul = mul * 128
+		end
+	end
+	while pos <= #data do
+		local start = pos
+		local tag = varint()
+		local num, wire, value = math.floor(tag / 8), tag % 8, nil
+		if wire == 0 then
+			value = varint()
+		elseif wire == 2 then
+			local l = varint()
+			value = string.sub(data, pos, pos + l - 1)
+			pos = pos + l
+		elseif wire == 1 then
+			pos = pos + 8
+		elseif wire == 5 then
+			pos = pos + 4
+		else
+			error('unexpected proto wire type: ' .. wire)
+		end
+		table.insert(fields, {num = num, wire = wire, value = value, raw = string.sub(data, start, pos - 1)})
+	end
+	return fields
+end
+
+local function pbVarint(v)
+	local out = {}
+	while v >= 128 do
+		table.insert(out, string.char(v % 128 + 128))
+		v = math.floor(v / 128)
+	end
+	table.insert(out, string.char(v))
+	return table.concat(out)
+end
+
+local function pbEncode(fields)
+	local out = {}
+	for _, f in ipairs(fields) do
+		if f.raw then
+			table.insert(out, f.raw)
+		elseif f.wire == 0 then
+			table.insert(out, pbVarint(f.num * 8) .. pbVarint(f.value))
+		else
+			table.insert(out, pbVarint(f.num * 8 + 2) .. pbVarint(#f.value) .. f.value)
+		end
+	end
+	return table.concat(out)
+end
+
+local function pbGet(fields, num)
+	local v = 0
+	for _, f in ipairs(fields) do
+		if f.num == num and f.wire == 0 then
+			v = f.value
+		end
+	end
+	return v
+end
+
+local function pbInsert(fields, field)
+	local pos = #fields + 1
+	for i, f in ipairs(fields) do
+		if f.num > field.num then
+			pos = i
+			break
+		end
+	end
+	table.insert(fields, pos, field)
+end
+
+local function pbSet(fields, num, v)
+	for i = #fields, 1, -1 do
+		if fields[i].num == num then
+			table.remove(fields, i)
+		end
+	end
+	if v ~= 0 then
+		pbInsert(fields, {num = num, wire = 0, value = v})
+	end
+end
+
+local underflows = 0
+-- the logical sizes of the groups created before the tracking are zero until the backfill, their underflows are counted apart
+local logicalUnderflows = 0
+
+local function pbIncr(fields, num, v)
+	pbSet(fields, num, pbGet(fields, num) + v)
+end
+
+-- pbDecr keeps the value as is in case of underflow, same as decrLogicalSize does
+local function pbDecr(fields, num, v)
+	local cur = pbGet(fields, num)
+	if cur < v then
+		underflows = underflows + 1
+		return
+	end
+	pbSet(fields, num, cur - v)
+end
+
+local function pbAddString(fields, num, s)
+	for _, f in ipairs(fields) do
+		if f.num == num and f.value == s then
+			return
+		end
+	end
+	pbInsert(fields, {num = num, wire = 2, value = s})
+end
+
+local function pbLogicalSize(fields, delta)
+	if delta >= 0 then
+		pbIncr(fields, 9, delta)
+	else
+		local cur = pbGet(fields, 9)
+		if cur < -delta then
+			logicalUnderflows = logicalUnderflows + 1
+			return
+		end
+		pbSet(fields, 9, cur + delta)
+	end
+end
+`
+
+// entryScriptHeader loads the space and group entries and the common arguments:
+// KEYS: space key, group key, files index key
+// ARGV: default space entry, default group entry, update time, isolated flag, spaceId, logical size delta, file key,
+// count of the removed and count of the added files index members, the removed and the added members, the script arguments
+const entryScriptHeader = luaProto + `
+local space = pbDecode(redis.call('HGET', KEYS[1], 'info') or ARGV[1])
+local group = pbDecode(redis.call('HGET', KEYS[2], 'info') or ARGV[2])
+local now = tonumber(ARGV[3])
+local isolated = ARGV[4] == '1'
+local spaceId = ARGV[5]
+local logicalDelta = tonumber(ARGV[6])
+local fileKey = ARGV[7]
+local indexRemoveEnd = 9 + tonumber(ARGV[8])
+local indexAddEnd = indexRemoveEnd + tonumber(ARGV[9])
+local args = {}
+for i = indexAddEnd + 1, #ARGV do
+	args[#args + 1] = ARGV[i]
+end
+local affected = {}
+
+-- refIncr increments the cid refs of the space and the group, returns true when the space ref is created
+local function refIncr(ck, size)
+	if not isolated and redis.call('HINCRBY', KEYS[2], ck, 1) == 1 then
+		pbIncr(group, 5, 1)
+		pbIncr(group, 4, size)
+	end
+	if redis.call('HINCRBY', KEYS[1], ck, 1) == 1 then
+		pbIncr(space, 6, 1)
+		pbIncr(space, 4, size)
+		return true
+	end
+	return false
+end
+
+-- refDecr decrements the cid refs of the space and the group, returns true when the space ref is removed
+local function refDecr(ck, size)
+	if not isolated then
+		local groupRefs = tonumber(redis.call('HGET', KEYS[2], ck) or '0')
+		if groupRefs == 1 then
+			redis.call('HDEL', KEYS[2], ck)
+			pbDecr(group, 4, size)
+			pbDecr(group, 5, 1)
+		elseif groupRefs > 1 then
+			redis.call('HINCRBY', KEYS[2], ck, -1)
+		end
+	end
+	local refs = tonumber(redis.call('HGET', KEYS[1], ck) or '0')
+	if refs == 1 then
+		redis.call('HDEL', KEYS[1], ck)
+		pbDecr(space, 4, size)
+		pbDecr(space, 6, 1)
+		return true
+	elseif refs > 1 then
+		redis.call('HINCRBY', KEYS[1], ck, -1)
+	end
+	return false
+end
+`
+
+// entryScriptFooter updates the files index, saves the entries and returns {space entry, group entry, affected cid positions, underflows, logical size underflows}
+const entryScriptFooter = `
+if redis.call('EXISTS', KEYS[3]) == 1 then
+	for i = 10, indexRemoveEnd do
+		redis.call('ZREM', KEYS[3], ARGV[i])
+	end
+	for i = indexRemoveEnd + 1, indexAddEnd do
+		redis.call('ZADD', KEYS[3], 0, ARGV[i])
+	end
+end
+pbLogicalSize(space, logicalDelta)
+pbLogicalSize(group, logicalDelta)
+pbSet(space, 3, now)
+pbSet(group, 3, now)
+local spaceData, groupData = pbEncode(space), pbEncode(group)
+redis.call('HSET', KEYS[1], 'info', spaceData)
+redis.call('HSET', KEYS[2], 'info', groupData)
+return {spaceData, groupData, affected, underflows, logicalUnderflows}
+`
+
+// fileBindScript increments the cid refs of the space and group and updates the entries counters.
+// Script arguments: file entry, new file flag, pairs of cid key and cid size
+var fileBindScript = redis.NewScript(entryScriptHeader + `
+for i = 3, #args, 2 do
+	if refIncr(args[i], tonumber(args[i + 1])) then
+		table.insert(affected, (i - 1) / 2)
+	end
+end
+if args[2] == '1' then
+	pbIncr(space, 5, 1)
+end
+pbAddString(group, 6, spaceId)
+redis.call('HSET', KEYS[1], fileKey, args[1])
+` + entryScriptFooter)
+
+// fileUnbindScript removes the file, decrements the cid refs of the space and group and updates the entries counters.
+// Script arguments: pairs of cid key and cid size
+var fileUnbindScript = redis.NewScript(entryScriptHeader + `
+pbDecr(space, 5, 1)
+for i = 1, #args, 2 do
+	if refDecr(args[i], tonumber(args[i + 1])) then
+		table.insert(affected, (i + 1) / 2)
+	end
+end
+redis.call('HDEL', KEYS[1], fileKey)
+` + entryScriptFooter)
+
+// fileUpdateScript saves the changed file entry, increments the refs of the added cids and decrements the refs of the removed cids,
+// it's used when the file content is replaced by a revision or a version restore, when the versions are pruned or the metadata is changed.
+// Script arguments: file entry, count of the added cids, pairs of cid key and cid size: the added cids, then the removed cids
+var fileUpdateScript = redis.NewScript(entryScriptHeader + `
+local addedCount = tonumber(args[2])
+for i = 3, #args, 2 do
+	local pos = (i - 1) / 2
+	local changed
+	if pos <= addedCount then
+		changed = refIncr(args[i], tonumber(args[i + 1]))
+	else
+		changed = refDecr(args[i], tonumber(args[i + 1]))
+	end
+	if changed then
+		table.insert(affected, pos)
+	end
+end
+pbAddString(group, 6, spaceId)
+redis.call('HSET', KEYS[1], fileKey, args[1])
+` + entryScriptFooter)
+
+// evalEntryScript runs the bind or unbind script and applies the saved entries to the given in-memory entry.
+// It returns the indexes of the cids whose space refs were created or removed.
+func (ri *redisIndex) evalEntryScript(ctx context.Context, script *redis.Script, key Key, entry groupSpaceEntry, fileId string, indexUpdate filesIndexUpdate, logicalDelta int64, args ...any) (affected []int, err error) {
+	spaceData, err := entry.space.MarshalVT()
+	if err != nil {
+		return
+	}
+	groupData, err := entry.group.MarshalVT()
+	if err != nil {
+		return
+	}
+	var isolated = "0"
+	if entry.space.Limit != 0 {
+		isolated = "1"
+	}
+	argv := make([]any, 0, 9+len(indexUpdate.remove)+len(indexUpdate.add)+len(args))
+	argv = append(argv, spaceData, groupData, time.Now().Unix(), isolated, key.SpaceId, logicalDelta, FileKey(fileId), len(indexUpdate.remove), len(indexUpdate.add))
+	for _, m := range indexUpdate.remove {
+		argv = append(argv, m)
+	}
+	for _, m := range indexUpdate.add {
+		argv = append(argv, m)
+	}
+	argv = append(argv, args...)
+	res, err := script.Run(ctx, ri.cl, []string{SpaceKey(key), GroupKey(key), FilesIndexKey(key)}, argv...).Slice()
+	if err != nil {
+		return
+	}
+	if len(res) != 5 {
+		return nil, fmt.Errorf("unexpected entry script result len: %d", len(res))
+	}
+
+	savedSpace, sOk := res[0].(string)
+	savedGroup, gOk := res[1].(string)
+	if !sOk || !gOk {
+		return nil, fmt.Errorf("unexpected entry script result types: %T, %T", res[0], res[1])
+	}
+	var (
+		spaceProto = &indexproto.SpaceEntry{}
+		groupProto = &indexproto.GroupEntry{}
+	)
+	if err = spaceProto.UnmarshalVT([]byte(savedSpace)); err != nil {
+		return
+	}
+	if err = groupProto.UnmarshalVT([]byte(savedGroup)); err != nil {
+		return
+	}
+	entry.space.SpaceEntry = spaceProto
+	entry.group.GroupEntry = ri.groupEntryDefaults(key, groupProto)
+
+	positions, _ := res[2].([]any)
+	affected = make([]int, 0, len(positions))
+	for _, pos := range positions {
+		// lua positions are 1-based
+		affected = append(affected, int(pos.(int64))-1)
+	}
+	if underflows, _ := res[3].(int64); underflows != 0 {
+		log.WarnCtx(ctx, "unable to decrement entry counters", zap.Int64("count", underflows), zap.String("spaceId", key.SpaceId))
+		ri.reportAnomaly(ctx, key, anomalyScriptUnderflow)
+	}
+	if underflows, _ := res[4].(int64); underflows != 0 {
+		ri.logicalSizeUnderflow(ctx, key, anomalyScriptUnderflow, "unable to decrement entry logical size", zap.Int64("count", underflows))
+	}
+
+	if err = ri.incrFileSizeSum(ctx, logicalDelta); err != nil {
+		return
+	}
+	return
+}
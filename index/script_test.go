@@ -0,0 +1,478 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/anyproto/any-sync-filenode/index/indexproto"
+	"github.com/anyproto/any-sync-filenode/testutil"
+)
+
+func TestFileEntryScripts_Equivalence(t *testing.T) {
+	for _, isolated := range []bool{false, true} {
+		name := "shared group"
+		if isolated {
+			name = "isolated space"
+		}
+		t.Run(name, func(t *testing.T) {
+			fx := newFixture(t)
+			defer fx.Finish(t)
+			bs := testutil.NewRandBlocks(20)
+			require.NoError(t, fx.BlocksAdd(ctx, bs))
+
+			var (
+				scriptGroupId    = "A" + testutil.NewRandCid().String()
+				referenceGroupId = "A" + testutil.NewRandCid().String()
+				spaceIds         = []string{testutil.NewRandSpaceId(), testutil.NewRandSpaceId()}
+				fileIds          = make([]string, 5)
+			)
+			for i := range fileIds {
+				fileIds[i] = testutil.NewRandCid().String()
+			}
+			if isolated {
+				require.NoError(t, fx.SetSpaceLimit(ctx, Key{GroupId: scriptGroupId, SpaceId: spaceIds[0]}, 100))
+				require.NoError(t, fx.SetSpaceLimit(ctx, Key{GroupId: referenceGroupId, SpaceId: spaceIds[0]}, 100))
+			}
+
+			rnd := rand.New(rand.NewPCG(uint64(len(name)), 42))
+			for range 200 {
+				spaceId := spaceIds[rnd.IntN(len(spaceIds))]
+				fileId := fileIds[rnd.IntN(len(fileIds))]
+				scriptKey := Key{GroupId: scriptGroupId, SpaceId: spaceId}
+				referenceKey := Key{GroupId: referenceGroupId, SpaceId: spaceId}
+				if rnd.IntN(3) == 0 {
+					require.NoError(t, fx.FileUnbind(ctx, scriptKey, fileId))
+					require.NoError(t, fx.withSpace(referenceKey, func(entry groupSpaceEntry) error {
+						return fx.fileUnbindReference(ctx, referenceKey, entry, fileId)
+					}))
+					continue
+				}
+				var fileBlocks []blocks.Block
+				for _, b := range bs {
+					if rnd.IntN(4) == 0 {
+						fileBlocks = append(fileBlocks, b)
+					}
+				}
+				if len(fileBlocks) == 0 {
+					continue
+				}
+				cids, err := fx.CidEntriesByBlocks(ctx, fileBlocks)
+				require.NoError(t, err)
+				require.NoError(t, fx.FileBind(ctx, scriptKey, fileId, cids))
+				require.NoError(t, fx.withSpace(referenceKey, func(entry groupSpaceEntry) error {
+					return fx.fileBindReference(ctx, referenceKey, fileId, cids, entry)
+				}))
+				cids.Release()
+			}
+
+			fx.assertEquivalent(t, scriptGroupId, referenceGroupId, spaceIds, bs)
+		})
+	}
+}
+
+func TestFileEntryScripts_Entries(t *testing.T) {
+	fx := newFixture(t)
+	defer fx.Finish(t)
+	bs := testutil.NewRandBlocks(3)
+	require.NoError(t, fx.BlocksAdd(ctx, bs))
+	key := newRandKey()
+	fileId := testutil.NewRandCid().String()
+
+	// the fields unknown to the script must survive the update
+	entry, release, err := fx.AcquireSpace(ctx, "test", key)
+	require.NoError(t, err)
+	entry.space.VersionPolicy = &indexproto.VersionPolicy{KeepVersions: 3, KeepDays: 7}
+	entry.space.CreateTime = 12345
+	_, err = fx.cl.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		entry.space.Save(ctx, key, pipe)
+		return nil
+	})
+	require.NoError(t, err)
+	release()
+
+	cids, err := fx.CidEntriesByBlocks(ctx, bs)
+	require.NoError(t, err)
+	require.NoError(t, fx.FileBind(ctx, key, fileId, cids))
+	cids.Release()
+
+	sEntry, err := fx.getSpaceEntry(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(3), sEntry.GetVersionPolicy().GetKeepVersions())
+	assert.Equal(t, uint32(7), sEntry.GetVersionPolicy().GetKeepDays())
+	assert.Equal(t, int64(12345), sEntry.CreateTime)
+	assert.Equal(t, uint32(1), sEntry.FileCount)
+	assert.Equal(t, uint64(3), sEntry.CidCount)
+
+	require.NoError(t, fx.FileUnbind(ctx, key, fileId))
+	sEntry, err = fx.getSpaceEntry(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(3), sEntry.GetVersionPolicy().GetKeepVersions())
+	assert.Equal(t, uint32(0), sEntry.FileCount)
+	assert.Equal(t, uint64(0), sEntry.CidCount)
+	assert.Equal(t, uint64(0), sEntry.Size)
+	gEntry, err := fx.getGroupEntry(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, []string{key.SpaceId}, gEntry.SpaceIds)
+	assert.Equal(t, uint64(0), gEntry.Size)
+}
+
+func (fx *fixture) withSpace(key Key, f func(entry groupSpaceEntry) error) error {
+	entry, release, err := fx.AcquireSpace(ctx, "test", key)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return f(entry)
+}
+
+func (fx *fixture) assertEquivalent(t *testing.T, scriptGroupId, referenceGroupId string, spaceIds []string, bs []blocks.Block) {
+	var cidSpaceRefs = make(map[string]int32)
+	hashContent := func(k string) (refs map[string]string, files map[string][]string) {
+		all, err := fx.cl.HGetAll(ctx, k).Result()
+		require.NoError(t, err)
+		refs = make(map[string]string)
+		files = make(map[string][]string)
+		for hk, v := range all {
+			switch {
+			case strings.HasPrefix(hk, "c:"):
+				refs[hk] = v
+				if strings.HasPrefix(k, "s:") {
+					cidSpaceRefs[hk]++
+				}
+			case strings.HasPrefix(hk, "f:"):
+				fEntry := &indexproto.FileEntry{}
+				require.NoError(t, fEntry.UnmarshalVT([]byte(v)))
+				files[hk] = fEntry.Cids
+			}
+		}
+		return
+	}
+
+	for _, spaceId := range spaceIds {
+		scriptKey := Key{GroupId: scriptGroupId, SpaceId: spaceId}
+		referenceKey := Key{GroupId: referenceGroupId, SpaceId: spaceId}
+		scriptEntry, err := fx.getSpaceEntry(ctx, scriptKey)
+		require.NoError(t, err)
+		referenceEntry, err := fx.getSpaceEntry(ctx, referenceKey)
+		require.NoError(t, err)
+		assert.Equal(t, referenceEntry.Size, scriptEntry.Size, spaceId)
+		assert.Equal(t, referenceEntry.CidCount, scriptEntry.CidCount, spaceId)
+		assert.Equal(t, referenceEntry.FileCount, scriptEntry.FileCount, spaceId)
+		assert.Equal(t, referenceEntry.LogicalSize, scriptEntry.LogicalSize, spaceId)
+		assert.Equal(t, referenceEntry.Limit, scriptEntry.Limit, spaceId)
+
+		scriptRefs, scriptFiles := hashContent(SpaceKey(scriptKey))
+		referenceRefs, referenceFiles := hashContent(SpaceKey(referenceKey))
+		assert.Equal(t, referenceRefs, scriptRefs, spaceId)
+		assert.Equal(t, referenceFiles, scriptFiles, spaceId)
+	}
+
+	scriptGroup, err := fx.getGroupEntry(ctx, Key{GroupId: scriptGroupId})
+	require.NoError(t, err)
+	referenceGroup, err := fx.getGroupEntry(ctx, Key{GroupId: referenceGroupId})
+	require.NoError(t, err)
+	assert.Equal(t, referenceGroup.Size, scriptGroup.Size)
+	assert.Equal(t, referenceGroup.CidCount, scriptGroup.CidCount)
+	assert.Equal(t, referenceGroup.LogicalSize, scriptGroup.LogicalSize)
+	assert.Equal(t, referenceGroup.Limit, scriptGroup.Limit)
+	assert.ElementsMatch(t, referenceGroup.SpaceIds, scriptGroup.SpaceIds)
+	scriptRefs, _ := hashContent(GroupKey(Key{GroupId: scriptGroupId}))
+	referenceRefs, _ := hashContent(GroupKey(Key{GroupId: referenceGroupId}))
+	assert.Equal(t, referenceRefs, scriptRefs)
+
+	// both implementations share the cids, so the cid refs are the sum of the space refs
+	for _, b := range bs {
+		cEntry, err := fx.getCidEntry(ctx, b.Cid())
+		require.NoError(t, err)
+		assert.Equal(t, cidSpaceRefs[CidKey(b.Cid())], cEntry.Refs, b.Cid().String())
+	}
+}
+
+// fileBindReference is the pipeline implementation of fileBind used before the lua scripts
+func (ri *redisIndex) fileBindReference(ctx context.Context, key Key, fileId string, cids *CidEntries, entry groupSpaceEntry) (err error) {
+	var gk = GroupKey(key)
+	var sk = SpaceKey(key)
+
+	// get file entry
+	fileInfo, isNewFile, err := ri.getFileEntry(ctx, key, fileId)
+	if err != nil {
+		return
+	}
+
+	isolatedSpace := entry.space.Limit != 0
+	prevSize := fileInfo.Size
+
+	// make a list of indexes of non-exists cids
+	var newFileCidIdx = make([]int, 0, len(cids.entries))
+	for i, c := range cids.entries {
+		if !fileInfo.Exists(c.Cid.String()) {
+			newFileCidIdx = append(newFileCidIdx, i)
+			fileInfo.Cids = append(fileInfo.Cids, c.Cid.String())
+			fileInfo.Size += c.Size
+		}
+	}
+
+	// all cids exists, nothing to do
+	if len(newFileCidIdx) == 0 {
+		return
+	}
+
+	var affectedCidIdx = make([]int, 0, len(cids.entries))
+
+	// get all cids from space and group in one pipeline
+	var (
+		cidExistSpaceCmds = make([]*redis.BoolCmd, len(newFileCidIdx))
+		cidExistGroupCmds = make([]*redis.BoolCmd, len(newFileCidIdx))
+	)
+	_, err = ri.cl.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, idx := range newFileCidIdx {
+			ck := CidKey(cids.entries[idx].Cid)
+			cidExistSpaceCmds[i] = pipe.HExists(ctx, sk, ck)
+			if !isolatedSpace {
+				cidExistGroupCmds[i] = pipe.HExists(ctx, gk, ck)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	// calculate new group and space stats
+	for i, idx := range newFileCidIdx {
+		if !isolatedSpace {
+			ex, err := cidExistGroupCmds[i].Result()
+			if err != nil {
+				return err
+			}
+			if !ex {
+				entry.group.CidCount++
+				entry.group.Size += cids.entries[idx].Size
+			}
+		}
+		ex, err := cidExistSpaceCmds[i].Result()
+		if err != nil {
+			return err
+		}
+		if !ex {
+			entry.space.CidCount++
+			entry.space.Size += cids.entries[idx].Size
+			affectedCidIdx = append(affectedCidIdx, idx)
+		}
+	}
+	entry.group.AddSpaceId(key.SpaceId)
+	if isNewFile {
+		entry.space.FileCount++
+	}
+
+	logicalDelta := ri.updateLogicalSize(ctx, key, entry, prevSize, fileInfo.Size)
+
+	// make group and space updates in one tx
+	_, err = ri.cl.TxPipelined(ctx, func(tx redis.Pipeliner) error {
+		// increment cid refs
+		for _, idx := range newFileCidIdx {
+			ck := CidKey(cids.entries[idx].Cid)
+			if !isolatedSpace {
+				tx.HIncrBy(ctx, gk, ck, 1)
+			}
+			tx.HIncrBy(ctx, sk, ck, 1)
+		}
+		// save info
+		entry.space.Save(ctx, key, tx)
+		entry.group.Save(ctx, tx)
+		fileInfo.Save(ctx, key, fileId, tx)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	if err = ri.incrFileSizeSum(ctx, logicalDelta); err != nil {
+		return
+	}
+
+	// update cids
+	var saveErrs []error
+	for _, idx := range affectedCidIdx {
+		cids.entries[idx].Refs++
+		if saveErr := cids.entries[idx].Save(ctx, ri.cl); saveErr != nil {
+			log.WarnCtx(ctx, "unable to save cid info", zap.Error(saveErr), zap.String("cid", cids.entries[idx].Cid.String()))
+			saveErrs = append(saveErrs, saveErr)
+		}
+	}
+	return errors.Join(saveErrs...)
+}
+
+// fileUnbindReference is the pipeline implementation of fileUnbind used before the lua scripts
+func (ri *redisIndex) fileUnbindReference(ctx context.Context, key Key, entry groupSpaceEntry, fileId string) (err error) {
+	var (
+		sk = SpaceKey(key)
+		gk = GroupKey(key)
+	)
+	// get file entry
+	fileInfo, isNewFile, err := ri.getFileEntry(ctx, key, fileId)
+	if err != nil {
+		return
+	}
+	if isNewFile {
+		// means file doesn't exist
+		return nil
+	}
+
+	// fetch cids including versions
+	cids, err := ri.CidEntriesByString(ctx, fileInfo.AllCids())
+	if err != nil {
+		return err
+	}
+	defer cids.Release()
+
+	isolatedSpace := entry.space.Limit != 0
+
+	// fetch cid refs in one pipeline
+	var (
+		groupCidRefs = make([]*redis.StringCmd, len(cids.entries))
+		spaceCidRefs = make([]*redis.StringCmd, len(cids.entries))
+	)
+	_, err = ri.cl.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, c := range cids.entries {
+			if !isolatedSpace {
+				groupCidRefs[i] = pipe.HGet(ctx, gk, CidKey(c.Cid))
+			}
+			spaceCidRefs[i] = pipe.HGet(ctx, sk, CidKey(c.Cid))
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	// update info and calculate changes
+	var (
+		groupRemoveKeys = make([]string, 0, len(cids.entries))
+		spaceRemoveKeys = make([]string, 0, len(cids.entries))
+		groupDecrKeys   = make([]string, 0, len(cids.entries))
+		spaceDecrKeys   = make([]string, 0, len(cids.entries))
+		affectedCidIdx  = make([]int, 0, len(cids.entries))
+	)
+	if entry.space.FileCount != 0 {
+		entry.space.FileCount--
+	} else {
+		log.WarnCtx(ctx, "file: unable to decrement 0-ref", zap.String("spaceId", key.SpaceId))
+	}
+	for i, c := range cids.entries {
+		ck := CidKey(c.Cid)
+		if !isolatedSpace {
+			res, err := groupCidRefs[i].Result()
+			if err != nil {
+				return err
+			}
+			if res == "1" {
+				groupRemoveKeys = append(groupRemoveKeys, ck)
+				if entry.group.Size-c.Size > entry.group.Size {
+					log.WarnCtx(ctx, "group: unable to decrement size", zap.Uint64("before", entry.group.Size), zap.Uint64("size", c.Size), zap.String("spaceId", key.SpaceId))
+				} else {
+					entry.group.Size -= c.Size
+				}
+				if entry.group.CidCount != 0 {
+					entry.group.CidCount--
+				} else {
+					log.WarnCtx(ctx, "group: unable to decrement 0-ref", zap.String("spaceId", key.SpaceId))
+				}
+			} else {
+				groupDecrKeys = append(groupDecrKeys, ck)
+			}
+		}
+		res, err := spaceCidRefs[i].Result()
+		if err != nil {
+			return err
+		}
+		if res == "1" {
+			spaceRemoveKeys = append(spaceRemoveKeys, ck)
+			if entry.space.Size-c.Size > entry.space.Size {
+				log.WarnCtx(ctx, "space: unable to decrement size", zap.Uint64("before", entry.space.Size), zap.Uint64("size", c.Size), zap.String("spaceId", key.SpaceId))
+			} else {
+				entry.space.Size -= c.Size
+			}
+			if entry.space.CidCount != 0 {
+				entry.space.CidCount--
+			} else {
+				log.WarnCtx(ctx, "space: unable to decrement 0-ref", zap.String("spaceId", key.SpaceId))
+			}
+			affectedCidIdx = append(affectedCidIdx, i)
+		} else {
+			spaceDecrKeys = append(spaceDecrKeys, ck)
+		}
+	}
+
+	logicalDelta := ri.updateLogicalSize(ctx, key, entry, fileInfo.Size, 0)
+
+	// do updates in one tx
+	_, err = ri.cl.TxPipelined(ctx, func(tx redis.Pipeliner) error {
+		tx.HDel(ctx, sk, FileKey(fileId))
+		if len(spaceRemoveKeys) != 0 {
+			tx.HDel(ctx, sk, spaceRemoveKeys...)
+		}
+		if len(groupRemoveKeys) != 0 {
+			tx.HDel(ctx, gk, groupRemoveKeys...)
+		}
+		if len(spaceDecrKeys) != 0 {
+			for _, k := range spaceDecrKeys {
+				tx.HIncrBy(ctx, sk, k, -1)
+			}
+		}
+		if len(groupDecrKeys) != 0 {
+			for _, k := range groupDecrKeys {
+				tx.HIncrBy(ctx, gk, k, -1)
+			}
+		}
+		entry.space.Save(ctx, key, tx)
+		entry.group.Save(ctx, tx)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	if err = ri.incrFileSizeSum(ctx, logicalDelta); err != nil {
+		return
+	}
+
+	// update cids
+	var saveErrs []error
+	for _, idx := range affectedCidIdx {
+		if cids.entries[idx].Refs != 0 {
+			cids.entries[idx].Refs--
+		} else {
+			log.WarnCtx(ctx, "cid: unable to decrement 0-ref", zap.String("cid", cids.entries[idx].Cid.String()), zap.String("spaceId", key.SpaceId))
+			continue
+		}
+		if saveErr := cids.entries[idx].Save(ctx, ri.cl); saveErr != nil {
+			log.WarnCtx(ctx, "unable to save cid info", zap.Error(saveErr), zap.String("cid", cids.entries[idx].Cid.String()), zap.String("spaceId", key.SpaceId))
+			saveErrs = append(saveErrs, saveErr)
+		}
+	}
+	return errors.Join(saveErrs...)
+}
+
+// updateLogicalSize applies the file size change to the space and group logical sizes the way the scripts do,
+// returns the delta for the node-wide counter
+func (ri *redisIndex) updateLogicalSize(ctx context.Context, key Key, entry groupSpaceEntry, prevSize, size uint64) (delta int64) {
+	if prevSize == size {
+		return
+	}
+	if size > prevSize {
+		entry.space.LogicalSize += size - prevSize
+		entry.group.LogicalSize += size - prevSize
+	} else {
+		entry.space.LogicalSize = ri.decrLogicalSize(ctx, "space logical", entry.space.LogicalSize, prevSize-size, key)
+		entry.group.LogicalSize = ri.decrLogicalSize(ctx, "group logical", entry.group.LogicalSize, prevSize-size, key)
+	}
+	return int64(size) - int64(prevSize)
+}
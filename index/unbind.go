@@ -3,13 +3,15 @@ package index
 import (
 	"context"
 	"errors"
+	"time"
 
-	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
+
+	"github.com/anyproto/any-sync-filenode/tracing"
 )
 
 func (ri *redisIndex) FileUnbind(ctx context.Context, key Key, fileIds ...string) (err error) {
-	entry, release, err := ri.AcquireSpace(ctx, key)
+	entry, release, err := ri.AcquireSpace(ctx, "fileUnbind", key)
 	if err != nil {
 		return
 	}
@@ -23,10 +25,12 @@ func (ri *redisIndex) FileUnbind(ctx context.Context, key Key, fileIds ...string
 }
 
 func (ri *redisIndex) fileUnbind(ctx context.Context, key Key, entry groupSpaceEntry, fileId string) (err error) {
-	var (
-		sk = SpaceKey(key)
-		gk = GroupKey(key)
-	)
+	ctx, span := tracing.Start(ctx, "index.fileUnbind", tracing.SpaceId(key.SpaceId), tracing.GroupId(key.GroupId), tracing.FileId(fileId))
+	defer func(st time.Time) {
+		ri.metric.IndexOp("fileUnbind", time.Since(st))
+		tracing.End(span, err)
+	}(time.Now())
+
 	// get file entry
 	fileInfo, isNewFile, err := ri.getFileEntry(ctx, key, fileId)
 	if err != nil {
@@ -37,125 +41,33 @@ func (ri *redisIndex) fileUnbind(ctx context.Context, key Key, entry groupSpaceE
 		return nil
 	}
 
-	// fetch cids
-	cids, err := ri.CidEntriesByString(ctx, fileInfo.Cids)
+	// fetch cids including versions
+	cids, err := ri.CidEntriesByString(ctx, fileInfo.AllCids())
 	if err != nil {
 		return err
 	}
 	defer cids.Release()
 
-	isolatedSpace := entry.space.Limit != 0
-
-	// fetch cid refs in one pipeline
-	var (
-		groupCidRefs = make([]*redis.StringCmd, len(cids.entries))
-		spaceCidRefs = make([]*redis.StringCmd, len(cids.entries))
-	)
-	_, err = ri.cl.Pipelined(ctx, func(pipe redis.Pipeliner) error {
-		for i, c := range cids.entries {
-			if !isolatedSpace {
-				groupCidRefs[i] = pipe.HGet(ctx, gk, CidKey(c.Cid))
-			}
-			spaceCidRefs[i] = pipe.HGet(ctx, sk, CidKey(c.Cid))
-		}
-		return nil
-	})
-	if err != nil {
-		return
+	var args = make([]any, 0, len(cids.entries)*2)
+	for _, c := range cids.entries {
+		args = append(args, CidKey(c.Cid), c.Size)
 	}
 
-	// update info and calculate changes
-	var (
-		groupRemoveKeys = make([]string, 0, len(cids.entries))
-		spaceRemoveKeys = make([]string, 0, len(cids.entries))
-		groupDecrKeys   = make([]string, 0, len(cids.entries))
-		spaceDecrKeys   = make([]string, 0, len(cids.entries))
-		affectedCidIdx  = make([]int, 0, len(cids.entries))
-	)
-	if entry.space.FileCount != 0 {
-		entry.space.FileCount--
-	} else {
-		log.WarnCtx(ctx, "file: unable to decrement 0-ref", zap.String("spaceId", key.SpaceId))
-	}
-	for i, c := range cids.entries {
-		ck := CidKey(c.Cid)
-		if !isolatedSpace {
-			res, err := groupCidRefs[i].Result()
-			if err != nil {
-				return err
-			}
-			if res == "1" {
-				groupRemoveKeys = append(groupRemoveKeys, ck)
-				if entry.group.Size-c.Size > entry.group.Size {
-					log.WarnCtx(ctx, "group: unable to decrement size", zap.Uint64("before", entry.group.Size), zap.Uint64("size", c.Size), zap.String("spaceId", key.SpaceId))
-				} else {
-					entry.group.Size -= c.Size
-				}
-				if entry.group.CidCount != 0 {
-					entry.group.CidCount--
-				} else {
-					log.WarnCtx(ctx, "group: unable to decrement 0-ref", zap.String("spaceId", key.SpaceId))
-				}
-			} else {
-				groupDecrKeys = append(groupDecrKeys, ck)
-			}
-		}
-		res, err := spaceCidRefs[i].Result()
-		if err != nil {
-			return err
-		}
-		if res == "1" {
-			spaceRemoveKeys = append(spaceRemoveKeys, ck)
-			if entry.space.Size-c.Size > entry.space.Size {
-				log.WarnCtx(ctx, "space: unable to decrement size", zap.Uint64("before", entry.space.Size), zap.Uint64("size", c.Size), zap.String("spaceId", key.SpaceId))
-			} else {
-				entry.space.Size -= c.Size
-			}
-			if entry.space.CidCount != 0 {
-				entry.space.CidCount--
-			} else {
-				log.WarnCtx(ctx, "space: unable to decrement 0-ref", zap.String("spaceId", key.SpaceId))
-			}
-			affectedCidIdx = append(affectedCidIdx, i)
-		} else {
-			spaceDecrKeys = append(spaceDecrKeys, ck)
-		}
-	}
-
-	// do updates in one tx
-	_, err = ri.cl.TxPipelined(ctx, func(tx redis.Pipeliner) error {
-		tx.HDel(ctx, sk, FileKey(fileId))
-		if len(spaceRemoveKeys) != 0 {
-			tx.HDel(ctx, sk, spaceRemoveKeys...)
-		}
-		if len(groupRemoveKeys) != 0 {
-			tx.HDel(ctx, gk, groupRemoveKeys...)
-		}
-		if len(spaceDecrKeys) != 0 {
-			for _, k := range spaceDecrKeys {
-				tx.HIncrBy(ctx, sk, k, -1)
-			}
-		}
-		if len(groupDecrKeys) != 0 {
-			for _, k := range groupDecrKeys {
-				tx.HIncrBy(ctx, gk, k, -1)
-			}
-		}
-		entry.space.Save(ctx, key, tx)
-		entry.group.Save(ctx, tx)
-		return nil
-	})
+	// remove the file, decrement refs and update group and space stats in one atomic script call
+	indexUpdate := newFilesIndexUpdate(filesIndexMembers(fileId, fileInfo.FileEntry), nil)
+	affected, err := ri.evalEntryScript(ctx, fileUnbindScript, key, entry, fileId, indexUpdate, -int64(fileInfo.Size), args...)
 	if err != nil {
 		return
 	}
 
 	// update cids
 	var saveErrs []error
-	for _, idx := range affectedCidIdx {
+	for _, idx := range affected {
 		if cids.entries[idx].Refs != 0 {
 			cids.entries[idx].Refs--
 		} else {
 			log.WarnCtx(ctx, "cid: unable to decrement 0-ref", zap.String("cid", cids.entries[idx].Cid.String()), zap.String("spaceId", key.SpaceId))
+			ri.reportAnomaly(ctx, key, anomalyCidZeroRef)
 			continue
 		}
 		if saveErr := cids.entries[idx].Save(ctx, ri.cl); saveErr != nil {
@@ -0,0 +1,361 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/go-redsync/redsync/v4"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/anyproto/any-sync-filenode/index/indexproto"
+)
+
+var ErrVersionNotFound = errors.New("file version not found")
+
+// versionedSpacesKey is the set of the spaces which versions expire, they are pruned periodically
+const versionedSpacesKey = "versionedSpaces.{system}"
+
+type VersionPolicy struct {
+	KeepVersions uint32
+	KeepDays     uint32
+}
+
+func (vp VersionPolicy) Enabled() bool {
+	return vp.KeepVersions != 0 || vp.KeepDays != 0
+}
+
+type FileVersionInfo struct {
+	VersionId  uint32
+	BytesUsage uint64
+	CidsCount  uint64
+	CreateTime int64
+}
+
+// FileBindRevision binds cids to the file as a new revision: the previous content of the file is kept as a version
+// according to the space version policy. If the space has no version policy, it works as FileBind.
+func (ri *redisIndex) FileBindRevision(ctx context.Context, key Key, fileId string, cids *CidEntries) (err error) {
+	entry, release, err := ri.AcquireSpace(ctx, "fileBindRevision", key)
+	if err != nil {
+		return
+	}
+	defer release()
+
+	policy := spaceVersionPolicy(entry.space)
+	if !policy.Enabled() {
+		return ri.fileBind(ctx, key, fileId, cids, entry, nil)
+	}
+
+	fileInfo, isNewFile, err := ri.getFileEntry(ctx, key, fileId)
+	if err != nil {
+		return
+	}
+	if isNewFile {
+		return ri.fileBind(ctx, key, fileId, cids, entry, nil)
+	}
+
+	prevCids, prevSize, prevIndexMembers := fileInfo.AllCids(), fileInfo.Size, filesIndexMembers(fileId, fileInfo.FileEntry)
+	fileInfo.addVersion()
+	fileInfo.Cids = make([]string, 0, len(cids.entries))
+	fileInfo.Size = 0
+	for _, c := range cids.entries {
+		if cs := c.Cid.String(); !slices.Contains(fileInfo.Cids, cs) {
+			fileInfo.Cids = append(fileInfo.Cids, cs)
+			fileInfo.Size += c.Size
+		}
+	}
+	fileInfo.pruneVersions(policy)
+	return ri.fileUpdateRefs(ctx, key, entry, fileId, fileInfo, prevCids, prevSize, prevIndexMembers)
+}
+
+// FileVersionRestore makes the given version the current content of the file, the current content is kept as a version
+func (ri *redisIndex) FileVersionRestore(ctx context.Context, key Key, fileId string, versionId uint32) (err error) {
+	entry, release, err := ri.AcquireSpace(ctx, "fileVersionRestore", key)
+	if err != nil {
+		return
+	}
+	defer release()
+
+	fileInfo, isNewFile, err := ri.getFileEntry(ctx, key, fileId)
+	if err != nil {
+		return
+	}
+	if isNewFile {
+		return ErrVersionNotFound
+	}
+	idx := slices.IndexFunc(fileInfo.Versions, func(v *indexproto.FileVersion) bool {
+		return v.Id == versionId
+	})
+	if idx == -1 {
+		return ErrVersionNotFound
+	}
+
+	prevCids, prevSize, prevIndexMembers := fileInfo.AllCids(), fileInfo.Size, filesIndexMembers(fileId, fileInfo.FileEntry)
+	version := fileInfo.Versions[idx]
+	fileInfo.Versions = slices.Delete(fileInfo.Versions, idx, idx+1)
+	fileInfo.addVersion()
+	fileInfo.Cids = version.Cids
+	fileInfo.Size = version.Size
+	fileInfo.pruneVersions(spaceVersionPolicy(entry.space))
+	return ri.fileUpdateRefs(ctx, key, entry, fileId, fileInfo, prevCids, prevSize, prevIndexMembers)
+}
+
+// FileVersions returns the versions list of the file, from the oldest to the newest
+func (ri *redisIndex) FileVersions(ctx context.Context, key Key, fileId string) (versions []FileVersionInfo, err error) {
+	sk := SpaceKey(key)
+	var cmd *redis.StringCmd
+	if _, err = ri.readKey(ctx, sk, func(tx redis.Pipeliner) {
+		cmd = tx.HGet(ctx, sk, FileKey(fileId))
+	}); err != nil {
+		return
+	}
+	fileInfo, _, err := fileEntryFromCmd(cmd)
+	if err != nil {
+		return
+	}
+	versions = make([]FileVersionInfo, len(fileInfo.Versions))
+	for i, v := range fileInfo.Versions {
+		versions[i] = FileVersionInfo{
+			VersionId:  v.Id,
+			BytesUsage: v.Size,
+			CidsCount:  uint64(len(v.Cids)),
+			CreateTime: v.CreateTime,
+		}
+	}
+	return
+}
+
+func (ri *redisIndex) SetSpaceVersionPolicy(ctx context.Context, key Key, policy VersionPolicy) (err error) {
+	entry, release, err := ri.AcquireSpace(ctx, "setSpaceVersionPolicy", key)
+	if err != nil {
+		return
+	}
+	defer release()
+	if policy.Enabled() {
+		entry.space.VersionPolicy = &indexproto.VersionPolicy{
+			KeepVersions: policy.KeepVersions,
+			KeepDays:     policy.KeepDays,
+		}
+	} else {
+		entry.space.VersionPolicy = nil
+	}
+	entry.group.AddSpaceId(key.SpaceId)
+	_, err = ri.cl.TxPipelined(ctx, func(tx redis.Pipeliner) error {
+		entry.space.Save(ctx, key, tx)
+		entry.group.Save(ctx, tx)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	// the set is in another cluster slot, the space without the expiring versions is removed from the set by the next prune
+	if policy.KeepDays != 0 {
+		return ri.cl.SAdd(ctx, versionedSpacesKey, spaceMember(key)).Err()
+	}
+	return
+}
+
+// pruneVersionsPeriodic removes the expired versions of all the spaces with KeepDays policy, it runs on one instance at a time
+func (ri *redisIndex) pruneVersionsPeriodic(ctx context.Context) (err error) {
+	// pruning is a write
+	if ri.InMaintenance() {
+		return
+	}
+	mu := ri.redsync.NewMutex(lockKeyPrefix+"versionsPrune", redsync.WithExpiry(time.Hour), redsync.WithGenValueFunc(ri.lockValueFunc("versionsPrune")))
+	if err = mu.TryLockContext(ctx); err != nil {
+		var errTaken *redsync.ErrTaken
+		if errors.As(err, &errTaken) || errors.Is(err, redsync.ErrFailed) {
+			// another node is pruning
+			return nil
+		}
+		return
+	}
+	defer func() {
+		_, _ = mu.Unlock()
+	}()
+	st := time.Now()
+	var spaces, pruned int
+	var cursor uint64
+	for {
+		var members []string
+		if members, cursor, err = ri.cl.SScan(ctx, versionedSpacesKey, cursor, "", 100).Result(); err != nil {
+			return
+		}
+		for _, m := range members {
+			key, ok := parseSpaceMember(m)
+			if !ok {
+				log.Warn("invalid versioned space member", zap.String("member", m))
+				continue
+			}
+			count, pErr := ri.pruneSpaceVersions(ctx, key)
+			if pErr != nil {
+				if errors.Is(pErr, ErrSpaceIsDeleted) {
+					continue
+				}
+				return pErr
+			}
+			spaces++
+			pruned += count
+		}
+		if _, err = mu.ExtendContext(ctx); err != nil {
+			return
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	log.Info("expired versions pruned", zap.Int("spaces", spaces), zap.Int("versions", pruned), zap.Duration("dur", time.Since(st)))
+	return
+}
+
+// pruneSpaceVersions removes the expired versions of the space files and releases their cids
+func (ri *redisIndex) pruneSpaceVersions(ctx context.Context, key Key) (pruned int, err error) {
+	entry, release, err := ri.AcquireSpace(ctx, "versionsPrune", key)
+	if err != nil {
+		return
+	}
+	defer release()
+	policy := spaceVersionPolicy(entry.space)
+	if policy.KeepDays == 0 {
+		// the policy is changed or the space is removed
+		return 0, ri.cl.SRem(ctx, versionedSpacesKey, spaceMember(key)).Err()
+	}
+
+	// collect the files first, the updates change the hash during the scan
+	var (
+		files  = map[string]*fileEntry{}
+		sk     = SpaceKey(key)
+		cursor uint64
+	)
+	for {
+		var res []string
+		if res, cursor, err = ri.cl.HScan(ctx, sk, cursor, "f:*", 1000).Result(); err != nil {
+			return
+		}
+		// the result contains fields and values
+		for i := 1; i < len(res); i += 2 {
+			fileEntryProto := &indexproto.FileEntry{}
+			if uErr := fileEntryProto.UnmarshalVT([]byte(res[i])); uErr != nil {
+				log.WarnCtx(ctx, "can't unmarshal file entry", zap.String("spaceId", key.SpaceId), zap.String("fileId", res[i-1][2:]), zap.Error(uErr))
+				continue
+			}
+			// the scan can return the field twice
+			if len(fileEntryProto.Versions) != 0 {
+				files[res[i-1][2:]] = &fileEntry{FileEntry: fileEntryProto}
+			}
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+
+	for fileId, fileInfo := range files {
+		prevCids, prevVersions, prevIndexMembers := fileInfo.AllCids(), len(fileInfo.Versions), filesIndexMembers(fileId, fileInfo.FileEntry)
+		fileInfo.pruneVersions(policy)
+		if len(fileInfo.Versions) == prevVersions {
+			continue
+		}
+		if err = ri.fileUpdateRefs(ctx, key, entry, fileId, fileInfo, prevCids, fileInfo.Size, prevIndexMembers); err != nil {
+			return
+		}
+		pruned += prevVersions - len(fileInfo.Versions)
+	}
+	return
+}
+
+func spaceVersionPolicy(se *spaceEntry) VersionPolicy {
+	if se.VersionPolicy == nil {
+		return VersionPolicy{}
+	}
+	return VersionPolicy{
+		KeepVersions: se.VersionPolicy.KeepVersions,
+		KeepDays:     se.VersionPolicy.KeepDays,
+	}
+}
+
+// fileUpdateRefs saves the file entry and updates the space and group refs according to the difference
+// between prevCids and the current file cids including versions, and the files index according to the difference of the members
+func (ri *redisIndex) fileUpdateRefs(ctx context.Context, key Key, entry groupSpaceEntry, fileId string, fileInfo *fileEntry, prevCids []string, prevSize uint64, prevIndexMembers []string) (err error) {
+	var (
+		newCids = fileInfo.AllCids()
+		added   []string
+		removed []string
+	)
+	for _, c := range newCids {
+		if !slices.Contains(prevCids, c) {
+			added = append(added, c)
+		}
+	}
+	for _, c := range prevCids {
+		if !slices.Contains(newCids, c) {
+			removed = append(removed, c)
+		}
+	}
+
+	cids, err := ri.CidEntriesByString(ctx, append(slices.Clone(added), removed...))
+	if err != nil {
+		return
+	}
+	defer cids.Release()
+
+	fileData, err := fileInfo.Marshal()
+	if err != nil {
+		return
+	}
+	var args = make([]any, 0, 2+len(cids.entries)*2)
+	args = append(args, fileData, len(added))
+	for _, c := range cids.entries {
+		args = append(args, CidKey(c.Cid), c.Size)
+	}
+
+	// update refs and group and space stats in one atomic script call
+	indexUpdate := newFilesIndexUpdate(prevIndexMembers, filesIndexMembers(fileId, fileInfo.FileEntry))
+	affected, err := ri.evalEntryScript(ctx, fileUpdateScript, key, entry, fileId, indexUpdate, int64(fileInfo.Size)-int64(prevSize), args...)
+	if err != nil {
+		return
+	}
+
+	// update cids, the added cids are first
+	var saveErrs []error
+	for _, idx := range affected {
+		c := cids.entries[idx]
+		if idx < len(added) {
+			c.Refs++
+		} else if c.Refs != 0 {
+			c.Refs--
+		} else {
+			log.WarnCtx(ctx, "cid: unable to decrement 0-ref", zap.String("cid", c.Cid.String()), zap.String("spaceId", key.SpaceId))
+			ri.reportAnomaly(ctx, key, anomalyCidZeroRef)
+			continue
+		}
+		if saveErr := c.Save(ctx, ri.cl); saveErr != nil {
+			log.WarnCtx(ctx, "unable to save cid info", zap.Error(saveErr), zap.String("cid", c.Cid.String()))
+			saveErrs = append(saveErrs, saveErr)
+		}
+	}
+	return errors.Join(saveErrs...)
+}
+
+func (f *fileEntry) addVersion() {
+	f.LastVersionId++
+	f.Versions = append(f.Versions, &indexproto.FileVersion{
+		Id:         f.LastVersionId,
+		Cids:       f.Cids,
+		Size:       f.Size,
+		CreateTime: time.Now().Unix(),
+	})
+}
+
+func (f *fileEntry) pruneVersions(policy VersionPolicy) {
+	if policy.KeepDays != 0 {
+		deadline := time.Now().Add(-time.Hour * 24 * time.Duration(policy.KeepDays)).Unix()
+		f.Versions = slices.DeleteFunc(f.Versions, func(v *indexproto.FileVersion) bool {
+			return v.CreateTime < deadline
+		})
+	}
+	if policy.KeepVersions != 0 && len(f.Versions) > int(policy.KeepVersions) {
+		f.Versions = f.Versions[len(f.Versions)-int(policy.KeepVersions):]
+	}
+}
@@ -0,0 +1,160 @@
+package index
+
+import (
+	"testing"
+	"time"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyproto/any-sync-filenode/testutil"
+)
+
+func TestRedisIndex_FileBindRevision(t *testing.T) {
+	bindRevision := func(t *testing.T, fx *fixture, key Key, fileId string, bs []blocks.Block) {
+		cids, err := fx.CidEntriesByBlocks(ctx, bs)
+		require.NoError(t, err)
+		defer cids.Release()
+		require.NoError(t, fx.FileBindRevision(ctx, key, fileId, cids))
+	}
+	sumSize := func(bs []blocks.Block) (size uint64) {
+		for _, b := range bs {
+			size += uint64(len(b.RawData()))
+		}
+		return
+	}
+
+	t.Run("no policy", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.Finish(t)
+		key := newRandKey()
+		fileId := testutil.NewRandCid().String()
+		bs := testutil.NewRandBlocks(4)
+		require.NoError(t, fx.BlocksAdd(ctx, bs))
+
+		bindRevision(t, fx, key, fileId, bs[:2])
+		bindRevision(t, fx, key, fileId, bs[2:])
+
+		// works as a regular bind
+		fInfo, err := fx.FileInfo(ctx, key, fileId)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(4), fInfo[0].CidsCount)
+		assert.Equal(t, uint32(0), fInfo[0].VersionsCount)
+	})
+	t.Run("keep versions", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.Finish(t)
+		key := newRandKey()
+		fileId := testutil.NewRandCid().String()
+		bs := testutil.NewRandBlocks(5)
+		require.NoError(t, fx.BlocksAdd(ctx, bs))
+		require.NoError(t, fx.SetSpaceVersionPolicy(ctx, key, VersionPolicy{KeepVersions: 2}))
+
+		bindRevision(t, fx, key, fileId, bs[:2])
+		bindRevision(t, fx, key, fileId, bs[2:4])
+
+		fInfo, err := fx.FileInfo(ctx, key, fileId)
+		require.NoError(t, err)
+		assert.Equal(t, FileInfo{BytesUsage: sumSize(bs[2:4]), CidsCount: 2, VersionsCount: 1}, fInfo[0])
+
+		// versions count toward usage
+		spaceInfo, err := fx.SpaceInfo(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, sumSize(bs[:4]), spaceInfo.BytesUsage)
+		assert.Equal(t, uint64(4), spaceInfo.CidsCount)
+
+		bindRevision(t, fx, key, fileId, bs[4:])
+		bindRevision(t, fx, key, fileId, bs[:1])
+
+		// the first version is pruned, but bs[0] is the current content
+		versions, err := fx.FileVersions(ctx, key, fileId)
+		require.NoError(t, err)
+		require.Len(t, versions, 2)
+		assert.Equal(t, uint32(2), versions[0].VersionId)
+		assert.Equal(t, uint32(3), versions[1].VersionId)
+
+		spaceInfo, err = fx.SpaceInfo(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, sumSize(bs)-sumSize(bs[1:2]), spaceInfo.BytesUsage)
+		assert.Equal(t, uint64(4), spaceInfo.CidsCount)
+
+		res, err := fx.Check(ctx, key, false)
+		require.NoError(t, err)
+		assert.Empty(t, res)
+	})
+	t.Run("restore", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.Finish(t)
+		key := newRandKey()
+		fileId := testutil.NewRandCid().String()
+		bs := testutil.NewRandBlocks(4)
+		require.NoError(t, fx.BlocksAdd(ctx, bs))
+		require.NoError(t, fx.SetSpaceVersionPolicy(ctx, key, VersionPolicy{KeepDays: 1}))
+
+		bindRevision(t, fx, key, fileId, bs[:2])
+		bindRevision(t, fx, key, fileId, bs[2:])
+
+		assert.ErrorIs(t, fx.FileVersionRestore(ctx, key, fileId, 42), ErrVersionNotFound)
+		require.NoError(t, fx.FileVersionRestore(ctx, key, fileId, 1))
+
+		fInfo, err := fx.FileInfo(ctx, key, fileId)
+		require.NoError(t, err)
+		assert.Equal(t, FileInfo{BytesUsage: sumSize(bs[:2]), CidsCount: 2, VersionsCount: 1}, fInfo[0])
+
+		versions, err := fx.FileVersions(ctx, key, fileId)
+		require.NoError(t, err)
+		require.Len(t, versions, 1)
+		assert.Equal(t, uint32(2), versions[0].VersionId)
+		assert.Equal(t, sumSize(bs[2:]), versions[0].BytesUsage)
+
+		// unbind removes all the versions
+		require.NoError(t, fx.FileUnbind(ctx, key, fileId))
+		spaceInfo, err := fx.SpaceInfo(ctx, key)
+		require.NoError(t, err)
+		assert.Empty(t, spaceInfo.BytesUsage)
+		assert.Empty(t, spaceInfo.CidsCount)
+	})
+	t.Run("prune expired", func(t *testing.T) {
+		fx := newFixture(t)
+		defer fx.Finish(t)
+		key := newRandKey()
+		fileId := testutil.NewRandCid().String()
+		bs := testutil.NewRandBlocks(4)
+		require.NoError(t, fx.BlocksAdd(ctx, bs))
+		require.NoError(t, fx.SetSpaceVersionPolicy(ctx, key, VersionPolicy{KeepDays: 1}))
+		require.NoError(t, fx.cl.SIsMember(ctx, versionedSpacesKey, spaceMember(key)).Err())
+
+		bindRevision(t, fx, key, fileId, bs[:2])
+		bindRevision(t, fx, key, fileId, bs[2:])
+
+		// make the version expired without a new bind
+		fEntry, _, err := fx.getFileEntry(ctx, key, fileId)
+		require.NoError(t, err)
+		require.Len(t, fEntry.Versions, 1)
+		fEntry.Versions[0].CreateTime = time.Now().Add(-time.Hour * 48).Unix()
+		fData, _ := fEntry.MarshalVT()
+		require.NoError(t, fx.cl.HSet(ctx, SpaceKey(key), FileKey(fileId), fData).Err())
+
+		require.NoError(t, fx.pruneVersionsPeriodic(ctx))
+
+		versions, err := fx.FileVersions(ctx, key, fileId)
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+		spaceInfo, err := fx.SpaceInfo(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, sumSize(bs[2:]), spaceInfo.BytesUsage)
+		assert.Equal(t, uint64(2), spaceInfo.CidsCount)
+
+		res, err := fx.Check(ctx, key, false)
+		require.NoError(t, err)
+		assert.Empty(t, res)
+
+		// the space without the policy leaves the set on the next prune
+		require.NoError(t, fx.SetSpaceVersionPolicy(ctx, key, VersionPolicy{}))
+		require.NoError(t, fx.pruneVersionsPeriodic(ctx))
+		isMember, err := fx.cl.SIsMember(ctx, versionedSpacesKey, spaceMember(key)).Result()
+		require.NoError(t, err)
+		assert.False(t, isMember)
+	})
+}
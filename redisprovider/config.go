@@ -7,4 +7,38 @@ type configSource interface {
 type Config struct {
 	IsCluster bool   `yaml:"isCluster"`
 	Url       string `yaml:"url"`
+	// Username and Password override the url credentials, the ANY_SYNC_FILENODE_REDIS_USERNAME and ANY_SYNC_FILENODE_REDIS_PASSWORD env variables override both (see config.EnvPrefix)
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	Sentinel Sentinel `yaml:"sentinel"`
+	TLS      TLS      `yaml:"tls"`
+	Pool     Pool     `yaml:"pool"`
+}
+
+// Sentinel enables the failover client when MasterName is set, the url is used for the db number and timeouts only
+type Sentinel struct {
+	MasterName string   `yaml:"masterName"`
+	Addrs      []string `yaml:"addrs"`
+	Username   string   `yaml:"username"`
+	Password   string   `yaml:"password"`
+}
+
+type TLS struct {
+	Enabled            bool   `yaml:"enabled"`
+	CAFile             string `yaml:"caFile"`
+	CertFile           string `yaml:"certFile"`
+	KeyFile            string `yaml:"keyFile"`
+	ServerName         string `yaml:"serverName"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+}
+
+// Pool overrides the connection pool options of the url, zero values are ignored
+type Pool struct {
+	Size               int `yaml:"size"`
+	MinIdleConns       int `yaml:"minIdleConns"`
+	MaxIdleConns       int `yaml:"maxIdleConns"`
+	MaxActiveConns     int `yaml:"maxActiveConns"`
+	TimeoutSec         int `yaml:"timeoutSec"`
+	ConnMaxIdleTimeSec int `yaml:"connMaxIdleTimeSec"`
+	ConnMaxLifetimeSec int `yaml:"connMaxLifetimeSec"`
 }
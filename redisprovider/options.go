@@ -0,0 +1,198 @@
+package redisprovider
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const defaultUrl = "redis://127.0.0.1:6379/?dial_timeout=3&db=1&read_timeout=6s&max_retries=2"
+
+func newClient(conf Config) (cl redis.UniversalClient, err error) {
+	if conf.Url == "" && conf.Sentinel.MasterName == "" {
+		conf.IsCluster = false
+		conf.Url = defaultUrl
+	}
+	tlsConf, err := conf.TLS.tlsConfig()
+	if err != nil {
+		return
+	}
+
+	switch {
+	case conf.Sentinel.MasterName != "":
+		opts, e := failoverOptions(conf)
+		if e != nil {
+			return nil, e
+		}
+		if tlsConf != nil {
+			opts.TLSConfig = tlsConf
+		}
+		return redis.NewFailoverClient(opts), nil
+	case conf.IsCluster:
+		opts, e := redis.ParseClusterURL(conf.Url)
+		if e != nil {
+			return nil, e
+		}
+		conf.applyOptions(clusterConnOptions(opts))
+		if tlsConf != nil {
+			opts.TLSConfig = tlsConf
+		}
+		return redis.NewClusterClient(opts), nil
+	default:
+		opts, e := redis.ParseURL(conf.Url)
+		if e != nil {
+			return nil, e
+		}
+		conf.applyOptions(clientConnOptions(opts))
+		if tlsConf != nil {
+			opts.TLSConfig = tlsConf
+		}
+		return redis.NewClient(opts), nil
+	}
+}
+
+func failoverOptions(conf Config) (opts *redis.FailoverOptions, err error) {
+	if len(conf.Sentinel.Addrs) == 0 {
+		return nil, fmt.Errorf("sentinel addrs are required for the master %q", conf.Sentinel.MasterName)
+	}
+	// the url is optional here, it provides the db number, timeouts and retries
+	base := &redis.Options{}
+	if conf.Url != "" {
+		if base, err = redis.ParseURL(conf.Url); err != nil {
+			return
+		}
+	}
+	conf.applyOptions(clientConnOptions(base))
+	return &redis.FailoverOptions{
+		MasterName:       conf.Sentinel.MasterName,
+		SentinelAddrs:    conf.Sentinel.Addrs,
+		SentinelUsername: conf.Sentinel.Username,
+		SentinelPassword: conf.Sentinel.Password,
+		Username:         base.Username,
+		Password:         base.Password,
+		DB:               base.DB,
+		MaxRetries:       base.MaxRetries,
+		DialTimeout:      base.DialTimeout,
+		ReadTimeout:      base.ReadTimeout,
+		WriteTimeout:     base.WriteTimeout,
+		PoolSize:         base.PoolSize,
+		MinIdleConns:     base.MinIdleConns,
+		MaxIdleConns:     base.MaxIdleConns,
+		MaxActiveConns:   base.MaxActiveConns,
+		PoolTimeout:      base.PoolTimeout,
+		ConnMaxIdleTime:  base.ConnMaxIdleTime,
+		ConnMaxLifetime:  base.ConnMaxLifetime,
+		TLSConfig:        base.TLSConfig,
+	}, nil
+}
+
+// connOptions points to the credentials and the pool fields shared by redis.Options and redis.ClusterOptions
+type connOptions struct {
+	username, password                                   *string
+	poolSize, minIdleConns, maxIdleConns, maxActiveConns *int
+	poolTimeout, connMaxIdleTime, connMaxLifetime        *time.Duration
+}
+
+func clientConnOptions(opts *redis.Options) connOptions {
+	return connOptions{
+		username:        &opts.Username,
+		password:        &opts.Password,
+		poolSize:        &opts.PoolSize,
+		minIdleConns:    &opts.MinIdleConns,
+		maxIdleConns:    &opts.MaxIdleConns,
+		maxActiveConns:  &opts.MaxActiveConns,
+		poolTimeout:     &opts.PoolTimeout,
+		connMaxIdleTime: &opts.ConnMaxIdleTime,
+		connMaxLifetime: &opts.ConnMaxLifetime,
+	}
+}
+
+func clusterConnOptions(opts *redis.ClusterOptions) connOptions {
+	return connOptions{
+		username:        &opts.Username,
+		password:        &opts.Password,
+		poolSize:        &opts.PoolSize,
+		minIdleConns:    &opts.MinIdleConns,
+		maxIdleConns:    &opts.MaxIdleConns,
+		maxActiveConns:  &opts.MaxActiveConns,
+		poolTimeout:     &opts.PoolTimeout,
+		connMaxIdleTime: &opts.ConnMaxIdleTime,
+		connMaxLifetime: &opts.ConnMaxLifetime,
+	}
+}
+
+// applyOptions overrides the url options by the config values, the zero values keep the url options
+func (c Config) applyOptions(opts connOptions) {
+	if c.Username != "" {
+		*opts.username = c.Username
+	}
+	if c.Password != "" {
+		*opts.password = c.Password
+	}
+	p := c.Pool
+	setInt(opts.poolSize, p.Size)
+	setInt(opts.minIdleConns, p.MinIdleConns)
+	setInt(opts.maxIdleConns, p.MaxIdleConns)
+	setInt(opts.maxActiveConns, p.MaxActiveConns)
+	setSec(opts.poolTimeout, p.TimeoutSec)
+	setSec(opts.connMaxIdleTime, p.ConnMaxIdleTimeSec)
+	setSec(opts.connMaxLifetime, p.ConnMaxLifetimeSec)
+}
+
+func (t TLS) tlsConfig() (conf *tls.Config, err error) {
+	if !t.Enabled {
+		return
+	}
+	conf = &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+	if t.CAFile != "" {
+		ca, e := os.ReadFile(t.CAFile)
+		if e != nil {
+			return nil, fmt.Errorf("read redis ca file: %w", e)
+		}
+		conf.RootCAs = x509.NewCertPool()
+		if !conf.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates in the redis ca file %q", t.CAFile)
+		}
+	}
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, e := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if e != nil {
+			return nil, fmt.Errorf("load redis client certificate: %w", e)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return
+}
+
+func setInt(dst *int, v int) {
+	if v != 0 {
+		*dst = v
+	}
+}
+
+func setSec(dst *time.Duration, v int) {
+	if v != 0 {
+		*dst = time.Duration(v) * time.Second
+	}
+}
+
+func health(ctx context.Context, cl redis.UniversalClient) error {
+	if cc, ok := cl.(*redis.ClusterClient); ok {
+		return cc.ForEachShard(ctx, func(ctx context.Context, shard *redis.Client) error {
+			if err := shard.Ping(ctx).Err(); err != nil {
+				return fmt.Errorf("shard %s: %w", shard.Options().Addr, err)
+			}
+			return nil
+		})
+	}
+	return cl.Ping(ctx).Err()
+}
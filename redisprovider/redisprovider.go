@@ -15,6 +15,8 @@ func New() RedisProvider {
 
 type RedisProvider interface {
 	Redis() redis.UniversalClient
+	// Health pings redis, in the cluster mode every shard is checked
+	Health(ctx context.Context) error
 	app.ComponentRunnable
 }
 
@@ -24,23 +26,7 @@ type redisProvider struct {
 
 func (r *redisProvider) Init(a *app.App) (err error) {
 	conf := a.MustComponent("config").(configSource).GetRedis()
-	if conf.Url == "" {
-		conf.IsCluster = false
-		conf.Url = "redis://127.0.0.1:6379/?dial_timeout=3&db=1&read_timeout=6s&max_retries=2"
-	}
-	if conf.IsCluster {
-		opts, e := redis.ParseClusterURL(conf.Url)
-		if e != nil {
-			return e
-		}
-		r.redis = redis.NewClusterClient(opts)
-	} else {
-		opts, e := redis.ParseURL(conf.Url)
-		if e != nil {
-			return e
-		}
-		r.redis = redis.NewClient(opts)
-	}
+	r.redis, err = newClient(conf)
 	return
 }
 
@@ -62,6 +48,10 @@ func (r *redisProvider) Redis() redis.UniversalClient {
 	return r.redis
 }
 
+func (r *redisProvider) Health(ctx context.Context) error {
+	return health(ctx, r.redis)
+}
+
 func (r *redisProvider) Close(ctx context.Context) (err error) {
 	if r.redis != nil {
 		return r.redis.Close()
@@ -54,6 +54,10 @@ func (t *TestRedisProvider) Run(ctx context.Context) (err error) {
 	return t.client.Ping(ctx).Err()
 }
 
+func (t *TestRedisProvider) Health(ctx context.Context) error {
+	return t.client.Ping(ctx).Err()
+}
+
 func (t *TestRedisProvider) Close(ctx context.Context) (err error) {
 	return t.client.Close()
 }
@@ -3,7 +3,10 @@ package stat
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/anyproto/any-sync/app"
@@ -16,9 +19,18 @@ import (
 
 const CName = "filenode.stat"
 
+const filesFindLimit = 1000
+
 type accountInfoProvider interface {
 	AccountInfo(ctx context.Context, identity string) (*fileproto.AccountInfoResponse, error)
-	BatchAccountInfo(ctx context.Context, identities []string) ([]*fileproto.AccountInfoResponse, error)
+	BatchAccountInfo(ctx context.Context, identities []string) (infos []*fileproto.AccountInfoResponse, errs []error)
+}
+
+// identityInfo is the item of the /stat/identities response, the items are in the order of the requested ids
+type identityInfo struct {
+	Identity string `json:"identity"`
+	*fileproto.AccountInfoResponse
+	Error string `json:"error,omitempty"`
 }
 
 type Stat interface {
@@ -78,14 +90,20 @@ func (i *statService) Run(ctx context.Context) (err error) {
 			http.Error(writer, "invalid JSON", http.StatusBadRequest)
 			return
 		}
-		accountInfos, err := i.accountInfoProvider.BatchAccountInfo(request.Context(), data.Ids)
-		if err != nil {
-			http.Error(writer, err.Error(), http.StatusInternalServerError)
-			return
+		accountInfos, errs := i.accountInfoProvider.BatchAccountInfo(request.Context(), data.Ids)
+		resp := make([]identityInfo, len(data.Ids))
+		for idx, identity := range data.Ids {
+			resp[idx] = identityInfo{Identity: identity, AccountInfoResponse: accountInfos[idx]}
+			switch {
+			case errs[idx] != nil:
+				resp[idx].Error = errs[idx].Error()
+			case accountInfos[idx] == nil:
+				resp[idx].Error = "not found"
+			}
 		}
 		writer.Header().Set("Content-Type", "application/json")
 		writer.WriteHeader(http.StatusOK)
-		err = json.NewEncoder(writer).Encode(accountInfos)
+		err := json.NewEncoder(writer).Encode(resp)
 		if err != nil {
 			http.Error(writer, err.Error(), http.StatusInternalServerError)
 			return
@@ -97,10 +115,24 @@ func (i *statService) Run(ctx context.Context) (err error) {
 			http.Error(writer, "identity is empty", http.StatusBadRequest)
 			return
 		}
+		// without params it's the dry run, ?ids=id1,id2 fixes the selected results of the dry run, ?fix=1 fixes everything
 		isDoFix := request.URL.Query().Get("fix") != ""
+		ids := request.URL.Query().Get("ids")
 
 		st := time.Now()
-		res, err := i.index.Check(request.Context(), index.Key{GroupId: identity}, isDoFix)
+		var (
+			res []index.CheckResult
+			err error
+		)
+		if ids != "" {
+			res, err = i.index.CheckFix(request.Context(), index.Key{GroupId: identity}, strings.Split(ids, ","))
+		} else {
+			res, err = i.index.Check(request.Context(), index.Key{GroupId: identity}, isDoFix)
+		}
+		if errors.Is(err, index.ErrMaintenance) {
+			http.Error(writer, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
 		if err != nil {
 			http.Error(writer, err.Error(), http.StatusInternalServerError)
 			return
@@ -122,6 +154,21 @@ func (i *statService) Run(ctx context.Context) (err error) {
 			return
 		}
 	})
+	http.HandleFunc("/stat/check_audit", func(writer http.ResponseWriter, request *http.Request) {
+		limit, _ := strconv.Atoi(request.URL.Query().Get("limit"))
+		records, err := i.index.CheckAudit(request.Context(), limit)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(writer).Encode(records)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
 	http.HandleFunc("/stat/check_deletion/{identity}", func(writer http.ResponseWriter, request *http.Request) {
 		identity := request.PathValue("identity")
 		if identity == "" {
@@ -143,6 +190,10 @@ func (i *statService) Run(ctx context.Context) (err error) {
 			}
 			return
 		}, isDoFix)
+		if errors.Is(err, index.ErrMaintenance) {
+			http.Error(writer, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
 		if err != nil {
 			http.Error(writer, err.Error(), http.StatusInternalServerError)
 		}
@@ -162,6 +213,264 @@ func (i *statService) Run(ctx context.Context) (err error) {
 			return
 		}
 	})
+	http.HandleFunc("/stat/files/{identity}/{spaceId}", func(writer http.ResponseWriter, request *http.Request) {
+		identity := request.PathValue("identity")
+		spaceId := request.PathValue("spaceId")
+		if identity == "" || spaceId == "" {
+			http.Error(writer, "identity or spaceId is empty", http.StatusBadRequest)
+			return
+		}
+		query := request.URL.Query()
+		filter := index.FileMetadataFilter{
+			MimeType: query.Get("mimeType"),
+			RootCid:  query.Get("rootCid"),
+		}
+		// attributes are passed as attr=key:value
+		for _, attr := range query["attr"] {
+			k, v, ok := strings.Cut(attr, ":")
+			if !ok {
+				http.Error(writer, "invalid attr: "+attr, http.StatusBadRequest)
+				return
+			}
+			if filter.Attributes == nil {
+				filter.Attributes = make(map[string]string)
+			}
+			filter.Attributes[k] = v
+		}
+		limit := filesFindLimit
+		if l := query.Get("limit"); l != "" {
+			var err error
+			if limit, err = strconv.Atoi(l); err != nil {
+				http.Error(writer, "invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+
+		st := time.Now()
+		res, err := i.index.FilesFind(request.Context(), index.Key{GroupId: identity, SpaceId: spaceId}, filter, limit)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp := struct {
+			Results  []index.FileInfo `json:"results"`
+			Duration string           `json:"duration"`
+		}{
+			Results:  res,
+			Duration: time.Since(st).String(),
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(writer).Encode(resp)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+	http.HandleFunc("/stat/files_list/{identity}/{spaceId}", func(writer http.ResponseWriter, request *http.Request) {
+		identity := request.PathValue("identity")
+		spaceId := request.PathValue("spaceId")
+		if identity == "" || spaceId == "" {
+			http.Error(writer, "identity or spaceId is empty", http.StatusBadRequest)
+			return
+		}
+		query := request.URL.Query()
+		params := index.FilesListParams{
+			Cursor: query.Get("cursor"),
+			Desc:   query.Get("desc") == "true",
+		}
+		switch query.Get("sort") {
+		case "":
+		case "size":
+			params.Sort = index.FilesListBySize
+		case "createTime":
+			params.Sort = index.FilesListByCreateTime
+		case "updateTime":
+			params.Sort = index.FilesListByUpdateTime
+		default:
+			http.Error(writer, "invalid sort", http.StatusBadRequest)
+			return
+		}
+		if l := query.Get("limit"); l != "" {
+			var err error
+			if params.Limit, err = strconv.Atoi(l); err != nil {
+				http.Error(writer, "invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+
+		page, err := i.index.FilesListPaged(request.Context(), index.Key{GroupId: identity, SpaceId: spaceId}, params)
+		if err != nil {
+			if errors.Is(err, index.ErrInvalidCursor) {
+				http.Error(writer, err.Error(), http.StatusBadRequest)
+				return
+			}
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(writer).Encode(page)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+	http.HandleFunc("/stat/dedup", func(writer http.ResponseWriter, request *http.Request) {
+		info, err := i.index.DedupInfo(request.Context())
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(writer).Encode(info)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+	http.HandleFunc("/stat/dedup/{identity}", func(writer http.ResponseWriter, request *http.Request) {
+		identity := request.PathValue("identity")
+		if identity == "" {
+			http.Error(writer, "identity is empty", http.StatusBadRequest)
+			return
+		}
+		info, err := i.index.GroupDedupInfo(request.Context(), identity)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(writer).Encode(info)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+	http.HandleFunc("/stat/bloom", func(writer http.ResponseWriter, request *http.Request) {
+		infos, err := i.index.BloomInfo(request.Context())
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(writer).Encode(infos)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+	http.HandleFunc("/stat/bloom/rebuild", func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodPost {
+			http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		res, err := i.index.BloomRebuild(request.Context())
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(writer).Encode(res)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+	http.HandleFunc("/stat/maintenance", func(writer http.ResponseWriter, request *http.Request) {
+		// POST /stat/maintenance?enabled=true&reason=... toggles the mode, GET returns the status
+		if request.Method == http.MethodPost {
+			enabled, err := strconv.ParseBool(request.URL.Query().Get("enabled"))
+			if err != nil {
+				http.Error(writer, "enabled param is invalid", http.StatusBadRequest)
+				return
+			}
+			if err = i.index.SetMaintenance(request.Context(), enabled, request.URL.Query().Get("reason")); err != nil {
+				http.Error(writer, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		status, err := i.index.MaintenanceStatus(request.Context())
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(writer).Encode(status)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+	http.HandleFunc("/stat/locks", func(writer http.ResponseWriter, request *http.Request) {
+		// GET lists the held locks, POST /stat/locks?key=_lock:... force releases the lock
+		if request.Method == http.MethodPost {
+			released, err := i.index.ForceReleaseLock(request.Context(), request.URL.Query().Get("key"))
+			if errors.Is(err, index.ErrNotLockKey) {
+				http.Error(writer, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				http.Error(writer, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			writer.Header().Set("Content-Type", "application/json")
+			writer.WriteHeader(http.StatusOK)
+			_ = json.NewEncoder(writer).Encode(struct {
+				Released bool `json:"released"`
+			}{Released: released})
+			return
+		}
+		locks, err := i.index.Locks(request.Context())
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(writer).Encode(locks)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+	http.HandleFunc("/stat/space_restore/{identity}/{spaceId}", func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodPost {
+			http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		identity := request.PathValue("identity")
+		spaceId := request.PathValue("spaceId")
+		if identity == "" || spaceId == "" {
+			http.Error(writer, "identity or spaceId is empty", http.StatusBadRequest)
+			return
+		}
+		ok, err := i.index.SpaceRestore(request.Context(), index.Key{GroupId: identity, SpaceId: spaceId})
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp := struct {
+			Restored bool `json:"restored"`
+		}{
+			Restored: ok,
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(writer).Encode(resp)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
 	return nil
 }
 
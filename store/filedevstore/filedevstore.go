@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"slices"
+	"strings"
 
 	anystore "github.com/anyproto/any-store"
 	"github.com/anyproto/any-store/anyenc"
@@ -162,3 +163,38 @@ func (s *fsstore) IndexPut(ctx context.Context, key string, value []byte) (err e
 func (s *fsstore) IndexDelete(ctx context.Context, key string) (err error) {
 	return s.data.DeleteId(ctx, key)
 }
+
+func (s *fsstore) IndexList(ctx context.Context, f func(keys []string) error) (err error) {
+	iter, err := s.data.Find(nil).Iter(ctx)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = iter.Close()
+	}()
+	const pageSize = 1000
+	var keys = make([]string, 0, pageSize)
+	for iter.Next() {
+		doc, err := iter.Doc()
+		if err != nil {
+			return err
+		}
+		// blocks are stored in the same collection, index keys always have a prefix
+		if id := doc.Value().GetString("id"); strings.Contains(id, ":") {
+			keys = append(keys, id)
+		}
+		if len(keys) == pageSize {
+			if err = f(keys); err != nil {
+				return err
+			}
+			keys = make([]string, 0, pageSize)
+		}
+	}
+	if err = iter.Err(); err != nil {
+		return
+	}
+	if len(keys) != 0 {
+		return f(keys)
+	}
+	return
+}
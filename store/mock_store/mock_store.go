@@ -143,6 +143,20 @@ func (mr *MockStoreMockRecorder) IndexGet(ctx, key any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IndexGet", reflect.TypeOf((*MockStore)(nil).IndexGet), ctx, key)
 }
 
+// IndexList mocks base method.
+func (m *MockStore) IndexList(ctx context.Context, f func([]string) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IndexList", ctx, f)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IndexList indicates an expected call of IndexList.
+func (mr *MockStoreMockRecorder) IndexList(ctx, f any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IndexList", reflect.TypeOf((*MockStore)(nil).IndexList), ctx, f)
+}
+
 // IndexPut mocks base method.
 func (m *MockStore) IndexPut(ctx context.Context, key string, value []byte) error {
 	m.ctrl.T.Helper()
@@ -7,6 +7,7 @@ import (
 	"io"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/anyproto/any-sync/app"
@@ -18,9 +19,12 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3"
 	blocks "github.com/ipfs/go-block-format"
 	"github.com/ipfs/go-cid"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 
+	"github.com/anyproto/any-sync-filenode/filenodemetric"
 	"github.com/anyproto/any-sync-filenode/store"
+	"github.com/anyproto/any-sync-filenode/tracing"
 )
 
 const CName = fileblockstore.CName
@@ -40,8 +44,9 @@ type s3store struct {
 	bucket      *string
 	indexBucket *string
 	client      *s3.S3
-	limiter     chan struct{}
+	limiter     atomic.Pointer[chan struct{}]
 	sess        *session.Session
+	metric      filenodemetric.Metric
 }
 
 func (s *s3store) Init(a *app.App) (err error) {
@@ -90,10 +95,40 @@ func (s *s3store) Init(a *app.App) (err error) {
 	s.indexBucket = aws.String(conf.IndexBucket)
 
 	s.client = s3.New(s.sess)
-	s.limiter = make(chan struct{}, conf.MaxThreads)
+	s.setMaxThreads(conf.MaxThreads)
+	// the metric is optional, e.g. the store used by the tools
+	s.metric, _ = a.Component(filenodemetric.CName).(filenodemetric.Metric)
 	return nil
 }
 
+// ReloadConfig applies the new s3Store.maxThreads value, the requests in progress keep the previous limiter
+func (s *s3store) ReloadConfig(conf any) (err error) {
+	maxThreads := conf.(configSource).GetS3Store().MaxThreads
+	if maxThreads <= 0 {
+		maxThreads = 16
+	}
+	s.setMaxThreads(maxThreads)
+	return
+}
+
+func (s *s3store) setMaxThreads(maxThreads int) {
+	limiter := make(chan struct{}, maxThreads)
+	s.limiter.Store(&limiter)
+}
+
+// acquire takes a slot of the current limiter and returns the release func
+func (s *s3store) acquire() (release func()) {
+	limiter := *s.limiter.Load()
+	limiter <- struct{}{}
+	return func() { <-limiter }
+}
+
+func (s *s3store) observeRequest(op string, st time.Time, wait time.Duration) {
+	if s.metric != nil {
+		s.metric.S3Request(op, time.Since(st), wait)
+	}
+}
+
 func (s *s3store) Name() (name string) {
 	return CName
 }
@@ -102,11 +137,20 @@ func (s *s3store) Run(ctx context.Context) (err error) {
 	return nil
 }
 
-func (s *s3store) Get(ctx context.Context, k cid.Cid) (blocks.Block, error) {
+func (s *s3store) Get(ctx context.Context, k cid.Cid) (b blocks.Block, err error) {
+	ctx, span := tracing.Start(ctx, "s3.get", attribute.String("cid", k.String()))
+	defer func() {
+		if b != nil {
+			span.SetAttributes(tracing.Bytes(len(b.RawData())))
+		}
+		tracing.End(span, err)
+	}()
 	st := time.Now()
-	s.limiter <- struct{}{}
-	defer func() { <-s.limiter }()
+	defer s.acquire()()
 	wait := time.Since(st)
+	defer func() {
+		s.observeRequest("get", st, wait)
+	}()
 	obj, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
 		Bucket: s.bucket,
 		Key:    aws.String(k.String()),
@@ -162,12 +206,19 @@ func (s *s3store) GetMany(ctx context.Context, ks []cid.Cid) <-chan blocks.Block
 	return res
 }
 
-func (s *s3store) Add(ctx context.Context, bs []blocks.Block) error {
+func (s *s3store) Add(ctx context.Context, bs []blocks.Block) (err error) {
+	ctx, span := tracing.Start(ctx, "s3.add", tracing.CidCount(len(bs)))
+	var dataLen int
+	defer func() {
+		span.SetAttributes(tracing.Bytes(dataLen))
+		tracing.End(span, err)
+	}()
 	st := time.Now()
-	s.limiter <- struct{}{}
-	defer func() { <-s.limiter }()
+	defer s.acquire()()
 	wait := time.Since(st)
-	var dataLen int
+	defer func() {
+		s.observeRequest("add", st, wait)
+	}()
 	for _, b := range bs {
 		data := b.RawData()
 		dataLen += len(data)
@@ -200,9 +251,9 @@ func (s *s3store) DeleteMany(ctx context.Context, ks []cid.Cid) error {
 func (s *s3store) Delete(ctx context.Context, c cid.Cid) error {
 	// TODO: make batch delete
 	st := time.Now()
-	s.limiter <- struct{}{}
+	release := s.acquire()
 	wait := time.Since(st)
-	defer func() { <-s.limiter }()
+	defer release()
 	_, err := s.client.DeleteObject(&s3.DeleteObjectInput{
 		Bucket: s.bucket,
 		Key:    aws.String(c.String()),
@@ -247,6 +298,34 @@ func (s *s3store) IndexDelete(ctx context.Context, key string) (err error) {
 	return
 }
 
+func (s *s3store) IndexList(ctx context.Context, f func(keys []string) error) (err error) {
+	var fErr error
+	err = s.client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: s.indexBucket,
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		keys := make([]string, 0, len(page.Contents))
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.StringValue(obj.Key))
+		}
+		fErr = f(keys)
+		return fErr == nil
+	})
+	if fErr != nil {
+		return fErr
+	}
+	return
+}
+
+// HealthCheck checks the access to the block and index buckets
+func (s *s3store) HealthCheck(ctx context.Context) (err error) {
+	for _, bucket := range []*string{s.bucket, s.indexBucket} {
+		if _, err = s.client.HeadBucketWithContext(ctx, &s3.HeadBucketInput{Bucket: bucket}); err != nil {
+			return fmt.Errorf("bucket %s: %w", *bucket, err)
+		}
+	}
+	return
+}
+
 func (s *s3store) Close(ctx context.Context) (err error) {
 	return nil
 }
@@ -7,10 +7,13 @@ import (
 	"time"
 
 	"github.com/anyproto/any-sync/app"
+	"github.com/anyproto/any-sync/metric"
 	blocks "github.com/ipfs/go-block-format"
 	"github.com/ipfs/go-cid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/anyproto/any-sync-filenode/filenodemetric"
 )
 
 var ctx = context.Background()
@@ -22,6 +25,8 @@ func TestS3store_GetMany(t *testing.T) {
 	a := new(app.App)
 	store := New()
 	a.Register(&config{})
+	a.Register(metric.New())
+	a.Register(filenodemetric.New())
 	a.Register(store)
 	require.NoError(t, a.Start(ctx))
 	defer a.Close(ctx)
@@ -56,6 +61,10 @@ type config struct {
 func (c config) Init(a *app.App) error { return nil }
 func (c config) Name() string          { return "config" }
 
+func (c config) GetMetric() metric.Config {
+	return metric.Config{}
+}
+
 func (c config) GetS3Store() Config {
 	return Config{
 		Region:      "eu-central-1",
@@ -16,5 +16,7 @@ type Store interface {
 	IndexGet(ctx context.Context, key string) (value []byte, err error)
 	IndexPut(ctx context.Context, key string, value []byte) (err error)
 	IndexDelete(ctx context.Context, key string) (err error)
+	// IndexList calls the given func with the pages of all the persisted index keys
+	IndexList(ctx context.Context, f func(keys []string) error) (err error)
 	app.Component
 }
@@ -0,0 +1,17 @@
+package tracing
+
+type configSource interface {
+	GetTracing() Config
+}
+
+type Config struct {
+	// Exporter is one of: "otlp", "file"; tracing is disabled when empty
+	Exporter string `yaml:"exporter"`
+	// Endpoint is the OTLP http endpoint, like localhost:4318
+	Endpoint string `yaml:"endpoint"`
+	Insecure bool   `yaml:"insecure"`
+	// FilePath is the path of the file exporter output, spans are written as json lines
+	FilePath string `yaml:"filePath"`
+	// SampleRatio is the ratio of the sampled traces, all traces are sampled when 0
+	SampleRatio float64 `yaml:"sampleRatio"`
+}
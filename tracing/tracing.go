@@ -0,0 +1,162 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+
+	"github.com/anyproto/any-sync/app"
+	"github.com/anyproto/any-sync/app/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const CName = "filenode.tracing"
+
+const (
+	exporterOtlp = "otlp"
+	exporterFile = "file"
+)
+
+var log = logger.NewNamed(CName)
+
+var (
+	tracer  = otel.Tracer("github.com/anyproto/any-sync-filenode")
+	enabled atomic.Bool
+)
+
+func New() Tracing {
+	return new(tracing)
+}
+
+// Tracing configures the global otel tracer provider, without the exporter all the spans are no-op
+type Tracing interface {
+	app.ComponentRunnable
+}
+
+type tracing struct {
+	provider *sdktrace.TracerProvider
+	file     io.Closer
+}
+
+func (t *tracing) Init(a *app.App) (err error) {
+	conf := a.MustComponent("config").(configSource).GetTracing()
+	var exporter sdktrace.SpanExporter
+	switch conf.Exporter {
+	case "":
+		return
+	case exporterOtlp:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(conf.Endpoint)}
+		if conf.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if exporter, err = otlptracehttp.New(context.Background(), opts...); err != nil {
+			return
+		}
+	case exporterFile:
+		f, fErr := os.OpenFile(conf.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if fErr != nil {
+			return fErr
+		}
+		t.file = f
+		if exporter, err = stdouttrace.New(stdouttrace.WithWriter(f)); err != nil {
+			return
+		}
+	default:
+		return fmt.Errorf("unexpected tracing exporter: %s", conf.Exporter)
+	}
+
+	sampler := sdktrace.AlwaysSample()
+	if conf.SampleRatio > 0 {
+		sampler = sdktrace.ParentBased(sdktrace.TraceIDRatioBased(conf.SampleRatio))
+	}
+	t.provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithResource(resource.NewSchemaless(
+			semconv.ServiceName("any-sync-filenode"),
+			semconv.ServiceVersion(a.Version()),
+		)),
+	)
+	otel.SetTracerProvider(t.provider)
+	enabled.Store(true)
+	log.Info("tracing enabled", zap.String("exporter", conf.Exporter))
+	return
+}
+
+func (t *tracing) Name() (name string) {
+	return CName
+}
+
+func (t *tracing) Run(ctx context.Context) (err error) {
+	return
+}
+
+func (t *tracing) Close(ctx context.Context) (err error) {
+	if t.provider != nil {
+		enabled.Store(false)
+		if err = t.provider.Shutdown(ctx); err != nil {
+			log.Warn("tracer provider shutdown error", zap.Error(err))
+		}
+	}
+	if t.file != nil {
+		return t.file.Close()
+	}
+	return nil
+}
+
+// Start starts the span, when the tracing is disabled it returns the context as is with a no-op span
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if !enabled.Load() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records the error, if any, and ends the span
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// TraceId returns the trace id log field of the current span, the field is skipped when there is no span
+func TraceId(ctx context.Context) zap.Field {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.HasTraceID() {
+		return zap.Skip()
+	}
+	return zap.String("traceId", sc.TraceID().String())
+}
+
+func SpaceId(spaceId string) attribute.KeyValue {
+	return attribute.String("spaceId", spaceId)
+}
+
+func GroupId(groupId string) attribute.KeyValue {
+	return attribute.String("groupId", groupId)
+}
+
+func FileId(fileId string) attribute.KeyValue {
+	return attribute.String("fileId", fileId)
+}
+
+func CidCount(count int) attribute.KeyValue {
+	return attribute.Int("cidCount", count)
+}
+
+func Bytes(size int) attribute.KeyValue {
+	return attribute.Int("bytes", size)
+}
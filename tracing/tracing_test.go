@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anyproto/any-sync/app"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+var ctx = context.Background()
+
+func TestTracing(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		a := new(app.App)
+		a.Register(config{}).Register(New())
+		require.NoError(t, a.Start(ctx))
+		defer func() {
+			require.NoError(t, a.Close(ctx))
+		}()
+		spanCtx, span := Start(ctx, "test")
+		End(span, nil)
+		assert.Equal(t, zap.Skip(), TraceId(spanCtx))
+	})
+	t.Run("file exporter", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "traces.json")
+		a := new(app.App)
+		a.Register(config{Config{Exporter: exporterFile, FilePath: path}}).Register(New())
+		require.NoError(t, a.Start(ctx))
+
+		spanCtx, span := Start(ctx, "test.span", SpaceId("spaceId"))
+		traceId := TraceId(spanCtx)
+		assert.Equal(t, "traceId", traceId.Key)
+		assert.Equal(t, span.SpanContext().TraceID().String(), traceId.String)
+		End(span, errors.New("test error"))
+
+		require.NoError(t, a.Close(ctx))
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "test.span")
+		assert.Contains(t, string(data), "test error")
+	})
+	t.Run("unexpected exporter", func(t *testing.T) {
+		a := new(app.App)
+		a.Register(config{Config{Exporter: "unknown"}}).Register(New())
+		assert.Error(t, a.Start(ctx))
+	})
+}
+
+type config struct {
+	Config
+}
+
+func (c config) Init(a *app.App) (err error) {
+	return
+}
+
+func (c config) Name() string {
+	return "config"
+}
+
+func (c config) GetTracing() Config {
+	return c.Config
+}